@@ -4,5 +4,5 @@ import (
 	"embed"
 )
 
-//go:embed "emails" "migrations"
+//go:embed "emails" "fixtures" "migrations" "migrations_sqlite"
 var EmbeddedFiles embed.FS
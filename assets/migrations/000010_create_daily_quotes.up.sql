@@ -0,0 +1,13 @@
+create table if not exists daily_quotes
+(
+    day         date        not null,
+    pool        text        not null default '',
+    quote_id    uuid        not null references quotes on delete cascade,
+    cycle       integer     not null,
+    is_override boolean     not null default false,
+    created_by  uuid        references users on delete set null,
+    created_at  timestamptz not null default now(),
+    primary key (day, pool)
+);
+
+create index if not exists daily_quotes_pool_cycle_idx on daily_quotes (pool, cycle);
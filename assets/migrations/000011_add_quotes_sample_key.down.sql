@@ -0,0 +1,4 @@
+drop index if exists quotes_sample_key_idx;
+
+alter table quotes
+    drop column if exists sample_key;
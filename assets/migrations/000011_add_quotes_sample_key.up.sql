@@ -0,0 +1,6 @@
+-- sample_key gives every quote a random position so random quotes can be
+-- drawn with index lookups instead of ordering the whole table by random().
+alter table quotes
+    add column if not exists sample_key double precision not null default random();
+
+create index if not exists quotes_sample_key_idx on quotes (sample_key);
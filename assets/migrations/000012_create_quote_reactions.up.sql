@@ -0,0 +1,23 @@
+alter table quotes
+    add column if not exists like_count     integer not null default 0,
+    add column if not exists favorite_count integer not null default 0;
+
+create index if not exists quotes_like_count_idx on quotes (like_count);
+
+create table if not exists quote_likes
+(
+    user_id    uuid        not null references users on delete cascade,
+    quote_id   uuid        not null references quotes on delete cascade,
+    created_at timestamptz not null default now(),
+    primary key (user_id, quote_id)
+);
+
+create table if not exists quote_favorites
+(
+    user_id    uuid        not null references users on delete cascade,
+    quote_id   uuid        not null references quotes on delete cascade,
+    created_at timestamptz not null default now(),
+    primary key (user_id, quote_id)
+);
+
+create index if not exists quote_favorites_user_created_at_idx on quote_favorites (user_id, created_at);
@@ -0,0 +1,5 @@
+drop table if exists collection_follows;
+
+drop table if exists collection_items;
+
+drop table if exists collections;
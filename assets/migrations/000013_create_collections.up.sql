@@ -0,0 +1,33 @@
+create table if not exists collections
+(
+    id          uuid        not null primary key,
+    owner_id    uuid        not null references users on delete cascade,
+    name        text        not null,
+    description text        not null default '',
+    is_public   boolean     not null default false,
+    created_at  timestamptz not null default now(),
+    updated_at  timestamptz not null default now(),
+    version     integer     not null default 1,
+    unique (owner_id, name)
+);
+
+create table if not exists collection_items
+(
+    collection_id uuid        not null references collections on delete cascade,
+    quote_id      uuid        not null references quotes on delete cascade,
+    position      integer     not null,
+    added_at      timestamptz not null default now(),
+    primary key (collection_id, quote_id)
+);
+
+create index if not exists collection_items_position_idx on collection_items (collection_id, position);
+
+create table if not exists collection_follows
+(
+    user_id       uuid        not null references users on delete cascade,
+    collection_id uuid        not null references collections on delete cascade,
+    created_at    timestamptz not null default now(),
+    primary key (user_id, collection_id)
+);
+
+create index if not exists collection_follows_collection_idx on collection_follows (collection_id);
@@ -0,0 +1,4 @@
+drop table if exists comments;
+
+alter table quotes
+    drop column if exists comment_count;
@@ -0,0 +1,22 @@
+alter table quotes
+    add column if not exists comment_count integer not null default 0;
+
+create table if not exists comments
+(
+    id         uuid        not null primary key,
+    quote_id   uuid        not null references quotes on delete cascade,
+    parent_id  uuid references comments on delete cascade,
+    user_id    uuid        not null references users on delete cascade,
+    text       text        not null,
+    state      uuid        not null references quote_states (id) on delete no action,
+    is_hidden  boolean     not null default false,
+    hidden_by  uuid references users on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    edited_at  timestamptz,
+    deleted_at timestamptz
+);
+
+create index if not exists comments_quote_created_at_idx on comments (quote_id, created_at) where parent_id is null;
+
+create index if not exists comments_parent_created_at_idx on comments (parent_id, created_at);
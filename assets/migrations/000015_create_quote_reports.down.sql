@@ -0,0 +1,3 @@
+drop table if exists quote_reports;
+
+drop table if exists quote_state_changes;
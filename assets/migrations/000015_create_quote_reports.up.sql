@@ -0,0 +1,30 @@
+create table if not exists quote_state_changes
+(
+    id         uuid        not null primary key,
+    quote_id   uuid        not null references quotes on delete cascade,
+    from_state uuid references quote_states (id) on delete set null,
+    to_state   uuid references quote_states (id) on delete set null,
+    changed_by uuid references users on delete set null,
+    reason     text        not null default '',
+    created_at timestamptz not null default now()
+);
+
+create index if not exists quote_state_changes_quote_created_at_idx on quote_state_changes (quote_id, created_at);
+
+create table if not exists quote_reports
+(
+    id              uuid        not null primary key,
+    quote_id        uuid        not null references quotes on delete cascade,
+    user_id         uuid        not null references users on delete cascade,
+    reason          text        not null,
+    note            text        not null default '',
+    status          text        not null default 'open',
+    resolved_by     uuid references users on delete set null,
+    resolved_at     timestamptz,
+    state_change_id uuid references quote_state_changes on delete set null,
+    created_at      timestamptz not null default now()
+);
+
+create unique index if not exists quote_reports_open_user_idx on quote_reports (quote_id, user_id) where status = 'open';
+
+create index if not exists quote_reports_status_created_at_idx on quote_reports (status, created_at);
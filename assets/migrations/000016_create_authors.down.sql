@@ -0,0 +1,8 @@
+drop index if exists quotes_author_id_idx;
+
+alter table quotes
+    drop column if exists author_id;
+
+drop table if exists author_aliases;
+
+drop table if exists authors;
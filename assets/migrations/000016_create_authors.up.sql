@@ -0,0 +1,43 @@
+create table if not exists authors
+(
+    id         uuid        not null primary key,
+    name       text        not null,
+    birth_year integer,
+    death_year integer,
+    bio        text        not null default '',
+    photo_id   uuid references photos on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    version    integer     not null default 1
+);
+
+create unique index if not exists authors_name_idx on authors (lower(name));
+
+create table if not exists author_aliases
+(
+    alias     text not null,
+    author_id uuid not null references authors on delete cascade
+);
+
+create unique index if not exists author_aliases_alias_idx on author_aliases (lower(alias));
+
+create index if not exists author_aliases_author_idx on author_aliases (author_id);
+
+alter table quotes
+    add column if not exists author_id uuid references authors on delete set null;
+
+create index if not exists quotes_author_id_idx on quotes (author_id);
+
+-- Every distinct free-text author becomes an author of its own; duplicates
+-- spelled differently are left to be merged by hand.
+insert into authors (id, name)
+select gen_random_uuid(), min(trim(author))
+from quotes
+where trim(author) <> ''
+group by lower(trim(author))
+on conflict do nothing;
+
+update quotes q
+set author_id = a.id
+from authors a
+where lower(trim(q.author)) = lower(a.name);
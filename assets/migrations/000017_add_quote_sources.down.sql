@@ -0,0 +1,15 @@
+delete from permissions where code = 'quotes:verify';
+
+drop index if exists quotes_verification_idx;
+
+alter table quotes
+    drop constraint if exists quotes_verification_check,
+    drop column if exists verification,
+    drop column if exists verified_by,
+    drop column if exists verified_at,
+    drop column if exists source_type,
+    drop column if exists source_title,
+    drop column if exists source_url,
+    drop column if exists source_location,
+    drop column if exists source_year,
+    drop column if exists source_language;
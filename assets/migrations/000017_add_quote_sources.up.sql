@@ -0,0 +1,29 @@
+alter table quotes
+    add column if not exists verification    text        not null default 'unverified',
+    add column if not exists verified_by     uuid references users on delete set null,
+    add column if not exists verified_at     timestamptz,
+    add column if not exists source_type     text        not null default '',
+    add column if not exists source_title    text        not null default '',
+    add column if not exists source_url      text        not null default '',
+    add column if not exists source_location text        not null default '',
+    add column if not exists source_year     integer,
+    add column if not exists source_language text        not null default '';
+
+alter table quotes
+    add constraint quotes_verification_check check (verification in ('unverified', 'verified', 'disputed', 'misattributed'));
+
+create index if not exists quotes_verification_idx on quotes (verification);
+
+insert into permissions (id, code)
+values (gen_random_uuid(), 'quotes:verify');
+
+-- Existing moderators get the new permission along with quotes:state, as
+-- sudoers do when they are verified.
+insert into users_permissions (user_id, permission_id)
+select up.user_id, verify.id
+from users_permissions up
+inner join permissions state
+on up.permission_id = state.id and state.code = 'quotes:state'
+cross join permissions verify
+where verify.code = 'quotes:verify'
+on conflict do nothing;
@@ -0,0 +1,9 @@
+drop index if exists quotes_text_search_idx;
+
+drop function if exists quote_ts_config(text);
+
+drop index if exists quotes_original_language_idx;
+
+alter table quotes
+    drop column if exists original_id,
+    drop column if exists language;
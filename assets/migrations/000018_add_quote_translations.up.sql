@@ -0,0 +1,26 @@
+alter table quotes
+    add column if not exists language    text not null default '',
+    add column if not exists original_id uuid references quotes on delete set null;
+
+create unique index if not exists quotes_original_language_idx on quotes (original_id, language) where original_id is not null;
+
+-- quote_ts_config picks the text search configuration for a quote's
+-- language; languages Postgres has no stemmer for fall back to simple.
+create or replace function quote_ts_config(lang text) returns regconfig
+    language sql
+    immutable as
+$$
+select case lang
+           when 'ar' then 'arabic'
+           when 'de' then 'german'
+           when 'en' then 'english'
+           when 'es' then 'spanish'
+           when 'fr' then 'french'
+           when 'it' then 'italian'
+           when 'ru' then 'russian'
+           when 'tr' then 'turkish'
+           else 'simple'
+           end::regconfig
+$$;
+
+create index if not exists quotes_text_search_idx on quotes using gin (to_tsvector(quote_ts_config(language), text));
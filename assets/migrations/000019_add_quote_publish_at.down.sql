@@ -0,0 +1,3 @@
+drop index if exists quotes_publish_at_idx;
+
+alter table quotes drop column if exists publish_at;
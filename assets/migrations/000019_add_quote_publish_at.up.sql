@@ -0,0 +1,3 @@
+alter table quotes add column if not exists publish_at timestamptz;
+
+create index if not exists quotes_publish_at_idx on quotes (publish_at) where publish_at is not null;
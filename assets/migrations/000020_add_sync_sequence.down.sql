@@ -0,0 +1,29 @@
+drop trigger if exists quote_states_sync_visibility on quote_states;
+
+drop trigger if exists quote_states_sync_tombstone on quote_states;
+drop trigger if exists photos_sync_tombstone on photos;
+drop trigger if exists hashtags_sync_tombstone on hashtags;
+drop trigger if exists quotes_sync_tombstone on quotes;
+
+drop trigger if exists quote_states_sync_bump on quote_states;
+drop trigger if exists photos_sync_bump on photos;
+drop trigger if exists hashtags_sync_bump on hashtags;
+drop trigger if exists quotes_sync_bump on quotes;
+
+drop function if exists sync_state_visibility();
+drop function if exists sync_tombstone();
+drop function if exists sync_bump();
+
+drop table if exists sync_tombstones;
+
+alter table quote_states drop column if exists sync_xid;
+alter table photos drop column if exists sync_xid;
+alter table hashtags drop column if exists sync_xid;
+alter table quotes drop column if exists sync_xid;
+
+alter table quote_states drop column if exists sync_seq;
+alter table photos drop column if exists sync_seq;
+alter table hashtags drop column if exists sync_seq;
+alter table quotes drop column if exists sync_seq;
+
+drop sequence if exists sync_seq;
@@ -0,0 +1,87 @@
+-- sync_seq orders every change clients can sync. Rows take the next value
+-- when they are inserted or updated, deletions leave a tombstone with one.
+-- The value is taken when the row is written, not when the transaction
+-- commits, so changes are synced in order of sync_xid, the transaction that
+-- wrote them, first.
+create sequence if not exists sync_seq;
+
+alter table quotes add column if not exists sync_seq bigint not null default nextval('sync_seq');
+alter table hashtags add column if not exists sync_seq bigint not null default nextval('sync_seq');
+alter table photos add column if not exists sync_seq bigint not null default nextval('sync_seq');
+alter table quote_states add column if not exists sync_seq bigint not null default nextval('sync_seq');
+
+alter table quotes add column if not exists sync_xid xid8 not null default pg_current_xact_id();
+alter table hashtags add column if not exists sync_xid xid8 not null default pg_current_xact_id();
+alter table photos add column if not exists sync_xid xid8 not null default pg_current_xact_id();
+alter table quote_states add column if not exists sync_xid xid8 not null default pg_current_xact_id();
+
+create index if not exists quotes_sync_idx on quotes (sync_xid, sync_seq);
+create index if not exists hashtags_sync_idx on hashtags (sync_xid, sync_seq);
+create index if not exists photos_sync_idx on photos (sync_xid, sync_seq);
+create index if not exists quote_states_sync_idx on quote_states (sync_xid, sync_seq);
+
+create table if not exists sync_tombstones
+(
+    seq        bigint primary key default nextval('sync_seq'),
+    xid        xid8        not null default pg_current_xact_id(),
+    entity     text        not null,
+    entity_id  uuid        not null,
+    deleted_at timestamptz not null default now()
+);
+
+create index if not exists sync_tombstones_xid_idx on sync_tombstones (xid, seq);
+
+create or replace function sync_bump() returns trigger as
+$$
+begin
+    new.sync_seq := nextval('sync_seq');
+    new.sync_xid := pg_current_xact_id();
+    return new;
+end;
+$$ language plpgsql;
+
+create or replace function sync_tombstone() returns trigger as
+$$
+begin
+    insert into sync_tombstones (entity, entity_id) values (tg_argv[0], old.id);
+    return old;
+end;
+$$ language plpgsql;
+
+-- A state turning public or private changes what clients may see of all of
+-- its quotes.
+create or replace function sync_state_visibility() returns trigger as
+$$
+begin
+    if new.is_public <> old.is_public then
+        update quotes set sync_seq = nextval('sync_seq') where state = new.id;
+    end if;
+    return new;
+end;
+$$ language plpgsql;
+
+-- Likes, favorites and comments only move the counters on a quote; bumping
+-- it for them would churn the sync feed and the bundle on every reaction.
+create trigger quotes_sync_bump before update on quotes
+    for each row
+    when (to_jsonb(old) - array ['like_count', 'favorite_count', 'comment_count']
+        is distinct from to_jsonb(new) - array ['like_count', 'favorite_count', 'comment_count'])
+    execute function sync_bump();
+create trigger hashtags_sync_bump before update on hashtags
+    for each row execute function sync_bump();
+create trigger photos_sync_bump before update on photos
+    for each row execute function sync_bump();
+create trigger quote_states_sync_bump before update on quote_states
+    for each row execute function sync_bump();
+
+create trigger quotes_sync_tombstone after delete on quotes
+    for each row execute function sync_tombstone('quote');
+create trigger hashtags_sync_tombstone after delete on hashtags
+    for each row execute function sync_tombstone('hashtag');
+create trigger photos_sync_tombstone after delete on photos
+    for each row execute function sync_tombstone('photo');
+create trigger quote_states_sync_tombstone after delete on quote_states
+    for each row execute function sync_tombstone('state');
+
+create trigger quote_states_sync_visibility after update of is_public on quote_states
+    for each row execute function sync_state_visibility();
@@ -0,0 +1,5 @@
+delete from permissions where code = 'webhooks:manage';
+
+drop table if exists webhook_attempts;
+drop table if exists webhook_deliveries;
+drop table if exists webhooks;
@@ -0,0 +1,54 @@
+create table if not exists webhooks (
+    id            uuid primary key,
+    url           text        not null,
+    secret        text        not null,
+    event_types   text[]      not null default '{}',
+    is_active     boolean     not null default true,
+    failure_count integer     not null default 0,
+    disabled_at   timestamptz,
+    created_by    uuid        not null references users on delete cascade,
+    created_at    timestamptz not null default now(),
+    updated_at    timestamptz not null default now()
+);
+
+create table if not exists webhook_deliveries (
+    id               uuid primary key,
+    webhook_id       uuid        not null references webhooks on delete cascade,
+    event_id         uuid        not null,
+    event_type       text        not null,
+    payload          text        not null,
+    status           text        not null default 'pending' check (status in ('pending', 'succeeded', 'failed')),
+    attempts         integer     not null default 0,
+    next_attempt_at  timestamptz not null default now(),
+    last_status_code integer,
+    last_error       text        not null default '',
+    delivered_at     timestamptz,
+    created_at       timestamptz not null default now()
+);
+
+create index if not exists webhook_deliveries_due_idx on webhook_deliveries (next_attempt_at) where status = 'pending';
+create index if not exists webhook_deliveries_webhook_id_idx on webhook_deliveries (webhook_id, created_at);
+
+create table if not exists webhook_attempts (
+    id           bigserial primary key,
+    delivery_id  uuid        not null references webhook_deliveries on delete cascade,
+    status_code  integer,
+    error        text        not null default '',
+    duration_ms  integer     not null,
+    attempted_at timestamptz not null default now()
+);
+
+create index if not exists webhook_attempts_delivery_id_idx on webhook_attempts (delivery_id);
+
+insert into permissions (id, code)
+values (gen_random_uuid(), 'webhooks:manage');
+
+-- Whoever can moderate quotes today can manage webhooks too.
+insert into users_permissions (user_id, permission_id)
+select up.user_id, manage.id
+from users_permissions up
+inner join permissions state
+on up.permission_id = state.id and state.code = 'quotes:state'
+cross join permissions manage
+where manage.code = 'webhooks:manage'
+on conflict do nothing;
@@ -0,0 +1,16 @@
+create table if not exists telegram_posts (
+    id         uuid primary key,
+    quote_id   uuid        not null references quotes on delete cascade,
+    chat_id    text        not null,
+    is_repost  boolean     not null default false,
+    status     text        not null default 'pending' check (status in ('pending', 'posted', 'failed')),
+    message_id bigint,
+    error      text        not null default '',
+    created_by uuid        references users on delete set null,
+    created_at timestamptz not null default now(),
+    posted_at  timestamptz
+);
+
+-- A quote is posted to a channel automatically at most once.
+create unique index if not exists telegram_posts_quote_id_chat_id_idx on telegram_posts (quote_id, chat_id) where not is_repost;
+create index if not exists telegram_posts_created_at_idx on telegram_posts (created_at);
@@ -0,0 +1,13 @@
+drop trigger if exists quotes_fts_delete;
+drop trigger if exists quotes_fts_update;
+drop trigger if exists quotes_fts_insert;
+drop table if exists quotes_fts;
+drop table if exists users_permissions;
+drop table if exists permissions;
+drop table if exists quote_hashtags;
+drop table if exists hashtags;
+drop table if exists quotes;
+drop table if exists photos;
+drop table if exists quote_states;
+drop table if exists otps;
+drop table if exists users;
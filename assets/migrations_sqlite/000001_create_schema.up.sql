@@ -0,0 +1,116 @@
+create table if not exists users
+(
+    id              text      not null primary key,
+    created_at      timestamp not null,
+    email           text      not null unique,
+    hashed_password text      not null,
+    name            text      not null,
+    is_activated    boolean   not null default false
+);
+
+create table if not exists otps
+(
+    id      text      not null primary key,
+    hash    text      not null,
+    user_id text      not null references users (id) on delete cascade,
+    expiry  timestamp not null,
+    created timestamp not null,
+    "scope" text      not null
+);
+
+create table if not exists quote_states
+(
+    id         text        not null primary key,
+    value      text        not null unique,
+    is_default boolean     not null default false,
+    color      varchar(10) not null unique,
+    is_public  boolean     not null default false
+);
+
+insert into quote_states (id, value, is_default, color, is_public)
+values ('3c1b0c4e-4b9f-4a52-9a0a-1f6f0c3d2a01', 'pending', true, '#F3D104', false),
+       ('3c1b0c4e-4b9f-4a52-9a0a-1f6f0c3d2a02', 'rejected', false, '#C94040', false),
+       ('3c1b0c4e-4b9f-4a52-9a0a-1f6f0c3d2a03', 'accepted', false, '#047A37', true);
+
+create table if not exists photos
+(
+    id        text         not null primary key,
+    color     varchar(8)   not null,
+    blur_hash varchar(200) not null,
+    author    varchar(100) not null,
+    url       varchar(500) not null
+);
+
+create table if not exists quotes
+(
+    id         text      not null primary key,
+    author     text      not null,
+    text       text      not null,
+    created_by text      not null references users (id) on delete no action,
+    state      text      not null references quote_states (id) on delete no action,
+    photo_id   text      not null references photos (id) on delete no action,
+    created_at timestamp not null,
+    updated_at timestamp not null,
+    version    integer   not null default 1
+);
+
+create table if not exists hashtags
+(
+    id    text not null primary key,
+    value text not null unique
+);
+
+create table if not exists quote_hashtags
+(
+    quote_id   text not null references quotes on delete cascade,
+    hashtag_id text not null references hashtags on delete cascade,
+    primary key (quote_id, hashtag_id)
+);
+
+create table if not exists permissions
+(
+    id   text primary key,
+    code varchar(100) not null
+);
+
+create table if not exists users_permissions
+(
+    user_id       text not null references users on delete cascade,
+    permission_id text not null references permissions on delete cascade,
+    primary key (user_id, permission_id)
+);
+
+insert into permissions (id, code)
+values ('8e5f3a2b-6d1c-4f0e-9b7a-2c4d6e8f0a01', 'quotes:read'),
+       ('8e5f3a2b-6d1c-4f0e-9b7a-2c4d6e8f0a02', 'quotes:write'),
+       ('8e5f3a2b-6d1c-4f0e-9b7a-2c4d6e8f0a03', 'quotes:state');
+
+-- quotes_fts replaces the to_tsvector('simple', ...) searches used on Postgres.
+create virtual table if not exists quotes_fts using fts5
+(
+    quote_id unindexed,
+    author,
+    text,
+    tokenize = 'unicode61 remove_diacritics 0'
+);
+
+create trigger if not exists quotes_fts_insert
+    after insert
+    on quotes
+begin
+    insert into quotes_fts (quote_id, author, text) values (new.id, new.author, new.text);
+end;
+
+create trigger if not exists quotes_fts_update
+    after update of author, text
+    on quotes
+begin
+    update quotes_fts set author = new.author, text = new.text where quote_id = new.id;
+end;
+
+create trigger if not exists quotes_fts_delete
+    after delete
+    on quotes
+begin
+    delete from quotes_fts where quote_id = old.id;
+end;
@@ -0,0 +1,5 @@
+drop trigger if exists quotes_sample_key_insert;
+
+drop index if exists quotes_sample_key_idx;
+
+alter table quotes drop column sample_key;
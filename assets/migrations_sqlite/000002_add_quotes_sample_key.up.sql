@@ -0,0 +1,15 @@
+-- sample_key gives every quote a random position so random quotes can be
+-- drawn with index lookups instead of ordering the whole table by random().
+-- SQLite can't add a column with a random default, so a trigger fills it in.
+alter table quotes add column sample_key integer not null default 0;
+
+update quotes set sample_key = random();
+
+create index if not exists quotes_sample_key_idx on quotes (sample_key);
+
+create trigger if not exists quotes_sample_key_insert
+    after insert on quotes
+    when new.sample_key = 0
+begin
+    update quotes set sample_key = random() where id = new.id;
+end;
@@ -0,0 +1,9 @@
+drop table if exists quote_favorites;
+
+drop table if exists quote_likes;
+
+drop index if exists quotes_like_count_idx;
+
+alter table quotes drop column favorite_count;
+
+alter table quotes drop column like_count;
@@ -0,0 +1,21 @@
+delete from permissions where code = 'quotes:verify';
+
+drop index if exists quotes_verification_idx;
+
+alter table quotes drop column source_language;
+
+alter table quotes drop column source_year;
+
+alter table quotes drop column source_location;
+
+alter table quotes drop column source_url;
+
+alter table quotes drop column source_title;
+
+alter table quotes drop column source_type;
+
+alter table quotes drop column verified_at;
+
+alter table quotes drop column verified_by;
+
+alter table quotes drop column verification;
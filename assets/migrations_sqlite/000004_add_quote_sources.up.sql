@@ -0,0 +1,30 @@
+alter table quotes add column verification text not null default 'unverified' check (verification in ('unverified', 'verified', 'disputed', 'misattributed'));
+
+alter table quotes add column verified_by text;
+
+alter table quotes add column verified_at timestamp;
+
+alter table quotes add column source_type text not null default '';
+
+alter table quotes add column source_title text not null default '';
+
+alter table quotes add column source_url text not null default '';
+
+alter table quotes add column source_location text not null default '';
+
+alter table quotes add column source_year integer;
+
+alter table quotes add column source_language text not null default '';
+
+create index if not exists quotes_verification_idx on quotes (verification);
+
+insert into permissions (id, code)
+values ('8e5f3a2b-6d1c-4f0e-9b7a-2c4d6e8f0a04', 'quotes:verify');
+
+insert or ignore into users_permissions (user_id, permission_id)
+select up.user_id, verify.id
+from users_permissions up
+inner join permissions state
+on up.permission_id = state.id and state.code = 'quotes:state'
+cross join permissions verify
+where verify.code = 'quotes:verify';
@@ -0,0 +1,7 @@
+drop trigger if exists quotes_original_delete;
+
+drop index if exists quotes_original_language_idx;
+
+alter table quotes drop column original_id;
+
+alter table quotes drop column language;
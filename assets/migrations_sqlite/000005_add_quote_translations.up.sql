@@ -0,0 +1,15 @@
+alter table quotes add column language text not null default '';
+
+-- original_id points at the quote this one translates. SQLite can't drop a
+-- foreign key column again, so the trigger below stands in for on delete
+-- set null.
+alter table quotes add column original_id text;
+
+create unique index if not exists quotes_original_language_idx on quotes (original_id, language) where original_id is not null;
+
+create trigger if not exists quotes_original_delete
+    after delete
+    on quotes
+begin
+    update quotes set original_id = null where original_id = old.id;
+end;
@@ -0,0 +1,3 @@
+alter table quotes add column publish_at timestamp;
+
+create index if not exists quotes_publish_at_idx on quotes (publish_at) where publish_at is not null;
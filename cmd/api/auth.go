@@ -5,6 +5,7 @@ import (
 	"github.com/google/uuid"
 	"golang.org/x/exp/slices"
 	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
 	"javlonrahimov/quotes-api/internal/security"
 	"net/http"
 	"time"
@@ -61,7 +62,7 @@ func (app *application) register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := app.db.InsertUser(input.Email, hashedPassword, input.Name)
+	user, err := app.db.InsertUser(r.Context(), input.Email, hashedPassword, input.Name)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrDuplicateEmail):
@@ -73,20 +74,20 @@ func (app *application) register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.db.DeleteAllOTPForUser(user.ID, o.ScopeAuthentication)
+	err = app.db.DeleteAllOTPForUser(r.Context(), user.ID, o.ScopeAuthentication)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	otp, err := app.db.NewOtp(user.ID, 20*time.Minute, o.ScopeAuthentication)
+	otp, err := app.db.NewOtp(r.Context(), user.ID, 20*time.Minute, o.ScopeAuthentication)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
 	go func() {
-		err = app.mailer.Send(input.Email, otp.Plaintext, map[string]interface{}{
+		err := app.mailer.Send(input.Email, otp.Plaintext, map[string]interface{}{
 			"UserName":        input.Name,
 			"ApplicationName": "Quotes",
 			"OTP":             otp.Plaintext,
@@ -124,7 +125,7 @@ func (app *application) verify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := app.db.GetUserByEmail(input.Email)
+	user, err := app.db.GetUserByEmail(r.Context(), input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -135,7 +136,7 @@ func (app *application) verify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	otp, err := app.db.GetOTPForEmail(input.Email, o.ScopeAuthentication)
+	otp, err := app.db.GetOTPForEmail(r.Context(), input.Email, o.ScopeAuthentication)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -162,19 +163,21 @@ func (app *application) verify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.db.ActivateUser(user.ID)
+	err = app.db.ActivateUser(r.Context(), user.ID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 	user.IsActivated = true
+	app.publish(events.UserChanged, user.ID)
 
 	if slices.Contains(app.config.Sudoers, user.Email) {
-		err = app.db.AddPermissionForUser(user.ID, "quotes:state")
+		err = app.db.AddPermissionForUser(r.Context(), user.ID, "quotes:state", "quotes:verify", "webhooks:manage")
 		if err != nil {
 			app.serverError(w, r, err)
 			return
 		}
+		app.publish(events.PermissionsChanged, user.ID)
 	}
 
 	expiry := time.Now().Add(7 * 24 * time.Hour)
@@ -194,7 +197,7 @@ func (app *application) verify(w http.ResponseWriter, r *http.Request) {
 		AuthTokenExpiry: expiry.Format(time.RFC3339),
 	}
 
-	err = app.db.DeleteAllOTPForUser(user.ID, o.ScopeAuthentication)
+	err = app.db.DeleteAllOTPForUser(r.Context(), user.ID, o.ScopeAuthentication)
 	if err != nil {
 		app.logger.Error(err)
 	}
@@ -218,7 +221,7 @@ func (app *application) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := app.db.GetUserByEmail(input.Email)
+	user, err := app.db.GetUserByEmail(r.Context(), input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -233,20 +236,20 @@ func (app *application) login(w http.ResponseWriter, r *http.Request) {
 	input.Validator.CheckField(user != nil, "email", "email address could not be found")
 
 	if !user.IsActivated {
-		err := app.db.DeleteAllOTPForUser(user.ID, o.ScopeAuthentication)
+		err := app.db.DeleteAllOTPForUser(r.Context(), user.ID, o.ScopeAuthentication)
 		if err != nil {
 			app.serverError(w, r, err)
 			return
 		}
 
-		otp, err := app.db.NewOtp(user.ID, 20*time.Minute, o.ScopeAuthentication)
+		otp, err := app.db.NewOtp(r.Context(), user.ID, 20*time.Minute, o.ScopeAuthentication)
 		if err != nil {
 			app.serverError(w, r, err)
 			return
 		}
 
 		go func() {
-			err = app.mailer.Send(input.Email, otp.Plaintext, map[string]interface{}{
+			err := app.mailer.Send(input.Email, otp.Plaintext, map[string]interface{}{
 				"UserName":        user.Name,
 				"ApplicationName": "Quotes",
 				"OTP":             otp.Plaintext,
@@ -324,7 +327,7 @@ func (app *application) forgotPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := app.db.GetUserByEmail(input.Email)
+	user, err := app.db.GetUserByEmail(r.Context(), input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -343,20 +346,20 @@ func (app *application) forgotPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.db.DeleteAllOTPForUser(user.ID, o.ScopeResetPassword)
+	err = app.db.DeleteAllOTPForUser(r.Context(), user.ID, o.ScopeResetPassword)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	otp, err := app.db.NewOtp(user.ID, 20*time.Minute, o.ScopeResetPassword)
+	otp, err := app.db.NewOtp(r.Context(), user.ID, 20*time.Minute, o.ScopeResetPassword)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
 	go func() {
-		err = app.mailer.Send(input.Email, otp.Plaintext, map[string]interface{}{
+		err := app.mailer.Send(input.Email, otp.Plaintext, map[string]interface{}{
 			"UserName":        user.Name,
 			"ApplicationName": "Quotes",
 			"OTP":             otp.Plaintext,
@@ -404,7 +407,7 @@ func (app *application) resetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := app.db.GetUserByEmail(input.Email)
+	user, err := app.db.GetUserByEmail(r.Context(), input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -422,7 +425,7 @@ func (app *application) resetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	otp, err := app.db.GetOTPForEmail(input.Email, o.ScopeResetPassword)
+	otp, err := app.db.GetOTPForEmail(r.Context(), input.Email, o.ScopeResetPassword)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -445,11 +448,13 @@ func (app *application) resetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.db.UpdateUserHashedPassword(user.ID, hashedPassword)
+	err = app.db.UpdateUserHashedPassword(r.Context(), user.ID, hashedPassword)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
+	app.publish(events.UserChanged, user.ID)
+
 	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"userID": user.ID}))
 	if err != nil {
 		app.serverError(w, r, err)
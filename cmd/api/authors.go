@@ -0,0 +1,513 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/filters"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/validator"
+
+	"github.com/alexedwards/flow"
+	"github.com/google/uuid"
+)
+
+const maxAuthorAliases = 50
+
+type AuthorResponse struct {
+	ID         uuid.UUID      `json:"id"`
+	Name       string         `json:"name"`
+	Aliases    []string       `json:"aliases"`
+	BirthYear  *int           `json:"birthYear"`
+	DeathYear  *int           `json:"deathYear"`
+	Bio        string         `json:"bio"`
+	Photo      *PhotoResponse `json:"photo,omitempty"`
+	QuoteCount int            `json:"quoteCount"`
+	CreatedAt  string         `json:"createdAt"`
+	UpdatedAt  string         `json:"updatedAt"`
+	Version    int            `json:"version"`
+}
+
+func newAuthorResponse(author *database.Author) AuthorResponse {
+	data := AuthorResponse{
+		ID:         author.ID,
+		Name:       author.Name,
+		Aliases:    author.Aliases,
+		BirthYear:  author.BirthYear,
+		DeathYear:  author.DeathYear,
+		Bio:        author.Bio,
+		QuoteCount: author.QuoteCount,
+		CreatedAt:  author.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:  author.UpdatedAt.Format(time.RFC3339),
+		Version:    author.Version,
+	}
+
+	if data.Aliases == nil {
+		data.Aliases = []string{}
+	}
+
+	if author.Photo != nil {
+		data.Photo = &PhotoResponse{
+			ID:       author.Photo.ID,
+			Color:    author.Photo.Color,
+			BlurHash: author.Photo.BlurHash,
+			Author:   author.Photo.Author,
+			Url:      author.Photo.Url,
+		}
+	}
+
+	return data
+}
+
+func (app *application) authorStore(w http.ResponseWriter, r *http.Request) (database.AuthorStore, bool) {
+	store, ok := app.db.(database.AuthorStore)
+	if !ok {
+		app.notSupported(w, r)
+	}
+	return store, ok
+}
+
+// readAuthor loads the author named by the id path parameter.
+func (app *application) readAuthor(w http.ResponseWriter, r *http.Request, store database.AuthorStore) (*database.Author, bool) {
+	id, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "author not found", nil)
+		return nil, false
+	}
+
+	author, err := store.GetAuthor(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "author not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return author, true
+}
+
+// cleanAliases trims the aliases and drops empty ones as well as repeats
+// that only differ in case.
+func cleanAliases(aliases []string) []string {
+	seen := make(map[string]bool, len(aliases))
+	cleaned := make([]string, 0, len(aliases))
+
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		if alias == "" || seen[strings.ToLower(alias)] {
+			continue
+		}
+		seen[strings.ToLower(alias)] = true
+		cleaned = append(cleaned, alias)
+	}
+
+	return cleaned
+}
+
+func validateAuthor(v *validator.Validator, author *database.Author) {
+	year := time.Now().Year()
+
+	v.CheckField(author.Name != "", "name", "must be provided")
+	v.CheckField(validator.MaxRunes(author.Name, 200), "name", "must not be more than 200 characters long")
+	v.CheckField(len(author.Aliases) <= maxAuthorAliases, "aliases", "must not contain more than 50 aliases")
+	for _, alias := range author.Aliases {
+		v.CheckField(validator.MaxRunes(alias, 200), "aliases", "must not be more than 200 characters long each")
+	}
+	v.CheckField(validator.MaxRunes(author.Bio, 2000), "bio", "must not be more than 2000 characters long")
+	if author.BirthYear != nil {
+		v.CheckField(validator.Between(*author.BirthYear, -3000, year), "birthYear", "must be a valid year")
+	}
+	if author.DeathYear != nil {
+		v.CheckField(validator.Between(*author.DeathYear, -3000, year), "deathYear", "must be a valid year")
+	}
+	if author.BirthYear != nil && author.DeathYear != nil {
+		v.CheckField(*author.DeathYear >= *author.BirthYear, "deathYear", "must not be before the birth year")
+	}
+}
+
+func (app *application) writeAuthorError(w http.ResponseWriter, r *http.Request, v validator.Validator, err error) {
+	switch {
+	case errors.Is(err, database.ErrEditConflict):
+		app.editConflictResponse(w, r)
+	case errors.Is(err, database.ErrDuplicateAuthor):
+		v.AddFieldError("name", "the name or one of the aliases is already used by another author")
+		app.failedValidation(w, r, v)
+	case errors.Is(err, database.ErrRecordNotFound):
+		v.AddFieldError("photoId", "photo not found")
+		app.failedValidation(w, r, v)
+	default:
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) getAuthors(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	store, ok := app.authorStore(w, r)
+	if !ok {
+		return
+	}
+
+	qs := r.URL.Query()
+	input.Name = request.ReadString(qs, "name", "")
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+	input.Filters.Sort = request.ReadString(qs, "sort", "name")
+	input.Filters.SortSafeList = []string{"name", "-name"}
+
+	if filters.ValidateFilters(&input.Validator, input.Filters); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	authors, metadata, err := store.GetAuthors(r.Context(), input.Name, input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := make([]AuthorResponse, 0, len(authors))
+	for i := range authors {
+		data = append(data, newAuthorResponse(&authors[i]))
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// autocompleteAuthors suggests authors for the author field of the quote
+// form, matching the start of any word of their name or aliases.
+func (app *application) autocompleteAuthors(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Query     string
+		Limit     int
+		Validator validator.Validator
+	}
+
+	store, ok := app.authorStore(w, r)
+	if !ok {
+		return
+	}
+
+	qs := r.URL.Query()
+	input.Query = strings.TrimSpace(request.ReadString(qs, "q", ""))
+	input.Limit = request.ReadInt(qs, "limit", 10, &input.Validator)
+
+	input.Validator.CheckField(input.Query != "", "q", "must be provided")
+	input.Validator.CheckField(validator.MaxRunes(input.Query, 200), "q", "must not be more than 200 characters long")
+	input.Validator.CheckField(validator.Between(input.Limit, 1, 50), "limit", "must be between 1 and 50")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	matches, err := store.AutocompleteAuthors(r.Context(), input.Query, input.Limit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := make([]envelope, 0, len(matches))
+	for _, match := range matches {
+		suggestion := envelope{"id": match.ID, "name": match.Name, "quoteCount": match.QuoteCount}
+		if match.Alias != "" {
+			suggestion["alias"] = match.Alias
+		}
+		data = append(data, suggestion)
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(data))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// lookupAuthor finds the author a free-text name refers to, aliases
+// included.
+func (app *application) lookupAuthor(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+
+	store, ok := app.authorStore(w, r)
+	if !ok {
+		return
+	}
+
+	name := strings.TrimSpace(request.ReadString(r.URL.Query(), "name", ""))
+
+	if v.CheckField(name != "", "name", "must be provided"); v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	author, err := store.FindAuthor(r.Context(), name)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "author not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(newAuthorResponse(author)))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) createAuthor(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name      string              `json:"name"`
+		Aliases   []string            `json:"aliases"`
+		BirthYear *int                `json:"birthYear"`
+		DeathYear *int                `json:"deathYear"`
+		Bio       string              `json:"bio"`
+		PhotoID   *uuid.UUID          `json:"photoId"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	store, ok := app.authorStore(w, r)
+	if !ok {
+		return
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	author := &database.Author{
+		Name:      strings.TrimSpace(input.Name),
+		Aliases:   cleanAliases(input.Aliases),
+		BirthYear: input.BirthYear,
+		DeathYear: input.DeathYear,
+		Bio:       strings.TrimSpace(input.Bio),
+		PhotoID:   input.PhotoID,
+	}
+
+	if validateAuthor(&input.Validator, author); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	err = store.InsertAuthor(r.Context(), author)
+	if err != nil {
+		app.writeAuthorError(w, r, input.Validator, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusCreated, getWrapper(newAuthorResponse(author)))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) getAuthor(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.authorStore(w, r)
+	if !ok {
+		return
+	}
+
+	author, ok := app.readAuthor(w, r, store)
+	if !ok {
+		return
+	}
+
+	err := response.JSON(w, http.StatusOK, getWrapper(newAuthorResponse(author)))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// updateAuthor changes the given fields only. Aliases, when given, replace
+// the current ones; a null photoId keeps the photo, use clearPhoto to drop it.
+func (app *application) updateAuthor(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name       *string             `json:"name"`
+		Aliases    *[]string           `json:"aliases"`
+		BirthYear  *int                `json:"birthYear"`
+		DeathYear  *int                `json:"deathYear"`
+		Bio        *string             `json:"bio"`
+		PhotoID    *uuid.UUID          `json:"photoId"`
+		ClearPhoto bool                `json:"clearPhoto"`
+		Version    *int                `json:"version"`
+		Validator  validator.Validator `json:"-"`
+	}
+
+	store, ok := app.authorStore(w, r)
+	if !ok {
+		return
+	}
+
+	author, ok := app.readAuthor(w, r, store)
+	if !ok {
+		return
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if input.Version != nil && *input.Version != author.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	if input.Name != nil {
+		author.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Aliases != nil {
+		author.Aliases = cleanAliases(*input.Aliases)
+	}
+	if input.BirthYear != nil {
+		author.BirthYear = input.BirthYear
+	}
+	if input.DeathYear != nil {
+		author.DeathYear = input.DeathYear
+	}
+	if input.Bio != nil {
+		author.Bio = strings.TrimSpace(*input.Bio)
+	}
+	if input.PhotoID != nil {
+		author.PhotoID = input.PhotoID
+	}
+	if input.ClearPhoto {
+		author.PhotoID = nil
+	}
+
+	if validateAuthor(&input.Validator, author); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	err = store.UpdateAuthor(r.Context(), author)
+	if err != nil {
+		app.writeAuthorError(w, r, input.Validator, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(newAuthorResponse(author)))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// mergeAuthors folds the authors listed in authorIds into the author of the
+// path: their quotes move over and their names become aliases.
+func (app *application) mergeAuthors(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		AuthorIDs []uuid.UUID         `json:"authorIds"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	store, ok := app.authorStore(w, r)
+	if !ok {
+		return
+	}
+
+	author, ok := app.readAuthor(w, r, store)
+	if !ok {
+		return
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Validator.CheckField(len(input.AuthorIDs) > 0, "authorIds", "must contain at least one author")
+	input.Validator.CheckField(len(input.AuthorIDs) <= 20, "authorIds", "must not contain more than 20 authors")
+	input.Validator.CheckField(validator.NoDuplicates(input.AuthorIDs), "authorIds", "must not contain duplicates")
+	input.Validator.CheckField(validator.NotIn(author.ID, input.AuthorIDs...), "authorIds", "must not contain the author merged into")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	merged, err := store.MergeAuthors(r.Context(), author.ID, input.AuthorIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			input.Validator.AddFieldError("authorIds", "author not found")
+			app.failedValidation(w, r, input.Validator)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(newAuthorResponse(merged)))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) getAuthorQuotes(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	store, ok := app.authorStore(w, r)
+	if !ok {
+		return
+	}
+
+	author, ok := app.readAuthor(w, r, store)
+	if !ok {
+		return
+	}
+
+	qs := r.URL.Query()
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+	input.Filters.Sort = request.ReadString(qs, "sort", "-date")
+	input.Filters.SortSafeList = []string{"date", "-date", "popularity", "-popularity"}
+
+	if filters.ValidateFilters(&input.Validator, input.Filters); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	quotes, metadata, err := store.GetAuthorQuotes(r.Context(), author.ID, input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := make([]QuoteResponse, 0, len(quotes))
+	for i := range quotes {
+		data = append(data, newQuoteResponse(&quotes[i]))
+	}
+
+	err = app.setReactions(r, data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// getBundle serves the offline bundle, a SQLite file of all public quotes.
+// The file is cached until the data changes; its ETag lets clients skip the
+// download when they already have it.
+func (app *application) getBundle(w http.ResponseWriter, r *http.Request) {
+	b, err := app.bundles.Get(r.Context(), app.db)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", b.ETag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", `attachment; filename="quotes.sqlite"`)
+
+	http.ServeContent(w, r, "quotes.sqlite", b.BuiltAt, bytes.NewReader(b.Data))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/cache"
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/filters"
+	"javlonrahimov/quotes-api/internal/response"
+
+	"github.com/google/uuid"
+)
+
+type page[T any] struct {
+	items    []T
+	metadata filters.Metadata
+}
+
+// caches hold the reference data read on most requests. Writes invalidate
+// them through events, on this instance and the others; writes made outside
+// the API, such as seeding, show up once entries expire.
+type caches struct {
+	states      *cache.Cache[string, page[database.QuoteState]]
+	hashtags    *cache.Cache[string, page[database.Hashtag]]
+	photos      *cache.Cache[string, page[*database.Photo]]
+	permissions *cache.Cache[uuid.UUID, []string]
+	users       *cache.Cache[uuid.UUID, database.User]
+}
+
+func newCaches(ttl time.Duration, size int) *caches {
+	return &caches{
+		states:      cache.New[string, page[database.QuoteState]]("states", ttl, 1),
+		hashtags:    cache.New[string, page[database.Hashtag]]("hashtags", ttl, size),
+		photos:      cache.New[string, page[*database.Photo]]("photos", ttl, 1),
+		permissions: cache.New[uuid.UUID, []string]("permissions", ttl, size),
+		users:       cache.New[uuid.UUID, database.User]("users", ttl, size),
+	}
+}
+
+func (c *caches) purge() {
+	c.states.Purge()
+	c.hashtags.Purge()
+	c.photos.Purge()
+	c.permissions.Purge()
+	c.users.Purge()
+}
+
+func (c *caches) stats() []cache.Stats {
+	return []cache.Stats{c.states.Stats(), c.hashtags.Stats(), c.photos.Stats(), c.permissions.Stats(), c.users.Stats()}
+}
+
+func (app *application) quoteStates(ctx context.Context) ([]database.QuoteState, filters.Metadata, error) {
+	p, err := app.caches.states.GetOrLoad(ctx, "all", func(ctx context.Context) (page[database.QuoteState], error) {
+		states, metadata, err := app.db.GetAllQuoteStates(ctx)
+		return page[database.QuoteState]{states, metadata}, err
+	})
+	return p.items, p.metadata, err
+}
+
+func (app *application) hashtags(ctx context.Context, f filters.Filters) ([]database.Hashtag, filters.Metadata, error) {
+	key := fmt.Sprintf("%d:%d:%s", f.Page, f.PageSize, f.Sort)
+
+	p, err := app.caches.hashtags.GetOrLoad(ctx, key, func(ctx context.Context) (page[database.Hashtag], error) {
+		hashtags, metadata, err := app.db.GetHashtags(ctx, f)
+		return page[database.Hashtag]{hashtags, metadata}, err
+	})
+	return p.items, p.metadata, err
+}
+
+func (app *application) photos(ctx context.Context) ([]*database.Photo, filters.Metadata, error) {
+	p, err := app.caches.photos.GetOrLoad(ctx, "all", func(ctx context.Context) (page[*database.Photo], error) {
+		photos, metadata, err := app.db.GetPhotos(ctx)
+		return page[*database.Photo]{photos, metadata}, err
+	})
+	return p.items, p.metadata, err
+}
+
+// permissions returns the permission codes of a user. The slice is shared
+// between callers and must not be modified.
+func (app *application) permissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	return app.caches.permissions.GetOrLoad(ctx, userID, func(ctx context.Context) ([]string, error) {
+		return app.db.GetAllPermissionsForUser(ctx, userID)
+	})
+}
+
+// user returns a copy of the cached user, which the caller is free to modify.
+func (app *application) user(ctx context.Context, id uuid.UUID) (*database.User, error) {
+	user, err := app.caches.users.GetOrLoad(ctx, id, func(ctx context.Context) (database.User, error) {
+		user, err := app.db.GetUser(ctx, id)
+		if err != nil {
+			return database.User{}, err
+		}
+		return *user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (app *application) getCacheStats(w http.ResponseWriter, r *http.Request) {
+	err := response.JSON(w, http.StatusOK, getWrapper(app.caches.stats()))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
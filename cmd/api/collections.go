@@ -0,0 +1,593 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/filters"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/validator"
+
+	"github.com/alexedwards/flow"
+	"github.com/google/uuid"
+)
+
+type CollectionResponse struct {
+	ID            uuid.UUID `json:"id"`
+	OwnerID       uuid.UUID `json:"ownerID"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	IsPublic      bool      `json:"isPublic"`
+	ItemCount     int       `json:"itemCount"`
+	FollowerCount int       `json:"followerCount"`
+	Following     *bool     `json:"following,omitempty"`
+	CreatedAt     string    `json:"createdAt"`
+	UpdatedAt     string    `json:"updatedAt"`
+	Version       int       `json:"version"`
+}
+
+func newCollectionResponse(collection *database.Collection) CollectionResponse {
+	return CollectionResponse{
+		ID:            collection.ID,
+		OwnerID:       collection.OwnerID,
+		Name:          collection.Name,
+		Description:   collection.Description,
+		IsPublic:      collection.IsPublic,
+		ItemCount:     collection.ItemCount,
+		FollowerCount: collection.FollowerCount,
+		CreatedAt:     collection.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:     collection.UpdatedAt.Format(time.RFC3339),
+		Version:       collection.Version,
+	}
+}
+
+func (app *application) collectionStore(w http.ResponseWriter, r *http.Request) (database.CollectionStore, bool) {
+	store, ok := app.db.(database.CollectionStore)
+	if !ok {
+		app.notSupported(w, r)
+	}
+	return store, ok
+}
+
+// readCollection loads the collection named by the id path parameter and
+// answers 404 when it is missing or hidden from the authenticated user, so
+// private collections don't leak their existence. With edit set, only the
+// owner gets past.
+func (app *application) readCollection(w http.ResponseWriter, r *http.Request, store database.CollectionStore, edit bool) (*database.Collection, bool) {
+	id, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "collection not found", nil)
+		return nil, false
+	}
+
+	collection, err := store.GetCollection(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "collection not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	user := contextGetAuthenticatedUser(r)
+
+	switch {
+	case !collection.CanView(user.ID):
+		app.errorMessage(w, r, http.StatusNotFound, "collection not found", nil)
+		return nil, false
+	case edit && !collection.CanEdit(user.ID):
+		app.errorMessage(w, r, http.StatusForbidden, "only the owner can change this collection", nil)
+		return nil, false
+	}
+
+	return collection, true
+}
+
+func validateCollection(v *validator.Validator, collection *database.Collection) {
+	v.CheckField(collection.Name != "", "name", "must be provided")
+	v.CheckField(utf8.RuneCountInString(collection.Name) <= 100, "name", "must not be more than 100 characters long")
+	v.CheckField(utf8.RuneCountInString(collection.Description) <= 500, "description", "must not be more than 500 characters long")
+}
+
+func (app *application) createCollection(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string              `json:"name"`
+		Description string              `json:"description"`
+		IsPublic    bool                `json:"isPublic"`
+		Validator   validator.Validator `json:"-"`
+	}
+
+	store, ok := app.collectionStore(w, r)
+	if !ok {
+		return
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	collection := &database.Collection{
+		OwnerID:     contextGetAuthenticatedUser(r).ID,
+		Name:        strings.TrimSpace(input.Name),
+		Description: strings.TrimSpace(input.Description),
+		IsPublic:    input.IsPublic,
+	}
+
+	if validateCollection(&input.Validator, collection); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	err = store.InsertCollection(r.Context(), collection)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrDuplicateCollection):
+			input.Validator.AddFieldError("name", "you already have a collection with this name")
+			app.failedValidation(w, r, input.Validator)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusCreated, getWrapper(newCollectionResponse(collection)))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// getCollection returns the collection together with a page of its quotes
+// in their stored order. Only the owner sees quotes that are not public.
+func (app *application) getCollection(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	store, ok := app.collectionStore(w, r)
+	if !ok {
+		return
+	}
+
+	qs := r.URL.Query()
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+
+	input.Filters.Sort = "position"
+	input.Filters.SortSafeList = []string{"position"}
+
+	if filters.ValidateFilters(&input.Validator, input.Filters); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	collection, ok := app.readCollection(w, r, store, false)
+	if !ok {
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+
+	quotes, metadata, err := store.GetCollectionQuotes(r.Context(), collection.ID, !collection.CanEdit(user.ID), input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	following, err := store.IsFollowingCollection(r.Context(), user.ID, collection.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	quotesResponse := make([]QuoteResponse, 0)
+	for i := range quotes {
+		quotesResponse = append(quotesResponse, newQuoteResponse(&quotes[i]))
+	}
+
+	err = app.setReactions(r, quotesResponse)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := newCollectionResponse(collection)
+	data.Following = &following
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"collection": data, "metadata": metadata, "quotes": quotesResponse}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) updateCollection(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        *string             `json:"name"`
+		Description *string             `json:"description"`
+		IsPublic    *bool               `json:"isPublic"`
+		Version     *int                `json:"version"`
+		Validator   validator.Validator `json:"-"`
+	}
+
+	store, ok := app.collectionStore(w, r)
+	if !ok {
+		return
+	}
+
+	collection, ok := app.readCollection(w, r, store, true)
+	if !ok {
+		return
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if input.Version != nil && *input.Version != collection.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	if input.Name != nil {
+		collection.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Description != nil {
+		collection.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.IsPublic != nil {
+		collection.IsPublic = *input.IsPublic
+	}
+
+	if validateCollection(&input.Validator, collection); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	err = store.UpdateCollection(r.Context(), collection)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, database.ErrDuplicateCollection):
+			input.Validator.AddFieldError("name", "you already have a collection with this name")
+			app.failedValidation(w, r, input.Validator)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(newCollectionResponse(collection)))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) deleteCollection(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.collectionStore(w, r)
+	if !ok {
+		return
+	}
+
+	collection, ok := app.readCollection(w, r, store, true)
+	if !ok {
+		return
+	}
+
+	err := store.DeleteCollection(r.Context(), collection.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"id": collection.ID}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// getCollections lists the collections of the user given by ?userId=,
+// defaulting to the authenticated user. Other users' private collections are
+// left out.
+func (app *application) getCollections(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		OwnerID uuid.UUID
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	store, ok := app.collectionStore(w, r)
+	if !ok {
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+
+	qs := r.URL.Query()
+	input.OwnerID = user.ID
+	if value := request.ReadString(qs, "userId", ""); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			input.Validator.AddFieldError("userId", "invalid user id")
+		}
+		input.OwnerID = id
+	}
+
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+	input.Filters.Sort = request.ReadString(qs, "sort", "-updated_at")
+	input.Filters.SortSafeList = []string{"name", "created_at", "updated_at", "-name", "-created_at", "-updated_at"}
+
+	if filters.ValidateFilters(&input.Validator, input.Filters); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	collections, metadata, err := store.GetUserCollections(r.Context(), input.OwnerID, input.OwnerID != user.ID, input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.writeCollections(w, r, collections, metadata)
+}
+
+func (app *application) getFollowedCollections(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	store, ok := app.collectionStore(w, r)
+	if !ok {
+		return
+	}
+
+	qs := r.URL.Query()
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+	input.Filters.Sort = "followed_at"
+	input.Filters.SortSafeList = []string{"followed_at"}
+
+	if filters.ValidateFilters(&input.Validator, input.Filters); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	collections, metadata, err := store.GetFollowedCollections(r.Context(), contextGetAuthenticatedUser(r).ID, input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.writeCollections(w, r, collections, metadata)
+}
+
+func (app *application) writeCollections(w http.ResponseWriter, r *http.Request, collections []database.Collection, metadata filters.Metadata) {
+	data := make([]CollectionResponse, 0, len(collections))
+	for i := range collections {
+		data = append(data, newCollectionResponse(&collections[i]))
+	}
+
+	err := response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) addCollectionQuote(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		QuoteID   uuid.UUID           `json:"quoteID"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	store, ok := app.collectionStore(w, r)
+	if !ok {
+		return
+	}
+
+	collection, ok := app.readCollection(w, r, store, true)
+	if !ok {
+		return
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if input.Validator.CheckField(input.QuoteID != uuid.Nil, "quoteID", "must be provided"); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	err = store.AddCollectionQuote(r.Context(), collection.ID, input.QuoteID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			input.Validator.AddFieldError("quoteID", "quote not found")
+			app.failedValidation(w, r, input.Validator)
+		case errors.Is(err, database.ErrCollectionFull):
+			input.Validator.AddFieldError("quoteID", "collection cannot hold more than 500 quotes")
+			app.failedValidation(w, r, input.Validator)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.writeCollection(w, r, store, collection.ID)
+}
+
+func (app *application) removeCollectionQuote(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.collectionStore(w, r)
+	if !ok {
+		return
+	}
+
+	collection, ok := app.readCollection(w, r, store, true)
+	if !ok {
+		return
+	}
+
+	quoteID, err := uuid.Parse(flow.Param(r.Context(), "quoteId"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		return
+	}
+
+	err = store.RemoveCollectionQuote(r.Context(), collection.ID, quoteID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found in collection", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.writeCollection(w, r, store, collection.ID)
+}
+
+func (app *application) reorderCollection(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		QuoteIDs  []uuid.UUID         `json:"quoteIDs"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	store, ok := app.collectionStore(w, r)
+	if !ok {
+		return
+	}
+
+	collection, ok := app.readCollection(w, r, store, true)
+	if !ok {
+		return
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	err = store.ReorderCollection(r.Context(), collection.ID, input.QuoteIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrCollectionOrder):
+			input.Validator.AddFieldError("quoteIDs", "must list every quote of the collection exactly once")
+			app.failedValidation(w, r, input.Validator)
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.writeCollection(w, r, store, collection.ID)
+}
+
+func (app *application) followCollection(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.collectionStore(w, r)
+	if !ok {
+		return
+	}
+
+	collection, ok := app.readCollection(w, r, store, false)
+	if !ok {
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+
+	if !collection.CanFollow(user.ID) {
+		app.errorMessage(w, r, http.StatusUnprocessableEntity, "you cannot follow your own collection", nil)
+		return
+	}
+
+	err := store.FollowCollection(r.Context(), user.ID, collection.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.writeCollection(w, r, store, collection.ID)
+}
+
+// unfollowCollection skips the visibility check so that followers can drop a
+// collection that has since been made private.
+func (app *application) unfollowCollection(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.collectionStore(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "collection not found", nil)
+		return
+	}
+
+	err = store.UnfollowCollection(r.Context(), contextGetAuthenticatedUser(r).ID, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"id": id}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// writeCollection answers a change to a collection with its fresh state.
+func (app *application) writeCollection(w http.ResponseWriter, r *http.Request, store database.CollectionStore, id uuid.UUID) {
+	collection, err := store.GetCollection(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	following, err := store.IsFollowingCollection(r.Context(), contextGetAuthenticatedUser(r).ID, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := newCollectionResponse(collection)
+	data.Following = &following
+
+	err = response.JSON(w, http.StatusOK, getWrapper(data))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/filters"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/validator"
+
+	"github.com/alexedwards/flow"
+	"github.com/google/uuid"
+)
+
+const maxCommentLength = 2000
+
+type CommentResponse struct {
+	ID        uuid.UUID         `json:"id"`
+	QuoteID   uuid.UUID         `json:"quoteID"`
+	ParentID  *uuid.UUID        `json:"parentID,omitempty"`
+	UserID    uuid.UUID         `json:"userID"`
+	UserName  string            `json:"userName"`
+	Text      string            `json:"text"`
+	State     StateResponse     `json:"state"`
+	IsHidden  bool              `json:"isHidden"`
+	IsDeleted bool              `json:"isDeleted"`
+	CreatedAt string            `json:"createdAt"`
+	EditedAt  string            `json:"editedAt,omitempty"`
+	Replies   []CommentResponse `json:"replies,omitempty"`
+}
+
+func newCommentResponse(comment *database.Comment) CommentResponse {
+	data := CommentResponse{
+		ID:       comment.ID,
+		QuoteID:  comment.QuoteID,
+		ParentID: comment.ParentID,
+		UserID:   comment.UserID,
+		UserName: comment.UserName,
+		Text:     comment.Text,
+		State: StateResponse{
+			ID:        comment.State.ID,
+			Value:     comment.State.Value,
+			IdDefault: comment.State.IsDefault,
+			Color:     comment.State.Color,
+			IsPublic:  comment.State.IsPublic,
+		},
+		IsHidden:  comment.IsHidden,
+		IsDeleted: comment.DeletedAt != nil,
+		CreatedAt: comment.CreatedAt.Format(time.RFC3339),
+	}
+
+	if comment.EditedAt != nil {
+		data.EditedAt = comment.EditedAt.Format(time.RFC3339)
+	}
+
+	if data.IsDeleted {
+		data.Text = ""
+	}
+
+	for i := range comment.Replies {
+		data.Replies = append(data.Replies, newCommentResponse(&comment.Replies[i]))
+	}
+
+	return data
+}
+
+func (app *application) commentStore(w http.ResponseWriter, r *http.Request) (database.CommentStore, bool) {
+	store, ok := app.db.(database.CommentStore)
+	if !ok {
+		app.notSupported(w, r)
+	}
+	return store, ok
+}
+
+// readComment loads the comment named by the id path parameter.
+func (app *application) readComment(w http.ResponseWriter, r *http.Request, store database.CommentStore) (*database.Comment, bool) {
+	id, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "comment not found", nil)
+		return nil, false
+	}
+
+	comment, err := store.GetComment(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "comment not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return comment, true
+}
+
+func validateCommentText(v *validator.Validator, text string) {
+	v.CheckField(text != "", "text", "must be provided")
+	v.CheckField(utf8.RuneCountInString(text) <= maxCommentLength, "text", fmt.Sprintf("must not be more than %d characters long", maxCommentLength))
+}
+
+// getQuoteComments lists the top-level comments of a quote with their
+// replies. Holders of quotes:state also see comments that are still being
+// screened or have been hidden.
+func (app *application) getQuoteComments(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	store, ok := app.commentStore(w, r)
+	if !ok {
+		return
+	}
+
+	quoteID, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		return
+	}
+
+	qs := r.URL.Query()
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+	input.Filters.Sort = "created_at"
+	input.Filters.SortSafeList = []string{"created_at"}
+
+	if filters.ValidateFilters(&input.Validator, input.Filters); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	moderator, err := app.hasPermission(r, "quotes:state")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	comments, metadata, err := store.GetQuoteComments(r.Context(), quoteID, contextGetAuthenticatedUser(r).ID, moderator, input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := make([]CommentResponse, 0, len(comments))
+	for i := range comments {
+		data = append(data, newCommentResponse(&comments[i]))
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) createComment(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Text      string              `json:"text"`
+		ParentID  *uuid.UUID          `json:"parentID"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	store, ok := app.commentStore(w, r)
+	if !ok {
+		return
+	}
+
+	quoteID, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		return
+	}
+
+	err = request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Text = strings.TrimSpace(input.Text)
+
+	if validateCommentText(&input.Validator, input.Text); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	comment := &database.Comment{
+		QuoteID:  quoteID,
+		ParentID: input.ParentID,
+		UserID:   contextGetAuthenticatedUser(r).ID,
+		Text:     input.Text,
+	}
+
+	err = store.InsertComment(r.Context(), comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound) && input.ParentID != nil:
+			input.Validator.AddFieldError("parentID", "comment not found")
+			app.failedValidation(w, r, input.Validator)
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		case errors.Is(err, database.ErrNestedReply):
+			input.Validator.AddFieldError("parentID", "replies can only be made to top-level comments of this quote")
+			app.failedValidation(w, r, input.Validator)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusCreated, getWrapper(newCommentResponse(comment)))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// updateComment lets authors fix their comment for a while after posting it.
+func (app *application) updateComment(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Text      string              `json:"text"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	store, ok := app.commentStore(w, r)
+	if !ok {
+		return
+	}
+
+	comment, ok := app.readComment(w, r, store)
+	if !ok {
+		return
+	}
+
+	if comment.UserID != contextGetAuthenticatedUser(r).ID {
+		app.errorMessage(w, r, http.StatusForbidden, "only the author can edit this comment", nil)
+		return
+	}
+
+	if time.Since(comment.CreatedAt) > app.config.Comments.EditWindow {
+		app.errorMessage(w, r, http.StatusForbidden, fmt.Sprintf("comments can only be edited for %s after posting", app.config.Comments.EditWindow), nil)
+		return
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Text = strings.TrimSpace(input.Text)
+
+	if validateCommentText(&input.Validator, input.Text); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	comment, err = store.UpdateCommentText(r.Context(), comment.ID, input.Text)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "comment not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(newCommentResponse(comment)))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// deleteComment is open to the author and to holders of quotes:state.
+func (app *application) deleteComment(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.commentStore(w, r)
+	if !ok {
+		return
+	}
+
+	comment, ok := app.readComment(w, r, store)
+	if !ok {
+		return
+	}
+
+	if comment.UserID != contextGetAuthenticatedUser(r).ID {
+		moderator, err := app.hasPermission(r, "quotes:state")
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if !moderator {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	err := store.DeleteComment(r.Context(), comment.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "comment not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"id": comment.ID}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) setCommentState(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ID        uuid.UUID           `json:"id"`
+		StateID   uuid.UUID           `json:"stateId"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	store, ok := app.commentStore(w, r)
+	if !ok {
+		return
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Validator.CheckField(input.ID != uuid.Nil, "id", "id is required")
+	input.Validator.CheckField(input.StateID != uuid.Nil, "stateId", "stateId is required")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	err = store.SetCommentState(r.Context(), input.ID, input.StateID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"stateID": input.StateID}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) hideComment(w http.ResponseWriter, r *http.Request) {
+	app.setCommentHidden(w, r, true)
+}
+
+func (app *application) unhideComment(w http.ResponseWriter, r *http.Request) {
+	app.setCommentHidden(w, r, false)
+}
+
+func (app *application) setCommentHidden(w http.ResponseWriter, r *http.Request, hidden bool) {
+	store, ok := app.commentStore(w, r)
+	if !ok {
+		return
+	}
+
+	comment, ok := app.readComment(w, r, store)
+	if !ok {
+		return
+	}
+
+	err := store.SetCommentHidden(r.Context(), comment.ID, hidden, contextGetAuthenticatedUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "comment not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"id": comment.ID, "isHidden": hidden}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"javlonrahimov/quotes-api/config"
+)
+
+func TestCommentEditWindow(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	_, admin := ts.registerUser(t, testSudoer)
+	_, reader := ts.registerUser(t, "reader@example.org")
+
+	quote := ts.publishedQuote(t, admin, "You have power over your mind, not outside events.")
+
+	var comment CommentResponse
+	ts.mustDo(t, http.StatusCreated, "POST", "/v1/quote/"+quote.ID.String()+"/comments", reader, map[string]string{
+		"text": "Easier said than done.",
+	}).decode(t, &comment)
+
+	if comment.EditedAt != "" {
+		t.Fatalf("new comment has editedAt %q", comment.EditedAt)
+	}
+
+	path := "/v1/comment/" + comment.ID.String()
+
+	ts.mustDo(t, http.StatusForbidden, "PATCH", path, admin, map[string]string{"text": "Someone else's words."})
+
+	ts.mustDo(t, http.StatusOK, "PATCH", path, reader, map[string]string{"text": "Easier said than done, but worth it."}).decode(t, &comment)
+	if comment.Text != "Easier said than done, but worth it." || comment.EditedAt == "" {
+		t.Fatalf("edited comment = %+v, want the new text and an editedAt", comment)
+	}
+
+	// Past the window even the author can no longer edit.
+	late := newTestServer(t, newTestApplication(t, func(cfg *config.Config) {
+		cfg.Comments.EditWindow = time.Nanosecond
+	}))
+
+	_, admin = late.registerUser(t, testSudoer)
+	_, reader = late.registerUser(t, "reader@example.org")
+
+	quote = late.publishedQuote(t, admin, "You have power over your mind, not outside events.")
+
+	late.mustDo(t, http.StatusCreated, "POST", "/v1/quote/"+quote.ID.String()+"/comments", reader, map[string]string{
+		"text": "Easier said than done.",
+	}).decode(t, &comment)
+
+	late.mustDo(t, http.StatusForbidden, "PATCH", "/v1/comment/"+comment.ID.String(), reader, map[string]string{"text": "Too late."})
+}
+
+func TestCommentSoftDelete(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	_, admin := ts.registerUser(t, testSudoer)
+	_, reader := ts.registerUser(t, "reader@example.org")
+
+	quote := ts.publishedQuote(t, admin, "The best revenge is not to be like that.")
+	comments := "/v1/quote/" + quote.ID.String() + "/comments"
+	public := ts.publicState(t, admin)
+
+	var parent, reply CommentResponse
+	ts.mustDo(t, http.StatusCreated, "POST", comments, reader, map[string]string{"text": "A top-level comment."}).decode(t, &parent)
+	ts.mustDo(t, http.StatusCreated, "POST", comments, admin, map[string]any{"text": "A reply.", "parentID": parent.ID}).decode(t, &reply)
+
+	for _, id := range []string{parent.ID.String(), reply.ID.String()} {
+		ts.mustDo(t, http.StatusOK, "PATCH", "/v1/comment/set-state", admin, map[string]string{"id": id, "stateId": public})
+	}
+
+	var fetched QuoteResponse
+	ts.mustDo(t, http.StatusOK, "GET", "/v1/quote/"+quote.ID.String(), reader, nil).decode(t, &fetched)
+	if fetched.CommentCount != 2 {
+		t.Fatalf("commentCount = %d, want 2", fetched.CommentCount)
+	}
+
+	ts.mustDo(t, http.StatusForbidden, "DELETE", "/v1/comment/"+reply.ID.String(), reader, nil)
+	ts.mustDo(t, http.StatusOK, "DELETE", "/v1/comment/"+parent.ID.String(), reader, nil)
+
+	// The deleted comment stays as a placeholder for its reply.
+	var listing struct {
+		Data []CommentResponse `json:"data"`
+	}
+	ts.mustDo(t, http.StatusOK, "GET", comments, reader, nil).decode(t, &listing)
+	if len(listing.Data) != 1 {
+		t.Fatalf("listing holds %d comments, want the deleted parent", len(listing.Data))
+	}
+
+	placeholder := listing.Data[0]
+	if !placeholder.IsDeleted || placeholder.Text != "" || len(placeholder.Replies) != 1 || placeholder.Replies[0].ID != reply.ID {
+		t.Fatalf("placeholder = %+v, want a deleted comment without text holding the reply", placeholder)
+	}
+
+	ts.mustDo(t, http.StatusOK, "GET", "/v1/quote/"+quote.ID.String(), reader, nil).decode(t, &fetched)
+	if fetched.CommentCount != 1 {
+		t.Fatalf("commentCount after delete = %d, want 1", fetched.CommentCount)
+	}
+
+	ts.mustDo(t, http.StatusNotFound, "PATCH", "/v1/comment/"+parent.ID.String(), reader, map[string]string{"text": "Back again."})
+	ts.mustDo(t, http.StatusNotFound, "DELETE", "/v1/comment/"+parent.ID.String(), reader, nil)
+	ts.mustDo(t, http.StatusUnprocessableEntity, "POST", comments, admin, map[string]any{"text": "A late reply.", "parentID": parent.ID})
+
+	// Once the reply is gone too, the thread disappears.
+	ts.mustDo(t, http.StatusOK, "DELETE", "/v1/comment/"+reply.ID.String(), admin, nil)
+
+	ts.mustDo(t, http.StatusOK, "GET", comments, reader, nil).decode(t, &listing)
+	if len(listing.Data) != 0 {
+		t.Fatalf("listing holds %d comments after deleting all, want 0", len(listing.Data))
+	}
+}
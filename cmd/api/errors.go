@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -20,6 +22,11 @@ func (app *application) errorMessage(w http.ResponseWriter, r *http.Request, sta
 }
 
 func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+		app.logger.Info("request cancelled: %s %s", r.Method, r.URL)
+		return
+	}
+
 	app.logger.Error(err)
 
 	message := "The server encountered a problem and could not process your request"
@@ -31,6 +38,11 @@ func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Requ
 	app.errorMessage(w, r, http.StatusConflict, message, nil)
 }
 
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record has changed since it was fetched, please fetch it again"
+	app.errorMessage(w, r, http.StatusPreconditionFailed, message, nil)
+}
+
 func (app *application) notFound(w http.ResponseWriter, r *http.Request) {
 	message := "The requested resource could not be found"
 	app.errorMessage(w, r, http.StatusNotFound, message, nil)
@@ -82,3 +94,8 @@ func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Requ
 	message := "your user account doesn't have the necessary permission to access this resource"
 	app.errorMessage(w, r, http.StatusForbidden, message, nil)
 }
+
+func (app *application) notSupported(w http.ResponseWriter, r *http.Request) {
+	message := "this feature is not supported by the configured database"
+	app.errorMessage(w, r, http.StatusNotImplemented, message, nil)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
+
+	"github.com/google/uuid"
+)
+
+// publish tells every instance about a write that already succeeded, so it
+// only logs failures. This instance's caches are invalidated right away, so
+// that the writer reads its own write; other instances follow as soon as the
+// event reaches them.
+func (app *application) publish(eventType string, id uuid.UUID) {
+	e := events.Event{Type: eventType, ID: id}
+
+	app.invalidateCaches(e)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := app.events.Publish(ctx, e)
+	if err != nil {
+		app.logger.Error(err)
+	}
+}
+
+// publishStateChange announces that a quote changed state and, when wasLive
+// is false and the new state made the quote live, that it went public.
+func (app *application) publishStateChange(quoteID uuid.UUID, wasLive bool) {
+	app.publish(events.QuoteStateChanged, quoteID)
+
+	if wasLive {
+		return
+	}
+
+	quote, err := app.db.GetQuoteById(context.Background(), quoteID)
+	if err != nil {
+		if !errors.Is(err, database.ErrRecordNotFound) {
+			app.logger.Error(err)
+		}
+		return
+	}
+
+	if quote.IsLive() {
+		app.publish(events.QuotePublished, quoteID)
+	}
+}
+
+// handleEvent keeps the caches of this instance in line with writes made by
+// the others.
+func (app *application) handleEvent(e events.Event) {
+	if e.Origin == app.events.Origin() && e.Type != events.Resync {
+		return
+	}
+
+	app.invalidateCaches(e)
+}
+
+func (app *application) invalidateCaches(e events.Event) {
+	switch e.Type {
+	case events.HashtagCreated, events.HashtagDeleted:
+		app.caches.hashtags.Purge()
+	case events.PhotoCreated:
+		app.caches.photos.Purge()
+	case events.StatesChanged:
+		app.caches.states.Purge()
+	case events.PermissionsChanged:
+		app.caches.permissions.Delete(e.ID)
+	case events.UserChanged:
+		app.caches.users.Delete(e.ID)
+	case events.Resync:
+		app.caches.purge()
+	}
+}
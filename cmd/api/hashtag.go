@@ -5,6 +5,7 @@ import (
 	"github.com/alexedwards/flow"
 	"github.com/google/uuid"
 	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
 	"javlonrahimov/quotes-api/internal/filters"
 	"javlonrahimov/quotes-api/internal/request"
 	"javlonrahimov/quotes-api/internal/response"
@@ -37,7 +38,7 @@ func (app *application) createHashtag(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hashtag, err := app.db.InsertHashtag(strings.ToLower(input.Value))
+	hashtag, err := app.db.InsertHashtag(r.Context(), strings.ToLower(input.Value))
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrDuplicateHashtag):
@@ -48,6 +49,7 @@ func (app *application) createHashtag(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	app.publish(events.HashtagCreated, hashtag.ID)
 
 	err = response.JSON(w, http.StatusOK, getWrapper(HashtagResponse{
 		ID:    hashtag.ID,
@@ -65,12 +67,12 @@ func (app *application) deleteHashtagById(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if exists := app.db.IsQuoteExistsWithThisHashtag(hashtagID); exists {
+	if exists := app.db.IsQuoteExistsWithThisHashtag(r.Context(), hashtagID); exists {
 		app.errorMessage(w, r, http.StatusBadRequest, "there are quotes with this hashtag", nil)
 		return
 	}
 
-	err = app.db.DeleteHashtagById(hashtagID)
+	err = app.db.DeleteHashtagById(r.Context(), hashtagID)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -81,6 +83,7 @@ func (app *application) deleteHashtagById(w http.ResponseWriter, r *http.Request
 			return
 		}
 	}
+	app.publish(events.HashtagDeleted, hashtagID)
 
 	err = response.JSON(w, http.StatusOK, getWrapper(map[string]interface{}{
 		"id": hashtagID,
@@ -97,7 +100,7 @@ func (app *application) getQuoteHashtags(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	data, metadata, err := app.db.GetQuoteHashtags(quoteID)
+	data, metadata, err := app.db.GetQuoteHashtags(r.Context(), quoteID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -111,7 +114,7 @@ func (app *application) getQuoteHashtags(w http.ResponseWriter, r *http.Request)
 		})
 	}
 
-	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": hashtags}))
+	err = response.JSONCached(w, r, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": hashtags}), response.Validators{Weak: true})
 	if err != nil {
 		app.serverError(w, r, err)
 	}
@@ -131,7 +134,7 @@ func (app *application) getHashtags(w http.ResponseWriter, r *http.Request) {
 
 	input.Filters.SortSafeList = []string{"value", "-value"}
 
-	data, metadata, err := app.db.GetHashtags(input.Filters)
+	data, metadata, err := app.hashtags(r.Context(), input.Filters)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -145,7 +148,7 @@ func (app *application) getHashtags(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": hashtags}))
+	err = response.JSONCached(w, r, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": hashtags}), response.Validators{Weak: true})
 	if err != nil {
 		app.serverError(w, r, err)
 	}
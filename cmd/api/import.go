@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"javlonrahimov/quotes-api/config"
+	"javlonrahimov/quotes-api/internal/importer"
+	"javlonrahimov/quotes-api/internal/leveledlog"
+)
+
+const importUsage = `usage: api [flags] import -user email [-format csv|json|ndjson] [-skip-invalid] [-max-rows N] <file|->
+
+Imports quotes created by the given user. The format defaults to the file
+extension; use - to read from stdin. Unlike POST /v1/quotes/import the state
+column is always honoured.`
+
+func runImport(cfg config.Config, logger *leveledlog.Logger, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	email := fs.String("user", "", "email of the user the quotes are created by")
+	format := fs.String("format", "", "input format: csv, json or ndjson")
+	skipInvalid := fs.Bool("skip-invalid", false, "import valid rows even when others are rejected")
+	maxRows := fs.Int("max-rows", 100_000, "maximum number of rows to read")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), importUsage)
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *email == "" {
+		return errors.New(importUsage)
+	}
+
+	name := fs.Arg(0)
+	if *format == "" {
+		*format = importer.FormatFromName(name)
+	}
+
+	var in io.Reader = os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	rows, err := importer.ReadAll(in, *format, *maxRows)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	user, err := db.GetUserByEmail(ctx, *email)
+	if err != nil {
+		return fmt.Errorf("user %s: %w", *email, err)
+	}
+
+	report, err := importer.Import(ctx, db, user.ID, rows, importer.Options{SkipInvalid: *skipInvalid, AllowState: true})
+	if err != nil {
+		return err
+	}
+
+	for _, row := range report.Rows {
+		switch row.Status {
+		case importer.StatusRejected:
+			fmt.Printf("row %d: rejected", row.Row)
+			for _, message := range row.Errors.Errors {
+				fmt.Printf(" %s;", message)
+			}
+			for _, fieldError := range row.Errors.FieldErrors {
+				fmt.Printf(" %s: %s;", fieldError.Field, fieldError.Error)
+			}
+			fmt.Println()
+		case importer.StatusDuplicate:
+			fmt.Printf("row %d: duplicate of %s\n", row.Row, row.QuoteID)
+		}
+	}
+
+	fmt.Printf("created: %d, duplicate: %d, rejected: %d\n", report.Created, report.Duplicate, report.Rejected)
+
+	if !report.Committed && report.Rejected > 0 {
+		return errors.New("import rejected, nothing was saved (use -skip-invalid to import the valid rows)")
+	}
+
+	return nil
+}
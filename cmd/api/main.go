@@ -1,22 +1,36 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"javlonrahimov/quotes-api/config"
 	"os"
 
+	"javlonrahimov/quotes-api/internal/bundle"
 	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/database/sqlite"
+	"javlonrahimov/quotes-api/internal/events"
 	"javlonrahimov/quotes-api/internal/leveledlog"
 	"javlonrahimov/quotes-api/internal/server"
 	"javlonrahimov/quotes-api/internal/smtp"
+	"javlonrahimov/quotes-api/internal/stream"
+	"javlonrahimov/quotes-api/internal/telegram"
 	"javlonrahimov/quotes-api/internal/version"
 )
 
 type application struct {
-	config config.Config
-	db     *database.DB
-	logger *leveledlog.Logger
-	mailer smtp.Mailer
+	config  config.Config
+	db      database.Store
+	logger  *leveledlog.Logger
+	mailer  smtp.Mailer
+	bundles *bundle.Cache
+	caches  *caches
+	events  *events.Bus
+	stream  *stream.Hub
+
+	// telegram queues quotes to be posted to the Telegram channel; it is
+	// nil unless posting is enabled.
+	telegram chan telegramJob
 }
 
 func main() {
@@ -29,7 +43,31 @@ func main() {
 
 	logger := leveledlog.NewLogger(os.Stdout, leveledlog.LevelAll, true)
 
-	db, err := database.New(cfg.DB.DSN, cfg.DB.Automigrate)
+	switch flag.Arg(0) {
+	case "migrate":
+		err := runMigrate(cfg, flag.Args()[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	case "import":
+		err := runImport(cfg, logger, flag.Args()[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	case "seed":
+		err := runSeed(cfg, logger, flag.Args()[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	db, err := openDB(cfg, logger)
 	if err != nil {
 		logger.Fatal(err)
 	}
@@ -43,19 +81,91 @@ func main() {
 		mailer = smtp.NewEmailSender(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.From)
 	}
 
+	bus, err := openEvents(cfg, db, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer bus.Close()
+
 	app := &application{
-		config: cfg,
-		db:     db,
-		logger: logger,
-		mailer: mailer,
+		config:  cfg,
+		db:      db,
+		logger:  logger,
+		mailer:  mailer,
+		bundles: bundle.NewCache(cfg.Bundle.MaxAge),
+		caches:  newCaches(cfg.Cache.TTL, cfg.Cache.Size),
+		events:  bus,
+		stream:  stream.NewHub(1000),
+	}
+
+	bus.Subscribe(app.handleEvent)
+
+	streamQueue := make(chan events.Event, 256)
+	bus.Subscribe(app.streamEvents(streamQueue))
+	go app.feedStream(streamQueue)
+
+	if store, ok := db.(database.WebhookStore); ok {
+		webhookQueue := make(chan events.Event, 256)
+		bus.Subscribe(app.webhookEvents(webhookQueue))
+		go app.enqueueWebhooks(store, webhookQueue)
+		go app.deliverWebhooks(store)
 	}
 
+	if cfg.TelegramPublisher.Enabled {
+		store, ok := db.(database.TelegramPostStore)
+		switch {
+		case !ok:
+			logger.Warning("telegram: posting needs a database that keeps a posting log, it is disabled")
+		case cfg.TelegramBot.BotToken == "" || cfg.TelegramPublisher.ChannelID == "":
+			logger.Warning("telegram: posting needs a bot token and a channel id, it is disabled")
+		default:
+			app.telegram = make(chan telegramJob, 256)
+			bus.Subscribe(app.telegramEvents(app.telegram))
+			go app.postToTelegram(store, telegram.NewClient(cfg.TelegramBot.BotToken), app.telegram)
+		}
+	}
+
+	go app.publishScheduledQuotes()
+
 	logger.Info("starting server on %s (version %s)", cfg.Addr, version.Get())
 
-	err = server.Run(cfg.Addr, app.routes(), cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	err = server.Run(cfg.Addr, app.routes(), cfg.TLS.CertFile, cfg.TLS.KeyFile, app.stream.Close)
 	if err != nil {
 		logger.Fatal(err)
 	}
 
 	logger.Info("server stopped")
 }
+
+type store interface {
+	database.Store
+	Close() error
+}
+
+func openDB(cfg config.Config, logger *leveledlog.Logger) (store, error) {
+	opts := database.Options{
+		ReadTimeout:        cfg.DB.ReadTimeout,
+		WriteTimeout:       cfg.DB.WriteTimeout,
+		QueryTimeouts:      cfg.DB.QueryTimeouts,
+		SlowQueryThreshold: cfg.DB.SlowQueryThreshold,
+	}
+
+	switch cfg.DB.Driver {
+	case "postgres":
+		return database.New(cfg.DB.DSN, cfg.DB.Automigrate, opts, logger)
+	case "sqlite":
+		return sqlite.New(cfg.DB.DSN, cfg.DB.Automigrate, opts, logger)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.DB.Driver)
+	}
+}
+
+// openEvents returns a bus shared by every instance on the same Postgres
+// database, or one local to this process on other drivers.
+func openEvents(cfg config.Config, db store, logger *leveledlog.Logger) (*events.Bus, error) {
+	if pg, ok := db.(*database.DB); ok {
+		return events.NewPostgres(pg.DB.DB, cfg.DB.DSN, logger)
+	}
+
+	return events.NewLocal(logger), nil
+}
@@ -65,7 +65,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 					return
 				}
 
-				user, err := app.db.GetUser(userID)
+				user, err := app.user(r.Context(), userID)
 				if err != nil {
 					app.serverError(w, r, err)
 					return
@@ -155,10 +155,11 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 			for i := range app.config.Cors.TrustedOrigins {
 				if origin == app.config.Cors.TrustedOrigins[i] {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 						w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, If-None-Match")
 						w.WriteHeader(http.StatusOK)
 						return
 					}
@@ -172,14 +173,13 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 
 func (app *application) requirePermission(code string, next http.HandlerFunc) http.Handler {
 	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user := contextGetAuthenticatedUser(r)
-		permissions, err := app.db.GetAllPermissionsForUser(user.ID)
+		permitted, err := app.hasPermission(r, code)
 		if err != nil {
 			app.serverError(w, r, err)
 			return
 		}
 
-		if !slices.Contains(permissions, code) {
+		if !permitted {
 			app.notPermittedResponse(w, r)
 			return
 		}
@@ -188,3 +188,54 @@ func (app *application) requirePermission(code string, next http.HandlerFunc) ht
 	})
 	return app.requireAuthenticatedUser(fn)
 }
+
+func (app *application) hasPermission(r *http.Request, code string) (bool, error) {
+	user := contextGetAuthenticatedUser(r)
+	if user == nil {
+		return false, nil
+	}
+
+	permissions, err := app.permissions(r.Context(), user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return slices.Contains(permissions, code), nil
+}
+
+// cacheControl sets the Cache-Control policy of a route. Error responses are
+// never stored, whatever the policy.
+func (app *application) cacheControl(policy string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&cacheControlWriter{ResponseWriter: w, policy: policy}, r)
+	})
+}
+
+type cacheControlWriter struct {
+	http.ResponseWriter
+	policy      string
+	wroteHeader bool
+}
+
+func (cw *cacheControlWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		if status >= http.StatusBadRequest {
+			cw.Header().Set("Cache-Control", "no-store")
+		} else {
+			cw.Header().Set("Cache-Control", cw.policy)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *cacheControlWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"javlonrahimov/quotes-api/config"
+	"javlonrahimov/quotes-api/internal/database/sqlite"
+	"javlonrahimov/quotes-api/internal/migrations"
+)
+
+const migrateUsage = `usage: api [flags] migrate <command> [-dry-run]
+
+commands:
+  up          apply all pending migrations
+  down N      roll back the last N migrations
+  goto V      migrate up or down to version V
+  status      show the current version and pending migrations
+  force V     mark version V as applied and clean without running anything`
+
+func runMigrate(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "list the migrations that would run without applying them")
+
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	command := args[0]
+
+	err := fs.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	var arg string
+	if fs.NArg() > 0 {
+		arg = fs.Arg(0)
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return err
+		}
+	}
+
+	migrator, err := openMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch command {
+	case "up":
+		if *dryRun {
+			plan, err := migrator.PlanUp()
+			if err != nil {
+				return err
+			}
+			printPlan("would apply", plan)
+			return nil
+		}
+		return reportVersion(migrator, migrator.Up())
+
+	case "down":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return fmt.Errorf("migrate down: expected a positive number of migrations, got %q", arg)
+		}
+		if *dryRun {
+			plan, err := migrator.PlanDown(n)
+			if err != nil {
+				return err
+			}
+			printPlan("would roll back", plan)
+			return nil
+		}
+		return reportVersion(migrator, migrator.Down(n))
+
+	case "goto":
+		version, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("migrate goto: expected a version, got %q", arg)
+		}
+		if *dryRun {
+			plan, err := migrator.PlanGoto(uint(version))
+			if err != nil {
+				return err
+			}
+			printPlan("would run", plan)
+			return nil
+		}
+		return reportVersion(migrator, migrator.Goto(uint(version)))
+
+	case "force":
+		version, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("migrate force: expected a version, got %q", arg)
+		}
+		return reportVersion(migrator, migrator.Force(version))
+
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("version: %d (latest %d)\n", status.Version, status.Latest)
+		fmt.Printf("dirty:   %t\n", status.Dirty)
+		if status.Version > status.Latest {
+			fmt.Println("the database schema is newer than this binary")
+		}
+		printPlan("pending", status.Pending)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n\n%s", command, migrateUsage)
+	}
+}
+
+func openMigrator(cfg config.Config) (*migrations.Migrator, error) {
+	switch cfg.DB.Driver {
+	case "postgres":
+		return migrations.Postgres(cfg.DB.DSN)
+	case "sqlite":
+		db, err := sqlite.Open(cfg.DB.DSN)
+		if err != nil {
+			return nil, err
+		}
+		return migrations.SQLite(db.DB)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.DB.Driver)
+	}
+}
+
+func printPlan(heading string, plan []migrations.Migration) {
+	if len(plan) == 0 {
+		fmt.Printf("%s: none\n", heading)
+		return
+	}
+
+	fmt.Printf("%s:\n", heading)
+	for _, migration := range plan {
+		fmt.Printf("  %s\n", migration)
+	}
+}
+
+func reportVersion(migrator *migrations.Migrator, err error) error {
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("version: %d (dirty: %t)\n", version, dirty)
+	return nil
+}
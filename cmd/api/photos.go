@@ -5,6 +5,7 @@ import (
 	"github.com/alexedwards/flow"
 	"github.com/google/uuid"
 	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
 	"javlonrahimov/quotes-api/internal/request"
 	"javlonrahimov/quotes-api/internal/response"
 	"javlonrahimov/quotes-api/internal/validator"
@@ -44,11 +45,12 @@ func (app *application) createPhoto(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	photo, err := app.db.InsertPhoto(*input.Color, *input.BlurHash, *input.Author, *input.Url)
+	photo, err := app.db.InsertPhoto(r.Context(), *input.Color, *input.BlurHash, *input.Author, *input.Url)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
+	app.publish(events.PhotoCreated, photo.ID)
 
 	data := PhotoResponse{
 		ID:       photo.ID,
@@ -71,7 +73,7 @@ func (app *application) getPhotoById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	photo, err := app.db.GetPhotoById(photoID)
+	photo, err := app.db.GetPhotoById(r.Context(), photoID)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -90,14 +92,14 @@ func (app *application) getPhotoById(w http.ResponseWriter, r *http.Request) {
 		Url:      photo.Url,
 	}
 
-	err = response.JSON(w, http.StatusOK, getWrapper(data))
+	err = response.JSONCached(w, r, http.StatusOK, getWrapper(data), response.Validators{})
 	if err != nil {
 		app.serverError(w, r, err)
 	}
 }
 
 func (app *application) getPhotos(w http.ResponseWriter, r *http.Request) {
-	photos, metadata, err := app.db.GetPhotos()
+	photos, metadata, err := app.photos(r.Context())
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -114,7 +116,7 @@ func (app *application) getPhotos(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}))
+	err = response.JSONCached(w, r, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}), response.Validators{Weak: true})
 	if err != nil {
 		app.serverError(w, r, err)
 	}
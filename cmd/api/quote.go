@@ -6,11 +6,13 @@ import (
 	"github.com/google/uuid"
 	"golang.org/x/exp/slices"
 	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
 	"javlonrahimov/quotes-api/internal/filters"
 	"javlonrahimov/quotes-api/internal/request"
 	"javlonrahimov/quotes-api/internal/response"
 	"javlonrahimov/quotes-api/internal/validator"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -18,12 +20,83 @@ type QuoteResponse struct {
 	ID        uuid.UUID         `json:"id,omitempty"`
 	State     StateResponse     `json:"state,omitempty"`
 	Author    string            `json:"author,omitempty"`
+	AuthorID  *uuid.UUID        `json:"authorID,omitempty"`
 	Text      string            `json:"text,omitempty"`
 	Hashtags  []HashtagResponse `json:"hashtags,omitempty"`
 	CreatedBy uuid.UUID         `json:"createdBy,omitempty"`
 	CreatedAt string            `json:"createdAt,omitempty"`
 	UpdatedAt string            `json:"updatedAt,omitempty"`
 	Photo     PhotoResponse     `json:"photo,omitempty"`
+
+	LikeCount     int   `json:"likeCount"`
+	FavoriteCount int   `json:"favoriteCount"`
+	CommentCount  int   `json:"commentCount"`
+	Liked         *bool `json:"liked,omitempty"`
+	Favorited     *bool `json:"favorited,omitempty"`
+
+	Verification string          `json:"verification"`
+	VerifiedAt   string          `json:"verifiedAt,omitempty"`
+	Source       *SourceResponse `json:"source,omitempty"`
+
+	Language      string     `json:"language,omitempty"`
+	OriginalID    *uuid.UUID `json:"originalID,omitempty"`
+	TranslationID *uuid.UUID `json:"translationID,omitempty"`
+
+	PublishAt string `json:"publishAt,omitempty"`
+	Scheduled bool   `json:"scheduled,omitempty"`
+}
+
+func newQuoteResponse(quote *database.Quote) QuoteResponse {
+	var hashtags []HashtagResponse
+	for _, hashtag := range quote.Hashtags {
+		hashtags = append(hashtags, HashtagResponse{
+			ID:    hashtag.ID,
+			Value: hashtag.Value,
+		})
+	}
+
+	data := QuoteResponse{
+		ID: quote.ID,
+		State: StateResponse{
+			ID:        quote.State.ID,
+			Value:     quote.State.Value,
+			IdDefault: quote.State.IsDefault,
+			Color:     quote.State.Color,
+			IsPublic:  quote.State.IsPublic,
+		},
+		Author:    quote.Author,
+		AuthorID:  quote.AuthorID,
+		Text:      quote.Text,
+		Hashtags:  hashtags,
+		CreatedBy: quote.CreatedBy,
+		CreatedAt: quote.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: quote.UpdatedAt.Format(time.RFC3339),
+		Photo: PhotoResponse{
+			ID:       quote.Photo.ID,
+			Color:    quote.Photo.Color,
+			BlurHash: quote.Photo.BlurHash,
+			Author:   quote.Photo.Author,
+			Url:      quote.Photo.Url,
+		},
+		LikeCount:     quote.LikeCount,
+		FavoriteCount: quote.FavoriteCount,
+		CommentCount:  quote.CommentCount,
+		Verification:  quote.Verification,
+		Source:        newSourceResponse(quote.Source),
+		Language:      quote.Language,
+		OriginalID:    quote.OriginalID,
+	}
+
+	if quote.VerifiedAt != nil {
+		data.VerifiedAt = quote.VerifiedAt.Format(time.RFC3339)
+	}
+
+	if quote.PublishAt != nil {
+		data.PublishAt = quote.PublishAt.UTC().Format(time.RFC3339)
+		data.Scheduled = quote.IsScheduled()
+	}
+
+	return data
 }
 
 func (app *application) createQuote(w http.ResponseWriter, r *http.Request) {
@@ -33,6 +106,7 @@ func (app *application) createQuote(w http.ResponseWriter, r *http.Request) {
 		PhotoID    *uuid.UUID          `json:"photoID,omitempty"`
 		HashtagIDs []uuid.UUID         `json:"hashtagIDs,omitempty"`
 		StateID    *uuid.UUID          `json:"stateID,omitempty"`
+		Language   string              `json:"language,omitempty"`
 		Validator  validator.Validator `json:"-"`
 	}
 
@@ -48,6 +122,11 @@ func (app *application) createQuote(w http.ResponseWriter, r *http.Request) {
 
 	input.Validator.CheckField(input.PhotoID != nil, "photoID", "required field")
 
+	input.Language = strings.ToLower(strings.TrimSpace(input.Language))
+	if input.Language != "" {
+		input.Validator.CheckField(validator.Matches(input.Language, validator.RgxLanguage), "language", "must be an ISO 639 language code")
+	}
+
 	if input.Validator.HasErrors() {
 		app.failedValidation(w, r, input.Validator)
 		return
@@ -63,7 +142,7 @@ func (app *application) createQuote(w http.ResponseWriter, r *http.Request) {
 		input.StateID = nil
 	}
 
-	quote, err := app.db.InsertQuote(*input.Author, *input.Text, user.ID, *input.PhotoID, input.HashtagIDs, input.StateID)
+	quote, err := app.db.InsertQuote(r.Context(), *input.Author, *input.Text, user.ID, *input.PhotoID, input.HashtagIDs, input.StateID)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -74,37 +153,18 @@ func (app *application) createQuote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var hashtags []HashtagResponse
-	for _, hashtag := range quote.Hashtags {
-		hashtags = append(hashtags, HashtagResponse{
-			ID:    hashtag.ID,
-			Value: hashtag.Value,
-		})
+	if input.Language != "" {
+		err = app.db.SetQuoteLanguage(r.Context(), quote.ID, input.Language)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		quote.Language = input.Language
 	}
 
-	data := QuoteResponse{
-		ID: quote.ID,
-		State: StateResponse{
-			ID:        quote.State.ID,
-			Value:     quote.State.Value,
-			IdDefault: quote.State.IsDefault,
-			Color:     quote.State.Color,
-			IsPublic:  quote.State.IsPublic,
-		},
-		Author:    quote.Author,
-		Text:      quote.Text,
-		Hashtags:  hashtags,
-		CreatedBy: quote.CreatedBy,
-		CreatedAt: quote.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: quote.UpdatedAt.Format(time.RFC3339),
-		Photo: PhotoResponse{
-			ID:       quote.Photo.ID,
-			Color:    quote.Photo.Color,
-			BlurHash: quote.Photo.BlurHash,
-			Author:   quote.Photo.Author,
-			Url:      quote.Photo.Url,
-		},
-	}
+	app.publish(events.QuoteCreated, quote.ID)
+
+	data := newQuoteResponse(quote)
 
 	err = response.JSON(w, http.StatusOK, getWrapper(data))
 	if err != nil {
@@ -150,7 +210,7 @@ func (app *application) updateQuote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	quote, err := app.db.GetQuoteById(quoteID)
+	quote, err := app.db.GetQuoteById(r.Context(), quoteID)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -162,7 +222,20 @@ func (app *application) updateQuote(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	quote, err = app.db.UpdateQuote(quote.ID, *input.PhotoID, *input.Author, *input.Text, input.HashtagIDs)
+	if r.Header.Get("If-Match") != "" {
+		match, err := app.quoteMatches(r, quote)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if !match {
+			app.preconditionFailedResponse(w, r)
+			return
+		}
+	}
+
+	quote, err = app.db.UpdateQuote(r.Context(), quote.ID, *input.PhotoID, *input.Author, *input.Text, input.HashtagIDs)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrEditConflict):
@@ -174,38 +247,17 @@ func (app *application) updateQuote(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var hashtags []HashtagResponse
-	for _, hashtag := range quote.Hashtags {
-		hashtags = append(hashtags, HashtagResponse{
-			ID:    hashtag.ID,
-			Value: hashtag.Value,
-		})
-	}
+	app.publish(events.QuoteUpdated, quote.ID)
 
-	data := QuoteResponse{
-		ID: quote.ID,
-		State: StateResponse{
-			ID:        quote.State.ID,
-			Value:     quote.State.Value,
-			IdDefault: quote.State.IsDefault,
-			IsPublic:  quote.State.IsPublic,
-		},
-		Author:    quote.Author,
-		Text:      quote.Text,
-		Hashtags:  hashtags,
-		CreatedBy: quote.CreatedBy,
-		CreatedAt: quote.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: quote.UpdatedAt.Format(time.RFC3339),
-		Photo: PhotoResponse{
-			ID:       quote.Photo.ID,
-			Color:    quote.Photo.Color,
-			BlurHash: quote.Photo.BlurHash,
-			Author:   quote.Photo.Author,
-			Url:      quote.Photo.Url,
-		},
+	data := []QuoteResponse{newQuoteResponse(quote)}
+
+	err = app.setReactions(r, data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
-	err = response.JSON(w, http.StatusOK, getWrapper(data))
+	err = response.JSON(w, http.StatusOK, getWrapper(data[0]))
 	if err != nil {
 		app.serverError(w, r, err)
 	}
@@ -218,7 +270,7 @@ func (app *application) getQuoteById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	quote, err := app.db.GetQuoteById(quoteID)
+	quote, err := app.db.GetQuoteById(r.Context(), quoteID)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -230,36 +282,47 @@ func (app *application) getQuoteById(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var hashtags []HashtagResponse
-	for _, hashtag := range quote.Hashtags {
-		hashtags = append(hashtags, HashtagResponse{
-			ID:    hashtag.ID,
-			Value: hashtag.Value,
-		})
+	body, err := app.quoteEntity(r, quote)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
-	data := QuoteResponse{
-		ID: quote.ID,
-		State: StateResponse{
-			ID:        quote.State.ID,
-			Value:     quote.State.Value,
-			IdDefault: quote.State.IsDefault,
-			Color:     quote.State.Color,
-			IsPublic:  quote.State.IsPublic,
-		},
-		Author:    quote.Author,
-		Text:      quote.Text,
-		Hashtags:  hashtags,
-		CreatedBy: quote.CreatedBy,
-		CreatedAt: quote.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: quote.UpdatedAt.Format(time.RFC3339),
+	// No Last-Modified: likes, comments and the viewer's own flags change the
+	// body without touching updated_at, so only the ETag can tell.
+	err = response.JSONCached(w, r, http.StatusOK, body, response.Validators{})
+	if err != nil {
+		app.serverError(w, r, err)
 	}
+}
 
-	err = response.JSON(w, http.StatusOK, getWrapper(data))
+// quoteEntity builds the body getQuoteById serves for quote. Its ETag is what
+// If-Match is checked against on writes.
+func (app *application) quoteEntity(r *http.Request, quote *database.Quote) (envelope, error) {
+	data := []QuoteResponse{newQuoteResponse(quote)}
+
+	err := app.setReactions(r, data)
 	if err != nil {
-		app.serverError(w, r, err)
+		return nil, err
 	}
 
+	return getWrapper(data[0]), nil
+}
+
+// quoteMatches reports whether the If-Match header of r, if any, names the
+// current ETag of quote.
+func (app *application) quoteMatches(r *http.Request, quote *database.Quote) (bool, error) {
+	body, err := app.quoteEntity(r, quote)
+	if err != nil {
+		return false, err
+	}
+
+	tag, err := response.ETag(body)
+	if err != nil {
+		return false, err
+	}
+
+	return request.IfMatch(r, tag), nil
 }
 
 func (app *application) deleteQuoteById(w http.ResponseWriter, r *http.Request) {
@@ -269,7 +332,31 @@ func (app *application) deleteQuoteById(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.db.DeleteQuoteById(quoteID)
+	if r.Header.Get("If-Match") != "" {
+		quote, err := app.db.GetQuoteById(r.Context(), quoteID)
+		if err != nil {
+			switch {
+			case errors.Is(err, database.ErrRecordNotFound):
+				app.notFound(w, r)
+			default:
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		match, err := app.quoteMatches(r, quote)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if !match {
+			app.preconditionFailedResponse(w, r)
+			return
+		}
+	}
+
+	err = app.db.DeleteQuoteById(r.Context(), quoteID)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -280,13 +367,16 @@ func (app *application) deleteQuoteById(w http.ResponseWriter, r *http.Request)
 			return
 		}
 	}
+
+	app.publish(events.QuoteDeleted, quoteID)
 }
 
 func (app *application) getUserQuotes(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Text   string
-		Author string
-		State  uuid.UUID
+		Text        string
+		Author      string
+		State       uuid.UUID
+		Attribution database.AttributionFilter
 		filters.Filters
 		Validator validator.Validator
 	}
@@ -300,55 +390,38 @@ func (app *application) getUserQuotes(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 	input.Author = request.ReadString(qs, "author", "")
 	input.Text = request.ReadString(qs, "text", "")
+	input.Attribution = readAttributionFilter(qs, &input.Validator)
 
 	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
 	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
 
 	input.Filters.Sort = request.ReadString(qs, "sort", "id")
 
-	input.Filters.SortSafeList = []string{"id", "text", "date", "-id", "-text", "-date"}
+	input.Filters.SortSafeList = []string{"id", "text", "date", "popularity", "-id", "-text", "-date", "-popularity"}
 
-	quotes, metadata, err := app.db.GetUserQuotes(userId, input.Author, input.Text, input.State, input.Filters)
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	quotes, metadata, err := app.db.GetUserQuotes(r.Context(), userId, input.Author, input.Text, input.State, input.Attribution, input.Filters)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
 	quotesResponse := make([]QuoteResponse, 0)
-	for _, quote := range quotes {
-		var hashtags []HashtagResponse
-		for _, hashtag := range quote.Hashtags {
-			hashtags = append(hashtags, HashtagResponse{
-				ID:    hashtag.ID,
-				Value: hashtag.Value,
-			})
-		}
-		quotesResponse = append(quotesResponse, QuoteResponse{
-			ID: quote.ID,
-			State: StateResponse{
-				ID:        quote.State.ID,
-				Value:     quote.State.Value,
-				IdDefault: quote.State.IsDefault,
-				Color:     quote.State.Color,
-				IsPublic:  quote.State.IsPublic,
-			},
-			Author:    quote.Author,
-			Text:      quote.Text,
-			Hashtags:  hashtags,
-			CreatedBy: quote.CreatedBy,
-			CreatedAt: quote.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: quote.UpdatedAt.Format(time.RFC3339),
-			Photo: PhotoResponse{
-				ID:       quote.Photo.ID,
-				Color:    quote.Photo.Color,
-				BlurHash: quote.Photo.BlurHash,
-				Author:   quote.Photo.Author,
-				Url:      quote.Photo.Url,
-			},
-		})
+	for i := range quotes {
+		quotesResponse = append(quotesResponse, newQuoteResponse(&quotes[i]))
 	}
 
-	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": quotesResponse}))
+	err = app.setReactions(r, quotesResponse)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSONCached(w, r, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": quotesResponse}), response.Validators{Weak: true})
 	if err != nil {
 		app.serverError(w, r, err)
 	}
@@ -356,9 +429,10 @@ func (app *application) getUserQuotes(w http.ResponseWriter, r *http.Request) {
 
 func (app *application) getQuotes(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Text    string
-		Author  string
-		StateId *uuid.UUID
+		Text        string
+		Author      string
+		StateId     *uuid.UUID
+		Attribution database.AttributionFilter
 		filters.Filters
 		Validator validator.Validator
 	}
@@ -366,6 +440,7 @@ func (app *application) getQuotes(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 	input.Author = request.ReadString(qs, "author", "")
 	input.Text = request.ReadString(qs, "text", "")
+	input.Attribution = readAttributionFilter(qs, &input.Validator)
 
 	stateIDStr := request.ReadString(qs, "stateId", "")
 	if stateIDStr != "" {
@@ -381,54 +456,39 @@ func (app *application) getQuotes(w http.ResponseWriter, r *http.Request) {
 
 	input.Filters.Sort = request.ReadString(qs, "sort", "created_at")
 
-	input.Filters.SortSafeList = []string{"text", "created_at", "-text", "-created_at"}
+	input.Filters.SortSafeList = []string{"text", "created_at", "popularity", "-text", "-created_at", "-popularity"}
 
 	if input.Validator.HasErrors() {
 		app.failedValidation(w, r, input.Validator)
 		return
 	}
 
-	quotes, metadata, err := app.db.GetQuotes(input.Author, input.Text, input.Filters)
+	quotes, metadata, err := app.db.GetQuotes(r.Context(), input.Author, input.Text, input.Attribution, input.Filters)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
 	quotesResponse := make([]QuoteResponse, 0)
-	for _, quote := range quotes {
-		var hashtags []HashtagResponse
-		for _, hashtag := range quote.Hashtags {
-			hashtags = append(hashtags, HashtagResponse{
-				ID:    hashtag.ID,
-				Value: hashtag.Value,
-			})
-		}
-		quotesResponse = append(quotesResponse, QuoteResponse{
-			ID: quote.ID,
-			State: StateResponse{
-				ID:        quote.State.ID,
-				Value:     quote.State.Value,
-				IdDefault: quote.State.IsDefault,
-				Color:     quote.State.Color,
-				IsPublic:  quote.State.IsPublic,
-			},
-			Author:    quote.Author,
-			Text:      quote.Text,
-			Hashtags:  hashtags,
-			CreatedBy: quote.CreatedBy,
-			CreatedAt: quote.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: quote.UpdatedAt.Format(time.RFC3339),
-			Photo: PhotoResponse{
-				ID:       quote.Photo.ID,
-				Color:    quote.Photo.Color,
-				BlurHash: quote.Photo.BlurHash,
-				Author:   quote.Photo.Author,
-				Url:      quote.Photo.Url,
-			},
-		})
+	for i := range quotes {
+		quotesResponse = append(quotesResponse, newQuoteResponse(&quotes[i]))
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+
+	err = app.translateQuotes(r, quotesResponse)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.setReactions(r, quotesResponse)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
-	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": quotesResponse}))
+	err = response.JSONCached(w, r, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": quotesResponse}), response.Validators{Weak: true})
 	if err != nil {
 		app.serverError(w, r, err)
 	}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	// Embed the time zone database so ?tz= works on hosts without one.
+	_ "time/tzdata"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/filters"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/validator"
+
+	"github.com/google/uuid"
+)
+
+const dateLayout = "2006-01-02"
+
+// earliestTimezone is the last place on Earth to reach a new day. Overrides
+// can only be scheduled for days that haven't ended there yet.
+var earliestTimezone = time.FixedZone("UTC-12", -12*60*60)
+
+type DailyQuoteResponse struct {
+	Date    string        `json:"date"`
+	Hashtag string        `json:"hashtag,omitempty"`
+	Quote   QuoteResponse `json:"quote"`
+}
+
+func (app *application) dailyQuoteStore(w http.ResponseWriter, r *http.Request) (database.DailyQuoteStore, bool) {
+	store, ok := app.db.(database.DailyQuoteStore)
+	if !ok {
+		app.notSupported(w, r)
+	}
+	return store, ok
+}
+
+// readDailyPool reads the tz and hashtag query parameters shared by the
+// daily quote endpoints.
+func (app *application) readDailyPool(r *http.Request, v *validator.Validator) (*time.Location, string) {
+	qs := r.URL.Query()
+
+	location, err := time.LoadLocation(request.ReadString(qs, "tz", "UTC"))
+	if err != nil {
+		v.AddFieldError("tz", "unknown time zone")
+	}
+
+	hashtag := normalizeHashtag(request.ReadString(qs, "hashtag", ""))
+	if hashtag != "" {
+		_, err := app.db.GetHashtagByValue(r.Context(), hashtag)
+		if err != nil {
+			v.AddFieldError("hashtag", "unknown hashtag")
+		}
+	}
+
+	return location, hashtag
+}
+
+func normalizeHashtag(value string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(value), "#"))
+}
+
+func (app *application) getDailyQuote(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.dailyQuoteStore(w, r)
+	if !ok {
+		return
+	}
+
+	var v validator.Validator
+
+	location, hashtag := app.readDailyPool(r, &v)
+	if v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	today := time.Now().In(location)
+
+	daily, err := store.GetDailyQuote(r.Context(), today, hashtag)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "there are no public quotes to choose from", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	quotes := []QuoteResponse{newQuoteResponse(&daily.Quote)}
+
+	w.Header().Add("Vary", "Accept-Language")
+
+	err = app.translateQuotes(r, quotes)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := DailyQuoteResponse{
+		Date:    daily.Day.Format(dateLayout),
+		Hashtag: daily.Hashtag,
+		Quote:   quotes[0],
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(data))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) getDailyQuoteHistory(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.dailyQuoteStore(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	location, hashtag := app.readDailyPool(r, &input.Validator)
+
+	qs := r.URL.Query()
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	history, metadata, err := store.GetDailyQuoteHistory(r.Context(), hashtag, time.Now().In(location), input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	quotes := make([]QuoteResponse, 0, len(history))
+	for i := range history {
+		quotes = append(quotes, newQuoteResponse(&history[i].Quote))
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+
+	err = app.translateQuotes(r, quotes)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := make([]DailyQuoteResponse, 0, len(history))
+	for i := range history {
+		data = append(data, DailyQuoteResponse{
+			Date:    history[i].Day.Format(dateLayout),
+			Hashtag: history[i].Hashtag,
+			Quote:   quotes[i],
+		})
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) setDailyQuoteOverride(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.dailyQuoteStore(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Date      string              `json:"date"`
+		QuoteID   *uuid.UUID          `json:"quoteID"`
+		Hashtag   string              `json:"hashtag"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	day, err := time.Parse(dateLayout, input.Date)
+	input.Validator.CheckField(err == nil, "date", "must be a date in YYYY-MM-DD format")
+	input.Validator.CheckField(err != nil || !day.Before(truncateDay(time.Now().In(earliestTimezone))), "date", "must not be in the past")
+	input.Validator.CheckField(input.QuoteID != nil, "quoteID", "required field")
+
+	input.Hashtag = normalizeHashtag(input.Hashtag)
+	if input.Hashtag != "" {
+		_, err := app.db.GetHashtagByValue(r.Context(), input.Hashtag)
+		input.Validator.CheckField(err == nil, "hashtag", "unknown hashtag")
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	quote, err := app.db.GetQuoteById(r.Context(), *input.QuoteID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	input.Validator.CheckField(quote.State.IsPublic, "quoteID", "quote is not public")
+	input.Validator.CheckField(quote.OriginalID == nil, "quoteID", "must be an original, not a translation")
+	if input.Hashtag != "" {
+		tagged := false
+		for _, hashtag := range quote.Hashtags {
+			tagged = tagged || hashtag.Value == input.Hashtag
+		}
+		input.Validator.CheckField(tagged, "quoteID", "quote doesn't have this hashtag")
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+
+	err = store.SetDailyQuoteOverride(r.Context(), day, input.Hashtag, quote.ID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := DailyQuoteResponse{
+		Date:    day.Format(dateLayout),
+		Hashtag: input.Hashtag,
+		Quote:   newQuoteResponse(quote),
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(data))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) deleteDailyQuoteOverride(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.dailyQuoteStore(w, r)
+	if !ok {
+		return
+	}
+
+	var v validator.Validator
+
+	qs := r.URL.Query()
+
+	day, err := time.Parse(dateLayout, request.ReadString(qs, "date", ""))
+	v.CheckField(err == nil, "date", "must be a date in YYYY-MM-DD format")
+	v.CheckField(err != nil || !day.Before(truncateDay(time.Now().In(earliestTimezone))), "date", "must not be in the past")
+
+	if v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	hashtag := normalizeHashtag(request.ReadString(qs, "hashtag", ""))
+
+	err = store.DeleteDailyQuoteOverride(r.Context(), day, hashtag)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"date": day.Format(dateLayout), "hashtag": hashtag}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// truncateDay returns midnight UTC of t's calendar date.
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/validator"
+	"javlonrahimov/quotes-api/internal/xlsx"
+)
+
+// exportFlushEvery is how many quotes are encoded between flushes to the
+// client.
+const exportFlushEvery = 100
+
+var exportColumns = []string{
+	"id", "author", "text", "state", "hashtags",
+	"photo_url", "photo_author", "photo_color", "photo_blur_hash",
+	"created_by", "created_at", "updated_at",
+}
+
+func (app *application) exportQuotes(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Format    string
+		Filter    database.QuoteFilter
+		Validator validator.Validator
+	}
+
+	qs := r.URL.Query()
+	input.Format = request.ReadString(qs, "format", "csv")
+	input.Filter.Author = request.ReadString(qs, "author", "")
+	input.Filter.Text = request.ReadString(qs, "text", "")
+	input.Filter.Sort = request.ReadString(qs, "sort", "created_at")
+
+	stateIDStr := request.ReadString(qs, "stateId", "")
+	if stateIDStr != "" {
+		stateID, err := uuid.Parse(stateIDStr)
+		if err != nil {
+			input.Validator.AddFieldError("stateId", "invalid state id")
+		}
+		input.Filter.StateID = &stateID
+	}
+
+	input.Validator.CheckField(validator.In(input.Format, "csv", "ndjson", "json", "xlsx"), "format", "must be csv, ndjson, json or xlsx")
+	input.Validator.CheckField(validator.In(input.Filter.Sort, "text", "created_at", "-text", "-created_at"), "sort", "invalid sort value")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+
+	permissions, err := app.permissions(r.Context(), user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	input.Filter.AnyState = slices.Contains(permissions, "quotes:state")
+
+	// The export format has no column tying a translation to its original,
+	// so importing one would make it a quote of its own.
+	input.Filter.Originals = true
+
+	var contentType string
+	switch input.Format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+	case "ndjson":
+		contentType = "application/x-ndjson"
+	case "json":
+		contentType = "application/json"
+	case "xlsx":
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+
+	filename := fmt.Sprintf("quotes-%s.%s", time.Now().UTC().Format("20060102"), input.Format)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	// Exports outlive the server's write timeout; the request context still
+	// ends them when the client goes away or the server shuts down.
+	err = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	sent := &countingWriter{w: w}
+	var out io.Writer = sent
+
+	// xlsx files are zip archives already, compressing them again is wasted work.
+	var gz *gzip.Writer
+	if input.Format != "xlsx" && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(sent)
+		out = gz
+	}
+
+	encoder, err := newQuoteEncoder(input.Format, out)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	flush := func() error {
+		if err := encoder.flush(); err != nil {
+			return err
+		}
+		if gz != nil {
+			if err := gz.Flush(); err != nil {
+				return err
+			}
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	count := 0
+	err = app.db.ExportQuotes(r.Context(), input.Filter, func(quote *database.Quote) error {
+		if err := encoder.encode(quote); err != nil {
+			return err
+		}
+
+		count++
+		if count%exportFlushEvery == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = encoder.close()
+	}
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+
+	if err != nil {
+		if sent.n == 0 {
+			w.Header().Del("Content-Disposition")
+			w.Header().Del("Content-Encoding")
+			app.serverError(w, r, err)
+			return
+		}
+
+		// The status line has gone out, all we can do is cut the download
+		// short and leave a trace in the logs.
+		app.logger.Warning("quote export aborted after %d quotes: %s", count, err)
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+type quoteEncoder interface {
+	encode(quote *database.Quote) error
+	flush() error
+	close() error
+}
+
+func newQuoteEncoder(format string, w io.Writer) (quoteEncoder, error) {
+	switch format {
+	case "csv":
+		enc := &csvQuoteEncoder{w: csv.NewWriter(w)}
+		return enc, enc.w.Write(exportColumns)
+	case "xlsx":
+		xw, err := xlsx.NewWriter(w, "quotes")
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxQuoteEncoder{w: xw}, xw.WriteRow(exportColumns...)
+	case "json":
+		_, err := io.WriteString(w, "[")
+		return &jsonQuoteEncoder{w: w, enc: json.NewEncoder(w), array: true}, err
+	default:
+		return &jsonQuoteEncoder{w: w, enc: json.NewEncoder(w)}, nil
+	}
+}
+
+func exportRecord(quote *database.Quote) []string {
+	var hashtags []string
+	for _, hashtag := range quote.Hashtags {
+		hashtags = append(hashtags, hashtag.Value)
+	}
+
+	return []string{
+		quote.ID.String(),
+		quote.Author,
+		quote.Text,
+		quote.State.Value,
+		strings.Join(hashtags, ";"),
+		quote.Photo.Url,
+		quote.Photo.Author,
+		quote.Photo.Color,
+		quote.Photo.BlurHash,
+		quote.CreatedBy.String(),
+		quote.CreatedAt.Format(time.RFC3339),
+		quote.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+type csvQuoteEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvQuoteEncoder) encode(quote *database.Quote) error {
+	return e.w.Write(exportRecord(quote))
+}
+
+func (e *csvQuoteEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvQuoteEncoder) close() error {
+	return e.flush()
+}
+
+type xlsxQuoteEncoder struct {
+	w *xlsx.Writer
+}
+
+func (e *xlsxQuoteEncoder) encode(quote *database.Quote) error {
+	return e.w.WriteRow(exportRecord(quote)...)
+}
+
+func (e *xlsxQuoteEncoder) flush() error {
+	return e.w.Flush()
+}
+
+func (e *xlsxQuoteEncoder) close() error {
+	return e.w.Close()
+}
+
+// jsonQuoteEncoder writes one QuoteResponse per line, wrapped in a JSON array
+// when array is set.
+type jsonQuoteEncoder struct {
+	w     io.Writer
+	enc   *json.Encoder
+	array bool
+	n     int
+}
+
+func (e *jsonQuoteEncoder) encode(quote *database.Quote) error {
+	if e.array && e.n > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.n++
+
+	return e.enc.Encode(newQuoteResponse(quote))
+}
+
+func (e *jsonQuoteEncoder) flush() error {
+	return nil
+}
+
+func (e *jsonQuoteEncoder) close() error {
+	if !e.array {
+		return nil
+	}
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
+	"javlonrahimov/quotes-api/internal/importer"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/validator"
+)
+
+const (
+	importMaxBytes = 10 << 20
+	importMaxRows  = 5000
+)
+
+func (app *application) importQuotes(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+
+	qs := r.URL.Query()
+
+	format := request.ReadString(qs, "format", importer.FormatFromContentType(r.Header.Get("Content-Type")))
+	v.CheckField(validator.In(format, importer.FormatCSV, importer.FormatJSON, importer.FormatNDJSON), "format", "must be csv, json or ndjson, or set a matching Content-Type")
+
+	skipInvalid, err := strconv.ParseBool(request.ReadString(qs, "skipInvalid", "false"))
+	v.CheckField(err == nil, "skipInvalid", "must be a boolean value")
+
+	if v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes)
+
+	rows, err := importer.ReadAll(r.Body, format, importMaxRows)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.badRequest(w, r, fmt.Errorf("body must not be larger than %d bytes", importMaxBytes))
+		default:
+			app.badRequest(w, r, err)
+		}
+		return
+	}
+
+	opts := importer.Options{
+		SkipInvalid: skipInvalid,
+		AllowState:  slices.Contains(app.config.Sudoers, user.Email),
+	}
+
+	report, err := importer.Import(r.Context(), app.db, user.ID, rows, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrDefaultState):
+			app.errorMessage(w, r, http.StatusConflict, "no default quote state is set", nil)
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusConflict, "the import refers to a state that no longer exists, please retry", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if report.Committed {
+		app.publish(events.HashtagCreated, uuid.Nil)
+		app.publish(events.PhotoCreated, uuid.Nil)
+
+		// Each new quote gets its own event, so that the live feed, webhooks
+		// and the Telegram channel treat it like one created by hand.
+		for _, row := range report.Rows {
+			if row.Status == importer.StatusCreated {
+				app.publish(events.QuoteCreated, *row.QuoteID)
+			}
+		}
+	}
+
+	if !report.Committed && report.Rejected > 0 {
+		err = response.JSON(w, http.StatusUnprocessableEntity, getWrapper(report, "import rejected, nothing was saved"))
+	} else {
+		err = response.JSON(w, http.StatusOK, getWrapper(report))
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
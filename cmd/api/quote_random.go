@@ -0,0 +1,158 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/validator"
+
+	"github.com/google/uuid"
+)
+
+const (
+	randomMaxCount   = 50
+	randomMaxExclude = 200
+
+	// randomPoolFactor is how many candidates are drawn per requested quote
+	// so that weighting has something to choose from.
+	randomPoolFactor  = 8
+	randomMinPoolSize = 32
+)
+
+func (app *application) getRandomQuotes(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Count     int
+		Hashtag   string
+		Exclude   []uuid.UUID
+		Weight    string
+		Validator validator.Validator
+	}
+
+	qs := r.URL.Query()
+	input.Count = request.ReadInt(qs, "count", 1, &input.Validator)
+	input.Hashtag = normalizeHashtag(request.ReadString(qs, "hashtag", ""))
+	input.Weight = request.ReadString(qs, "weight", "none")
+
+	for _, value := range strings.Split(request.ReadString(qs, "exclude", ""), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+
+		id, err := uuid.Parse(value)
+		if err != nil {
+			input.Validator.AddFieldError("exclude", "must be a comma-separated list of quote ids")
+			break
+		}
+		input.Exclude = append(input.Exclude, id)
+	}
+
+	input.Validator.CheckField(validator.Between(input.Count, 1, randomMaxCount), "count", "must be between 1 and 50")
+	input.Validator.CheckField(len(input.Exclude) <= randomMaxExclude, "exclude", "must not contain more than 200 ids")
+	input.Validator.CheckField(validator.In(input.Weight, "none", "recency", "popularity"), "weight", "must be none, recency or popularity")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	size := input.Count * randomPoolFactor
+	if size < randomMinPoolSize {
+		size = randomMinPoolSize
+	}
+
+	candidates, err := app.db.SampleQuotes(r.Context(), database.SampleFilter{
+		Size:    size,
+		Hashtag: input.Hashtag,
+		Exclude: input.Exclude,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var weight func(quote *database.Quote) float64
+	switch input.Weight {
+	case "recency":
+		now := time.Now()
+		weight = func(quote *database.Quote) float64 {
+			// A month-old quote weighs half as much as a new one, a
+			// two-month-old one a third, so old quotes still turn up.
+			age := now.Sub(quote.CreatedAt).Hours() / 24
+			return 1 / (1 + age/30)
+		}
+	case "popularity":
+		weight = func(quote *database.Quote) float64 {
+			// Likes count logarithmically, so a handful of popular quotes
+			// don't crowd out everything else; unliked quotes keep a weight
+			// of one.
+			return 1 + math.Log1p(float64(quote.LikeCount))
+		}
+	default:
+		weight = func(*database.Quote) float64 { return 1 }
+	}
+
+	picked := pickWeighted(candidates, input.Count, weight)
+
+	data := make([]QuoteResponse, 0, len(picked))
+	for i := range picked {
+		hashtags, _, err := app.db.GetQuoteHashtags(r.Context(), picked[i].ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		picked[i].Hashtags = hashtags
+
+		data = append(data, newQuoteResponse(&picked[i]))
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+
+	err = app.translateQuotes(r, data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(data))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// pickWeighted draws up to n quotes without replacement, each with a chance
+// proportional to its weight (Efraimidis-Spirakis): every quote gets the key
+// u^(1/weight) for a uniform u and the n largest keys win.
+func pickWeighted(quotes []database.Quote, n int, weight func(*database.Quote) float64) []database.Quote {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	keys := make([]float64, len(quotes))
+	for i := range quotes {
+		keys[i] = math.Pow(rng.Float64(), 1/weight(&quotes[i]))
+	}
+
+	order := make([]int, len(quotes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return keys[order[a]] > keys[order[b]]
+	})
+
+	if len(order) > n {
+		order = order[:n]
+	}
+
+	picked := make([]database.Quote, 0, len(order))
+	for _, i := range order {
+		picked = append(picked, quotes[i])
+	}
+
+	return picked
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"javlonrahimov/quotes-api/internal/database"
+
+	"github.com/google/uuid"
+)
+
+func TestRandomQuotes(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	_, admin := ts.registerUser(t, testSudoer)
+	public := ts.publicState(t, admin)
+
+	for _, text := range []string{"The first random quote.", "The second random quote.", "The third random quote."} {
+		quote := ts.testQuote(t, admin, text)
+		ts.mustDo(t, http.StatusOK, "PATCH", "/v1/quote/set-state", admin, map[string]string{"id": quote.ID.String(), "stateId": public})
+	}
+
+	for _, weight := range []string{"none", "recency", "popularity"} {
+		var quotes []QuoteResponse
+		ts.mustDo(t, http.StatusOK, "GET", "/v1/quotes/random?count=2&weight="+weight, admin, nil).decode(t, &quotes)
+
+		if len(quotes) != 2 || quotes[0].ID == quotes[1].ID {
+			t.Fatalf("weight=%s: got %d quotes, want 2 distinct ones", weight, len(quotes))
+		}
+	}
+
+	ts.mustDo(t, http.StatusUnprocessableEntity, "GET", "/v1/quotes/random?weight=length", admin, nil)
+}
+
+func TestPickWeighted(t *testing.T) {
+	quotes := make([]database.Quote, 10)
+	for i := range quotes {
+		quotes[i].ID = uuid.New()
+	}
+
+	// Only the first quote has any weight to speak of, so it is all but
+	// certain to be the one picked.
+	weight := func(quote *database.Quote) float64 {
+		if quote.ID == quotes[0].ID {
+			return 1e6
+		}
+		return 1e-6
+	}
+
+	for i := 0; i < 20; i++ {
+		picked := pickWeighted(quotes, 1, weight)
+		if len(picked) != 1 || picked[0].ID != quotes[0].ID {
+			t.Fatalf("pickWeighted = %v, want the heavy quote", picked)
+		}
+	}
+
+	if picked := pickWeighted(quotes, 20, weight); len(picked) != len(quotes) {
+		t.Fatalf("pickWeighted of 20 out of %d = %d quotes, want all", len(quotes), len(picked))
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/filters"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/validator"
+
+	"github.com/alexedwards/flow"
+	"github.com/google/uuid"
+)
+
+func (app *application) likeQuote(w http.ResponseWriter, r *http.Request) {
+	app.setQuoteReaction(w, r, "like", true)
+}
+
+func (app *application) unlikeQuote(w http.ResponseWriter, r *http.Request) {
+	app.setQuoteReaction(w, r, "like", false)
+}
+
+func (app *application) favoriteQuote(w http.ResponseWriter, r *http.Request) {
+	app.setQuoteReaction(w, r, "favorite", true)
+}
+
+func (app *application) unfavoriteQuote(w http.ResponseWriter, r *http.Request) {
+	app.setQuoteReaction(w, r, "favorite", false)
+}
+
+// setQuoteReaction is idempotent: liking a quote twice or removing a like
+// that isn't there leaves the count alone.
+func (app *application) setQuoteReaction(w http.ResponseWriter, r *http.Request, kind string, on bool) {
+	quoteID, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+
+	var count int
+	data := envelope{"quoteID": quoteID}
+
+	switch kind {
+	case "like":
+		count, err = app.db.SetQuoteLiked(r.Context(), user.ID, quoteID, on)
+		data["liked"], data["likeCount"] = on, count
+	default:
+		count, err = app.db.SetQuoteFavorited(r.Context(), user.ID, quoteID, on)
+		data["favorited"], data["favoriteCount"] = on, count
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(data))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) getFavoriteQuotes(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	qs := r.URL.Query()
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+
+	input.Filters.Sort = "favorited_at"
+	input.Filters.SortSafeList = []string{"favorited_at"}
+
+	if filters.ValidateFilters(&input.Validator, input.Filters); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+
+	quotes, metadata, err := app.db.GetFavoriteQuotes(r.Context(), user.ID, input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	quotesResponse := make([]QuoteResponse, 0)
+	for i := range quotes {
+		quotesResponse = append(quotesResponse, newQuoteResponse(&quotes[i]))
+	}
+
+	err = app.setReactions(r, quotesResponse)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": quotesResponse}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// setReactions fills in whether the authenticated user has liked and
+// favorited each of quotes.
+func (app *application) setReactions(r *http.Request, quotes []QuoteResponse) error {
+	user := contextGetAuthenticatedUser(r)
+	if user == nil || len(quotes) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(quotes))
+	for i := range quotes {
+		ids[i] = quotes[i].ID
+	}
+
+	reactions, err := app.db.GetQuoteReactions(r.Context(), user.ID, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range quotes {
+		reaction := reactions[quotes[i].ID]
+		quotes[i].Liked = &reaction.Liked
+		quotes[i].Favorited = &reaction.Favorited
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLikeCounts(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	_, admin := ts.registerUser(t, testSudoer)
+	_, reader := ts.registerUser(t, "reader@example.org")
+
+	quote := ts.testQuote(t, admin, "Waste no more time arguing what a good man should be.")
+	path := "/v1/quote/" + quote.ID.String()
+
+	ts.mustDo(t, http.StatusNotFound, "POST", path+"/like", reader, nil)
+
+	ts.mustDo(t, http.StatusOK, "PATCH", "/v1/quote/set-state", admin, map[string]string{
+		"id":      quote.ID.String(),
+		"stateId": ts.publicState(t, admin),
+	})
+
+	// Both users like the quote many times over at once; each like counts
+	// only once.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, token := range []string{admin, reader} {
+			wg.Add(1)
+			go func(token string) {
+				defer wg.Done()
+
+				res := ts.do(t, "POST", path+"/like", token, nil)
+				if res.status != http.StatusOK {
+					t.Errorf("like: got status %d, want %d", res.status, http.StatusOK)
+				}
+			}(token)
+		}
+	}
+	wg.Wait()
+
+	var fetched QuoteResponse
+	ts.mustDo(t, http.StatusOK, "GET", path, reader, nil).decode(t, &fetched)
+	if fetched.LikeCount != 2 || fetched.Liked == nil || !*fetched.Liked {
+		t.Fatalf("after liking: likeCount = %d, liked = %v, want 2 and true", fetched.LikeCount, fetched.Liked)
+	}
+
+	var reaction struct {
+		Liked     bool `json:"liked"`
+		LikeCount int  `json:"likeCount"`
+	}
+	for i := 0; i < 2; i++ {
+		ts.mustDo(t, http.StatusOK, "DELETE", path+"/like", reader, nil).decode(t, &reaction)
+		if reaction.Liked || reaction.LikeCount != 1 {
+			t.Fatalf("unlike %d: got %+v, want not liked and a count of 1", i+1, reaction)
+		}
+	}
+
+	ts.mustDo(t, http.StatusOK, "GET", path, reader, nil).decode(t, &fetched)
+	if fetched.LikeCount != 1 || fetched.Liked == nil || *fetched.Liked {
+		t.Fatalf("after unliking: likeCount = %d, liked = %v, want 1 and false", fetched.LikeCount, fetched.Liked)
+	}
+}
+
+func TestQuoteRevalidationAfterLike(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	_, admin := ts.registerUser(t, testSudoer)
+	_, reader := ts.registerUser(t, "reader@example.org")
+
+	quote := ts.publishedQuote(t, admin, "The impediment to action advances action.")
+	path := "/v1/quote/" + quote.ID.String()
+
+	res := ts.mustDo(t, http.StatusOK, "GET", path, reader, nil)
+	tag := res.header.Get("ETag")
+	if tag == "" || res.header.Get("Last-Modified") != "" {
+		t.Fatalf("validators: ETag %q, Last-Modified %q, want only an ETag", tag, res.header.Get("Last-Modified"))
+	}
+
+	ts.mustDo(t, http.StatusNotModified, "GET", path, reader, nil, "If-None-Match", tag)
+
+	// A like leaves updated_at alone but changes the body, so neither
+	// validator may claim the client's copy is still fresh.
+	ts.mustDo(t, http.StatusOK, "POST", path+"/like", reader, nil)
+
+	since := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	ts.mustDo(t, http.StatusOK, "GET", path, reader, nil, "If-None-Match", tag)
+	ts.mustDo(t, http.StatusOK, "GET", path, reader, nil, "If-Modified-Since", since)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
+	"javlonrahimov/quotes-api/internal/filters"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/smtp"
+	"javlonrahimov/quotes-api/internal/validator"
+
+	"github.com/alexedwards/flow"
+	"github.com/google/uuid"
+)
+
+// scheduleQuote sets or moves the time a quote goes public. The quote still
+// needs a public state to show up once the time has come.
+func (app *application) scheduleQuote(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PublishAt *time.Time          `json:"publishAt"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	quoteID, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		return
+	}
+
+	err = request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Validator.CheckField(input.PublishAt != nil, "publishAt", "must be provided")
+	input.Validator.CheckField(input.PublishAt == nil || input.PublishAt.After(time.Now()), "publishAt", "must be in the future")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	err = app.db.ScheduleQuote(r.Context(), quoteID, input.PublishAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.publish(events.QuoteUpdated, quoteID)
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"quoteID": quoteID, "publishAt": input.PublishAt.UTC().Format(time.RFC3339)}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// unscheduleQuote drops the publish time of a quote, so that it is public
+// right away if its state is.
+func (app *application) unscheduleQuote(w http.ResponseWriter, r *http.Request) {
+	quoteID, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		return
+	}
+
+	quote, err := app.db.GetQuoteById(r.Context(), quoteID)
+	if err == nil {
+		err = app.db.ScheduleQuote(r.Context(), quoteID, nil)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Only a public quote that was still waiting goes live here.
+	if quote.State.IsPublic && quote.IsScheduled() {
+		app.publish(events.QuotePublished, quoteID)
+	} else {
+		app.publish(events.QuoteUpdated, quoteID)
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"quoteID": quoteID}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) getScheduledQuotes(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	qs := r.URL.Query()
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+
+	input.Filters.Sort = "publish_at"
+	input.Filters.SortSafeList = []string{"publish_at"}
+
+	if filters.ValidateFilters(&input.Validator, input.Filters); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	quotes, metadata, err := app.db.GetScheduledQuotes(r.Context(), input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := make([]QuoteResponse, 0, len(quotes))
+	for i := range quotes {
+		data = append(data, newQuoteResponse(&quotes[i]))
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// publishScheduledQuotes periodically marks the scheduled quotes whose time
+// has come as published and emails their creators. Visibility doesn't wait
+// for it: public reads compare the publish time themselves.
+func (app *application) publishScheduledQuotes() {
+	if app.config.Scheduler.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(app.config.Scheduler.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.publishDueQuotes()
+	}
+}
+
+func (app *application) publishDueQuotes() {
+	quotes, err := app.db.PublishDueQuotes(context.Background())
+	if err != nil {
+		app.logger.Error(err)
+		return
+	}
+
+	for i := range quotes {
+		app.publish(events.QuotePublished, quotes[i].ID)
+		app.notifyQuotePublished(&quotes[i])
+	}
+}
+
+// notifyQuotePublished emails the creator of a quote that went live. The
+// Telegram sender only relays OTPs and cannot render the email, so with it
+// the notification is skipped rather than posting the creator's address to
+// the channel.
+func (app *application) notifyQuotePublished(quote *database.Quote) {
+	app.logger.Info("quote %s went live", quote.ID)
+
+	if _, ok := app.mailer.(*smtp.TelegramSender); ok {
+		return
+	}
+
+	user, err := app.db.GetUser(context.Background(), quote.CreatedBy)
+	if err != nil {
+		app.logger.Error(err)
+		return
+	}
+	if user == nil {
+		return
+	}
+
+	go func() {
+		err := app.mailer.Send(user.Email, "", map[string]interface{}{
+			"UserName":        user.Name,
+			"ApplicationName": "Quotes",
+			"Author":          quote.Author,
+			"Text":            quote.Text,
+		}, "quote_published.tmpl")
+		if err != nil {
+			app.logger.Error(err)
+		}
+	}()
+}
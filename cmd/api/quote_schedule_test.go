@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/events"
+
+	"github.com/google/uuid"
+)
+
+func TestScheduledPublishing(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	var mu sync.Mutex
+	var seen []events.Event
+	ts.app.events.Subscribe(func(e events.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, e)
+	})
+
+	emitted := func(eventType string, id uuid.UUID) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, e := range seen {
+			if e.Type == eventType && e.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	_, admin := ts.registerUser(t, testSudoer)
+	_, reader := ts.registerUser(t, "reader@example.org")
+
+	public := ts.publicState(t, admin)
+	quote := ts.testQuote(t, admin, "Very little is needed to make a happy life.")
+
+	// A failed state change leaves the quote unscheduled.
+	publishAt := time.Now().Add(300 * time.Millisecond)
+	ts.mustDo(t, http.StatusNotFound, "PATCH", "/v1/quote/set-state", admin, map[string]any{
+		"id":        quote.ID,
+		"stateId":   uuid.New(),
+		"publishAt": publishAt,
+	})
+
+	var scheduled struct {
+		Data []QuoteResponse `json:"data"`
+	}
+	ts.mustDo(t, http.StatusOK, "GET", "/v1/quotes/scheduled", admin, nil).decode(t, &scheduled)
+	if len(scheduled.Data) != 0 {
+		t.Fatalf("scheduled list holds %d quotes after a failed state change, want 0", len(scheduled.Data))
+	}
+
+	ts.mustDo(t, http.StatusOK, "PATCH", "/v1/quote/set-state", admin, map[string]any{
+		"id":        quote.ID,
+		"stateId":   public,
+		"publishAt": publishAt,
+	})
+
+	var listing struct {
+		Data []QuoteResponse `json:"data"`
+	}
+	ts.mustDo(t, http.StatusOK, "GET", "/v1/quotes", reader, nil).decode(t, &listing)
+	if len(listing.Data) != 0 {
+		t.Fatalf("public listing holds %d quotes before the publish time, want 0", len(listing.Data))
+	}
+
+	ts.mustDo(t, http.StatusOK, "GET", "/v1/quotes/scheduled", admin, nil).decode(t, &scheduled)
+	if len(scheduled.Data) != 1 || scheduled.Data[0].ID != quote.ID {
+		t.Fatalf("scheduled list = %+v, want the scheduled quote", scheduled.Data)
+	}
+
+	// Before its time the worker leaves the quote alone.
+	ts.app.publishDueQuotes()
+
+	ts.mustDo(t, http.StatusOK, "GET", "/v1/quotes/scheduled", admin, nil).decode(t, &scheduled)
+	if len(scheduled.Data) != 1 {
+		t.Fatalf("scheduled list holds %d quotes before the publish time, want 1", len(scheduled.Data))
+	}
+
+	time.Sleep(time.Until(publishAt))
+	ts.app.publishDueQuotes()
+
+	ts.mustDo(t, http.StatusOK, "GET", "/v1/quotes/scheduled", admin, nil).decode(t, &scheduled)
+	if len(scheduled.Data) != 0 {
+		t.Fatalf("scheduled list holds %d quotes after publishing, want 0", len(scheduled.Data))
+	}
+
+	ts.mustDo(t, http.StatusOK, "GET", "/v1/quotes", reader, nil).decode(t, &listing)
+	if len(listing.Data) != 1 || listing.Data[0].ID != quote.ID {
+		t.Fatalf("public listing = %+v, want the published quote", listing.Data)
+	}
+
+	select {
+	case <-ts.app.mailer.(*testMailer).inbox(testSudoer):
+	case <-time.After(5 * time.Second):
+		t.Fatal("the creator was not notified of the published quote")
+	}
+
+	waitFor(t, "the published event", func() bool {
+		return emitted(events.QuotePublished, quote.ID)
+	})
+
+	// Unscheduling a quote that is not public publishes nothing.
+	pending := ts.testQuote(t, admin, "Luck is what happens when preparation meets opportunity.")
+	ts.mustDo(t, http.StatusOK, "DELETE", "/v1/quote/"+pending.ID.String()+"/schedule", admin, nil)
+
+	waitFor(t, "the update event", func() bool {
+		return emitted(events.QuoteUpdated, pending.ID)
+	})
+	if emitted(events.QuotePublished, pending.ID) {
+		t.Fatal("unscheduling a pending quote emitted a published event")
+	}
+}
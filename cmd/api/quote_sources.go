@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/validator"
+
+	"github.com/alexedwards/flow"
+	"github.com/google/uuid"
+)
+
+type SourceResponse struct {
+	Type     string `json:"type"`
+	Title    string `json:"title,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Location string `json:"location,omitempty"`
+	Year     *int   `json:"year,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+func newSourceResponse(source database.QuoteSource) *SourceResponse {
+	if source.Type == "" {
+		return nil
+	}
+
+	return &SourceResponse{
+		Type:     source.Type,
+		Title:    source.Title,
+		URL:      source.URL,
+		Location: source.Location,
+		Year:     source.Year,
+		Language: source.Language,
+	}
+}
+
+// readAttributionFilter reads the verification and sourceType parameters of
+// a quote listing.
+func readAttributionFilter(qs url.Values, v *validator.Validator) database.AttributionFilter {
+	filter := database.AttributionFilter{
+		Verification: request.ReadString(qs, "verification", ""),
+		SourceType:   request.ReadString(qs, "sourceType", ""),
+	}
+
+	if filter.Verification != "" {
+		v.CheckField(validator.In(filter.Verification, database.VerificationStatuses...), "verification", "must be one of "+strings.Join(database.VerificationStatuses, ", "))
+	}
+	if filter.SourceType != "" {
+		v.CheckField(validator.In(filter.SourceType, database.SourceTypes...), "sourceType", "must be one of "+strings.Join(database.SourceTypes, ", "))
+	}
+
+	return filter
+}
+
+func validateSource(v *validator.Validator, source *database.QuoteSource) {
+	v.CheckField(validator.In(source.Type, database.SourceTypes...), "type", "must be one of "+strings.Join(database.SourceTypes, ", "))
+	v.CheckField(source.Type == "url" || source.Title != "", "title", "must be provided")
+	v.CheckField(validator.MaxRunes(source.Title, 300), "title", "must not be more than 300 characters long")
+	v.CheckField(source.Type != "url" || source.URL != "", "url", "must be provided")
+	if source.URL != "" {
+		v.CheckField(validator.IsURL(source.URL), "url", "must be a valid URL")
+	}
+	v.CheckField(validator.MaxRunes(source.Location, 50), "location", "must not be more than 50 characters long")
+	if source.Year != nil {
+		v.CheckField(validator.Between(*source.Year, -3000, time.Now().Year()), "year", "must be a valid year")
+	}
+	if source.Language != "" {
+		v.CheckField(validator.Matches(source.Language, validator.RgxLanguage), "language", "must be an ISO 639 language code")
+	}
+}
+
+// readEditableQuote loads the quote of the id path parameter and checks that
+// the user created it or holds quotes:verify.
+func (app *application) readEditableQuote(w http.ResponseWriter, r *http.Request) (*database.Quote, bool) {
+	quoteID, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		return nil, false
+	}
+
+	quote, err := app.db.GetQuoteById(r.Context(), quoteID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if quote.CreatedBy != contextGetAuthenticatedUser(r).ID {
+		verifier, err := app.hasPermission(r, "quotes:verify")
+		if err != nil {
+			app.serverError(w, r, err)
+			return nil, false
+		}
+
+		if !verifier {
+			app.notPermittedResponse(w, r)
+			return nil, false
+		}
+	}
+
+	return quote, true
+}
+
+// setQuoteSource cites where a quote comes from. Open to the quote's
+// creator and to holders of quotes:verify.
+func (app *application) setQuoteSource(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Type      string              `json:"type"`
+		Title     string              `json:"title"`
+		URL       string              `json:"url"`
+		Location  string              `json:"location"`
+		Year      *int                `json:"year"`
+		Language  string              `json:"language"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	quote, ok := app.readEditableQuote(w, r)
+	if !ok {
+		return
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	source := database.QuoteSource{
+		Type:     input.Type,
+		Title:    strings.TrimSpace(input.Title),
+		URL:      strings.TrimSpace(input.URL),
+		Location: strings.TrimSpace(input.Location),
+		Year:     input.Year,
+		Language: strings.ToLower(strings.TrimSpace(input.Language)),
+	}
+
+	if validateSource(&input.Validator, &source); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	err = app.db.SetQuoteSource(r.Context(), quote.ID, &source)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.publish(events.QuoteUpdated, quote.ID)
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"quoteID": quote.ID, "source": newSourceResponse(source)}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) deleteQuoteSource(w http.ResponseWriter, r *http.Request) {
+	quote, ok := app.readEditableQuote(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.db.SetQuoteSource(r.Context(), quote.ID, nil)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.publish(events.QuoteUpdated, quote.ID)
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"quoteID": quote.ID}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) setQuoteVerification(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status    string              `json:"status"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	quoteID, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		return
+	}
+
+	err = request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Validator.CheckField(validator.In(input.Status, database.VerificationStatuses...), "status", "must be one of "+strings.Join(database.VerificationStatuses, ", "))
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	err = app.db.SetQuoteVerification(r.Context(), quoteID, input.Status, contextGetAuthenticatedUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.publish(events.QuoteUpdated, quoteID)
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"quoteID": quoteID, "verification": input.Status}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
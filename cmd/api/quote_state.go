@@ -5,11 +5,13 @@ import (
 	"github.com/alexedwards/flow"
 	"github.com/google/uuid"
 	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
 	"javlonrahimov/quotes-api/internal/request"
 	"javlonrahimov/quotes-api/internal/response"
 	"javlonrahimov/quotes-api/internal/util"
 	"javlonrahimov/quotes-api/internal/validator"
 	"net/http"
+	"time"
 )
 
 type StateResponse struct {
@@ -46,7 +48,7 @@ func (app *application) createQuoteState(w http.ResponseWriter, r *http.Request)
 
 	state := &database.QuoteState{Value: input.Value, IsDefault: input.IsDefault, Color: input.Color, IsPublic: input.IsPublic}
 
-	err = app.db.InsertQuoteState(state)
+	err = app.db.InsertQuoteState(r.Context(), state)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrDuplicateQuoteState):
@@ -57,6 +59,7 @@ func (app *application) createQuoteState(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
+	app.publish(events.StatesChanged, uuid.Nil)
 
 	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"stateID": state.ID}))
 	if err != nil {
@@ -83,7 +86,7 @@ func (app *application) setDefaultQuoteState(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err = app.db.SetDefaultQuoteState(input.ID)
+	err = app.db.SetDefaultQuoteState(r.Context(), input.ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -93,6 +96,7 @@ func (app *application) setDefaultQuoteState(w http.ResponseWriter, r *http.Requ
 		}
 		return
 	}
+	app.publish(events.StatesChanged, uuid.Nil)
 
 	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"stateID": input.ID}))
 	if err != nil {
@@ -107,12 +111,12 @@ func (app *application) deleteQuoteStateByID(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if exists := app.db.IsExistsWithThisState(stateID); exists {
+	if exists := app.db.IsExistsWithThisState(r.Context(), stateID); exists {
 		app.errorMessage(w, r, http.StatusConflict, "there one or more quotes with this state", nil)
 		return
 	}
 
-	err = app.db.DeleteQuoteStateById(stateID)
+	err = app.db.DeleteQuoteStateById(r.Context(), stateID)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrDefaultState):
@@ -122,6 +126,7 @@ func (app *application) deleteQuoteStateByID(w http.ResponseWriter, r *http.Requ
 		}
 		return
 	}
+	app.publish(events.StatesChanged, uuid.Nil)
 
 	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"stateID": stateID.String()}))
 	if err != nil {
@@ -130,7 +135,7 @@ func (app *application) deleteQuoteStateByID(w http.ResponseWriter, r *http.Requ
 }
 
 func (app *application) getQuoteStates(w http.ResponseWriter, r *http.Request) {
-	states, metadata, err := app.db.GetAllQuoteStates()
+	states, metadata, err := app.quoteStates(r.Context())
 	if err != nil {
 		app.logger.Info(err.Error())
 		app.serverError(w, r, err)
@@ -147,7 +152,7 @@ func (app *application) getQuoteStates(w http.ResponseWriter, r *http.Request) {
 		}
 	})
 
-	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}))
+	err = response.JSONCached(w, r, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}), response.Validators{})
 	if err != nil {
 		app.logger.Info(err.Error())
 		app.serverError(w, r, err)
@@ -158,6 +163,7 @@ func (app *application) setQuoteState(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		ID        uuid.UUID           `json:"id"`
 		StateID   uuid.UUID           `json:"stateId"`
+		PublishAt *time.Time          `json:"publishAt"`
 		Validator validator.Validator `json:"-"`
 	}
 
@@ -169,13 +175,14 @@ func (app *application) setQuoteState(w http.ResponseWriter, r *http.Request) {
 
 	input.Validator.CheckField(input.ID.String() != "", "id", "id is required")
 	input.Validator.CheckField(input.StateID.String() != "", "stateId", "stateId is required")
+	input.Validator.CheckField(input.PublishAt == nil || input.PublishAt.After(time.Now()), "publishAt", "must be in the future")
 
 	if input.Validator.HasErrors() {
 		app.failedValidation(w, r, input.Validator)
 		return
 	}
 
-	err = app.db.SetQuoteState(input.ID, input.StateID)
+	quote, err := app.db.GetQuoteById(r.Context(), input.ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -186,7 +193,46 @@ func (app *application) setQuoteState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"stateID": input.StateID}))
+	// The publish time goes in before the state, so that a quote moved to a
+	// public state never shows up ahead of its schedule. Should the state
+	// change fail, the previous publish time is put back.
+	if input.PublishAt != nil {
+		err = app.db.ScheduleQuote(r.Context(), input.ID, input.PublishAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, database.ErrRecordNotFound):
+				app.notFound(w, r)
+			default:
+				app.serverError(w, r, err)
+			}
+			return
+		}
+	}
+
+	if history, ok := app.db.(database.QuoteStateHistoryStore); ok {
+		_, err = history.ChangeQuoteState(r.Context(), input.ID, input.StateID, &contextGetAuthenticatedUser(r).ID, "")
+	} else {
+		err = app.db.SetQuoteState(r.Context(), input.ID, input.StateID)
+	}
+	if err != nil {
+		if input.PublishAt != nil {
+			if err := app.db.ScheduleQuote(r.Context(), input.ID, quote.PublishAt); err != nil {
+				app.logger.Error(err)
+			}
+		}
+
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.publishStateChange(input.ID, quote.IsLive())
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"stateID": input.StateID, "publishAt": input.PublishAt}))
 	if err != nil {
 		app.serverError(w, r, err)
 	}
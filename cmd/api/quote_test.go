@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestQuoteLifecycle walks a quote from a new user's registration through
+// moderation to the public listing.
+func TestQuoteLifecycle(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	_, author := ts.registerUser(t, "author@example.org")
+	_, reader := ts.registerUser(t, "reader@example.org")
+	_, admin := ts.registerUser(t, testSudoer)
+
+	quote := ts.testQuote(t, author, "The impediment to action advances action.")
+
+	if quote.State.IsPublic {
+		t.Fatalf("new quote is in public state %q, want the default state", quote.State.Value)
+	}
+
+	var listing struct {
+		Data []QuoteResponse `json:"data"`
+	}
+	ts.mustDo(t, http.StatusOK, "GET", "/v1/quotes", reader, nil).decode(t, &listing)
+	if len(listing.Data) != 0 {
+		t.Fatalf("public listing holds %d quotes before moderation, want 0", len(listing.Data))
+	}
+
+	public := ts.publicState(t, admin)
+	moderation := map[string]string{"id": quote.ID.String(), "stateId": public}
+
+	ts.mustDo(t, http.StatusForbidden, "PATCH", "/v1/quote/set-state", author, moderation)
+	ts.mustDo(t, http.StatusOK, "PATCH", "/v1/quote/set-state", admin, moderation)
+
+	ts.mustDo(t, http.StatusOK, "GET", "/v1/quotes", reader, nil).decode(t, &listing)
+	if len(listing.Data) != 1 || listing.Data[0].ID != quote.ID {
+		t.Fatalf("public listing after moderation = %+v, want the quote", listing.Data)
+	}
+	if !listing.Data[0].State.IsPublic {
+		t.Errorf("listed quote is in state %q, want a public one", listing.Data[0].State.Value)
+	}
+
+	var fetched QuoteResponse
+	ts.mustDo(t, http.StatusOK, "GET", "/v1/quote/"+quote.ID.String(), reader, nil).decode(t, &fetched)
+	if fetched.ID != quote.ID || fetched.Text != quote.Text {
+		t.Fatalf("fetched quote = %+v, want %q", fetched, quote.Text)
+	}
+}
+
+func TestVerifyRejectsWrongOTP(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	ts.mustDo(t, http.StatusOK, "POST", "/v1/register", "", map[string]string{
+		"email":    "someone@example.org",
+		"password": "correct-horse-battery",
+		"name":     "Someone",
+	})
+
+	ts.mustDo(t, http.StatusUnprocessableEntity, "POST", "/v1/verify", "", map[string]string{
+		"email": "someone@example.org",
+		"otp":   "000000",
+	})
+
+	ts.mustDo(t, http.StatusUnauthorized, "GET", "/v1/quotes", "", nil)
+}
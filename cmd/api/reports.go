@@ -0,0 +1,376 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
+	"javlonrahimov/quotes-api/internal/filters"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/validator"
+
+	"github.com/alexedwards/flow"
+	"github.com/google/uuid"
+)
+
+type ReportResponse struct {
+	ID              uuid.UUID      `json:"id"`
+	QuoteID         uuid.UUID      `json:"quoteID"`
+	UserID          uuid.UUID      `json:"userID"`
+	Reason          string         `json:"reason"`
+	Note            string         `json:"note"`
+	Status          string         `json:"status"`
+	ResolvedBy      *uuid.UUID     `json:"resolvedBy,omitempty"`
+	ResolvedAt      string         `json:"resolvedAt,omitempty"`
+	StateChangeID   *uuid.UUID     `json:"stateChangeID,omitempty"`
+	CreatedAt       string         `json:"createdAt"`
+	QuoteAuthor     string         `json:"quoteAuthor,omitempty"`
+	QuoteText       string         `json:"quoteText,omitempty"`
+	QuoteState      *StateResponse `json:"quoteState,omitempty"`
+	OpenReportCount int            `json:"openReportCount,omitempty"`
+}
+
+func newReportResponse(report *database.QuoteReport) ReportResponse {
+	data := ReportResponse{
+		ID:              report.ID,
+		QuoteID:         report.QuoteID,
+		UserID:          report.UserID,
+		Reason:          report.Reason,
+		Note:            report.Note,
+		Status:          report.Status,
+		ResolvedBy:      report.ResolvedBy,
+		StateChangeID:   report.StateChangeID,
+		CreatedAt:       report.CreatedAt.Format(time.RFC3339),
+		QuoteAuthor:     report.QuoteAuthor,
+		QuoteText:       report.QuoteText,
+		OpenReportCount: report.OpenReportCount,
+	}
+
+	if report.ResolvedAt != nil {
+		data.ResolvedAt = report.ResolvedAt.Format(time.RFC3339)
+	}
+
+	if report.QuoteState.ID != uuid.Nil {
+		data.QuoteState = newStateResponse(&report.QuoteState)
+	}
+
+	return data
+}
+
+type StateChangeResponse struct {
+	ID        uuid.UUID      `json:"id"`
+	QuoteID   uuid.UUID      `json:"quoteID"`
+	FromState *StateResponse `json:"fromState"`
+	ToState   *StateResponse `json:"toState"`
+	ChangedBy *uuid.UUID     `json:"changedBy"`
+	Reason    string         `json:"reason"`
+	CreatedAt string         `json:"createdAt"`
+}
+
+func newStateChangeResponse(change *database.QuoteStateChange) StateChangeResponse {
+	return StateChangeResponse{
+		ID:        change.ID,
+		QuoteID:   change.QuoteID,
+		FromState: newStateResponse(change.FromState),
+		ToState:   newStateResponse(change.ToState),
+		ChangedBy: change.ChangedBy,
+		Reason:    change.Reason,
+		CreatedAt: change.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func newStateResponse(state *database.QuoteState) *StateResponse {
+	if state == nil {
+		return nil
+	}
+
+	return &StateResponse{
+		ID:        state.ID,
+		Value:     state.Value,
+		IdDefault: state.IsDefault,
+		Color:     state.Color,
+		IsPublic:  state.IsPublic,
+	}
+}
+
+func (app *application) reportStore(w http.ResponseWriter, r *http.Request) (database.ReportStore, bool) {
+	store, ok := app.db.(database.ReportStore)
+	if !ok {
+		app.notSupported(w, r)
+	}
+	return store, ok
+}
+
+func (app *application) reportQuote(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Reason    string              `json:"reason"`
+		Note      string              `json:"note"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	store, ok := app.reportStore(w, r)
+	if !ok {
+		return
+	}
+
+	quoteID, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		return
+	}
+
+	err = request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Note = strings.TrimSpace(input.Note)
+
+	input.Validator.CheckField(validator.In(input.Reason, database.ReportReasons...), "reason", "must be one of "+strings.Join(database.ReportReasons, ", "))
+	input.Validator.CheckField(utf8.RuneCountInString(input.Note) <= 1000, "note", "must not be more than 1000 characters long")
+	input.Validator.CheckField(input.Reason != "other" || input.Note != "", "note", "must be provided when the reason is other")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	report := &database.QuoteReport{
+		QuoteID: quoteID,
+		UserID:  contextGetAuthenticatedUser(r).ID,
+		Reason:  input.Reason,
+		Note:    input.Note,
+	}
+
+	change, err := store.InsertQuoteReport(r.Context(), report, app.config.Reports.Threshold)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		case errors.Is(err, database.ErrDuplicateReport):
+			app.errorMessage(w, r, http.StatusConflict, "you have already reported this quote", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if change != nil {
+		app.logger.Info("quote %s moved to review after %s", report.QuoteID, change.Reason)
+		app.publish(events.QuoteStateChanged, report.QuoteID)
+	}
+
+	err = response.JSON(w, http.StatusCreated, getWrapper(newReportResponse(report)))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// getReports is the moderators' inbox, open reports by default.
+func (app *application) getReports(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	store, ok := app.reportStore(w, r)
+	if !ok {
+		return
+	}
+
+	qs := r.URL.Query()
+	input.Status = request.ReadString(qs, "status", database.ReportOpen)
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+	input.Filters.Sort = "created_at"
+	input.Filters.SortSafeList = []string{"created_at"}
+
+	input.Validator.CheckField(validator.In(input.Status, database.ReportOpen, database.ReportResolved, database.ReportDismissed), "status", "must be open, resolved or dismissed")
+
+	if filters.ValidateFilters(&input.Validator, input.Filters); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	reports, metadata, err := store.GetQuoteReports(r.Context(), input.Status, input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := make([]ReportResponse, 0, len(reports))
+	for i := range reports {
+		data = append(data, newReportResponse(&reports[i]))
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// resolveReport settles all open reports of the reported quote as justified,
+// optionally moving the quote to the state given as stateId.
+func (app *application) resolveReport(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		StateID   *uuid.UUID          `json:"stateId"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	store, ok := app.reportStore(w, r)
+	if !ok {
+		return
+	}
+
+	report, ok := app.readOpenReport(w, r, store)
+	if !ok {
+		return
+	}
+
+	// The body is optional: without a state the quote stays where it is.
+	if r.ContentLength != 0 {
+		err := request.DecodeJSON(w, r, &input)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+	}
+
+	closed, change, err := store.ResolveQuoteReports(r.Context(), report.QuoteID, contextGetAuthenticatedUser(r).ID, input.StateID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			input.Validator.AddFieldError("stateId", "state not found")
+			app.failedValidation(w, r, input.Validator)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.writeReportOutcome(w, r, report.QuoteID, closed, change)
+}
+
+// dismissReport settles all open reports of the reported quote as unfounded.
+func (app *application) dismissReport(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.reportStore(w, r)
+	if !ok {
+		return
+	}
+
+	report, ok := app.readOpenReport(w, r, store)
+	if !ok {
+		return
+	}
+
+	closed, change, err := store.DismissQuoteReports(r.Context(), report.QuoteID, contextGetAuthenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.writeReportOutcome(w, r, report.QuoteID, closed, change)
+}
+
+func (app *application) readOpenReport(w http.ResponseWriter, r *http.Request, store database.ReportStore) (*database.QuoteReport, bool) {
+	id, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "report not found", nil)
+		return nil, false
+	}
+
+	report, err := store.GetQuoteReport(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "report not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if report.Status != database.ReportOpen {
+		app.errorMessage(w, r, http.StatusConflict, "report has already been "+report.Status, nil)
+		return nil, false
+	}
+
+	return report, true
+}
+
+func (app *application) writeReportOutcome(w http.ResponseWriter, r *http.Request, quoteID uuid.UUID, closed int, change *database.QuoteStateChange) {
+	data := envelope{"quoteID": quoteID, "closedReports": closed}
+	if change != nil {
+		data["stateChange"] = newStateChangeResponse(change)
+
+		// Reported quotes are never scheduled, so the previous state alone
+		// tells whether the quote was live.
+		wasLive := false
+		if states, _, err := app.quoteStates(r.Context()); err == nil {
+			for _, state := range states {
+				if state.ID == change.FromState.ID {
+					wasLive = state.IsPublic
+				}
+			}
+		}
+		app.publishStateChange(quoteID, wasLive)
+	}
+
+	err := response.JSON(w, http.StatusOK, getWrapper(data))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) getQuoteStateHistory(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	store, ok := app.db.(database.QuoteStateHistoryStore)
+	if !ok {
+		app.notSupported(w, r)
+		return
+	}
+
+	quoteID, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		return
+	}
+
+	qs := r.URL.Query()
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+	input.Filters.Sort = "-created_at"
+	input.Filters.SortSafeList = []string{"-created_at"}
+
+	if filters.ValidateFilters(&input.Validator, input.Filters); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	changes, metadata, err := store.GetQuoteStateHistory(r.Context(), quoteID, input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := make([]StateChangeResponse, 0, len(changes))
+	for i := range changes {
+		data = append(data, newStateChangeResponse(&changes[i]))
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"javlonrahimov/quotes-api/config"
+)
+
+func TestReportThreshold(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t, func(cfg *config.Config) {
+		cfg.Reports.Threshold = 2
+	}))
+
+	_, admin := ts.registerUser(t, testSudoer)
+	_, reader := ts.registerUser(t, "reader@example.org")
+
+	quote := ts.publishedQuote(t, admin, "If it is not right, do not do it.")
+	path := "/v1/quote/" + quote.ID.String()
+	complaint := map[string]string{"reason": "misattribution"}
+
+	var first, second ReportResponse
+	ts.mustDo(t, http.StatusCreated, "POST", path+"/report", reader, complaint).decode(t, &first)
+	ts.mustDo(t, http.StatusConflict, "POST", path+"/report", reader, complaint)
+
+	var fetched QuoteResponse
+	ts.mustDo(t, http.StatusOK, "GET", path, reader, nil).decode(t, &fetched)
+	if !fetched.State.IsPublic || first.StateChangeID != nil {
+		t.Fatalf("a report below the threshold moved the quote to %q", fetched.State.Value)
+	}
+
+	ts.mustDo(t, http.StatusCreated, "POST", path+"/report", admin, complaint).decode(t, &second)
+	if second.StateChangeID == nil {
+		t.Fatal("the report reaching the threshold is not linked to a state change")
+	}
+
+	ts.mustDo(t, http.StatusOK, "GET", path, admin, nil).decode(t, &fetched)
+	if fetched.State.IsPublic || !fetched.State.IdDefault {
+		t.Fatalf("reported quote is in state %q, want the default state", fetched.State.Value)
+	}
+
+	var listing struct {
+		Data []QuoteResponse `json:"data"`
+	}
+	ts.mustDo(t, http.StatusOK, "GET", "/v1/quotes", reader, nil).decode(t, &listing)
+	if len(listing.Data) != 0 {
+		t.Fatalf("public listing holds %d quotes, want the reported one gone", len(listing.Data))
+	}
+
+	ts.mustDo(t, http.StatusNotFound, "POST", path+"/report", reader, complaint)
+
+	var history struct {
+		Data []StateChangeResponse `json:"data"`
+	}
+	ts.mustDo(t, http.StatusOK, "GET", path+"/state-history", admin, nil).decode(t, &history)
+	if len(history.Data) == 0 || history.Data[0].ID != *second.StateChangeID || history.Data[0].ChangedBy != nil {
+		t.Fatalf("state history = %+v, want the reports' change, made by nobody, first", history.Data)
+	}
+
+	// Dismissing the reports puts the quote back where it was.
+	var outcome struct {
+		ClosedReports int                  `json:"closedReports"`
+		StateChange   *StateChangeResponse `json:"stateChange"`
+	}
+	ts.mustDo(t, http.StatusOK, "POST", "/v1/report/"+first.ID.String()+"/dismiss", admin, nil).decode(t, &outcome)
+	if outcome.ClosedReports != 2 || outcome.StateChange == nil {
+		t.Fatalf("dismiss = %+v, want 2 closed reports and a state change", outcome)
+	}
+
+	ts.mustDo(t, http.StatusOK, "GET", path, reader, nil).decode(t, &fetched)
+	if !fetched.State.IsPublic {
+		t.Fatalf("quote is in state %q after dismissal, want public again", fetched.State.Value)
+	}
+
+	ts.mustDo(t, http.StatusConflict, "POST", "/v1/report/"+second.ID.String()+"/dismiss", admin, nil)
+}
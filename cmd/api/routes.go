@@ -11,6 +11,17 @@ const (
 	uuidRegex = "^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$"
 )
 
+// Cache-Control policies. Photos, states and hashtags read the same for every
+// user, so the client may keep them for a minute; they sit behind
+// authentication though, so shared caches must not. Quotes carry the viewer's
+// likes and favorites, so the client revalidates them each time. Auth
+// responses hold tokens and are never stored.
+const (
+	cacheMinute  = "private, max-age=60"
+	cachePrivate = "private, no-cache"
+	cacheNoStore = "no-store"
+)
+
 func (app *application) routes() http.Handler {
 	mux := flow.New()
 
@@ -23,11 +34,11 @@ func (app *application) routes() http.Handler {
 
 	mux.HandleFunc("/v1/status", app.status, "GET")
 
-	mux.HandleFunc("/v1/register", app.register, "POST")
-	mux.HandleFunc("/v1/verify", app.verify, "POST")
-	mux.HandleFunc("/v1/login", app.login, "POST")
+	mux.Handle("/v1/register", app.cacheControl(cacheNoStore, app.register), "POST")
+	mux.Handle("/v1/verify", app.cacheControl(cacheNoStore, app.verify), "POST")
+	mux.Handle("/v1/login", app.cacheControl(cacheNoStore, app.login), "POST")
 	mux.HandleFunc("/v1/forgot-password", app.forgotPassword, "POST")
-	mux.HandleFunc("/v1/reset-password", app.resetPassword, "POST")
+	mux.Handle("/v1/reset-password", app.cacheControl(cacheNoStore, app.resetPassword), "POST")
 
 	mux.Group(func(mux *flow.Mux) {
 		mux.Use(app.authenticate)
@@ -36,28 +47,120 @@ func (app *application) routes() http.Handler {
 		// quotes
 		mux.HandleFunc("/v1/quote", app.createQuote, "POST")
 		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s", uuidRegex), app.updateQuote, "PUT")
-		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s", uuidRegex), app.getQuoteById, "GET")
+		mux.Handle(fmt.Sprintf("/v1/quote/:id|%s", uuidRegex), app.cacheControl(cachePrivate, app.getQuoteById), "GET")
 		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s", uuidRegex), app.deleteQuoteById, "DELETE")
-		mux.HandleFunc(fmt.Sprintf("/v1/quotes/:userId|%s", uuidRegex), app.getUserQuotes, "GET")
-		mux.HandleFunc("/v1/quotes", app.getQuotes, "GET")
+		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s/like", uuidRegex), app.likeQuote, "POST")
+		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s/like", uuidRegex), app.unlikeQuote, "DELETE")
+		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s/favorite", uuidRegex), app.favoriteQuote, "POST")
+		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s/favorite", uuidRegex), app.unfavoriteQuote, "DELETE")
+		mux.HandleFunc("/v1/me/favorites", app.getFavoriteQuotes, "GET")
+		mux.Handle(fmt.Sprintf("/v1/quotes/:userId|%s", uuidRegex), app.cacheControl(cachePrivate, app.getUserQuotes), "GET")
+		mux.Handle("/v1/quotes", app.cacheControl(cachePrivate, app.getQuotes), "GET")
+		mux.HandleFunc("/v1/quotes/export", app.exportQuotes, "GET")
+		mux.HandleFunc("/v1/quotes/random", app.getRandomQuotes, "GET")
+		mux.HandleFunc("/v1/quotes/daily", app.getDailyQuote, "GET")
+		mux.HandleFunc("/v1/quotes/daily/history", app.getDailyQuoteHistory, "GET")
+		mux.Handle("/v1/quotes/daily/override", app.requirePermission("quotes:state", app.setDailyQuoteOverride), "PUT")
+		mux.Handle("/v1/quotes/daily/override", app.requirePermission("quotes:state", app.deleteDailyQuoteOverride), "DELETE")
+		mux.Handle("/v1/quotes/import", app.requirePermission("quotes:state", app.importQuotes), "POST")
+
+		// sources and verification
+		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s/source", uuidRegex), app.setQuoteSource, "PUT")
+		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s/source", uuidRegex), app.deleteQuoteSource, "DELETE")
+		mux.Handle(fmt.Sprintf("/v1/quote/:id|%s/verification", uuidRegex), app.requirePermission("quotes:verify", app.setQuoteVerification), "PUT")
+
+		// translations
+		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s/translations", uuidRegex), app.getQuoteTranslations, "GET")
+		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s/translations", uuidRegex), app.createTranslation, "POST")
+		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s/language", uuidRegex), app.setQuoteLanguage, "PUT")
+
+		// comments
+		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s/comments", uuidRegex), app.getQuoteComments, "GET")
+		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s/comments", uuidRegex), app.createComment, "POST")
+		mux.HandleFunc(fmt.Sprintf("/v1/comment/:id|%s", uuidRegex), app.updateComment, "PATCH")
+		mux.HandleFunc(fmt.Sprintf("/v1/comment/:id|%s", uuidRegex), app.deleteComment, "DELETE")
+		mux.Handle(fmt.Sprintf("/v1/comment/:id|%s/hide", uuidRegex), app.requirePermission("quotes:state", app.hideComment), "POST")
+		mux.Handle(fmt.Sprintf("/v1/comment/:id|%s/unhide", uuidRegex), app.requirePermission("quotes:state", app.unhideComment), "POST")
+		mux.Handle("/v1/comment/set-state", app.requirePermission("quotes:state", app.setCommentState), "PATCH")
+
+		// reports
+		mux.HandleFunc(fmt.Sprintf("/v1/quote/:id|%s/report", uuidRegex), app.reportQuote, "POST")
+		mux.Handle(fmt.Sprintf("/v1/quote/:id|%s/state-history", uuidRegex), app.requirePermission("quotes:state", app.getQuoteStateHistory), "GET")
+		mux.Handle("/v1/reports", app.requirePermission("quotes:state", app.getReports), "GET")
+		mux.Handle(fmt.Sprintf("/v1/report/:id|%s/resolve", uuidRegex), app.requirePermission("quotes:state", app.resolveReport), "POST")
+		mux.Handle(fmt.Sprintf("/v1/report/:id|%s/dismiss", uuidRegex), app.requirePermission("quotes:state", app.dismissReport), "POST")
+
+		// authors
+		mux.HandleFunc("/v1/authors", app.getAuthors, "GET")
+		mux.HandleFunc("/v1/authors/autocomplete", app.autocompleteAuthors, "GET")
+		mux.HandleFunc("/v1/authors/lookup", app.lookupAuthor, "GET")
+		mux.HandleFunc(fmt.Sprintf("/v1/authors/:id|%s/quotes", uuidRegex), app.getAuthorQuotes, "GET")
+		mux.Handle("/v1/author", app.requirePermission("quotes:state", app.createAuthor), "POST")
+		mux.HandleFunc(fmt.Sprintf("/v1/author/:id|%s", uuidRegex), app.getAuthor, "GET")
+		mux.Handle(fmt.Sprintf("/v1/author/:id|%s", uuidRegex), app.requirePermission("quotes:state", app.updateAuthor), "PATCH")
+		mux.Handle(fmt.Sprintf("/v1/author/:id|%s/merge", uuidRegex), app.requirePermission("quotes:state", app.mergeAuthors), "POST")
+
+		// collections
+		mux.HandleFunc("/v1/collection", app.createCollection, "POST")
+		mux.HandleFunc(fmt.Sprintf("/v1/collection/:id|%s", uuidRegex), app.getCollection, "GET")
+		mux.HandleFunc(fmt.Sprintf("/v1/collection/:id|%s", uuidRegex), app.updateCollection, "PATCH")
+		mux.HandleFunc(fmt.Sprintf("/v1/collection/:id|%s", uuidRegex), app.deleteCollection, "DELETE")
+		mux.HandleFunc(fmt.Sprintf("/v1/collection/:id|%s/quotes", uuidRegex), app.addCollectionQuote, "POST")
+		mux.HandleFunc(fmt.Sprintf("/v1/collection/:id|%s/quotes/:quoteId|%s", uuidRegex, uuidRegex), app.removeCollectionQuote, "DELETE")
+		mux.HandleFunc(fmt.Sprintf("/v1/collection/:id|%s/order", uuidRegex), app.reorderCollection, "PUT")
+		mux.HandleFunc(fmt.Sprintf("/v1/collection/:id|%s/follow", uuidRegex), app.followCollection, "POST")
+		mux.HandleFunc(fmt.Sprintf("/v1/collection/:id|%s/follow", uuidRegex), app.unfollowCollection, "DELETE")
+		mux.HandleFunc("/v1/collections", app.getCollections, "GET")
+		mux.HandleFunc("/v1/collections/followed", app.getFollowedCollections, "GET")
 
 		// quote states
-		mux.HandleFunc("/v1/quote/states", app.getQuoteStates, "GET")
+		mux.Handle("/v1/quote/states", app.cacheControl(cacheMinute, app.getQuoteStates), "GET")
 		mux.HandleFunc("/v1/quote/state", app.createQuoteState, "POST")
 		mux.HandleFunc(fmt.Sprintf("/v1/quote/state/:id|%s", uuidRegex), app.deleteQuoteStateByID, "DELETE")
 		mux.Handle("/v1/quote/state", app.requirePermission("quotes:state", app.setDefaultQuoteState), "PATCH")
 		mux.Handle("/v1/quote/set-state", app.requirePermission("quotes:state", app.setQuoteState), "PATCH")
 
+		// scheduled publishing
+		mux.Handle("/v1/quotes/scheduled", app.requirePermission("quotes:state", app.getScheduledQuotes), "GET")
+		mux.Handle(fmt.Sprintf("/v1/quote/:id|%s/schedule", uuidRegex), app.requirePermission("quotes:state", app.scheduleQuote), "PUT")
+		mux.Handle(fmt.Sprintf("/v1/quote/:id|%s/schedule", uuidRegex), app.requirePermission("quotes:state", app.unscheduleQuote), "DELETE")
+
+		// offline sync
+		mux.HandleFunc("/v1/sync", app.sync, "GET")
+		mux.HandleFunc("/v1/bundle", app.getBundle, "GET")
+
+		// live feeds
+		mux.Handle("/v1/stream/moderation", app.requirePermission("quotes:state", app.streamModeration), "GET")
+		mux.HandleFunc("/v1/stream/quotes", app.streamQuotes, "GET")
+
+		// webhooks
+		mux.Handle("/v1/webhook", app.requirePermission("webhooks:manage", app.createWebhook), "POST")
+		mux.Handle("/v1/webhooks", app.requirePermission("webhooks:manage", app.getWebhooks), "GET")
+		mux.Handle(fmt.Sprintf("/v1/webhook/:id|%s", uuidRegex), app.requirePermission("webhooks:manage", app.getWebhook), "GET")
+		mux.Handle(fmt.Sprintf("/v1/webhook/:id|%s", uuidRegex), app.requirePermission("webhooks:manage", app.updateWebhook), "PATCH")
+		mux.Handle(fmt.Sprintf("/v1/webhook/:id|%s", uuidRegex), app.requirePermission("webhooks:manage", app.deleteWebhook), "DELETE")
+		mux.Handle(fmt.Sprintf("/v1/webhook/:id|%s/deliveries", uuidRegex), app.requirePermission("webhooks:manage", app.getWebhookDeliveries), "GET")
+		mux.Handle(fmt.Sprintf("/v1/webhook/:id|%s/deliveries/:deliveryId|%s", uuidRegex, uuidRegex), app.requirePermission("webhooks:manage", app.getWebhookDelivery), "GET")
+		mux.Handle(fmt.Sprintf("/v1/webhook/:id|%s/deliveries/:deliveryId|%s/redeliver", uuidRegex, uuidRegex), app.requirePermission("webhooks:manage", app.redeliverWebhookDelivery), "POST")
+
+		// telegram channel
+		mux.Handle("/v1/telegram/posts", app.requirePermission("quotes:state", app.getTelegramPosts), "GET")
+		mux.Handle(fmt.Sprintf("/v1/quote/:id|%s/telegram-posts", uuidRegex), app.requirePermission("quotes:state", app.getTelegramPosts), "GET")
+		mux.Handle(fmt.Sprintf("/v1/quote/:id|%s/telegram-repost", uuidRegex), app.requirePermission("quotes:state", app.repostToTelegram), "POST")
+
+		// caches
+		mux.Handle("/v1/cache/stats", app.requirePermission("quotes:state", app.getCacheStats), "GET")
+
 		//photos
 		mux.HandleFunc("/v1/photo", app.createPhoto, "POST")
-		mux.HandleFunc(fmt.Sprintf("/v1/photo/:id|%s", uuidRegex), app.getPhotoById, "GET")
-		mux.HandleFunc("/v1/photos", app.getPhotos, "GET")
+		mux.Handle(fmt.Sprintf("/v1/photo/:id|%s", uuidRegex), app.cacheControl(cacheMinute, app.getPhotoById), "GET")
+		mux.Handle("/v1/photos", app.cacheControl(cacheMinute, app.getPhotos), "GET")
 
 		// hashtags
 		mux.HandleFunc("/v1/hashtag", app.createHashtag, "POST")
 		mux.HandleFunc(fmt.Sprintf("/v1/hashtag/:id|%s", uuidRegex), app.deleteHashtagById, "DELETE")
-		mux.HandleFunc(fmt.Sprintf("/v1/hashtags/:id|%s", uuidRegex), app.getQuoteHashtags, "GET")
-		mux.HandleFunc("/v1/hashtags", app.getHashtags, "GET")
+		mux.Handle(fmt.Sprintf("/v1/hashtags/:id|%s", uuidRegex), app.cacheControl(cacheMinute, app.getQuoteHashtags), "GET")
+		mux.Handle("/v1/hashtags", app.cacheControl(cacheMinute, app.getHashtags), "GET")
 	})
 
 	return mux
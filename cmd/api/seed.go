@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"javlonrahimov/quotes-api/assets"
+	"javlonrahimov/quotes-api/config"
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/leveledlog"
+	"javlonrahimov/quotes-api/internal/seed"
+)
+
+const seedUsage = `usage: api [flags] seed [-file path]... [-reset] [-generate N] [-rand-seed S]
+
+Loads fixtures from the given YAML or JSON files, or the built-in development
+fixtures when no -file is given. Existing records are left untouched.`
+
+const defaultFixtures = "fixtures/default.yaml"
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func runSeed(cfg config.Config, logger *leveledlog.Logger, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+
+	var files stringList
+	fs.Var(&files, "file", "fixture file to load (.json, .yaml or .yml), may be repeated")
+	reset := fs.Bool("reset", false, "delete all users, photos, hashtags and quotes before loading fixtures")
+	generate := fs.Int("generate", 0, "number of random quotes to fabricate after loading fixtures")
+	randSeed := fs.Int64("rand-seed", time.Now().UnixNano(), "seed for the quote generator")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), seedUsage)
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q\n\n%s", fs.Arg(0), seedUsage)
+	}
+
+	if *reset && cfg.Env == "production" {
+		return errors.New("seed: refusing to reset a production database")
+	}
+
+	var fixtures []*seed.Fixtures
+
+	if len(files) == 0 {
+		fixture, err := loadFixtures(defaultFixtures, func(name string) (io.ReadCloser, error) {
+			return assets.EmbeddedFiles.Open(name)
+		})
+		if err != nil {
+			return err
+		}
+		fixtures = append(fixtures, fixture)
+	}
+
+	for _, file := range files {
+		fixture, err := loadFixtures(file, func(name string) (io.ReadCloser, error) {
+			return os.Open(name)
+		})
+		if err != nil {
+			return err
+		}
+		fixtures = append(fixtures, fixture)
+	}
+
+	db, err := openDB(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	if *reset {
+		resetter, ok := db.(database.Resetter)
+		if !ok {
+			return fmt.Errorf("seed: the %s driver does not support -reset", cfg.DB.Driver)
+		}
+
+		err := resetter.Reset(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println("database reset")
+	}
+
+	for _, fixture := range fixtures {
+		report, err := seed.Apply(ctx, db, fixture)
+		if err != nil {
+			return err
+		}
+		fmt.Println(report)
+	}
+
+	if *generate > 0 {
+		err := seed.Generate(ctx, db, *generate, rand.New(rand.NewSource(*randSeed)))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("generated %d quotes (rand-seed %d)\n", *generate, *randSeed)
+	}
+
+	return nil
+}
+
+func loadFixtures(name string, open func(string) (io.ReadCloser, error)) (*seed.Fixtures, error) {
+	file, err := open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return seed.Decode(file, name)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
+	"javlonrahimov/quotes-api/internal/stream"
+
+	"golang.org/x/exp/slices"
+)
+
+// Stream event names.
+const (
+	streamQuoteSubmitted    = "quote.submitted"
+	streamQuoteStateChanged = "quote.state_changed"
+	streamQuotePublished    = "quote.published"
+	streamQuoteUpdated      = "quote.updated"
+	streamResync            = "resync"
+)
+
+// streamWriteTimeout bounds each write to a stream, in place of the server's
+// write timeout, which would otherwise end every stream after 30 seconds. A
+// client that stops reading is dropped once a write stalls this long.
+const streamWriteTimeout = 10 * time.Second
+
+// streamEvents hands quote events from the bus to feedStream, which may block
+// on the database while bus subscribers must not.
+func (app *application) streamEvents(queue chan<- events.Event) func(events.Event) {
+	return func(e events.Event) {
+		switch e.Type {
+		case events.QuoteCreated, events.QuoteUpdated, events.QuoteStateChanged, events.QuotePublished, events.Resync:
+		default:
+			return
+		}
+
+		select {
+		case queue <- e:
+		default:
+			app.logger.Warning("stream: queue full, dropped %s event for %s", e.Type, e.ID)
+		}
+	}
+}
+
+// feedStream turns quote events into stream messages, loading each quote once
+// however many clients are connected.
+func (app *application) feedStream(queue <-chan events.Event) {
+	for e := range queue {
+		if e.Type == events.Resync {
+			app.stream.Publish(stream.Message{Event: streamResync, Data: []byte("{}")})
+			continue
+		}
+
+		quote, err := app.db.GetQuoteById(context.Background(), e.ID)
+		if err != nil {
+			if !errors.Is(err, database.ErrRecordNotFound) {
+				app.logger.Error(err)
+			}
+			continue
+		}
+
+		data, err := json.Marshal(newQuoteResponse(quote))
+		if err != nil {
+			app.logger.Error(err)
+			continue
+		}
+
+		tags := make([]string, 0, len(quote.Hashtags))
+		for _, hashtag := range quote.Hashtags {
+			tags = append(tags, hashtag.Value)
+		}
+
+		// quote.published marks the moment a quote goes live: a new quote that
+		// is live right away, or a QuotePublished event, which is only sent
+		// when a quote wasn't live before. Later changes to a live quote are
+		// quote.updated.
+		switch e.Type {
+		case events.QuoteCreated:
+			app.stream.Publish(stream.Message{Event: streamQuoteSubmitted, Data: data, Tags: tags})
+			if quote.IsLive() {
+				app.stream.Publish(stream.Message{Event: streamQuotePublished, Data: data, Tags: tags})
+			}
+		case events.QuoteStateChanged:
+			app.stream.Publish(stream.Message{Event: streamQuoteStateChanged, Data: data, Tags: tags})
+		case events.QuotePublished:
+			if quote.IsLive() {
+				app.stream.Publish(stream.Message{Event: streamQuotePublished, Data: data, Tags: tags})
+			}
+		case events.QuoteUpdated:
+			if quote.IsLive() {
+				app.stream.Publish(stream.Message{Event: streamQuoteUpdated, Data: data, Tags: tags})
+			}
+		}
+	}
+}
+
+// streamModeration pushes quotes as they are submitted, change state, go
+// public or change while public.
+func (app *application) streamModeration(w http.ResponseWriter, r *http.Request) {
+	app.serveStream(w, r, func(m stream.Message) bool {
+		return true
+	})
+}
+
+// streamQuotes pushes quotes as they go public and as public quotes change.
+func (app *application) streamQuotes(w http.ResponseWriter, r *http.Request) {
+	app.serveStream(w, r, func(m stream.Message) bool {
+		return m.Event == streamQuotePublished || m.Event == streamQuoteUpdated || m.Event == streamResync
+	})
+}
+
+// serveStream writes the messages feed accepts as Server-Sent Events until
+// the client goes away or the server shuts down. Repeated
+// hashtag parameters narrow the stream to quotes with any of those hashtags.
+func (app *application) serveStream(w http.ResponseWriter, r *http.Request, feed func(stream.Message) bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		app.serverError(w, r, errors.New("streaming is not supported by the response writer"))
+		return
+	}
+
+	var hashtags []string
+	for _, value := range r.URL.Query()["hashtag"] {
+		hashtags = append(hashtags, strings.ToLower(strings.TrimPrefix(strings.TrimSpace(value), "#")))
+	}
+
+	filter := func(m stream.Message) bool {
+		if !feed(m) {
+			return false
+		}
+		if len(hashtags) == 0 || m.Event == streamResync {
+			return true
+		}
+		for _, tag := range m.Tags {
+			if slices.Contains(hashtags, tag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	rc := http.NewResponseController(w)
+
+	err := rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// send writes under a fresh deadline, so that the stream lasts as long as
+	// the client keeps reading.
+	send := func(write func() error) bool {
+		if rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout)) != nil || write() != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	sub, replay, resumed := app.stream.Subscribe(r.Header.Get("Last-Event-ID"), filter)
+	defer app.stream.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !resumed {
+		replay = append([]stream.Message{{Event: streamResync, Data: []byte("{}")}}, replay...)
+	}
+
+	ok = send(func() error {
+		for _, m := range replay {
+			if err := stream.Write(w, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !ok {
+		return
+	}
+
+	var heartbeat <-chan time.Time
+	if interval := app.config.Stream.Heartbeat; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case m, ok := <-sub.C:
+			if !ok || !send(func() error { return stream.Write(w, m) }) {
+				return
+			}
+		case <-heartbeat:
+			if !send(func() error { return stream.Comment(w, "ping") }) {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
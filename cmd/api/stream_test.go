@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/events"
+)
+
+// TestStreamPublishedOnce checks that the public feed announces a quote once,
+// when it goes live, and reports later changes as updates.
+func TestStreamPublishedOnce(t *testing.T) {
+	ts := newTestServer(t, newTestApplication(t))
+
+	queue := make(chan events.Event, 16)
+	ts.app.events.Subscribe(ts.app.streamEvents(queue))
+	go ts.app.feedStream(queue)
+
+	_, admin := ts.registerUser(t, testSudoer)
+	_, reader := ts.registerUser(t, "reader@example.org")
+
+	req, err := http.NewRequest("GET", ts.URL+"/v1/stream/quotes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+reader)
+
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+
+	received := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+				received <- name
+			}
+		}
+	}()
+
+	next := func(want string) {
+		t.Helper()
+
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("stream sent %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("stream sent nothing, want %q", want)
+		}
+	}
+
+	quote := ts.testQuote(t, admin, "No man is free who is not master of himself.")
+	moderation := map[string]string{"id": quote.ID.String(), "stateId": ts.publicState(t, admin)}
+
+	ts.mustDo(t, http.StatusOK, "PATCH", "/v1/quote/set-state", admin, moderation)
+	next(streamQuotePublished)
+
+	// Saving the same state again and editing the live quote publish nothing
+	// new; the edit is an update.
+	ts.mustDo(t, http.StatusOK, "PATCH", "/v1/quote/set-state", admin, moderation)
+	ts.mustDo(t, http.StatusOK, "DELETE", "/v1/quote/"+quote.ID.String()+"/source", admin, nil)
+	next(streamQuoteUpdated)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/validator"
+
+	"github.com/google/uuid"
+)
+
+const syncTokenPrefix = "v2:"
+
+type TombstoneResponse struct {
+	Entity string    `json:"entity"`
+	ID     uuid.UUID `json:"id"`
+}
+
+// encodeSyncToken wraps a sync cursor into the opaque token handed to
+// clients.
+func encodeSyncToken(cursor database.SyncCursor) string {
+	token := syncTokenPrefix + strconv.FormatInt(cursor.XID, 10) + "." + strconv.FormatInt(cursor.Seq, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(token))
+}
+
+func decodeSyncToken(token string) (database.SyncCursor, error) {
+	invalid := errors.New("invalid sync token")
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return database.SyncCursor{}, invalid
+	}
+
+	rest, ok := strings.CutPrefix(string(b), syncTokenPrefix)
+	if !ok {
+		return database.SyncCursor{}, invalid
+	}
+
+	xid, seq, ok := strings.Cut(rest, ".")
+	if !ok {
+		return database.SyncCursor{}, invalid
+	}
+
+	var cursor database.SyncCursor
+
+	cursor.XID, err = strconv.ParseInt(xid, 10, 64)
+	if err != nil || cursor.XID < 0 {
+		return database.SyncCursor{}, invalid
+	}
+
+	cursor.Seq, err = strconv.ParseInt(seq, 10, 64)
+	if err != nil || cursor.Seq < 0 {
+		return database.SyncCursor{}, invalid
+	}
+
+	return cursor, nil
+}
+
+// sync returns what changed since the since token: the public quotes,
+// hashtags, photos and states created or updated, and tombstones for deleted
+// rows and for quotes that are no longer public. Without a token everything
+// is returned. Clients keep calling with the next token while hasMore is set.
+// Public translations are synced as quotes of their own, with originalID set,
+// so that clients can choose a language offline.
+func (app *application) sync(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.db.(database.SyncStore)
+	if !ok {
+		app.notSupported(w, r)
+		return
+	}
+
+	var input struct {
+		Since     database.SyncCursor
+		Limit     int
+		Validator validator.Validator
+	}
+
+	qs := r.URL.Query()
+
+	if token := request.ReadString(qs, "since", ""); token != "" {
+		cursor, err := decodeSyncToken(token)
+		if err != nil {
+			input.Validator.AddFieldError("since", "invalid sync token")
+		}
+		input.Since = cursor
+	}
+
+	input.Limit = request.ReadInt(qs, "limit", 500, &input.Validator)
+	input.Validator.CheckField(validator.Between(input.Limit, 1, 1000), "limit", "must be between 1 and 1000")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	changes, err := store.GetChanges(r.Context(), input.Since, input.Limit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	quotes := make([]QuoteResponse, 0, len(changes.Quotes))
+	for i := range changes.Quotes {
+		quotes = append(quotes, newQuoteResponse(&changes.Quotes[i]))
+	}
+
+	hashtags := make([]HashtagResponse, 0, len(changes.Hashtags))
+	for _, hashtag := range changes.Hashtags {
+		hashtags = append(hashtags, HashtagResponse{ID: hashtag.ID, Value: hashtag.Value})
+	}
+
+	photos := make([]PhotoResponse, 0, len(changes.Photos))
+	for _, photo := range changes.Photos {
+		photos = append(photos, PhotoResponse{ID: photo.ID, Color: photo.Color, BlurHash: photo.BlurHash, Author: photo.Author, Url: photo.Url})
+	}
+
+	states := make([]StateResponse, 0, len(changes.States))
+	for _, state := range changes.States {
+		states = append(states, StateResponse{ID: state.ID, Value: state.Value, IdDefault: state.IsDefault, Color: state.Color, IsPublic: state.IsPublic})
+	}
+
+	tombstones := make([]TombstoneResponse, 0, len(changes.Tombstones))
+	for _, tombstone := range changes.Tombstones {
+		tombstones = append(tombstones, TombstoneResponse{Entity: tombstone.Entity, ID: tombstone.ID})
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{
+		"quotes":     quotes,
+		"hashtags":   hashtags,
+		"photos":     photos,
+		"states":     states,
+		"tombstones": tombstones,
+		"next":       encodeSyncToken(changes.Next),
+		"hasMore":    changes.HasMore,
+	}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
+	"javlonrahimov/quotes-api/internal/filters"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/telegram"
+	"javlonrahimov/quotes-api/internal/validator"
+
+	"github.com/alexedwards/flow"
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+)
+
+var telegramPostStatuses = []string{database.TelegramPostPending, database.TelegramPostPosted, database.TelegramPostFailed}
+
+// telegramJob asks postToTelegram to post a quote. Post is set on reposts,
+// which are logged before they are queued; automatic posts are logged by the
+// worker, once it knows the quote is public and not posted yet.
+type telegramJob struct {
+	quoteID uuid.UUID
+	post    *database.TelegramPost
+}
+
+type TelegramPostResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	QuoteID   uuid.UUID  `json:"quoteID"`
+	ChatID    string     `json:"chatID"`
+	IsRepost  bool       `json:"isRepost"`
+	Status    string     `json:"status"`
+	MessageID *int64     `json:"messageID,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	CreatedBy *uuid.UUID `json:"createdBy,omitempty"`
+	CreatedAt string     `json:"createdAt"`
+	PostedAt  string     `json:"postedAt,omitempty"`
+}
+
+func newTelegramPostResponse(post *database.TelegramPost) TelegramPostResponse {
+	data := TelegramPostResponse{
+		ID:        post.ID,
+		QuoteID:   post.QuoteID,
+		ChatID:    post.ChatID,
+		IsRepost:  post.IsRepost,
+		Status:    post.Status,
+		MessageID: post.MessageID,
+		Error:     post.Error,
+		CreatedBy: post.CreatedBy,
+		CreatedAt: post.CreatedAt.Format(time.RFC3339),
+	}
+
+	if post.PostedAt != nil {
+		data.PostedAt = post.PostedAt.Format(time.RFC3339)
+	}
+
+	return data
+}
+
+// getTelegramPosts lists the posting log, of a single quote when the route
+// names one.
+func (app *application) getTelegramPosts(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		QuoteID *uuid.UUID
+		Status  string
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	store, ok := app.db.(database.TelegramPostStore)
+	if !ok {
+		app.notSupported(w, r)
+		return
+	}
+
+	if param := flow.Param(r.Context(), "id"); param != "" {
+		quoteID, err := uuid.Parse(param)
+		if err != nil {
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+			return
+		}
+		input.QuoteID = &quoteID
+	}
+
+	qs := r.URL.Query()
+	input.Status = request.ReadString(qs, "status", "")
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+	input.Filters.Sort = "-created_at"
+	input.Filters.SortSafeList = []string{"-created_at"}
+
+	if input.Status != "" {
+		input.Validator.CheckField(validator.In(input.Status, telegramPostStatuses...), "status", "must be one of "+strings.Join(telegramPostStatuses, ", "))
+	}
+
+	if filters.ValidateFilters(&input.Validator, input.Filters); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	posts, metadata, err := store.GetTelegramPosts(r.Context(), input.QuoteID, input.Status, input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := make([]TelegramPostResponse, 0, len(posts))
+	for i := range posts {
+		data = append(data, newTelegramPostResponse(&posts[i]))
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// repostToTelegram posts a public quote to the channel again, whether or not
+// it was posted before. The post is queued and sent in turn with the others.
+func (app *application) repostToTelegram(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.db.(database.TelegramPostStore)
+	if !ok {
+		app.notSupported(w, r)
+		return
+	}
+
+	if app.telegram == nil {
+		app.errorMessage(w, r, http.StatusNotImplemented, "posting to Telegram is not enabled", nil)
+		return
+	}
+
+	quoteID, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		return
+	}
+
+	quote, err := app.db.GetQuoteById(r.Context(), quoteID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if !quote.State.IsPublic || quote.IsScheduled() {
+		app.errorMessage(w, r, http.StatusConflict, "only public quotes can be posted", nil)
+		return
+	}
+
+	post := &database.TelegramPost{
+		QuoteID:   quote.ID,
+		ChatID:    app.config.TelegramPublisher.ChannelID,
+		IsRepost:  true,
+		CreatedBy: &contextGetAuthenticatedUser(r).ID,
+	}
+
+	err = store.InsertTelegramPost(r.Context(), post)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	select {
+	case app.telegram <- telegramJob{quoteID: quote.ID, post: post}:
+	default:
+		post.Status = database.TelegramPostFailed
+		post.Error = "queue full"
+
+		err = store.UpdateTelegramPost(r.Context(), post)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.errorMessage(w, r, http.StatusServiceUnavailable, "too many posts are waiting, try again later", nil)
+		return
+	}
+
+	err = response.JSON(w, http.StatusAccepted, getWrapper(newTelegramPostResponse(post)))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// telegramEvents hands events of quotes that may have gone public to
+// postToTelegram. Only the instance that published an event acts on it.
+func (app *application) telegramEvents(queue chan<- telegramJob) func(events.Event) {
+	return func(e events.Event) {
+		if e.Origin != app.events.Origin() {
+			return
+		}
+
+		switch e.Type {
+		case events.QuoteCreated, events.QuoteStateChanged, events.QuotePublished:
+		default:
+			return
+		}
+
+		select {
+		case queue <- telegramJob{quoteID: e.ID}:
+		default:
+			app.logger.Warning("telegram: queue full, dropped %s event for %s", e.Type, e.ID)
+		}
+	}
+}
+
+// postToTelegram posts queued quotes one at a time, no more often than the
+// configured interval allows. The posting log keeps a quote from being posted
+// automatically twice, even when it goes public again.
+func (app *application) postToTelegram(store database.TelegramPostStore, client *telegram.Client, queue <-chan telegramJob) {
+	limiter := rate.NewLimiter(rate.Every(app.config.TelegramPublisher.Interval), 1)
+
+	for job := range queue {
+		quote, err := app.db.GetQuoteById(context.Background(), job.quoteID)
+		if err != nil {
+			if !errors.Is(err, database.ErrRecordNotFound) {
+				app.logger.Error(err)
+			}
+			continue
+		}
+
+		post := job.post
+		if post == nil {
+			if !quote.State.IsPublic || quote.IsScheduled() {
+				continue
+			}
+
+			post = &database.TelegramPost{QuoteID: quote.ID, ChatID: app.config.TelegramPublisher.ChannelID}
+
+			err = store.InsertTelegramPost(context.Background(), post)
+			if err != nil {
+				if !errors.Is(err, database.ErrDuplicateTelegramPost) && !errors.Is(err, database.ErrRecordNotFound) {
+					app.logger.Error(err)
+				}
+				continue
+			}
+		}
+
+		messageID, err := app.sendTelegramPost(client, limiter, post.ChatID, quote)
+
+		now := time.Now()
+		if err != nil {
+			post.Status = database.TelegramPostFailed
+			post.Error = err.Error()
+			app.logger.Warning("telegram: posting quote %s failed: %s", quote.ID, err)
+		} else {
+			post.Status = database.TelegramPostPosted
+			post.MessageID = &messageID
+			post.PostedAt = &now
+		}
+
+		err = store.UpdateTelegramPost(context.Background(), post)
+		if err != nil {
+			app.logger.Error(err)
+		}
+	}
+}
+
+// sendTelegramPost sends the quote, waiting for the limiter first. When
+// Telegram still asks to slow down, it waits as long as told and tries again,
+// a few times at most.
+func (app *application) sendTelegramPost(client *telegram.Client, limiter *rate.Limiter, chatID string, quote *database.Quote) (int64, error) {
+	text, photoURL := telegramMessage(quote)
+
+	var err error
+
+	for i := 0; i < 3; i++ {
+		err = limiter.Wait(context.Background())
+		if err != nil {
+			return 0, err
+		}
+
+		var messageID int64
+		if photoURL != "" {
+			messageID, err = client.SendPhoto(context.Background(), chatID, photoURL, text)
+		} else {
+			messageID, err = client.SendMessage(context.Background(), chatID, text)
+		}
+
+		var retryErr *telegram.RetryAfterError
+		if !errors.As(err, &retryErr) {
+			return messageID, err
+		}
+
+		time.Sleep(retryErr.After)
+	}
+
+	return 0, err
+}
+
+// telegramMessage formats a quote as HTML for Telegram: the text, the author
+// and the hashtags. The photo, when there is one, is sent with the text as its
+// caption, or linked below the text when that is too long for a caption.
+func telegramMessage(quote *database.Quote) (text, photoURL string) {
+	var plain, b strings.Builder
+
+	fmt.Fprintf(&plain, "“%s”", quote.Text)
+	fmt.Fprintf(&b, "“%s”", html.EscapeString(quote.Text))
+
+	if quote.Author != "" {
+		fmt.Fprintf(&plain, "\n\n— %s", quote.Author)
+		fmt.Fprintf(&b, "\n\n— <b>%s</b>", html.EscapeString(quote.Author))
+	}
+
+	var tags []string
+	for _, hashtag := range quote.Hashtags {
+		if tag := telegramHashtag(hashtag.Value); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(tags) > 0 {
+		fmt.Fprintf(&plain, "\n\n%s", strings.Join(tags, " "))
+		fmt.Fprintf(&b, "\n\n%s", html.EscapeString(strings.Join(tags, " ")))
+	}
+
+	if quote.Photo == nil || quote.Photo.Url == "" {
+		return b.String(), ""
+	}
+
+	if utf8.RuneCountInString(plain.String()) > telegram.CaptionLimit {
+		fmt.Fprintf(&b, "\n\n%s", html.EscapeString(quote.Photo.Url))
+		return b.String(), ""
+	}
+
+	return b.String(), quote.Photo.Url
+}
+
+// telegramHashtag turns a hashtag value into a Telegram hashtag, which may
+// only hold letters, digits and underscores.
+func telegramHashtag(value string) string {
+	tag := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '_':
+			return r
+		case unicode.IsSpace(r), r == '-':
+			return '_'
+		default:
+			return -1
+		}
+	}, strings.TrimPrefix(value, "#"))
+
+	if tag == "" {
+		return ""
+	}
+
+	return "#" + tag
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"javlonrahimov/quotes-api/config"
+	"javlonrahimov/quotes-api/internal/bundle"
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/database/memory"
+	"javlonrahimov/quotes-api/internal/events"
+	"javlonrahimov/quotes-api/internal/leveledlog"
+	"javlonrahimov/quotes-api/internal/stream"
+)
+
+const testSudoer = "admin@example.org"
+
+// testMailer hands the OTPs the application sends to the test instead of
+// mailing them.
+type testMailer struct {
+	mu   sync.Mutex
+	otps map[string]chan string
+}
+
+func (m *testMailer) inbox(recipient string) chan string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.otps[recipient] == nil {
+		m.otps[recipient] = make(chan string, 8)
+	}
+	return m.otps[recipient]
+}
+
+func (m *testMailer) Send(recipient, plainOTP string, data any, patterns ...string) error {
+	m.inbox(recipient) <- plainOTP
+	return nil
+}
+
+// newTestApplication returns an application backed by the in-memory store.
+// configure, when given, adjusts the configuration before the application is
+// built.
+func newTestApplication(t *testing.T, configure ...func(*config.Config)) *application {
+	t.Helper()
+
+	var cfg config.Config
+	cfg.JWT.SecretKey = "abcdefghijklmnopqrstuvwxyz123456"
+	cfg.Sudoers = []string{testSudoer}
+	cfg.Comments.EditWindow = 15 * time.Minute
+	cfg.Reports.Threshold = 3
+	cfg.Bundle.MaxAge = time.Minute
+	cfg.Cache.TTL = time.Minute
+	cfg.Cache.Size = 100
+
+	for _, fn := range configure {
+		fn(&cfg)
+	}
+
+	return newTestApplicationWithStore(t, cfg, memory.New())
+}
+
+func newTestApplicationWithStore(t *testing.T, cfg config.Config, db database.Store) *application {
+	t.Helper()
+
+	logger := leveledlog.NewLogger(io.Discard, leveledlog.LevelAll, false)
+
+	bus := events.NewLocal(logger)
+	t.Cleanup(func() { bus.Close() })
+
+	app := &application{
+		config:  cfg,
+		db:      db,
+		logger:  logger,
+		mailer:  &testMailer{otps: make(map[string]chan string)},
+		bundles: bundle.NewCache(cfg.Bundle.MaxAge),
+		caches:  newCaches(cfg.Cache.TTL, cfg.Cache.Size),
+		events:  bus,
+		stream:  stream.NewHub(100),
+	}
+
+	bus.Subscribe(app.handleEvent)
+
+	return app
+}
+
+type testServer struct {
+	*httptest.Server
+	app      *application
+	hashtags int
+}
+
+func newTestServer(t *testing.T, app *application) *testServer {
+	t.Helper()
+
+	ts := httptest.NewServer(app.routes())
+	t.Cleanup(ts.Close)
+
+	return &testServer{Server: ts, app: app}
+}
+
+// testResponse is a decoded response envelope.
+type testResponse struct {
+	status  int
+	header  http.Header
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// decode unmarshals the data of the envelope into dst.
+func (res *testResponse) decode(t *testing.T, dst any) {
+	t.Helper()
+
+	err := json.Unmarshal(res.Data, dst)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", res.Data, err)
+	}
+}
+
+// do sends a request with an optional JSON body and bearer token.
+func (ts *testServer) do(t *testing.T, method, path, token string, body any, headers ...string) *testResponse {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, ts.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := &testResponse{status: res.StatusCode, header: res.Header}
+
+	if len(bytes.TrimSpace(b)) > 0 && strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		err = json.Unmarshal(b, result)
+		if err != nil {
+			t.Fatalf("%s %s: decoding %s: %v", method, path, b, err)
+		}
+	}
+
+	return result
+}
+
+// mustDo is do that fails the test unless the response has the given status.
+func (ts *testServer) mustDo(t *testing.T, status int, method, path, token string, body any, headers ...string) *testResponse {
+	t.Helper()
+
+	res := ts.do(t, method, path, token, body, headers...)
+	if res.status != status {
+		t.Fatalf("%s %s: got status %d, want %d: %s", method, path, res.status, status, res.Data)
+	}
+
+	return res
+}
+
+// registerUser registers and verifies a user with the OTP the application
+// sends, and returns the user's ID and auth token.
+func (ts *testServer) registerUser(t *testing.T, email string) (string, string) {
+	t.Helper()
+
+	res := ts.mustDo(t, http.StatusOK, "POST", "/v1/register", "", map[string]string{
+		"email":    email,
+		"password": "correct-horse-battery",
+		"name":     "Test User",
+	})
+
+	var registered struct {
+		UserID string `json:"userID"`
+	}
+	res.decode(t, &registered)
+
+	var otp string
+	select {
+	case otp = <-ts.app.mailer.(*testMailer).inbox(email):
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no OTP sent to %s", email)
+	}
+
+	res = ts.mustDo(t, http.StatusOK, "POST", "/v1/verify", "", map[string]string{
+		"email": email,
+		"otp":   otp,
+	})
+
+	var verified AuthResponse
+	res.decode(t, &verified)
+
+	if !verified.IsActivated || verified.AuthToken == "" {
+		t.Fatalf("verify: got %+v, want an activated user with a token", verified)
+	}
+
+	return registered.UserID, verified.AuthToken
+}
+
+// testQuote creates a photo, a hashtag and a quote as the user with token,
+// and returns the quote.
+func (ts *testServer) testQuote(t *testing.T, token, text string) QuoteResponse {
+	t.Helper()
+
+	var photo struct {
+		ID string `json:"id"`
+	}
+	ts.mustDo(t, http.StatusOK, "POST", "/v1/photo", token, map[string]string{
+		"color":    "#FFFFFF",
+		"blurHash": "LEHV6nWB2yk8pyo0adR*.7kCMdnj",
+		"author":   "Photographer",
+		"url":      "https://example.org/" + strings.ReplaceAll(text, " ", "-") + ".jpg",
+	}).decode(t, &photo)
+
+	ts.hashtags++
+
+	var hashtag struct {
+		ID string `json:"id"`
+	}
+	ts.mustDo(t, http.StatusOK, "POST", "/v1/hashtag", token, map[string]string{
+		"value": fmt.Sprintf("wisdom%d", ts.hashtags),
+	}).decode(t, &hashtag)
+
+	var quote QuoteResponse
+	ts.mustDo(t, http.StatusOK, "POST", "/v1/quote", token, map[string]any{
+		"author":     "Marcus Aurelius",
+		"text":       text,
+		"photoID":    photo.ID,
+		"hashtagIDs": []string{hashtag.ID},
+	}).decode(t, &quote)
+
+	return quote
+}
+
+// publicState returns the ID of a quote state that is public.
+func (ts *testServer) publicState(t *testing.T, token string) string {
+	t.Helper()
+
+	var states struct {
+		Data []StateResponse `json:"data"`
+	}
+	ts.mustDo(t, http.StatusOK, "GET", "/v1/quote/states", token, nil).decode(t, &states)
+
+	for _, state := range states.Data {
+		if state.IsPublic {
+			return state.ID.String()
+		}
+	}
+
+	t.Fatal("no public quote state")
+	return ""
+}
+
+// publishedQuote creates a quote as admin and moves it to a public state.
+func (ts *testServer) publishedQuote(t *testing.T, admin, text string) QuoteResponse {
+	t.Helper()
+
+	quote := ts.testQuote(t, admin, text)
+	ts.mustDo(t, http.StatusOK, "PATCH", "/v1/quote/set-state", admin, map[string]string{
+		"id":      quote.ID.String(),
+		"stateId": ts.publicState(t, admin),
+	})
+
+	return quote
+}
+
+// waitFor polls cond until it holds or a few seconds have passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/validator"
+
+	"github.com/alexedwards/flow"
+	"github.com/google/uuid"
+)
+
+// translateQuotes replaces the author and text of each quote with its public
+// translation that best matches the Accept-Language header. The quote keeps
+// its own id, so reactions and comments stay on the original.
+func (app *application) translateQuotes(r *http.Request, quotes []QuoteResponse) error {
+	languages := request.AcceptLanguages(r)
+	if len(languages) == 0 || len(quotes) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(quotes))
+	for i := range quotes {
+		ids[i] = quotes[i].ID
+	}
+
+	translations, err := app.db.GetQuoteTranslations(r.Context(), ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range quotes {
+		if len(translations[quotes[i].ID]) == 0 {
+			continue
+		}
+
+		for _, language := range languages {
+			if quotes[i].Language == language {
+				break
+			}
+
+			translation := findTranslation(translations[quotes[i].ID], language)
+			if translation == nil {
+				continue
+			}
+
+			quotes[i].Author = translation.Author
+			quotes[i].Text = translation.Text
+			quotes[i].Language = translation.Language
+			quotes[i].TranslationID = &translation.ID
+			break
+		}
+	}
+
+	return nil
+}
+
+func findTranslation(translations []database.Quote, language string) *database.Quote {
+	for i := range translations {
+		if translations[i].Language == language {
+			return &translations[i]
+		}
+	}
+
+	return nil
+}
+
+func (app *application) createTranslation(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Language  string              `json:"language"`
+		Author    string              `json:"author"`
+		Text      string              `json:"text"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	quoteID, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		return
+	}
+
+	err = request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Language = strings.ToLower(strings.TrimSpace(input.Language))
+	input.Author = strings.TrimSpace(input.Author)
+
+	input.Validator.CheckField(validator.Matches(input.Language, validator.RgxLanguage), "language", "must be an ISO 639 language code")
+	input.Validator.CheckField(len(input.Text) > 10, "text", "min length must be at least 10 characters long")
+	input.Validator.CheckField(input.Author == "" || len(input.Author) > 2, "author", "invalid author name")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	original, err := app.db.GetQuoteById(r.Context(), quoteID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if input.Author == "" {
+		input.Author = original.Author
+	}
+
+	quote, err := app.db.InsertTranslation(r.Context(), original.ID, input.Language, input.Author, input.Text, contextGetAuthenticatedUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		case errors.Is(err, database.ErrDuplicateLanguage):
+			input.Validator.AddFieldError("language", "the quote is already available in this language")
+			app.failedValidation(w, r, input.Validator)
+		case errors.Is(err, database.ErrDefaultState):
+			app.errorMessage(w, r, http.StatusConflict, "no default quote state is set", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.publish(events.QuoteCreated, quote.ID)
+
+	err = response.JSON(w, http.StatusCreated, getWrapper(newQuoteResponse(quote)))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// getQuoteTranslations lists the original of a quote followed by its public
+// translations. Any quote of the group can be used as the id.
+func (app *application) getQuoteTranslations(w http.ResponseWriter, r *http.Request) {
+	quoteID, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		return
+	}
+
+	quote, err := app.db.GetQuoteById(r.Context(), quoteID)
+	if err == nil && quote.OriginalID != nil {
+		quote, err = app.db.GetQuoteById(r.Context(), *quote.OriginalID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	translations, err := app.db.GetQuoteTranslations(r.Context(), []uuid.UUID{quote.ID})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := []QuoteResponse{newQuoteResponse(quote)}
+	for i := range translations[quote.ID] {
+		data = append(data, newQuoteResponse(&translations[quote.ID][i]))
+	}
+
+	err = app.setReactions(r, data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(data))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) setQuoteLanguage(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Language  string              `json:"language"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	quote, ok := app.readEditableQuote(w, r)
+	if !ok {
+		return
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Language = strings.ToLower(strings.TrimSpace(input.Language))
+
+	input.Validator.CheckField(validator.Matches(input.Language, validator.RgxLanguage), "language", "must be an ISO 639 language code")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	err = app.db.SetQuoteLanguage(r.Context(), quote.ID, input.Language)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "quote not found", nil)
+		case errors.Is(err, database.ErrDuplicateLanguage):
+			input.Validator.AddFieldError("language", "the quote is already available in this language")
+			app.failedValidation(w, r, input.Validator)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.publish(events.QuoteUpdated, quote.ID)
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"quoteID": quote.ID, "language": input.Language}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
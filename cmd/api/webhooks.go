@@ -0,0 +1,653 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/events"
+	"javlonrahimov/quotes-api/internal/filters"
+	"javlonrahimov/quotes-api/internal/request"
+	"javlonrahimov/quotes-api/internal/response"
+	"javlonrahimov/quotes-api/internal/validator"
+	"javlonrahimov/quotes-api/internal/webhook"
+
+	"github.com/alexedwards/flow"
+	"github.com/google/uuid"
+)
+
+// Event types webhooks can subscribe to. quote.published is sent whenever a
+// quote becomes visible to the public: it entered a public state or its
+// scheduled time came.
+const webhookQuotePublished = "quote.published"
+
+var webhookEventTypes = []string{events.QuoteCreated, events.QuoteUpdated, events.QuoteDeleted, events.QuoteStateChanged, webhookQuotePublished}
+
+var deliveryStatuses = []string{database.DeliveryPending, database.DeliverySucceeded, database.DeliveryFailed}
+
+type WebhookResponse struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	Secret       string    `json:"secret,omitempty"`
+	EventTypes   []string  `json:"eventTypes"`
+	IsActive     bool      `json:"isActive"`
+	FailureCount int       `json:"failureCount"`
+	DisabledAt   string    `json:"disabledAt,omitempty"`
+	CreatedBy    uuid.UUID `json:"createdBy"`
+	CreatedAt    string    `json:"createdAt"`
+	UpdatedAt    string    `json:"updatedAt"`
+}
+
+// newWebhookResponse leaves the secret out; it is only shown when it is set.
+func newWebhookResponse(webhook *database.Webhook) WebhookResponse {
+	data := WebhookResponse{
+		ID:           webhook.ID,
+		URL:          webhook.URL,
+		EventTypes:   webhook.EventTypes,
+		IsActive:     webhook.IsActive,
+		FailureCount: webhook.FailureCount,
+		CreatedBy:    webhook.CreatedBy,
+		CreatedAt:    webhook.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:    webhook.UpdatedAt.Format(time.RFC3339),
+	}
+
+	if data.EventTypes == nil {
+		data.EventTypes = []string{}
+	}
+
+	if webhook.DisabledAt != nil {
+		data.DisabledAt = webhook.DisabledAt.Format(time.RFC3339)
+	}
+
+	return data
+}
+
+type DeliveryResponse struct {
+	ID             uuid.UUID         `json:"id"`
+	WebhookID      uuid.UUID         `json:"webhookID"`
+	EventID        uuid.UUID         `json:"eventID"`
+	EventType      string            `json:"eventType"`
+	Payload        json.RawMessage   `json:"payload,omitempty"`
+	Status         string            `json:"status"`
+	Attempts       int               `json:"attempts"`
+	NextAttemptAt  string            `json:"nextAttemptAt,omitempty"`
+	LastStatusCode *int              `json:"lastStatusCode,omitempty"`
+	LastError      string            `json:"lastError,omitempty"`
+	DeliveredAt    string            `json:"deliveredAt,omitempty"`
+	CreatedAt      string            `json:"createdAt"`
+	AttemptLog     []AttemptResponse `json:"attemptLog,omitempty"`
+}
+
+type AttemptResponse struct {
+	StatusCode  *int   `json:"statusCode,omitempty"`
+	Error       string `json:"error,omitempty"`
+	DurationMs  int64  `json:"durationMs"`
+	AttemptedAt string `json:"attemptedAt"`
+}
+
+func newDeliveryResponse(d *database.WebhookDelivery) DeliveryResponse {
+	data := DeliveryResponse{
+		ID:             d.ID,
+		WebhookID:      d.WebhookID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt.Format(time.RFC3339),
+	}
+
+	if d.Status == database.DeliveryPending {
+		data.NextAttemptAt = d.NextAttemptAt.Format(time.RFC3339)
+	}
+
+	if d.DeliveredAt != nil {
+		data.DeliveredAt = d.DeliveredAt.Format(time.RFC3339)
+	}
+
+	return data
+}
+
+func (app *application) webhookStore(w http.ResponseWriter, r *http.Request) (database.WebhookStore, bool) {
+	store, ok := app.db.(database.WebhookStore)
+	if !ok {
+		app.notSupported(w, r)
+	}
+	return store, ok
+}
+
+func (app *application) readWebhook(w http.ResponseWriter, r *http.Request, store database.WebhookStore) (*database.Webhook, bool) {
+	id, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "webhook not found", nil)
+		return nil, false
+	}
+
+	webhook, err := store.GetWebhook(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "webhook not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+func validateWebhook(v *validator.Validator, webhook *database.Webhook) {
+	v.CheckField(validator.IsURL(webhook.URL), "url", "must be a valid URL")
+	v.CheckField(strings.HasPrefix(webhook.URL, "https://") || strings.HasPrefix(webhook.URL, "http://"), "url", "must be an http or https URL")
+	v.CheckField(validator.AllIn(webhook.EventTypes, webhookEventTypes...), "eventTypes", "must be any of "+strings.Join(webhookEventTypes, ", "))
+	v.CheckField(validator.NoDuplicates(webhook.EventTypes), "eventTypes", "must not contain duplicates")
+}
+
+// createWebhook subscribes a URL to quote events. The signing secret is
+// generated and returned only here and when it is rotated.
+func (app *application) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL        string              `json:"url"`
+		EventTypes []string            `json:"eventTypes"`
+		Validator  validator.Validator `json:"-"`
+	}
+
+	store, ok := app.webhookStore(w, r)
+	if !ok {
+		return
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	hook := &database.Webhook{
+		URL:        strings.TrimSpace(input.URL),
+		Secret:     secret,
+		EventTypes: input.EventTypes,
+		CreatedBy:  contextGetAuthenticatedUser(r).ID,
+	}
+
+	if validateWebhook(&input.Validator, hook); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	err = store.InsertWebhook(r.Context(), hook)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := newWebhookResponse(hook)
+	data.Secret = hook.Secret
+
+	err = response.JSON(w, http.StatusCreated, getWrapper(data))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	store, ok := app.webhookStore(w, r)
+	if !ok {
+		return
+	}
+
+	qs := r.URL.Query()
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+
+	input.Filters.Sort = "created_at"
+	input.Filters.SortSafeList = []string{"created_at"}
+
+	if filters.ValidateFilters(&input.Validator, input.Filters); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	webhooks, metadata, err := store.GetWebhooks(r.Context(), input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := make([]WebhookResponse, 0, len(webhooks))
+	for i := range webhooks {
+		data = append(data, newWebhookResponse(&webhooks[i]))
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) getWebhook(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.webhookStore(w, r)
+	if !ok {
+		return
+	}
+
+	hook, ok := app.readWebhook(w, r, store)
+	if !ok {
+		return
+	}
+
+	err := response.JSON(w, http.StatusOK, getWrapper(newWebhookResponse(hook)))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// updateWebhook changes the URL or event types of a webhook, turns it on or
+// off, or rotates its secret. Turning a disabled webhook back on resets its
+// failure count; deliveries still pending are then sent again.
+func (app *application) updateWebhook(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL          *string             `json:"url"`
+		EventTypes   []string            `json:"eventTypes"`
+		IsActive     *bool               `json:"isActive"`
+		RotateSecret bool                `json:"rotateSecret"`
+		Validator    validator.Validator `json:"-"`
+	}
+
+	store, ok := app.webhookStore(w, r)
+	if !ok {
+		return
+	}
+
+	hook, ok := app.readWebhook(w, r, store)
+	if !ok {
+		return
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if input.URL != nil {
+		hook.URL = strings.TrimSpace(*input.URL)
+	}
+	if input.EventTypes != nil {
+		hook.EventTypes = input.EventTypes
+	}
+	if input.IsActive != nil {
+		hook.IsActive = *input.IsActive
+	}
+	if input.RotateSecret {
+		hook.Secret, err = webhook.NewSecret()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if validateWebhook(&input.Validator, hook); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	err = store.UpdateWebhook(r.Context(), hook)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "webhook not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := newWebhookResponse(hook)
+	if input.RotateSecret {
+		data.Secret = hook.Secret
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(data))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.webhookStore(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "webhook not found", nil)
+		return
+	}
+
+	err = store.DeleteWebhook(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "webhook not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"webhookID": id}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		filters.Filters
+		Validator validator.Validator
+	}
+
+	store, ok := app.webhookStore(w, r)
+	if !ok {
+		return
+	}
+
+	hook, ok := app.readWebhook(w, r, store)
+	if !ok {
+		return
+	}
+
+	qs := r.URL.Query()
+	input.Status = request.ReadString(qs, "status", "")
+	input.Filters.Page = request.ReadInt(qs, "page", 1, &input.Validator)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, &input.Validator)
+
+	input.Filters.Sort = "-created_at"
+	input.Filters.SortSafeList = []string{"-created_at"}
+
+	if input.Status != "" {
+		input.Validator.CheckField(validator.In(input.Status, deliveryStatuses...), "status", "must be one of "+strings.Join(deliveryStatuses, ", "))
+	}
+
+	if filters.ValidateFilters(&input.Validator, input.Filters); input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	deliveries, metadata, err := store.GetWebhookDeliveries(r.Context(), hook.ID, input.Status, input.Filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := make([]DeliveryResponse, 0, len(deliveries))
+	for i := range deliveries {
+		data = append(data, newDeliveryResponse(&deliveries[i]))
+	}
+
+	err = response.JSON(w, http.StatusOK, getWrapper(envelope{"metadata": metadata, "data": data}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// readWebhookDelivery loads the delivery named in the URL, which must belong
+// to the webhook named there too.
+func (app *application) readWebhookDelivery(w http.ResponseWriter, r *http.Request, store database.WebhookStore) (*database.WebhookDelivery, []database.WebhookAttempt, bool) {
+	webhookID, err := uuid.Parse(flow.Param(r.Context(), "id"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "delivery not found", nil)
+		return nil, nil, false
+	}
+
+	deliveryID, err := uuid.Parse(flow.Param(r.Context(), "deliveryId"))
+	if err != nil {
+		app.errorMessage(w, r, http.StatusNotFound, "delivery not found", nil)
+		return nil, nil, false
+	}
+
+	delivery, attempts, err := store.GetWebhookDelivery(r.Context(), deliveryID)
+	if err == nil && delivery.WebhookID != webhookID {
+		err = database.ErrRecordNotFound
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "delivery not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return nil, nil, false
+	}
+
+	return delivery, attempts, true
+}
+
+// getWebhookDelivery shows a delivery with its payload and every attempt
+// made at it.
+func (app *application) getWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.webhookStore(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, attempts, ok := app.readWebhookDelivery(w, r, store)
+	if !ok {
+		return
+	}
+
+	data := newDeliveryResponse(delivery)
+	data.Payload = json.RawMessage(delivery.Payload)
+	data.AttemptLog = make([]AttemptResponse, 0, len(attempts))
+
+	for _, attempt := range attempts {
+		data.AttemptLog = append(data.AttemptLog, AttemptResponse{
+			StatusCode:  attempt.StatusCode,
+			Error:       attempt.Error,
+			DurationMs:  attempt.Duration.Milliseconds(),
+			AttemptedAt: attempt.AttemptedAt.Format(time.RFC3339),
+		})
+	}
+
+	err := response.JSON(w, http.StatusOK, getWrapper(data))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// redeliverWebhookDelivery queues a delivery to be sent again right away,
+// with the same payload and delivery ID so that receivers can spot repeats.
+func (app *application) redeliverWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	store, ok := app.webhookStore(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, _, ok := app.readWebhookDelivery(w, r, store)
+	if !ok {
+		return
+	}
+
+	err := store.RedeliverWebhookDelivery(r.Context(), delivery.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.errorMessage(w, r, http.StatusNotFound, "delivery not found", nil)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusAccepted, getWrapper(envelope{"deliveryID": delivery.ID}))
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// webhookEvents hands quote events published by this instance to
+// enqueueWebhooks. Every instance hears every event, but only the one that
+// published it queues deliveries, so each is sent once.
+func (app *application) webhookEvents(queue chan<- events.Event) func(events.Event) {
+	return func(e events.Event) {
+		if e.Origin != app.events.Origin() {
+			return
+		}
+
+		switch e.Type {
+		case events.QuoteCreated, events.QuoteUpdated, events.QuoteDeleted, events.QuoteStateChanged, events.QuotePublished:
+		default:
+			return
+		}
+
+		select {
+		case queue <- e:
+		default:
+			app.logger.Warning("webhooks: queue full, dropped %s event for %s", e.Type, e.ID)
+		}
+	}
+}
+
+// enqueueWebhooks turns quote events into webhook deliveries.
+func (app *application) enqueueWebhooks(store database.WebhookStore, queue <-chan events.Event) {
+	for e := range queue {
+		var data any = envelope{"id": e.ID}
+		var live bool
+
+		if e.Type != events.QuoteDeleted {
+			quote, err := app.db.GetQuoteById(context.Background(), e.ID)
+			if err != nil {
+				if !errors.Is(err, database.ErrRecordNotFound) {
+					app.logger.Error(err)
+				}
+				continue
+			}
+
+			data = newQuoteResponse(quote)
+			live = quote.IsLive()
+		}
+
+		var types []string
+
+		switch e.Type {
+		case events.QuoteCreated, events.QuoteUpdated, events.QuoteDeleted, events.QuoteStateChanged:
+			types = append(types, e.Type)
+		}
+
+		// As on the live feed, a quote is published when it is created live
+		// or goes live later, not again whenever a live quote changes.
+		if live && (e.Type == events.QuoteCreated || e.Type == events.QuotePublished) {
+			types = append(types, webhookQuotePublished)
+		}
+
+		for _, eventType := range types {
+			eventID := uuid.New()
+
+			payload, err := json.Marshal(envelope{"id": eventID, "type": eventType, "createdAt": e.At.Format(time.RFC3339), "data": data})
+			if err != nil {
+				app.logger.Error(err)
+				continue
+			}
+
+			_, err = store.EnqueueWebhookDeliveries(context.Background(), eventID, eventType, payload)
+			if err != nil {
+				app.logger.Error(err)
+			}
+		}
+	}
+}
+
+// deliverWebhooks periodically sends the deliveries that are due, a batch at
+// a time. Instances share the work: each claims its own deliveries.
+func (app *application) deliverWebhooks(store database.WebhookStore) {
+	if app.config.Webhooks.Interval <= 0 {
+		return
+	}
+
+	client := &http.Client{
+		Timeout: app.config.Webhooks.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	ticker := time.NewTicker(app.config.Webhooks.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deliveries, err := store.ClaimDueWebhookDeliveries(context.Background(), 50, app.config.Webhooks.Timeout+time.Minute)
+		if err != nil {
+			app.logger.Error(err)
+			continue
+		}
+
+		var wg sync.WaitGroup
+
+		for i := range deliveries {
+			wg.Add(1)
+			go func(d *database.WebhookDelivery) {
+				defer wg.Done()
+				app.deliverWebhook(store, client, d)
+			}(&deliveries[i])
+		}
+
+		wg.Wait()
+	}
+}
+
+func (app *application) deliverWebhook(store database.WebhookStore, client *http.Client, d *database.WebhookDelivery) {
+	start := time.Now()
+
+	status, err := webhook.Send(context.Background(), client, d.URL, d.Secret, d.EventType, d.ID.String(), []byte(d.Payload))
+
+	attempt := database.WebhookAttempt{
+		Duration:    time.Since(start),
+		AttemptedAt: start,
+	}
+
+	failed := true
+
+	switch {
+	case err != nil:
+		attempt.Error = err.Error()
+	case status < 200 || status > 299:
+		attempt.StatusCode = &status
+		attempt.Error = fmt.Sprintf("unexpected status %d", status)
+	default:
+		attempt.StatusCode = &status
+		failed = false
+	}
+
+	var next *time.Time
+	if failed && d.Attempts+1 < app.config.Webhooks.MaxAttempts {
+		t := time.Now().Add(webhook.Backoff(d.Attempts + 1))
+		next = &t
+	}
+
+	err = store.RecordWebhookAttempt(context.Background(), d, attempt, next, app.config.Webhooks.MaxFailures)
+	if err != nil {
+		app.logger.Error(err)
+	}
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -10,8 +12,13 @@ type Config struct {
 	BaseURL string
 	Env     string
 	DB      struct {
-		DSN         string
-		Automigrate bool
+		Driver             string
+		DSN                string
+		Automigrate        bool
+		ReadTimeout        time.Duration
+		WriteTimeout       time.Duration
+		QueryTimeouts      map[string]time.Duration
+		SlowQueryThreshold time.Duration
 	}
 	JWT struct {
 		SecretKey string
@@ -39,6 +46,36 @@ type Config struct {
 	Cors struct {
 		TrustedOrigins []string
 	}
+	Comments struct {
+		EditWindow time.Duration
+	}
+	Reports struct {
+		Threshold int
+	}
+	Scheduler struct {
+		Interval time.Duration
+	}
+	Bundle struct {
+		MaxAge time.Duration
+	}
+	Cache struct {
+		TTL  time.Duration
+		Size int
+	}
+	Stream struct {
+		Heartbeat time.Duration
+	}
+	Webhooks struct {
+		Interval    time.Duration
+		Timeout     time.Duration
+		MaxAttempts int
+		MaxFailures int
+	}
+	TelegramPublisher struct {
+		Enabled   bool
+		ChannelID string
+		Interval  time.Duration
+	}
 	Sudoers     []string
 	Version     bool
 	UseTelegram bool
@@ -52,8 +89,30 @@ func GetConfig() Config {
 	flag.StringVar(&cfg.BaseURL, "base-url", "", "base URL for the application")
 	flag.StringVar(&cfg.Env, "env", "development", "operating environment: development, testing, staging or production")
 
-	flag.StringVar(&cfg.DB.DSN, "db-dsn", "", "postgreSQL DSN")
+	flag.StringVar(&cfg.DB.Driver, "db-driver", "postgres", "database driver: postgres or sqlite")
+	flag.StringVar(&cfg.DB.DSN, "db-dsn", "", "postgreSQL DSN or SQLite database file")
 	flag.BoolVar(&cfg.DB.Automigrate, "db-automigrate", true, "run migrations on startup")
+	flag.DurationVar(&cfg.DB.ReadTimeout, "db-read-timeout", 3*time.Second, "timeout for read queries")
+	flag.DurationVar(&cfg.DB.WriteTimeout, "db-write-timeout", 3*time.Second, "timeout for write queries")
+	flag.DurationVar(&cfg.DB.SlowQueryThreshold, "db-slow-query-threshold", 500*time.Millisecond, "log queries slower than this (0 disables)")
+
+	flag.Func("db-query-timeouts", "Per-query timeouts (space separated name=duration pairs, e.g. GetQuotes=5s)", func(val string) error {
+		cfg.DB.QueryTimeouts = make(map[string]time.Duration)
+		for _, pair := range strings.Fields(val) {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid query timeout %q", pair)
+			}
+
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid query timeout %q: %w", pair, err)
+			}
+
+			cfg.DB.QueryTimeouts[name] = timeout
+		}
+		return nil
+	})
 
 	flag.StringVar(&cfg.JWT.SecretKey, "jwt-secret-key", "", "secret key for JWT authentication")
 
@@ -73,6 +132,28 @@ func GetConfig() Config {
 	flag.IntVar(&cfg.Limiter.Burst, "limiter-burst", 4, "rate limiter maximum burst")
 	flag.BoolVar(&cfg.Limiter.Enabled, "limiter-enabled", true, "enable rate limiter")
 
+	flag.DurationVar(&cfg.Comments.EditWindow, "comment-edit-window", 15*time.Minute, "how long after posting a comment can be edited")
+
+	flag.IntVar(&cfg.Reports.Threshold, "report-threshold", 3, "open reports after which a public quote goes back to review (0 disables)")
+
+	flag.DurationVar(&cfg.Scheduler.Interval, "publish-interval", time.Minute, "how often scheduled quotes are checked for going live (0 disables)")
+
+	flag.DurationVar(&cfg.Bundle.MaxAge, "bundle-max-age", 5*time.Minute, "how long the offline bundle is reused on databases without a data version")
+
+	flag.DurationVar(&cfg.Cache.TTL, "cache-ttl", time.Minute, "how long states, hashtags, photos, users and permissions are cached (0 disables)")
+	flag.IntVar(&cfg.Cache.Size, "cache-size", 1000, "maximum entries of each per-user or per-page cache")
+
+	flag.DurationVar(&cfg.Stream.Heartbeat, "stream-heartbeat", 15*time.Second, "interval of keep-alive comments on event streams (0 disables)")
+
+	flag.DurationVar(&cfg.Webhooks.Interval, "webhook-interval", 5*time.Second, "how often due webhook deliveries are sent (0 disables sending)")
+	flag.DurationVar(&cfg.Webhooks.Timeout, "webhook-timeout", 10*time.Second, "timeout for a single webhook request")
+	flag.IntVar(&cfg.Webhooks.MaxAttempts, "webhook-max-attempts", 8, "attempts after which a webhook delivery is given up")
+	flag.IntVar(&cfg.Webhooks.MaxFailures, "webhook-max-failures", 20, "consecutive failed attempts after which a webhook is disabled (0 never disables)")
+
+	flag.BoolVar(&cfg.TelegramPublisher.Enabled, "telegram-publish", false, "post quotes to a Telegram channel as they go public")
+	flag.StringVar(&cfg.TelegramPublisher.ChannelID, "telegram-publish-channel-id", "", "Telegram channel id quotes are posted to (defaults to -telegram-channel-id)")
+	flag.DurationVar(&cfg.TelegramPublisher.Interval, "telegram-publish-interval", 3*time.Second, "minimum time between Telegram posts; Telegram allows about 20 a minute per channel")
+
 	flag.BoolVar(&cfg.Version, "version", false, "display version and exit")
 
 	flag.BoolVar(&cfg.UseTelegram, "use-telegram", false, "Send OTPs through Telegram")
@@ -88,5 +169,10 @@ func GetConfig() Config {
 	})
 
 	flag.Parse()
+
+	if cfg.TelegramPublisher.ChannelID == "" {
+		cfg.TelegramPublisher.ChannelID = cfg.TelegramBot.ChannelID
+	}
+
 	return cfg
 }
@@ -0,0 +1,306 @@
+// Package bundle builds the offline bundle: a compact SQLite database of all
+// public quotes with their hashtags, authors and photo metadata, that mobile
+// clients download on first install instead of paging through the API.
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+
+	_ "modernc.org/sqlite"
+)
+
+// SchemaVersion is bumped whenever the layout of the bundle changes.
+const SchemaVersion = "1"
+
+const schema = `
+	create table meta (
+		key   text primary key,
+		value text not null
+	);
+
+	create table photos (
+		id        text primary key,
+		url       text not null,
+		color     text not null,
+		blur_hash text not null,
+		author    text not null
+	);
+
+	create table authors (
+		id         text primary key,
+		name       text not null,
+		birth_year integer,
+		death_year integer
+	);
+
+	create table hashtags (
+		id    text primary key,
+		value text not null
+	);
+
+	create table quotes (
+		id          text primary key,
+		author      text not null,
+		author_id   text,
+		text        text not null,
+		photo_id    text not null,
+		language    text not null,
+		original_id text,
+		like_count  integer not null,
+		created_at  text not null
+	);
+
+	create table quote_hashtags (
+		quote_id   text not null,
+		hashtag_id text not null,
+		primary key (quote_id, hashtag_id)
+	) without rowid;
+
+	create index quotes_author_id_idx on quotes (author_id);
+	create index quote_hashtags_hashtag_id_idx on quote_hashtags (hashtag_id);`
+
+// Bundle is a built bundle file. Version is the data version it was built
+// from, empty when the store has none; ETag is derived from Data.
+type Bundle struct {
+	Data    []byte
+	ETag    string
+	Version string
+	BuiltAt time.Time
+}
+
+// Cache keeps the last bundle built. It is rebuilt when the store's data
+// version moves on or, for stores without one, once it is older than MaxAge.
+// Concurrent requests for a stale bundle wait for a single rebuild.
+type Cache struct {
+	MaxAge time.Duration
+
+	mu     sync.Mutex
+	bundle *Bundle
+}
+
+func NewCache(maxAge time.Duration) *Cache {
+	return &Cache{MaxAge: maxAge}
+}
+
+func (c *Cache) Get(ctx context.Context, store database.Store) (*Bundle, error) {
+	var version string
+
+	if versioner, ok := store.(database.DataVersioner); ok {
+		v, err := versioner.DataVersion(ctx)
+		if err != nil {
+			return nil, err
+		}
+		version = v
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if b := c.bundle; b != nil {
+		if version != "" && b.Version == version {
+			return b, nil
+		}
+		if version == "" && time.Since(b.BuiltAt) < c.MaxAge {
+			return b, nil
+		}
+	}
+
+	b, err := Build(ctx, store, version)
+	if err != nil {
+		return nil, err
+	}
+
+	c.bundle = b
+
+	return b, nil
+}
+
+// Build writes the public quotes of store to a new SQLite file and returns
+// its contents. Rows are written in a fixed order and the data version is not
+// part of the file, so the same data gives the same file and ETag.
+func Build(ctx context.Context, store database.Store, version string) (*Bundle, error) {
+	file, err := os.CreateTemp("", "quotes-bundle-*.sqlite")
+	if err != nil {
+		return nil, err
+	}
+	path := file.Name()
+	file.Close()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	_, err = db.ExecContext(ctx, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fill(ctx, db, store)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.ExecContext(ctx, `vacuum`)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return &Bundle{
+		Data:    data,
+		ETag:    `"` + hex.EncodeToString(sum[:16]) + `"`,
+		Version: version,
+		BuiltAt: time.Now(),
+	}, nil
+}
+
+func fill(ctx context.Context, db *sql.DB, store database.Store) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	photos := make(map[uuid.UUID]database.Photo)
+	hashtags := make(map[uuid.UUID]string)
+	count := 0
+
+	// Translations go in too, linked by original_id, so that clients can pick
+	// a language offline.
+	err = store.ExportQuotes(ctx, database.QuoteFilter{}, func(quote *database.Quote) error {
+		photos[quote.Photo.ID] = *quote.Photo
+
+		_, err := tx.ExecContext(ctx, `
+			insert into quotes (id, author, author_id, text, photo_id, language, original_id, like_count, created_at)
+			values (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			quote.ID.String(), quote.Author, nullID(quote.AuthorID), quote.Text, quote.Photo.ID.String(),
+			quote.Language, nullID(quote.OriginalID), quote.LikeCount, quote.CreatedAt.UTC().Format(time.RFC3339))
+		if err != nil {
+			return err
+		}
+
+		for _, hashtag := range quote.Hashtags {
+			hashtags[hashtag.ID] = hashtag.Value
+
+			_, err = tx.ExecContext(ctx, `insert into quote_hashtags (quote_id, hashtag_id) values (?, ?)`, quote.ID.String(), hashtag.ID.String())
+			if err != nil {
+				return err
+			}
+		}
+
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range sortedIDs(photos) {
+		photo := photos[id]
+
+		_, err = tx.ExecContext(ctx, `insert into photos (id, url, color, blur_hash, author) values (?, ?, ?, ?, ?)`,
+			id.String(), photo.Url, photo.Color, photo.BlurHash, photo.Author)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, id := range sortedIDs(hashtags) {
+		_, err = tx.ExecContext(ctx, `insert into hashtags (id, value) values (?, ?)`, id.String(), hashtags[id])
+		if err != nil {
+			return err
+		}
+	}
+
+	if authors, ok := store.(database.AuthorStore); ok {
+		err = fillAuthors(ctx, tx, authors)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `insert into meta (key, value) values ('schema_version', ?), ('quote_count', ?)`, SchemaVersion, strconv.Itoa(count))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// fillAuthors adds the authors that have public quotes, page by page.
+func fillAuthors(ctx context.Context, tx *sql.Tx, store database.AuthorStore) error {
+	filters := f.Filters{Page: 1, PageSize: 500, Sort: "name"}
+
+	for {
+		authors, metadata, err := store.GetAuthors(ctx, "", filters)
+		if err != nil {
+			return err
+		}
+
+		for _, author := range authors {
+			if author.QuoteCount == 0 {
+				continue
+			}
+
+			_, err = tx.ExecContext(ctx, `insert into authors (id, name, birth_year, death_year) values (?, ?, ?, ?)`,
+				author.ID.String(), author.Name, author.BirthYear, author.DeathYear)
+			if err != nil {
+				return err
+			}
+		}
+
+		if filters.Page >= metadata.LastPage {
+			return nil
+		}
+		filters.Page++
+	}
+}
+
+func nullID(id *uuid.UUID) interface{} {
+	if id == nil {
+		return nil
+	}
+	return id.String()
+}
+
+func sortedIDs[T any](m map[uuid.UUID]T) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+
+	return ids
+}
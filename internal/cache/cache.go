@@ -0,0 +1,229 @@
+// Package cache provides a small in-process cache whose entries expire after
+// a TTL and are evicted least recently used first once it is full.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// loadTimeout bounds a shared load, which no single caller's context does.
+const loadTimeout = 10 * time.Second
+
+// Stats are the counters of a cache since it was created.
+type Stats struct {
+	Name      string `json:"name"`
+	Size      int    `json:"size"`
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Loads     int64  `json:"loads"`
+	Evictions int64  `json:"evictions"`
+}
+
+// Cache maps keys to values for up to TTL, holding at most Size entries. A
+// TTL of zero or less disables caching: every Get misses and GetOrLoad always
+// loads. It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	name string
+	ttl  time.Duration
+	size int
+
+	mu       sync.Mutex
+	entries  map[K]*list.Element
+	order    *list.List
+	inflight map[K]*call[V]
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	loads     atomic.Int64
+	evictions atomic.Int64
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// call is a load in progress, which concurrent misses on the same key wait
+// for instead of loading again.
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+func New[K comparable, V any](name string, ttl time.Duration, size int) *Cache[K, V] {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Cache[K, V]{
+		name:     name,
+		ttl:      ttl,
+		size:     size,
+		entries:  make(map[K]*list.Element),
+		order:    list.New(),
+		inflight: make(map[K]*call[V]),
+	}
+}
+
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok := c.get(key)
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+
+	return value, ok
+}
+
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value)
+}
+
+// GetOrLoad returns the cached value of key, calling load on a miss and
+// caching what it returns. Concurrent misses on a key share a single call to
+// load. Errors are returned to every waiter and not cached.
+//
+// load runs detached from the cancellation of ctx, bounded by loadTimeout
+// instead, since callers other than the one that started it depend on its
+// result. Each caller waiting for it gives up only when its own ctx is done.
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, load func(ctx context.Context) (V, error)) (V, error) {
+	c.mu.Lock()
+
+	if value, ok := c.get(key); ok {
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return value, nil
+	}
+	c.misses.Add(1)
+
+	if cl, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+
+		select {
+		case <-cl.done:
+			return cl.value, cl.err
+		case <-ctx.Done():
+			var zero V
+			return zero, ctx.Err()
+		}
+	}
+
+	cl := &call[V]{done: make(chan struct{})}
+	c.inflight[key] = cl
+	c.mu.Unlock()
+
+	c.loads.Add(1)
+
+	loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+	cl.value, cl.err = load(loadCtx)
+	cancel()
+
+	c.mu.Lock()
+	// An invalidation during the load drops the call, so the value it read
+	// may already be stale and isn't kept.
+	if c.inflight[key] == cl {
+		delete(c.inflight, key)
+		if cl.err == nil {
+			c.set(key, cl.value)
+		}
+	}
+	c.mu.Unlock()
+
+	close(cl.done)
+
+	return cl.value, cl.err
+}
+
+// Delete invalidates key.
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+	delete(c.inflight, key)
+}
+
+// Purge invalidates every key.
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[K]*list.Element)
+	c.order.Init()
+	c.inflight = make(map[K]*call[V])
+}
+
+func (c *Cache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Name:      c.name,
+		Size:      size,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Loads:     c.loads.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+func (c *Cache[K, V]) get(key K) (V, bool) {
+	var zero V
+
+	el, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+
+	e := el.Value.(*entry[K, V])
+	if time.Now().After(e.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return zero, false
+	}
+
+	c.order.MoveToFront(el)
+
+	return e.value, true
+}
+
+func (c *Cache[K, V]) set(key K, value V) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	expires := time.Now().Add(c.ttl)
+
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+
+	for c.order.Len() > c.size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*entry[K, V]).key)
+		c.evictions.Add(1)
+	}
+}
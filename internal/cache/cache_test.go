@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestGetOrLoadOutlivesCaller checks that a shared load keeps going when the
+// caller that started it goes away, so the others waiting for it still get
+// the value.
+func TestGetOrLoadOutlivesCaller(t *testing.T) {
+	c := New[string, int]("test", time.Minute, 10)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrLoad(ctx, "key", func(ctx context.Context) (int, error) {
+			close(started)
+			<-release
+			return 42, ctx.Err()
+		})
+		first <- err
+	}()
+
+	<-started
+
+	second := make(chan int, 1)
+	go func() {
+		value, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) (int, error) {
+			t.Error("a second load ran while the first was in flight")
+			return 0, nil
+		})
+		if err != nil {
+			t.Errorf("waiter: %v", err)
+		}
+		second <- value
+	}()
+
+	// The waiter's miss is counted just before it starts waiting.
+	for c.misses.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	close(release)
+
+	if err := <-first; err != nil {
+		t.Fatalf("starter: %v", err)
+	}
+	if value := <-second; value != 42 {
+		t.Fatalf("waiter got %d, want 42", value)
+	}
+
+	// A waiter whose own context ends stops waiting.
+	c.Purge()
+
+	release = make(chan struct{})
+	defer close(release)
+
+	go c.GetOrLoad(context.Background(), "key", func(ctx context.Context) (int, error) {
+		<-release
+		return 0, nil
+	})
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer waitCancel()
+
+	for {
+		c.mu.Lock()
+		_, inflight := c.inflight["key"]
+		c.mu.Unlock()
+		if inflight {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := c.GetOrLoad(waitCtx, "key", func(ctx context.Context) (int, error) {
+		t.Error("a second load ran while the first was in flight")
+		return 0, nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("waiter with an expired context got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
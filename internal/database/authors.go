@@ -0,0 +1,570 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Author is the canonical entry for a person quotes are attributed to.
+// Aliases are the other spellings the author is known by; names and aliases
+// are unique across all authors, ignoring case.
+type Author struct {
+	ID         uuid.UUID
+	Name       string
+	Aliases    []string
+	BirthYear  *int
+	DeathYear  *int
+	Bio        string
+	PhotoID    *uuid.UUID
+	Photo      *Photo
+	QuoteCount int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Version    int
+}
+
+// AuthorMatch is an autocomplete suggestion. Alias is set when the author
+// matched through one of their aliases rather than their name.
+type AuthorMatch struct {
+	ID         uuid.UUID
+	Name       string
+	Alias      string
+	QuoteCount int
+}
+
+// AuthorStore is implemented by stores that keep authors apart from the
+// free-text author of a quote.
+type AuthorStore interface {
+	InsertAuthor(ctx context.Context, author *Author) error
+	GetAuthor(ctx context.Context, id uuid.UUID) (*Author, error)
+	FindAuthor(ctx context.Context, name string) (*Author, error)
+	GetAuthors(ctx context.Context, name string, filters f.Filters) ([]Author, f.Metadata, error)
+	AutocompleteAuthors(ctx context.Context, prefix string, limit int) ([]AuthorMatch, error)
+	UpdateAuthor(ctx context.Context, author *Author) error
+	MergeAuthors(ctx context.Context, id uuid.UUID, sourceIDs []uuid.UUID) (*Author, error)
+	GetAuthorQuotes(ctx context.Context, id uuid.UUID, filters f.Filters) ([]Quote, f.Metadata, error)
+}
+
+var _ AuthorStore = (*DB)(nil)
+
+// publicQuoteCount counts the quotes of author a that everyone can see.
+const publicQuoteCount = `
+	(select count(*)
+	 from quotes q
+	 inner join quote_states s
+	 on q.state = s.id
+	 where q.author_id = a.id and s.is_public
+	 and (q.publish_at is null or q.publish_at <= now()))`
+
+const authorColumns = `
+	a.id, a.name, array(select al.alias from author_aliases al where al.author_id = a.id order by lower(al.alias)),
+	a.birth_year, a.death_year, a.bio, a.photo_id, a.created_at, a.updated_at, a.version,
+	` + publicQuoteCount + `,
+	p.id, p.url, p.color, p.blur_hash, p.author`
+
+const authorTables = `
+	authors a
+	left join photos p
+	on a.photo_id = p.id`
+
+func scanAuthor(row interface{ Scan(...any) error }, extra ...any) (Author, error) {
+	var a Author
+	var aliases pq.StringArray
+	var photo nullPhoto
+
+	dest := append(extra,
+		&a.ID, &a.Name, &aliases, &a.BirthYear, &a.DeathYear, &a.Bio, &a.PhotoID, &a.CreatedAt, &a.UpdatedAt, &a.Version,
+		&a.QuoteCount,
+		&photo.ID, &photo.Url, &photo.Color, &photo.BlurHash, &photo.Author,
+	)
+
+	err := row.Scan(dest...)
+	a.Aliases = []string(aliases)
+	a.Photo = photo.photo()
+	return a, err
+}
+
+// nullPhoto scans a photo from a left join.
+type nullPhoto struct {
+	ID       *uuid.UUID
+	Url      sql.NullString
+	Color    sql.NullString
+	BlurHash sql.NullString
+	Author   sql.NullString
+}
+
+func (p nullPhoto) photo() *Photo {
+	if p.ID == nil {
+		return nil
+	}
+
+	return &Photo{
+		ID:       *p.ID,
+		Url:      p.Url.String,
+		Color:    p.Color.String,
+		BlurHash: p.BlurHash.String,
+		Author:   p.Author.String,
+	}
+}
+
+func (db *DB) InsertAuthor(ctx context.Context, author *Author) error {
+	ctx, done := db.write(ctx, "InsertAuthor")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	author.ID = uuid.New()
+
+	err = checkAuthorNames(ctx, tx, author)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		insert into authors (id, name, birth_year, death_year, bio, photo_id)
+		values ($1, $2, $3, $4, $5, $6)`
+
+	args := []interface{}{author.ID, author.Name, author.BirthYear, author.DeathYear, author.Bio, author.PhotoID}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err):
+			return ErrDuplicateAuthor
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	err = setAuthorAliases(ctx, tx, author)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	stored, err := db.GetAuthor(ctx, author.ID)
+	if err != nil {
+		return err
+	}
+
+	*author = *stored
+	return nil
+}
+
+func (db *DB) GetAuthor(ctx context.Context, id uuid.UUID) (*Author, error) {
+	ctx, done := db.read(ctx, "GetAuthor")
+	defer done()
+
+	query := `select ` + authorColumns + ` from ` + authorTables + ` where a.id = $1`
+
+	author, err := scanAuthor(db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &author, nil
+}
+
+// FindAuthor looks an author up by their name or one of their aliases,
+// ignoring case.
+func (db *DB) FindAuthor(ctx context.Context, name string) (*Author, error) {
+	ctx, done := db.read(ctx, "FindAuthor")
+	defer done()
+
+	query := `
+		select ` + authorColumns + `
+		from ` + authorTables + `
+		where lower(a.name) = lower($1)
+		or exists (select 1 from author_aliases al where al.author_id = a.id and lower(al.alias) = lower($1))
+		limit 1`
+
+	author, err := scanAuthor(db.QueryRowContext(ctx, query, strings.TrimSpace(name)))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &author, nil
+}
+
+// GetAuthors lists authors whose name or one of whose aliases contains name.
+func (db *DB) GetAuthors(ctx context.Context, name string, filters f.Filters) ([]Author, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetAuthors")
+	defer done()
+
+	query := fmt.Sprintf(`
+		select count(*) over(), `+authorColumns+`
+		from `+authorTables+`
+		where $1 = ''
+		or a.name ilike '%%' || $1 || '%%'
+		or exists (select 1 from author_aliases al where al.author_id = a.id and al.alias ilike '%%' || $1 || '%%')
+		order by lower(a.name) %s, a.id
+		limit $2 offset $3`, filters.SortDirection())
+
+	rows, err := db.QueryContext(ctx, query, escapeLike(strings.TrimSpace(name)), filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	authors := make([]Author, 0)
+
+	for rows.Next() {
+		author, err := scanAuthor(rows, &totalRecords)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		authors = append(authors, author)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return authors, metadata, nil
+}
+
+// AutocompleteAuthors suggests authors with a name or alias in which a word
+// starts with prefix. Names starting with prefix come first, then authors
+// with more public quotes.
+func (db *DB) AutocompleteAuthors(ctx context.Context, prefix string, limit int) ([]AuthorMatch, error) {
+	ctx, done := db.read(ctx, "AutocompleteAuthors")
+	defer done()
+
+	query := `
+		select a.id, a.name,
+		       case when lower(a.name) like $1 or lower(a.name) like $2 then null else (
+		           select al.alias
+		           from author_aliases al
+		           where al.author_id = a.id and (lower(al.alias) like $1 or lower(al.alias) like $2)
+		           order by lower(al.alias)
+		           limit 1)
+		       end,
+		       ` + publicQuoteCount + ` as quote_count
+		from authors a
+		where lower(a.name) like $1 or lower(a.name) like $2
+		or exists (
+			select 1
+			from author_aliases al
+			where al.author_id = a.id and (lower(al.alias) like $1 or lower(al.alias) like $2))
+		order by lower(a.name) like $1 desc, quote_count desc, lower(a.name), a.id
+		limit $3`
+
+	prefix = escapeLike(strings.ToLower(strings.TrimSpace(prefix)))
+
+	rows, err := db.QueryContext(ctx, query, prefix+"%", "% "+prefix+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := make([]AuthorMatch, 0)
+
+	for rows.Next() {
+		var match AuthorMatch
+		var alias sql.NullString
+
+		err := rows.Scan(&match.ID, &match.Name, &alias, &match.QuoteCount)
+		if err != nil {
+			return nil, err
+		}
+
+		match.Alias = alias.String
+		matches = append(matches, match)
+	}
+
+	return matches, rows.Err()
+}
+
+// UpdateAuthor saves the author's details and replaces their aliases,
+// failing with ErrEditConflict when the author changed since it was read.
+func (db *DB) UpdateAuthor(ctx context.Context, author *Author) error {
+	ctx, done := db.write(ctx, "UpdateAuthor")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = checkAuthorNames(ctx, tx, author)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		update authors
+		set name = $1, birth_year = $2, death_year = $3, bio = $4, photo_id = $5, updated_at = now(), version = version + 1
+		where id = $6 and version = $7`
+
+	args := []interface{}{author.Name, author.BirthYear, author.DeathYear, author.Bio, author.PhotoID, author.ID, author.Version}
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err):
+			return ErrDuplicateAuthor
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrEditConflict
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from author_aliases where author_id = $1`, author.ID)
+	if err != nil {
+		return err
+	}
+
+	err = setAuthorAliases(ctx, tx, author)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	stored, err := db.GetAuthor(ctx, author.ID)
+	if err != nil {
+		return err
+	}
+
+	*author = *stored
+	return nil
+}
+
+// MergeAuthors folds the source authors into author id: their quotes move
+// over, and their names and aliases become aliases of id. The sources are
+// deleted afterwards.
+func (db *DB) MergeAuthors(ctx context.Context, id uuid.UUID, sourceIDs []uuid.UUID) (*Author, error) {
+	ctx, done := db.write(ctx, "MergeAuthors")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	sources := make(pq.StringArray, len(sourceIDs))
+	for i := range sourceIDs {
+		sources[i] = sourceIDs[i].String()
+	}
+
+	var found int
+
+	query := `
+		select count(*)
+		from (select id from authors where id = $1 or id = any($2::uuid[]) for update) a`
+
+	err = tx.QueryRowContext(ctx, query, id, sources).Scan(&found)
+	if err != nil {
+		return nil, err
+	}
+
+	if found != len(sourceIDs)+1 {
+		return nil, ErrRecordNotFound
+	}
+
+	queries := []string{
+		`update author_aliases set author_id = $1 where author_id = any($2::uuid[])`,
+		`insert into author_aliases (alias, author_id) select name, $1::uuid from authors where id = any($2::uuid[]) on conflict do nothing`,
+		`update quotes set author_id = $1 where author_id = any($2::uuid[])`,
+		`delete from authors where id = any($2::uuid[])`,
+	}
+
+	for _, query := range queries {
+		_, err = tx.ExecContext(ctx, query, id, sources)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `update authors set updated_at = now(), version = version + 1 where id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return db.GetAuthor(ctx, id)
+}
+
+// GetAuthorQuotes lists the public quotes linked to an author.
+func (db *DB) GetAuthorQuotes(ctx context.Context, id uuid.UUID, filters f.Filters) ([]Quote, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetAuthorQuotes")
+	defer done()
+
+	query := fmt.Sprintf(`
+		select count(*) over(), `+quoteColumns+`
+		from quotes q
+		inner join quote_states s
+		on q.state = s.id and s.is_public = true
+		inner join photos p
+		on q.photo_id = p.id
+		where q.author_id = $1
+		and (q.publish_at is null or q.publish_at <= now())
+		order by q.%s %s, q.created_at desc, q.id
+		limit $2 offset $3`, QuoteSortColumn(filters), filters.SortDirection())
+
+	rows, err := db.QueryContext(ctx, query, id, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	quotes := make([]Quote, 0)
+
+	for rows.Next() {
+		quote, err := scanQuote(rows, &totalRecords)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		quotes = append(quotes, quote)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+	rows.Close()
+
+	for i := range quotes {
+		quotes[i].Hashtags, _, err = db.GetQuoteHashtags(ctx, quotes[i].ID)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return quotes, metadata, nil
+}
+
+// checkAuthorNames fails with ErrDuplicateAuthor when the name or an alias
+// of author is already used by another author, as a name or as an alias.
+func checkAuthorNames(ctx context.Context, tx *sqlx.Tx, author *Author) error {
+	names := make(pq.StringArray, 0, len(author.Aliases)+1)
+	for _, name := range append([]string{author.Name}, author.Aliases...) {
+		names = append(names, strings.ToLower(name))
+	}
+
+	var taken bool
+
+	query := `
+		select exists (select 1 from authors where id <> $1 and lower(name) = any($2))
+		or exists (select 1 from author_aliases where author_id <> $1 and lower(alias) = any($2))`
+
+	err := tx.QueryRowContext(ctx, query, author.ID, names).Scan(&taken)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return ErrDuplicateAuthor
+	}
+
+	return nil
+}
+
+func setAuthorAliases(ctx context.Context, tx *sqlx.Tx, author *Author) error {
+	for _, alias := range author.Aliases {
+		if strings.EqualFold(alias, author.Name) {
+			continue
+		}
+
+		_, err := tx.ExecContext(ctx, `insert into author_aliases (alias, author_id) values ($1, $2) on conflict do nothing`, alias, author.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// linkQuoteAuthor points a quote at the author its free-text author names,
+// matching aliases too. Unknown names get an author of their own so that
+// every quote stays linked. When another request creates the same author
+// first, the no-op update on conflict still returns its id.
+func linkQuoteAuthor(ctx context.Context, q sqlx.QueryerContext, quoteID uuid.UUID, name string) (*uuid.UUID, error) {
+	var authorID *uuid.UUID
+
+	query := `
+		with found as (
+			select id from authors where lower(name) = lower($2)
+			union all
+			select author_id from author_aliases where lower(alias) = lower($2)
+			limit 1
+		), created as (
+			insert into authors (id, name)
+			select $3::uuid, $2
+			where $2 <> '' and not exists (select 1 from found)
+			on conflict (lower(name)) do update set name = authors.name
+			returning id
+		)
+		update quotes
+		set author_id = coalesce((select id from found), (select id from created))
+		where id = $1
+		returning author_id`
+
+	err := q.QueryRowxContext(ctx, query, quoteID, strings.TrimSpace(name), uuid.New()).Scan(&authorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return authorID, nil
+}
+
+// escapeLike escapes the wildcards of a like pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,492 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// MaxCollectionItems caps how many quotes a single collection may hold.
+const MaxCollectionItems = 500
+
+// Collection is a named, ordered list of quotes curated by one user. Private
+// collections are only visible to their owner.
+type Collection struct {
+	ID            uuid.UUID
+	OwnerID       uuid.UUID
+	Name          string
+	Description   string
+	IsPublic      bool
+	ItemCount     int
+	FollowerCount int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Version       int
+}
+
+// CanView reports whether userID may see the collection and its quotes.
+func (c *Collection) CanView(userID uuid.UUID) bool {
+	return c.IsPublic || c.OwnerID == userID
+}
+
+// CanEdit reports whether userID may change the collection or its items.
+func (c *Collection) CanEdit(userID uuid.UUID) bool {
+	return c.OwnerID == userID
+}
+
+// CanFollow reports whether userID may follow the collection: it has to be
+// someone else's public collection.
+func (c *Collection) CanFollow(userID uuid.UUID) bool {
+	return c.IsPublic && c.OwnerID != userID
+}
+
+// CollectionStore is implemented by stores that support quote collections.
+// Access rules are left to the caller, see Collection.CanView and friends.
+type CollectionStore interface {
+	InsertCollection(ctx context.Context, collection *Collection) error
+	GetCollection(ctx context.Context, id uuid.UUID) (*Collection, error)
+	UpdateCollection(ctx context.Context, collection *Collection) error
+	DeleteCollection(ctx context.Context, id uuid.UUID) error
+	GetUserCollections(ctx context.Context, ownerID uuid.UUID, publicOnly bool, filters f.Filters) ([]Collection, f.Metadata, error)
+	GetFollowedCollections(ctx context.Context, userID uuid.UUID, filters f.Filters) ([]Collection, f.Metadata, error)
+	GetCollectionQuotes(ctx context.Context, id uuid.UUID, publicOnly bool, filters f.Filters) ([]Quote, f.Metadata, error)
+	AddCollectionQuote(ctx context.Context, id, quoteID uuid.UUID) error
+	RemoveCollectionQuote(ctx context.Context, id, quoteID uuid.UUID) error
+	ReorderCollection(ctx context.Context, id uuid.UUID, quoteIDs []uuid.UUID) error
+	FollowCollection(ctx context.Context, userID, id uuid.UUID) error
+	UnfollowCollection(ctx context.Context, userID, id uuid.UUID) error
+	IsFollowingCollection(ctx context.Context, userID, id uuid.UUID) (bool, error)
+}
+
+var _ CollectionStore = (*DB)(nil)
+
+const collectionColumns = `
+	c.id, c.owner_id, c.name, c.description, c.is_public, c.created_at, c.updated_at, c.version,
+	(select count(*) from collection_items i where i.collection_id = c.id),
+	(select count(*) from collection_follows fo where fo.collection_id = c.id)`
+
+func scanCollection(row interface{ Scan(...any) error }, extra ...any) (Collection, error) {
+	var c Collection
+
+	dest := append(extra,
+		&c.ID, &c.OwnerID, &c.Name, &c.Description, &c.IsPublic, &c.CreatedAt, &c.UpdatedAt, &c.Version,
+		&c.ItemCount, &c.FollowerCount,
+	)
+
+	err := row.Scan(dest...)
+	return c, err
+}
+
+func (db *DB) InsertCollection(ctx context.Context, collection *Collection) error {
+	ctx, done := db.write(ctx, "InsertCollection")
+	defer done()
+
+	collection.ID = uuid.New()
+
+	query := `
+		insert into collections (id, owner_id, name, description, is_public)
+		values ($1, $2, $3, $4, $5)
+		returning created_at, updated_at, version`
+
+	args := []interface{}{collection.ID, collection.OwnerID, collection.Name, collection.Description, collection.IsPublic}
+
+	err := db.QueryRowContext(ctx, query, args...).Scan(&collection.CreatedAt, &collection.UpdatedAt, &collection.Version)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err):
+			return ErrDuplicateCollection
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) GetCollection(ctx context.Context, id uuid.UUID) (*Collection, error) {
+	ctx, done := db.read(ctx, "GetCollection")
+	defer done()
+
+	query := `select ` + collectionColumns + ` from collections c where c.id = $1`
+
+	collection, err := scanCollection(db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &collection, nil
+}
+
+// UpdateCollection saves the name, description and visibility, failing with
+// ErrEditConflict when the collection changed since it was read.
+func (db *DB) UpdateCollection(ctx context.Context, collection *Collection) error {
+	ctx, done := db.write(ctx, "UpdateCollection")
+	defer done()
+
+	query := `
+		update collections
+		set name = $1, description = $2, is_public = $3, updated_at = now(), version = version + 1
+		where id = $4 and version = $5
+		returning updated_at, version`
+
+	args := []interface{}{collection.Name, collection.Description, collection.IsPublic, collection.ID, collection.Version}
+
+	err := db.QueryRowContext(ctx, query, args...).Scan(&collection.UpdatedAt, &collection.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case isUniqueViolation(err):
+			return ErrDuplicateCollection
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteCollection(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "DeleteCollection")
+	defer done()
+
+	result, err := db.ExecContext(ctx, `delete from collections where id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) GetUserCollections(ctx context.Context, ownerID uuid.UUID, publicOnly bool, filters f.Filters) ([]Collection, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetUserCollections")
+	defer done()
+
+	query := fmt.Sprintf(`
+		select count(*) over(), `+collectionColumns+`
+		from collections c
+		where c.owner_id = $1
+		and (c.is_public or not $2)
+		order by c.%s %s, c.id
+		limit $3 offset $4`, filters.SortColumn(), filters.SortDirection())
+
+	return db.queryCollections(ctx, query, []interface{}{ownerID, publicOnly, filters.Limit(), filters.Offset()}, filters)
+}
+
+// GetFollowedCollections lists the public collections userID follows, most
+// recently followed first.
+func (db *DB) GetFollowedCollections(ctx context.Context, userID uuid.UUID, filters f.Filters) ([]Collection, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetFollowedCollections")
+	defer done()
+
+	query := `
+		select count(*) over(), ` + collectionColumns + `
+		from collection_follows cf
+		inner join collections c
+		on cf.collection_id = c.id and c.is_public = true
+		where cf.user_id = $1
+		order by cf.created_at desc, c.id
+		limit $2 offset $3`
+
+	return db.queryCollections(ctx, query, []interface{}{userID, filters.Limit(), filters.Offset()}, filters)
+}
+
+func (db *DB) queryCollections(ctx context.Context, query string, args []interface{}, filters f.Filters) ([]Collection, f.Metadata, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	collections := make([]Collection, 0)
+
+	for rows.Next() {
+		collection, err := scanCollection(rows, &totalRecords)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		collections = append(collections, collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return collections, metadata, nil
+}
+
+// GetCollectionQuotes returns the quotes of a collection in their stored
+// order. With publicOnly set, quotes that are no longer public are skipped.
+func (db *DB) GetCollectionQuotes(ctx context.Context, id uuid.UUID, publicOnly bool, filters f.Filters) ([]Quote, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetCollectionQuotes")
+	defer done()
+
+	query := `
+		select count(*) over(), ` + quoteColumns + `
+		from collection_items i
+		inner join quotes q
+		on i.quote_id = q.id
+		inner join quote_states s
+		on q.state = s.id
+		inner join photos p
+		on q.photo_id = p.id
+		where i.collection_id = $1
+		and ((s.is_public and (q.publish_at is null or q.publish_at <= now())) or not $2)
+		order by i.position, i.added_at
+		limit $3 offset $4`
+
+	rows, err := db.QueryContext(ctx, query, id, publicOnly, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	quotes := make([]Quote, 0)
+
+	for rows.Next() {
+		quote, err := scanQuote(rows, &totalRecords)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		quotes = append(quotes, quote)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+	rows.Close()
+
+	for i := range quotes {
+		hashtags, _, err := db.GetQuoteHashtags(ctx, quotes[i].ID)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+		quotes[i].Hashtags = hashtags
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return quotes, metadata, nil
+}
+
+// AddCollectionQuote appends a public quote to the end of a collection.
+// Adding a quote that is already there is a no-op.
+func (db *DB) AddCollectionQuote(ctx context.Context, id, quoteID uuid.UUID) error {
+	ctx, done := db.write(ctx, "AddCollectionQuote")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var items int
+
+	query := `
+		select (select count(*) from collection_items i where i.collection_id = c.id)
+		from collections c
+		where c.id = $1
+		for update`
+
+	err = tx.QueryRowContext(ctx, query, id).Scan(&items)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	var isPublic bool
+
+	query = `
+		select s.is_public and (q.publish_at is null or q.publish_at <= now())
+		from quotes q
+		inner join quote_states s
+		on q.state = s.id
+		where q.id = $1`
+
+	err = tx.QueryRowContext(ctx, query, quoteID).Scan(&isPublic)
+	switch {
+	case errors.Is(err, sql.ErrNoRows), err == nil && !isPublic:
+		return ErrRecordNotFound
+	case err != nil:
+		return err
+	}
+
+	query = `
+		insert into collection_items (collection_id, quote_id, position)
+		select $1, $2, coalesce(max(position) + 1, 0)
+		from collection_items
+		where collection_id = $1
+		on conflict do nothing`
+
+	result, err := tx.ExecContext(ctx, query, id, quoteID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil
+	}
+
+	// Checked after the insert so that re-adding an existing quote to a full
+	// collection stays a no-op; returning rolls the insert back.
+	if items >= MaxCollectionItems {
+		return ErrCollectionFull
+	}
+
+	_, err = tx.ExecContext(ctx, `update collections set updated_at = now() where id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *DB) RemoveCollectionQuote(ctx context.Context, id, quoteID uuid.UUID) error {
+	ctx, done := db.write(ctx, "RemoveCollectionQuote")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `delete from collection_items where collection_id = $1 and quote_id = $2`, id, quoteID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `update collections set updated_at = now() where id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReorderCollection stores quoteIDs as the new order of the collection. The
+// list has to name every item exactly once, otherwise ErrCollectionOrder is
+// returned and nothing changes.
+func (db *DB) ReorderCollection(ctx context.Context, id uuid.UUID, quoteIDs []uuid.UUID) error {
+	ctx, done := db.write(ctx, "ReorderCollection")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `update collections set updated_at = now() where id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	ids := make(pq.StringArray, len(quoteIDs))
+	for i, quoteID := range quoteIDs {
+		ids[i] = quoteID.String()
+	}
+
+	var items, matched, distinct int
+
+	query := `
+		select (select count(*) from collection_items where collection_id = $1),
+		       (select count(*) from collection_items where collection_id = $1 and quote_id = any($2::uuid[])),
+		       (select count(distinct id) from unnest($2::uuid[]) as o(id))`
+
+	err = tx.QueryRowContext(ctx, query, id, ids).Scan(&items, &matched, &distinct)
+	if err != nil {
+		return err
+	}
+
+	if items != len(quoteIDs) || matched != items || distinct != items {
+		return ErrCollectionOrder
+	}
+
+	query = `
+		update collection_items i
+		set position = o.ord
+		from unnest($2::uuid[]) with ordinality as o(quote_id, ord)
+		where i.collection_id = $1 and i.quote_id = o.quote_id`
+
+	_, err = tx.ExecContext(ctx, query, id, ids)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FollowCollection is idempotent; whether the user may follow the collection
+// is up to the caller.
+func (db *DB) FollowCollection(ctx context.Context, userID, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "FollowCollection")
+	defer done()
+
+	_, err := db.ExecContext(ctx, `insert into collection_follows (user_id, collection_id) values ($1, $2) on conflict do nothing`, userID, id)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) UnfollowCollection(ctx context.Context, userID, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "UnfollowCollection")
+	defer done()
+
+	_, err := db.ExecContext(ctx, `delete from collection_follows where user_id = $1 and collection_id = $2`, userID, id)
+	return err
+}
+
+func (db *DB) IsFollowingCollection(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	ctx, done := db.read(ctx, "IsFollowingCollection")
+	defer done()
+
+	var following bool
+
+	query := `select exists(select 1 from collection_follows where user_id = $1 and collection_id = $2)`
+
+	err := db.QueryRowContext(ctx, query, userID, id).Scan(&following)
+	return following, err
+}
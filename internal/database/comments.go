@@ -0,0 +1,384 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Comment is a remark on a quote, or a reply to one when ParentID is set.
+// Replies only go one level deep. Comments are screened like quotes: they
+// start in the default state and others only see them once their state is
+// public and no moderator has hidden them.
+type Comment struct {
+	ID        uuid.UUID
+	QuoteID   uuid.UUID
+	ParentID  *uuid.UUID
+	UserID    uuid.UUID
+	UserName  string
+	Text      string
+	State     QuoteState
+	IsHidden  bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	EditedAt  *time.Time
+	DeletedAt *time.Time
+	Replies   []Comment
+}
+
+// CommentStore is implemented by stores that support comments on quotes.
+type CommentStore interface {
+	InsertComment(ctx context.Context, comment *Comment) error
+	GetComment(ctx context.Context, id uuid.UUID) (*Comment, error)
+	GetQuoteComments(ctx context.Context, quoteID, viewerID uuid.UUID, moderator bool, filters f.Filters) ([]Comment, f.Metadata, error)
+	UpdateCommentText(ctx context.Context, id uuid.UUID, text string) (*Comment, error)
+	DeleteComment(ctx context.Context, id uuid.UUID) error
+	SetCommentState(ctx context.Context, id, stateID uuid.UUID) error
+	SetCommentHidden(ctx context.Context, id uuid.UUID, hidden bool, moderatorID uuid.UUID) error
+}
+
+var _ CommentStore = (*DB)(nil)
+
+const commentColumns = `
+	c.id, c.quote_id, c.parent_id, c.user_id, u.name, c.text,
+	s.id, s.value, s.is_default, s.color, s.is_public,
+	c.is_hidden, c.created_at, c.updated_at, c.edited_at, c.deleted_at`
+
+func scanComment(row interface{ Scan(...any) error }, extra ...any) (Comment, error) {
+	var c Comment
+
+	dest := append(extra,
+		&c.ID, &c.QuoteID, &c.ParentID, &c.UserID, &c.UserName, &c.Text,
+		&c.State.ID, &c.State.Value, &c.State.IsDefault, &c.State.Color, &c.State.IsPublic,
+		&c.IsHidden, &c.CreatedAt, &c.UpdatedAt, &c.EditedAt, &c.DeletedAt,
+	)
+
+	err := row.Scan(dest...)
+	return c, err
+}
+
+// InsertComment stores a comment on a public quote in the default state. A
+// reply has to point at a top-level comment of the same quote, otherwise
+// ErrNestedReply is returned.
+func (db *DB) InsertComment(ctx context.Context, comment *Comment) error {
+	ctx, done := db.write(ctx, "InsertComment")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var isPublic bool
+
+	query := `
+		select s.is_public and (q.publish_at is null or q.publish_at <= now())
+		from quotes q
+		inner join quote_states s
+		on q.state = s.id
+		where q.id = $1`
+
+	err = tx.QueryRowContext(ctx, query, comment.QuoteID).Scan(&isPublic)
+	switch {
+	case errors.Is(err, sql.ErrNoRows), err == nil && !isPublic:
+		return ErrRecordNotFound
+	case err != nil:
+		return err
+	}
+
+	if comment.ParentID != nil {
+		var quoteID uuid.UUID
+		var parentID *uuid.UUID
+
+		query = `select quote_id, parent_id from comments where id = $1 and deleted_at is null`
+
+		err = tx.QueryRowContext(ctx, query, *comment.ParentID).Scan(&quoteID, &parentID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case err != nil:
+			return err
+		case quoteID != comment.QuoteID || parentID != nil:
+			return ErrNestedReply
+		}
+	}
+
+	comment.ID = uuid.New()
+
+	query = `
+		insert into comments (id, quote_id, parent_id, user_id, text, state)
+		select $1, $2, $3, $4, $5, id
+		from quote_states
+		where is_default = true
+		returning state, created_at, updated_at`
+
+	args := []interface{}{comment.ID, comment.QuoteID, comment.ParentID, comment.UserID, comment.Text}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&comment.State.ID, &comment.CreatedAt, &comment.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrDefaultState
+		default:
+			return err
+		}
+	}
+
+	err = countComments(ctx, tx, comment.QuoteID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	stored, err := db.GetComment(ctx, comment.ID)
+	if err != nil {
+		return err
+	}
+
+	*comment = *stored
+	return nil
+}
+
+func (db *DB) GetComment(ctx context.Context, id uuid.UUID) (*Comment, error) {
+	ctx, done := db.read(ctx, "GetComment")
+	defer done()
+
+	query := `
+		select ` + commentColumns + `
+		from comments c
+		inner join users u
+		on c.user_id = u.id
+		inner join quote_states s
+		on c.state = s.id
+		where c.id = $1`
+
+	comment, err := scanComment(db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &comment, nil
+}
+
+// GetQuoteComments returns a page of top-level comments, oldest first, each
+// with all of its replies. Unless moderator is set, comments that are not
+// public or are hidden are left out, except the viewer's own. Deleted
+// comments only appear as placeholders for the replies they still have.
+func (db *DB) GetQuoteComments(ctx context.Context, quoteID, viewerID uuid.UUID, moderator bool, filters f.Filters) ([]Comment, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetQuoteComments")
+	defer done()
+
+	visible := `(c.deleted_at is null and ($2 or c.user_id = $3 or (s.is_public and not c.is_hidden)))`
+
+	query := `
+		select count(*) over(), ` + commentColumns + `
+		from comments c
+		inner join users u
+		on c.user_id = u.id
+		inner join quote_states s
+		on c.state = s.id
+		where c.quote_id = $1
+		and c.parent_id is null
+		and (` + visible + ` or exists (
+			select 1
+			from comments r
+			inner join quote_states rs
+			on r.state = rs.id
+			where r.parent_id = c.id
+			and r.deleted_at is null
+			and ($2 or r.user_id = $3 or (rs.is_public and not r.is_hidden))))
+		order by c.created_at, c.id
+		limit $4 offset $5`
+
+	rows, err := db.QueryContext(ctx, query, quoteID, moderator, viewerID, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	comments := make([]Comment, 0)
+
+	for rows.Next() {
+		comment, err := scanComment(rows, &totalRecords)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		comments = append(comments, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+	rows.Close()
+
+	if len(comments) == 0 {
+		metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+		return comments, metadata, nil
+	}
+
+	parents := make(pq.StringArray, len(comments))
+	index := make(map[uuid.UUID]int, len(comments))
+	for i := range comments {
+		parents[i] = comments[i].ID.String()
+		index[comments[i].ID] = i
+	}
+
+	// visible refers to $2 and $3, so the replies query keeps them in place.
+	query = `
+		select ` + commentColumns + `
+		from comments c
+		inner join users u
+		on c.user_id = u.id
+		inner join quote_states s
+		on c.state = s.id
+		where c.parent_id = any($1::uuid[])
+		and ` + visible + `
+		order by c.created_at, c.id`
+
+	rows, err = db.QueryContext(ctx, query, parents, moderator, viewerID)
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		reply, err := scanComment(rows)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		i := index[*reply.ParentID]
+		comments[i].Replies = append(comments[i].Replies, reply)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return comments, metadata, nil
+}
+
+// UpdateCommentText replaces the text of a comment that hasn't been deleted
+// and records when it was edited.
+func (db *DB) UpdateCommentText(ctx context.Context, id uuid.UUID, text string) (*Comment, error) {
+	ctx, done := db.write(ctx, "UpdateCommentText")
+	defer done()
+
+	query := `
+		update comments
+		set text = $1, edited_at = now(), updated_at = now()
+		where id = $2 and deleted_at is null`
+
+	result, err := db.ExecContext(ctx, query, text, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return db.GetComment(ctx, id)
+}
+
+// DeleteComment soft-deletes a comment: it keeps its row so that replies
+// stay attached, but its text is no longer shown.
+func (db *DB) DeleteComment(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "DeleteComment")
+	defer done()
+
+	return db.updateComment(ctx, id, `update comments set deleted_at = now(), updated_at = now() where id = $1 and deleted_at is null returning quote_id`)
+}
+
+func (db *DB) SetCommentState(ctx context.Context, id, stateID uuid.UUID) error {
+	ctx, done := db.write(ctx, "SetCommentState")
+	defer done()
+
+	if !db.ExistsQuoteStateById(ctx, stateID) {
+		return ErrRecordNotFound
+	}
+
+	return db.updateComment(ctx, id, `update comments set state = $2, updated_at = now() where id = $1 returning quote_id`, stateID)
+}
+
+func (db *DB) SetCommentHidden(ctx context.Context, id uuid.UUID, hidden bool, moderatorID uuid.UUID) error {
+	ctx, done := db.write(ctx, "SetCommentHidden")
+	defer done()
+
+	var hiddenBy *uuid.UUID
+	if hidden {
+		hiddenBy = &moderatorID
+	}
+
+	return db.updateComment(ctx, id, `update comments set is_hidden = $2, hidden_by = $3, updated_at = now() where id = $1 returning quote_id`, hidden, hiddenBy)
+}
+
+// updateComment runs query, which has to return the quote_id of the changed
+// comment, and refreshes that quote's comment count in the same transaction.
+func (db *DB) updateComment(ctx context.Context, id uuid.UUID, query string, args ...interface{}) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var quoteID uuid.UUID
+
+	err = tx.QueryRowContext(ctx, query, append([]interface{}{id}, args...)...).Scan(&quoteID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	err = countComments(ctx, tx, quoteID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// countComments stores on the quote how many of its comments everyone can
+// see.
+func countComments(ctx context.Context, tx *sqlx.Tx, quoteID uuid.UUID) error {
+	query := `
+		update quotes
+		set comment_count = (
+			select count(*)
+			from comments c
+			inner join quote_states s
+			on c.state = s.id
+			where c.quote_id = $1
+			and c.deleted_at is null
+			and s.is_public
+			and not c.is_hidden)
+		where id = $1`
+
+	_, err := tx.ExecContext(ctx, query, quoteID)
+	return err
+}
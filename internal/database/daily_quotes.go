@@ -0,0 +1,255 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"hash/fnv"
+	"time"
+
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// DailyQuote is the quote picked for one calendar day. Hashtag is empty for
+// the general pool, otherwise the pick only considers quotes with that
+// hashtag. Picks go through the public quotes of a pool in cycles, so no
+// quote repeats until every one of them has been shown.
+type DailyQuote struct {
+	Day        time.Time
+	Hashtag    string
+	Quote      Quote
+	IsOverride bool
+}
+
+// DailyQuoteStore is implemented by stores that support the quote of the day.
+type DailyQuoteStore interface {
+	GetDailyQuote(ctx context.Context, day time.Time, hashtag string) (*DailyQuote, error)
+	GetDailyQuoteHistory(ctx context.Context, hashtag string, until time.Time, filters f.Filters) ([]DailyQuote, f.Metadata, error)
+	SetDailyQuoteOverride(ctx context.Context, day time.Time, hashtag string, quoteID, userID uuid.UUID) error
+	DeleteDailyQuoteOverride(ctx context.Context, day time.Time, hashtag string) error
+}
+
+var _ DailyQuoteStore = (*DB)(nil)
+
+// GetDailyQuote returns the quote for day, picking and recording one on the
+// first request for that day. It returns ErrRecordNotFound when the pool has
+// no public quotes.
+func (db *DB) GetDailyQuote(ctx context.Context, day time.Time, hashtag string) (*DailyQuote, error) {
+	ctx, done := db.write(ctx, "GetDailyQuote")
+	defer done()
+
+	day = truncateDay(day)
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Serialize picks per pool so concurrent first requests agree.
+	_, err = tx.ExecContext(ctx, `select pg_advisory_xact_lock(hashtext('daily_quotes:' || $1::text))`, hashtag)
+	if err != nil {
+		return nil, err
+	}
+
+	var quoteID uuid.UUID
+	var isOverride, isPublic bool
+
+	query := `
+		select d.quote_id, d.is_override, s.is_public and (q.publish_at is null or q.publish_at <= now())
+		from daily_quotes d
+		inner join quotes q on d.quote_id = q.id
+		inner join quote_states s on q.state = s.id
+		where d.day = $1 and d.pool = $2`
+
+	err = tx.QueryRowContext(ctx, query, day, hashtag).Scan(&quoteID, &isOverride, &isPublic)
+	switch {
+	case err == nil && isPublic:
+	case err == nil, errors.Is(err, sql.ErrNoRows):
+		// Nothing picked yet, or the pick has been unpublished since.
+		quoteID, err = pickDailyQuote(ctx, tx, day, hashtag)
+		if err != nil {
+			return nil, err
+		}
+		isOverride = false
+	default:
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	quote, err := db.GetQuoteById(ctx, quoteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DailyQuote{Day: day, Hashtag: hashtag, Quote: *quote, IsOverride: isOverride}, nil
+}
+
+// pickDailyQuote chooses a public quote of the pool that hasn't been shown in
+// the current cycle, starting a new cycle once all of them have been. The
+// choice is a hash of the day and pool, so it only depends on the data.
+func pickDailyQuote(ctx context.Context, tx *sqlx.Tx, day time.Time, hashtag string) (uuid.UUID, error) {
+	var cycle int
+	err := tx.QueryRowContext(ctx, `select coalesce(max(cycle), 1) from daily_quotes where pool = $1`, hashtag).Scan(&cycle)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	candidates := `
+		from quotes q
+		inner join quote_states s on q.state = s.id and s.is_public = true
+		where q.original_id is null
+		and (q.publish_at is null or q.publish_at <= now())
+		and ($1 = '' or exists (
+			select 1 from quote_hashtags qh inner join hashtags h on qh.hashtag_id = h.id
+			where qh.quote_id = q.id and h.value = $1))
+		and q.id not in (select quote_id from daily_quotes where pool = $1 and cycle = $2 and day <> $3)`
+
+	var count int
+	err = tx.QueryRowContext(ctx, `select count(*) `+candidates, hashtag, cycle, day).Scan(&count)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if count == 0 {
+		cycle++
+		err = tx.QueryRowContext(ctx, `select count(*) `+candidates, hashtag, cycle, day).Scan(&count)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if count == 0 {
+			return uuid.Nil, ErrRecordNotFound
+		}
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(day.Format("2006-01-02") + "|" + hashtag))
+	offset := h.Sum64() % uint64(count)
+
+	var quoteID uuid.UUID
+	err = tx.QueryRowContext(ctx, `select q.id `+candidates+` order by q.id offset $4 limit 1`, hashtag, cycle, day, offset).Scan(&quoteID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	query := `
+		insert into daily_quotes (day, pool, quote_id, cycle, is_override)
+		values ($1, $2, $3, $4, false)
+		on conflict (day, pool) do update
+		set quote_id = excluded.quote_id, cycle = excluded.cycle, is_override = false, created_by = null, created_at = now()`
+
+	_, err = tx.ExecContext(ctx, query, day, hashtag, quoteID, cycle)
+	return quoteID, err
+}
+
+// GetDailyQuoteHistory lists the recorded daily quotes of a pool up to and
+// including until, newest first.
+func (db *DB) GetDailyQuoteHistory(ctx context.Context, hashtag string, until time.Time, filters f.Filters) ([]DailyQuote, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetDailyQuoteHistory")
+	defer done()
+
+	query := `
+		select count(*) over(), d.day, d.is_override, q.id
+		from daily_quotes d
+		inner join quotes q on d.quote_id = q.id
+		inner join quote_states s on q.state = s.id and s.is_public = true
+		where d.pool = $1 and d.day <= $2
+		and (q.publish_at is null or q.publish_at <= now())
+		order by d.day desc
+		limit $3 offset $4`
+
+	rows, err := db.QueryContext(ctx, query, hashtag, truncateDay(until), filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	var ids []uuid.UUID
+	history := make([]DailyQuote, 0)
+
+	for rows.Next() {
+		daily := DailyQuote{Hashtag: hashtag}
+		var id uuid.UUID
+
+		err := rows.Scan(&totalRecords, &daily.Day, &daily.IsOverride, &id)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		ids = append(ids, id)
+		history = append(history, daily)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		quote, err := db.GetQuoteById(ctx, id)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+		history[i].Quote = *quote
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return history, metadata, nil
+}
+
+// SetDailyQuoteOverride schedules quoteID as the daily quote of day,
+// replacing whatever was picked or scheduled before.
+func (db *DB) SetDailyQuoteOverride(ctx context.Context, day time.Time, hashtag string, quoteID, userID uuid.UUID) error {
+	ctx, done := db.write(ctx, "SetDailyQuoteOverride")
+	defer done()
+
+	query := `
+		insert into daily_quotes (day, pool, quote_id, cycle, is_override, created_by)
+		values ($1, $2, $3, (select coalesce(max(cycle), 1) from daily_quotes where pool = $2), true, $4)
+		on conflict (day, pool) do update
+		set quote_id = excluded.quote_id, is_override = true, created_by = excluded.created_by, created_at = now()`
+
+	_, err := db.ExecContext(ctx, query, truncateDay(day), hashtag, quoteID, userID)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteDailyQuoteOverride(ctx context.Context, day time.Time, hashtag string) error {
+	ctx, done := db.write(ctx, "DeleteDailyQuoteOverride")
+	defer done()
+
+	query := `delete from daily_quotes where day = $1 and pool = $2 and is_override = true`
+
+	result, err := db.ExecContext(ctx, query, truncateDay(day), hashtag)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// truncateDay keeps the calendar date of t as midnight UTC, the way a date
+// column stores it.
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
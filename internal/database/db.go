@@ -1,20 +1,21 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"time"
 
-	"javlonrahimov/quotes-api/assets"
+	"javlonrahimov/quotes-api/internal/leveledlog"
+	"javlonrahimov/quotes-api/internal/migrations"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/jmoiron/sqlx"
-
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-const defaultTimeout = 3 * time.Second
+const (
+	defaultReadTimeout  = 3 * time.Second
+	defaultWriteTimeout = 3 * time.Second
+)
 
 var (
 	ErrRecordNotFound      = errors.New("record not found")
@@ -23,13 +24,34 @@ var (
 	ErrDuplicateHashtag    = errors.New("duplicate hashtag")
 	ErrDuplicateQuoteState = errors.New("duplicate quote state")
 	ErrDefaultState        = errors.New("default state")
+	ErrDuplicateCollection = errors.New("duplicate collection")
+	ErrCollectionFull      = errors.New("collection full")
+	ErrCollectionOrder     = errors.New("collection order")
+	ErrNestedReply         = errors.New("nested reply")
+	ErrDuplicateReport     = errors.New("duplicate report")
+	ErrDuplicateAuthor     = errors.New("duplicate author")
+	ErrDuplicateLanguage   = errors.New("duplicate language")
+
+	ErrDuplicateTelegramPost = errors.New("duplicate telegram post")
 )
 
+// Options tunes how long database operations may run. QueryTimeouts overrides
+// the read/write timeout for individual operations, keyed by method name
+// (e.g. "GetQuotes"). Operations that take at least SlowQueryThreshold are
+// logged as warnings; a zero threshold disables slow query logging.
+type Options struct {
+	ReadTimeout        time.Duration
+	WriteTimeout       time.Duration
+	QueryTimeouts      map[string]time.Duration
+	SlowQueryThreshold time.Duration
+}
+
 type DB struct {
 	*sqlx.DB
+	tracker *Tracker
 }
 
-func New(dsn string, automigrate bool) (*DB, error) {
+func New(dsn string, automigrate bool, opts Options, logger *leveledlog.Logger) (*DB, error) {
 	db, err := sqlx.Connect("postgres", dsn)
 	if err != nil {
 		return nil, err
@@ -40,25 +62,93 @@ func New(dsn string, automigrate bool) (*DB, error) {
 	db.SetConnMaxIdleTime(5 * time.Minute)
 	db.SetConnMaxLifetime(2 * time.Hour)
 
+	migrator, err := migrations.Postgres(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer migrator.Close()
+
 	if automigrate {
-		iofsDriver, err := iofs.New(assets.EmbeddedFiles, "migrations")
-		if err != nil {
-			return nil, err
-		}
+		err = migrator.Up()
+	} else {
+		err = migrator.Check()
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		migrator, err := migrate.NewWithSourceInstance("iofs", iofsDriver, dsn)
-		if err != nil {
-			return nil, err
-		}
+	return &DB{DB: db, tracker: NewTracker(opts, logger)}, nil
+}
 
-		err = migrator.Up()
-		switch {
-		case errors.Is(err, migrate.ErrNoChange):
-			break
-		case err != nil:
-			return nil, err
-		}
+func (db *DB) Reset(ctx context.Context) error {
+	ctx, done := db.write(ctx, "Reset")
+	defer done()
+
+	_, err := db.ExecContext(ctx, `truncate telegram_posts, webhook_attempts, webhook_deliveries, webhooks, sync_tombstones, author_aliases, authors, quote_reports, quote_state_changes, comments, collection_follows, collection_items, collections, daily_quotes, quote_likes, quote_favorites, quote_hashtags, quotes, hashtags, photos, otps, users_permissions, users`)
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+func (db *DB) read(ctx context.Context, name string) (context.Context, func()) {
+	return db.tracker.Read(ctx, name)
+}
+
+func (db *DB) write(ctx context.Context, name string) (context.Context, func()) {
+	return db.tracker.Write(ctx, name)
+}
+
+// Tracker bounds database operations by the configured timeouts and logs the
+// slow ones. It is shared by every Store implementation backed by SQL.
+type Tracker struct {
+	opts   Options
+	logger *leveledlog.Logger
+}
+
+func NewTracker(opts Options, logger *leveledlog.Logger) *Tracker {
+	if opts.ReadTimeout <= 0 {
+		opts.ReadTimeout = defaultReadTimeout
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = defaultWriteTimeout
+	}
+
+	return &Tracker{opts: opts, logger: logger}
+}
+
+func (t *Tracker) Read(ctx context.Context, name string) (context.Context, func()) {
+	return t.operation(ctx, name, t.opts.ReadTimeout)
+}
+
+func (t *Tracker) Write(ctx context.Context, name string) (context.Context, func()) {
+	return t.operation(ctx, name, t.opts.WriteTimeout)
+}
+
+// operation derives a context for the named operation from the caller's
+// context, so the query is cancelled when the request is. The returned func
+// releases the context and reports the operation if it was slow.
+func (t *Tracker) operation(ctx context.Context, name string, timeout time.Duration) (context.Context, func()) {
+	if d, ok := t.opts.QueryTimeouts[name]; ok && d > 0 {
+		timeout = d
 	}
 
-	return &DB{db}, nil
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	start := time.Now()
+
+	return ctx, func() {
+		cancel()
+
+		elapsed := time.Since(start)
+		if t.logger != nil && t.opts.SlowQueryThreshold > 0 && elapsed >= t.opts.SlowQueryThreshold {
+			t.logger.Warning("slow query %s took %s", name, elapsed)
+		}
+	}
 }
@@ -0,0 +1,38 @@
+package database_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/database/storetest"
+	"javlonrahimov/quotes-api/internal/leveledlog"
+)
+
+// TestStore runs against the Postgres database in QUOTES_TEST_DB_DSN, which
+// it empties before every test. It is skipped when the variable is unset.
+func TestStore(t *testing.T) {
+	dsn := os.Getenv("QUOTES_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("QUOTES_TEST_DB_DSN is not set")
+	}
+
+	logger := leveledlog.NewLogger(io.Discard, leveledlog.LevelAll, false)
+
+	db, err := database.New(dsn, true, database.Options{}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	storetest.Run(t, func(t *testing.T) database.Store {
+		err := db.Reset(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return db
+	})
+}
@@ -14,9 +14,9 @@ type Hashtag struct {
 	Value string    `db:"value"`
 }
 
-func (db *DB) InsertHashtag(value string) (*Hashtag, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) InsertHashtag(ctx context.Context, value string) (*Hashtag, error) {
+	ctx, done := db.write(ctx, "InsertHashtag")
+	defer done()
 
 	hashtag := Hashtag{
 		ID:    uuid.New(),
@@ -39,28 +39,48 @@ func (db *DB) InsertHashtag(value string) (*Hashtag, error) {
 	return &hashtag, nil
 }
 
-func (db *DB) DeleteHashtagById(id uuid.UUID) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) GetHashtagByValue(ctx context.Context, value string) (*Hashtag, error) {
+	ctx, done := db.read(ctx, "GetHashtagByValue")
+	defer done()
 
-	query := `delete from hashtags where id = $1`
+	query := `select id, value from hashtags where value = $1`
+
+	var hashtag Hashtag
 
-	_, err := db.ExecContext(ctx, query, id)
+	err := db.QueryRowContext(ctx, query, value).Scan(&hashtag.ID, &hashtag.Value)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return ErrRecordNotFound
+			return nil, ErrRecordNotFound
 		default:
-			return err
+			return nil, err
 		}
 	}
 
+	return &hashtag, nil
+}
+
+func (db *DB) DeleteHashtagById(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "DeleteHashtagById")
+	defer done()
+
+	query := `delete from hashtags where id = $1`
+
+	result, err := db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrRecordNotFound
+	}
+
 	return nil
 }
 
-func (db *DB) IsQuoteExistsWithThisHashtag(hashtagID uuid.UUID) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) IsQuoteExistsWithThisHashtag(ctx context.Context, hashtagID uuid.UUID) bool {
+	ctx, done := db.read(ctx, "IsQuoteExistsWithThisHashtag")
+	defer done()
 
 	query :=
 		`select exists (select id from quote_hashtags where hashtag_id = $1)`
@@ -74,9 +94,9 @@ func (db *DB) IsQuoteExistsWithThisHashtag(hashtagID uuid.UUID) bool {
 	return exists
 }
 
-func (db *DB) GetQuoteHashtags(quoteID uuid.UUID) ([]Hashtag, f.Metadata, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) GetQuoteHashtags(ctx context.Context, quoteID uuid.UUID) ([]Hashtag, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetQuoteHashtags")
+	defer done()
 
 	query := `
 		select count(*) over(), h.id, h.value
@@ -115,9 +135,9 @@ func (db *DB) GetQuoteHashtags(quoteID uuid.UUID) ([]Hashtag, f.Metadata, error)
 	return hashtags, metadata, nil
 }
 
-func (db *DB) GetHashtags(filters f.Filters) ([]Hashtag, f.Metadata, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) GetHashtags(ctx context.Context, filters f.Filters) ([]Hashtag, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetHashtags")
+	defer done()
 
 	query := fmt.Sprintf(`
 		select count(*) over(), id, value
@@ -0,0 +1,253 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+type comment struct {
+	id        uuid.UUID
+	quoteID   uuid.UUID
+	parentID  *uuid.UUID
+	userID    uuid.UUID
+	text      string
+	stateID   uuid.UUID
+	isHidden  bool
+	hiddenBy  *uuid.UUID
+	createdAt time.Time
+	updatedAt time.Time
+	editedAt  *time.Time
+	deletedAt *time.Time
+}
+
+var _ database.CommentStore = (*Store)(nil)
+
+func (s *Store) InsertComment(ctx context.Context, c *database.Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.quoteIndex(c.QuoteID)
+	if i < 0 {
+		return database.ErrRecordNotFound
+	}
+
+	if state, err := s.state(s.quotes[i].stateID); err != nil || !state.IsPublic || !s.quotes[i].live() {
+		return database.ErrRecordNotFound
+	}
+
+	if c.ParentID != nil {
+		j := s.commentIndex(*c.ParentID)
+		switch {
+		case j < 0 || s.comments[j].deletedAt != nil:
+			return database.ErrRecordNotFound
+		case s.comments[j].quoteID != c.QuoteID || s.comments[j].parentID != nil:
+			return database.ErrNestedReply
+		}
+	}
+
+	var stateID uuid.UUID
+	for _, st := range s.states {
+		if st.IsDefault {
+			stateID = st.ID
+		}
+	}
+	if stateID == uuid.Nil {
+		return database.ErrDefaultState
+	}
+
+	now := time.Now()
+	stored := comment{
+		id:        uuid.New(),
+		quoteID:   c.QuoteID,
+		parentID:  c.ParentID,
+		userID:    c.UserID,
+		text:      c.Text,
+		stateID:   stateID,
+		createdAt: now,
+		updatedAt: now,
+	}
+	s.comments = append(s.comments, stored)
+
+	*c = s.hydrateComment(stored)
+	return nil
+}
+
+func (s *Store) GetComment(ctx context.Context, id uuid.UUID) (*database.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.commentIndex(id)
+	if i < 0 {
+		return nil, database.ErrRecordNotFound
+	}
+
+	c := s.hydrateComment(s.comments[i])
+	return &c, nil
+}
+
+func (s *Store) GetQuoteComments(ctx context.Context, quoteID, viewerID uuid.UUID, moderator bool, filters f.Filters) ([]database.Comment, f.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	visible := func(c comment) bool {
+		if c.deletedAt != nil {
+			return false
+		}
+		state, err := s.state(c.stateID)
+		return moderator || c.userID == viewerID || (err == nil && state.IsPublic && !c.isHidden)
+	}
+
+	ordered := append([]comment(nil), s.comments...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].createdAt.Before(ordered[j].createdAt)
+	})
+
+	replies := make(map[uuid.UUID][]database.Comment)
+	for _, c := range ordered {
+		if c.quoteID == quoteID && c.parentID != nil && visible(c) {
+			replies[*c.parentID] = append(replies[*c.parentID], s.hydrateComment(c))
+		}
+	}
+
+	comments := []database.Comment{}
+	for _, c := range ordered {
+		if c.quoteID != quoteID || c.parentID != nil || (!visible(c) && len(replies[c.id]) == 0) {
+			continue
+		}
+
+		hydrated := s.hydrateComment(c)
+		hydrated.Replies = replies[c.id]
+		comments = append(comments, hydrated)
+	}
+
+	metadata := f.CalculateMetadata(len(comments), filters.Page, filters.PageSize)
+
+	return paginate(comments, filters), metadata, nil
+}
+
+func (s *Store) UpdateCommentText(ctx context.Context, id uuid.UUID, text string) (*database.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.commentIndex(id)
+	if i < 0 || s.comments[i].deletedAt != nil {
+		return nil, database.ErrRecordNotFound
+	}
+
+	now := time.Now()
+	s.comments[i].text = text
+	s.comments[i].editedAt = &now
+	s.comments[i].updatedAt = now
+
+	c := s.hydrateComment(s.comments[i])
+	return &c, nil
+}
+
+func (s *Store) DeleteComment(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.commentIndex(id)
+	if i < 0 || s.comments[i].deletedAt != nil {
+		return database.ErrRecordNotFound
+	}
+
+	now := time.Now()
+	s.comments[i].deletedAt = &now
+	s.comments[i].updatedAt = now
+
+	return nil
+}
+
+func (s *Store) SetCommentState(ctx context.Context, id, stateID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.state(stateID); err != nil {
+		return err
+	}
+
+	i := s.commentIndex(id)
+	if i < 0 {
+		return database.ErrRecordNotFound
+	}
+
+	s.comments[i].stateID = stateID
+	s.comments[i].updatedAt = time.Now()
+
+	return nil
+}
+
+func (s *Store) SetCommentHidden(ctx context.Context, id uuid.UUID, hidden bool, moderatorID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.commentIndex(id)
+	if i < 0 {
+		return database.ErrRecordNotFound
+	}
+
+	s.comments[i].isHidden = hidden
+	s.comments[i].hiddenBy = nil
+	if hidden {
+		s.comments[i].hiddenBy = &moderatorID
+	}
+	s.comments[i].updatedAt = time.Now()
+
+	return nil
+}
+
+func (s *Store) commentIndex(id uuid.UUID) int {
+	for i := range s.comments {
+		if s.comments[i].id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Store) hydrateComment(c comment) database.Comment {
+	hydrated := database.Comment{
+		ID:        c.id,
+		QuoteID:   c.quoteID,
+		ParentID:  c.parentID,
+		UserID:    c.userID,
+		Text:      c.text,
+		IsHidden:  c.isHidden,
+		CreatedAt: c.createdAt,
+		UpdatedAt: c.updatedAt,
+		EditedAt:  c.editedAt,
+		DeletedAt: c.deletedAt,
+	}
+
+	if i := s.userIndex(c.userID); i >= 0 {
+		hydrated.UserName = s.users[i].Name
+	}
+
+	if state, err := s.state(c.stateID); err == nil {
+		hydrated.State = *state
+	}
+
+	return hydrated
+}
+
+// commentCount is how many comments of a quote everyone can see, which
+// Postgres keeps in quotes.comment_count.
+func (s *Store) commentCount(quoteID uuid.UUID) int {
+	count := 0
+	for _, c := range s.comments {
+		if c.quoteID != quoteID || c.deletedAt != nil || c.isHidden {
+			continue
+		}
+		if state, err := s.state(c.stateID); err == nil && state.IsPublic {
+			count++
+		}
+	}
+	return count
+}
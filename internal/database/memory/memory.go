@@ -0,0 +1,1463 @@
+// Package memory is an in-memory implementation of database.Store. It mirrors
+// the Postgres implementation's behaviour (unique constraints, not-found
+// errors, search and pagination) closely enough to exercise the API handlers
+// without a database.
+package memory
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"javlonrahimov/quotes-api/internal/database"
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+)
+
+var errForeignKey = errors.New("record is still referenced")
+
+type quote struct {
+	id        uuid.UUID
+	createdAt time.Time
+	updatedAt time.Time
+	createdBy uuid.UUID
+	author    string
+	text      string
+	stateID   uuid.UUID
+	photoID   uuid.UUID
+
+	source       database.QuoteSource
+	verification string
+	verifiedBy   *uuid.UUID
+	verifiedAt   *time.Time
+
+	language   string
+	originalID *uuid.UUID
+	publishAt  *time.Time
+}
+
+// live reports whether the quote's publish time, if any, has passed.
+func (q quote) live() bool {
+	return q.publishAt == nil || !q.publishAt.After(time.Now())
+}
+
+type permission struct {
+	id   uuid.UUID
+	code string
+}
+
+type Store struct {
+	mu              sync.RWMutex
+	users           []database.User
+	otps            []database.OTP
+	hashtags        []database.Hashtag
+	photos          []database.Photo
+	states          []database.QuoteState
+	quotes          []quote
+	quoteHashtags   map[uuid.UUID][]uuid.UUID
+	permissions     []permission
+	userPermissions map[uuid.UUID][]uuid.UUID
+	likes           map[uuid.UUID]map[uuid.UUID]time.Time
+	favorites       map[uuid.UUID]map[uuid.UUID]time.Time
+	comments        []comment
+	reports         []database.QuoteReport
+	stateChanges    []stateChange
+}
+
+var (
+	_ database.Store    = (*Store)(nil)
+	_ database.Resetter = (*Store)(nil)
+)
+
+// New returns an empty store holding the quote states and permissions that
+// the migrations seed.
+func New() *Store {
+	return &Store{
+		states: []database.QuoteState{
+			{ID: uuid.New(), Value: "pending", IsDefault: true, Color: "#F3D104"},
+			{ID: uuid.New(), Value: "rejected", Color: "#C94040"},
+			{ID: uuid.New(), Value: "accepted", Color: "#047A37", IsPublic: true},
+		},
+		permissions: []permission{
+			{id: uuid.New(), code: "quotes:read"},
+			{id: uuid.New(), code: "quotes:write"},
+			{id: uuid.New(), code: "quotes:state"},
+			{id: uuid.New(), code: "quotes:verify"},
+		},
+		quoteHashtags:   make(map[uuid.UUID][]uuid.UUID),
+		userPermissions: make(map[uuid.UUID][]uuid.UUID),
+		likes:           make(map[uuid.UUID]map[uuid.UUID]time.Time),
+		favorites:       make(map[uuid.UUID]map[uuid.UUID]time.Time),
+	}
+}
+
+func (s *Store) Reset(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = nil
+	s.otps = nil
+	s.hashtags = nil
+	s.photos = nil
+	s.quotes = nil
+	s.quoteHashtags = make(map[uuid.UUID][]uuid.UUID)
+	s.userPermissions = make(map[uuid.UUID][]uuid.UUID)
+	s.likes = make(map[uuid.UUID]map[uuid.UUID]time.Time)
+	s.favorites = make(map[uuid.UUID]map[uuid.UUID]time.Time)
+	s.comments = nil
+	s.reports = nil
+	s.stateChanges = nil
+
+	return nil
+}
+
+// users
+
+func (s *Store) InsertUser(ctx context.Context, email, hashedPassword, name string) (*database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return nil, database.ErrDuplicateEmail
+		}
+	}
+
+	user := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      time.Now(),
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Name:           name,
+	}
+	s.users = append(s.users, user)
+
+	return &user, nil
+}
+
+func (s *Store) GetUser(ctx context.Context, id uuid.UUID) (*database.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if i := s.userIndex(id); i >= 0 {
+		user := s.users[i]
+		return &user, nil
+	}
+
+	return nil, nil
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*database.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+
+	return nil, database.ErrRecordNotFound
+}
+
+func (s *Store) UpdateUserHashedPassword(ctx context.Context, id uuid.UUID, hashedPassword string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.userIndex(id); i >= 0 {
+		s.users[i].HashedPassword = hashedPassword
+	}
+
+	return nil
+}
+
+func (s *Store) ActivateUser(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.userIndex(id); i >= 0 {
+		s.users[i].IsActivated = true
+	}
+
+	return nil
+}
+
+func (s *Store) userIndex(id uuid.UUID) int {
+	for i := range s.users {
+		if s.users[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// otps
+
+func (s *Store) InsertOTP(ctx context.Context, otp *database.OTP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userIndex(otp.UserID) < 0 {
+		return errForeignKey
+	}
+
+	stored := *otp
+	stored.Plaintext = ""
+	s.otps = append(s.otps, stored)
+
+	return nil
+}
+
+func (s *Store) GetOTPForEmail(ctx context.Context, email string, scope string) (*database.OTP, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var latest *database.OTP
+
+	for _, u := range s.users {
+		if u.Email != email {
+			continue
+		}
+
+		for i := range s.otps {
+			otp := s.otps[i]
+			if otp.UserID == u.ID && otp.Scope == scope && (latest == nil || otp.Created.After(latest.Created)) {
+				latest = &otp
+			}
+		}
+	}
+
+	if latest == nil {
+		return nil, database.ErrRecordNotFound
+	}
+
+	return latest, nil
+}
+
+func (s *Store) DeleteAllOTPForUser(ctx context.Context, userID uuid.UUID, scope string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	otps := s.otps[:0]
+	for _, otp := range s.otps {
+		if otp.UserID != userID || otp.Scope != scope {
+			otps = append(otps, otp)
+		}
+	}
+	s.otps = otps
+
+	return nil
+}
+
+func (s *Store) NewOtp(ctx context.Context, userID uuid.UUID, ttl time.Duration, scope string) (*database.OTP, error) {
+	otp, err := database.GenerateOTP(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.InsertOTP(ctx, otp)
+	return otp, err
+}
+
+// permissions
+
+func (s *Store) GetAllPermissionsForUser(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var codes []string
+
+	for _, id := range s.userPermissions[userID] {
+		for _, p := range s.permissions {
+			if p.id == id {
+				codes = append(codes, p.code)
+			}
+		}
+	}
+
+	return codes, nil
+}
+
+func (s *Store) AddPermissionForUser(ctx context.Context, userID uuid.UUID, codes ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userIndex(userID) < 0 {
+		return errForeignKey
+	}
+
+	for _, p := range s.permissions {
+		if !slices.Contains(codes, p.code) || slices.Contains(s.userPermissions[userID], p.id) {
+			continue
+		}
+		s.userPermissions[userID] = append(s.userPermissions[userID], p.id)
+	}
+
+	return nil
+}
+
+// photos
+
+func (s *Store) InsertPhoto(ctx context.Context, color, blurHash, author, url string) (*database.Photo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	photo := database.Photo{
+		ID:       uuid.New(),
+		Color:    color,
+		BlurHash: blurHash,
+		Author:   author,
+		Url:      url,
+	}
+	s.photos = append(s.photos, photo)
+
+	return &photo, nil
+}
+
+func (s *Store) GetPhotoById(ctx context.Context, id uuid.UUID) (*database.Photo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.photo(id)
+}
+
+func (s *Store) GetPhotoByUrl(ctx context.Context, url string) (*database.Photo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.photos {
+		if p.Url == url {
+			return &p, nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (s *Store) GetPhotos(ctx context.Context) ([]*database.Photo, f.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	photos := []*database.Photo{}
+	for i := range s.photos {
+		photo := s.photos[i]
+		photos = append(photos, &photo)
+	}
+
+	return photos, f.CalculateMetadata(len(photos), 1, len(photos)), nil
+}
+
+func (s *Store) photo(id uuid.UUID) (*database.Photo, error) {
+	for _, p := range s.photos {
+		if p.ID == id {
+			return &p, nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+// hashtags
+
+func (s *Store) InsertHashtag(ctx context.Context, value string) (*database.Hashtag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, h := range s.hashtags {
+		if h.Value == value {
+			return nil, database.ErrDuplicateHashtag
+		}
+	}
+
+	hashtag := database.Hashtag{ID: uuid.New(), Value: value}
+	s.hashtags = append(s.hashtags, hashtag)
+
+	return &hashtag, nil
+}
+
+func (s *Store) GetHashtagByValue(ctx context.Context, value string) (*database.Hashtag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, h := range s.hashtags {
+		if h.Value == value {
+			return &h, nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (s *Store) DeleteHashtagById(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, h := range s.hashtags {
+		if h.ID == id {
+			s.hashtags = append(s.hashtags[:i], s.hashtags[i+1:]...)
+
+			for quoteID, ids := range s.quoteHashtags {
+				s.quoteHashtags[quoteID] = remove(ids, id)
+			}
+			return nil
+		}
+	}
+
+	return database.ErrRecordNotFound
+}
+
+func (s *Store) IsQuoteExistsWithThisHashtag(ctx context.Context, hashtagID uuid.UUID) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, ids := range s.quoteHashtags {
+		if slices.Contains(ids, hashtagID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) GetQuoteHashtags(ctx context.Context, quoteID uuid.UUID) ([]database.Hashtag, f.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hashtags := s.quoteHashtagList(quoteID)
+
+	return hashtags, f.CalculateMetadata(len(hashtags), 1, len(hashtags)), nil
+}
+
+func (s *Store) GetHashtags(ctx context.Context, filters f.Filters) ([]database.Hashtag, f.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hashtags := make([]database.Hashtag, len(s.hashtags))
+	copy(hashtags, s.hashtags)
+
+	desc := filters.SortDirection() == "DESC"
+	sort.SliceStable(hashtags, func(i, j int) bool {
+		if desc {
+			return hashtags[i].Value > hashtags[j].Value
+		}
+		return hashtags[i].Value < hashtags[j].Value
+	})
+
+	metadata := f.CalculateMetadata(len(hashtags), filters.Page, filters.PageSize)
+
+	return paginate(hashtags, filters), metadata, nil
+}
+
+func (s *Store) quoteHashtagList(quoteID uuid.UUID) []database.Hashtag {
+	hashtags := []database.Hashtag{}
+	for _, h := range s.hashtags {
+		if slices.Contains(s.quoteHashtags[quoteID], h.ID) {
+			hashtags = append(hashtags, h)
+		}
+	}
+	return hashtags
+}
+
+// quote states
+
+func (s *Store) InsertQuoteState(ctx context.Context, state *database.QuoteState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, st := range s.states {
+		if st.Value == state.Value {
+			return database.ErrDuplicateQuoteState
+		}
+		if st.Color == state.Color {
+			return errors.New("duplicate quote state color")
+		}
+	}
+
+	state.ID = uuid.New()
+	s.states = append(s.states, database.QuoteState{
+		ID:       state.ID,
+		Value:    state.Value,
+		Color:    state.Color,
+		IsPublic: state.IsPublic,
+	})
+
+	if state.IsDefault {
+		s.setDefaultState(state.ID)
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteQuoteStateById(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, st := range s.states {
+		if st.ID != id {
+			continue
+		}
+
+		if st.IsDefault {
+			return database.ErrDefaultState
+		}
+
+		for _, q := range s.quotes {
+			if q.stateID == id {
+				return errForeignKey
+			}
+		}
+
+		s.states = append(s.states[:i], s.states[i+1:]...)
+		break
+	}
+
+	return nil
+}
+
+func (s *Store) ExistsQuoteStateById(ctx context.Context, id uuid.UUID) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, err := s.state(id)
+	return err == nil
+}
+
+func (s *Store) SetDefaultQuoteState(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.state(id); err != nil {
+		return err
+	}
+
+	s.setDefaultState(id)
+	return nil
+}
+
+func (s *Store) GetAllQuoteStates(ctx context.Context) ([]database.QuoteState, f.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	states := make([]database.QuoteState, len(s.states))
+	copy(states, s.states)
+
+	return states, f.CalculateMetadata(len(states), 1, len(states)), nil
+}
+
+func (s *Store) state(id uuid.UUID) (*database.QuoteState, error) {
+	for _, st := range s.states {
+		if st.ID == id {
+			return &st, nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (s *Store) setDefaultState(id uuid.UUID) {
+	for i := range s.states {
+		s.states[i].IsDefault = s.states[i].ID == id
+	}
+}
+
+// quotes
+
+func (s *Store) InsertQuote(ctx context.Context, author, text string, userID, photoID uuid.UUID, hashtagIDs []uuid.UUID, stateID *uuid.UUID) (*database.Quote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var state *database.QuoteState
+	for i := range s.states {
+		if s.states[i].IsDefault {
+			state = &s.states[i]
+		}
+	}
+
+	if stateID != nil {
+		var err error
+		state, err = s.state(*stateID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if state == nil {
+		return nil, database.ErrRecordNotFound
+	}
+
+	if _, err := s.photo(photoID); err != nil {
+		return nil, err
+	}
+
+	if s.userIndex(userID) < 0 {
+		return nil, errForeignKey
+	}
+
+	q := quote{
+		id:        uuid.New(),
+		createdAt: time.Now(),
+		updatedAt: time.Now(),
+		createdBy: userID,
+		author:    author,
+		text:      text,
+		stateID:   state.ID,
+		photoID:   photoID,
+	}
+
+	if err := s.setQuoteHashtags(q.id, hashtagIDs); err != nil {
+		return nil, err
+	}
+
+	s.quotes = append(s.quotes, q)
+
+	return s.hydrate(q), nil
+}
+
+// ImportQuotes applies all quotes or none: on error the store is restored to
+// its state before the call.
+func (s *Store) ImportQuotes(ctx context.Context, userID uuid.UUID, quotes []database.QuoteImport) ([]database.ImportedQuote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	photos, hashtags, stored := len(s.photos), len(s.hashtags), len(s.quotes)
+
+	results, err := s.importQuotes(userID, quotes)
+	if err != nil {
+		for _, q := range s.quotes[stored:] {
+			delete(s.quoteHashtags, q.id)
+		}
+		s.photos, s.hashtags, s.quotes = s.photos[:photos], s.hashtags[:hashtags], s.quotes[:stored]
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (s *Store) importQuotes(userID uuid.UUID, quotes []database.QuoteImport) ([]database.ImportedQuote, error) {
+	if s.userIndex(userID) < 0 {
+		return nil, database.ErrRecordNotFound
+	}
+
+	results := make([]database.ImportedQuote, 0, len(quotes))
+
+	for _, in := range quotes {
+		duplicate := false
+		for _, q := range s.quotes {
+			if q.author == in.Author && q.text == in.Text {
+				results = append(results, database.ImportedQuote{QuoteID: q.id, Duplicate: true})
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		var state *database.QuoteState
+		if in.StateID != nil {
+			var err error
+			state, err = s.state(*in.StateID)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			for i := range s.states {
+				if s.states[i].IsDefault {
+					state = &s.states[i]
+				}
+			}
+		}
+
+		if state == nil {
+			return nil, database.ErrDefaultState
+		}
+
+		var photoID uuid.UUID
+		for _, p := range s.photos {
+			if p.Url == in.Photo.Url {
+				photoID = p.ID
+				break
+			}
+		}
+		if photoID == uuid.Nil {
+			photo := in.Photo
+			photo.ID = uuid.New()
+			s.photos = append(s.photos, photo)
+			photoID = photo.ID
+		}
+
+		var hashtagIDs []uuid.UUID
+		for _, value := range in.Hashtags {
+			var id uuid.UUID
+			for _, h := range s.hashtags {
+				if h.Value == value {
+					id = h.ID
+					break
+				}
+			}
+			if id == uuid.Nil {
+				id = uuid.New()
+				s.hashtags = append(s.hashtags, database.Hashtag{ID: id, Value: value})
+			}
+			hashtagIDs = append(hashtagIDs, id)
+		}
+
+		q := quote{
+			id:        uuid.New(),
+			createdAt: time.Now(),
+			updatedAt: time.Now(),
+			createdBy: userID,
+			author:    in.Author,
+			text:      in.Text,
+			stateID:   state.ID,
+			photoID:   photoID,
+		}
+
+		if err := s.setQuoteHashtags(q.id, hashtagIDs); err != nil {
+			return nil, err
+		}
+
+		s.quotes = append(s.quotes, q)
+		results = append(results, database.ImportedQuote{QuoteID: q.id})
+	}
+
+	return results, nil
+}
+
+func (s *Store) UpdateQuote(ctx context.Context, quoteID, photoID uuid.UUID, author, text string, hashtagIDs []uuid.UUID) (*database.Quote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.quoteIndex(quoteID)
+	if i < 0 {
+		return nil, database.ErrRecordNotFound
+	}
+
+	if _, err := s.photo(photoID); err != nil {
+		return nil, err
+	}
+
+	if err := s.setQuoteHashtags(quoteID, hashtagIDs); err != nil {
+		return nil, err
+	}
+
+	s.quotes[i].author = author
+	s.quotes[i].text = text
+	s.quotes[i].photoID = photoID
+	s.quotes[i].updatedAt = time.Now()
+
+	return s.hydrate(s.quotes[i]), nil
+}
+
+func (s *Store) DeleteQuoteById(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.quoteIndex(id)
+	if i < 0 {
+		return database.ErrRecordNotFound
+	}
+
+	s.quotes = append(s.quotes[:i], s.quotes[i+1:]...)
+	delete(s.quoteHashtags, id)
+
+	for j := range s.quotes {
+		if s.quotes[j].originalID != nil && *s.quotes[j].originalID == id {
+			s.quotes[j].originalID = nil
+		}
+	}
+	delete(s.likes, id)
+	delete(s.favorites, id)
+
+	comments := s.comments[:0]
+	for _, c := range s.comments {
+		if c.quoteID != id {
+			comments = append(comments, c)
+		}
+	}
+	s.comments = comments
+
+	reports := s.reports[:0]
+	for _, r := range s.reports {
+		if r.QuoteID != id {
+			reports = append(reports, r)
+		}
+	}
+	s.reports = reports
+
+	changes := s.stateChanges[:0]
+	for _, c := range s.stateChanges {
+		if c.quoteID != id {
+			changes = append(changes, c)
+		}
+	}
+	s.stateChanges = changes
+
+	return nil
+}
+
+func (s *Store) GetQuoteById(ctx context.Context, id uuid.UUID) (*database.Quote, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.quoteIndex(id)
+	if i < 0 {
+		return nil, database.ErrRecordNotFound
+	}
+
+	return s.hydrate(s.quotes[i]), nil
+}
+
+func (s *Store) GetQuoteByText(ctx context.Context, author, text string) (*database.Quote, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, q := range s.quotes {
+		if q.author == author && q.text == text {
+			return s.hydrate(q), nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (s *Store) GetUserQuotes(ctx context.Context, userID uuid.UUID, author string, text string, state uuid.UUID, attribution database.AttributionFilter, filters f.Filters) ([]database.Quote, f.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	quotes := []database.Quote{}
+	for _, q := range s.quotes {
+		if q.createdBy == userID && q.stateID == state && matches(q.author, author) && matches(q.text, text) && attributed(q, attribution) {
+			quotes = append(quotes, *s.hydrate(q))
+		}
+	}
+
+	sortQuotes(quotes, filters)
+
+	metadata := f.CalculateMetadata(len(quotes), filters.Page, filters.PageSize)
+
+	return paginate(quotes, filters), metadata, nil
+}
+
+func (s *Store) GetQuotes(ctx context.Context, author string, text string, attribution database.AttributionFilter, filters f.Filters) ([]database.Quote, f.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	quotes := []database.Quote{}
+	for _, q := range s.quotes {
+		state, err := s.state(q.stateID)
+		if err != nil || !state.IsPublic || !q.live() || q.originalID != nil {
+			continue
+		}
+
+		if matches(q.author, author) && s.matchesTranslated(q, text) && attributed(q, attribution) {
+			quotes = append(quotes, *s.hydrate(q))
+		}
+	}
+
+	sortQuotes(quotes, filters)
+
+	metadata := f.CalculateMetadata(len(quotes), filters.Page, filters.PageSize)
+
+	return paginate(quotes, filters), metadata, nil
+}
+
+// ExportQuotes takes a snapshot of the matching quotes and calls fn for each
+// one after releasing the lock, so fn may use the store.
+func (s *Store) ExportQuotes(ctx context.Context, filter database.QuoteFilter, fn func(*database.Quote) error) error {
+	s.mu.RLock()
+
+	quotes := []database.Quote{}
+	for _, q := range s.quotes {
+		state, err := s.state(q.stateID)
+		if err != nil || (filter.Originals && q.originalID != nil) || ((!state.IsPublic || !q.live()) && !filter.AnyState) {
+			continue
+		}
+		if filter.StateID != nil && state.ID != *filter.StateID {
+			continue
+		}
+
+		if matches(q.author, filter.Author) && matches(q.text, filter.Text) {
+			quotes = append(quotes, *s.hydrate(q))
+		}
+	}
+
+	s.mu.RUnlock()
+
+	desc := strings.HasPrefix(filter.Sort, "-")
+	byText := strings.TrimPrefix(filter.Sort, "-") == "text"
+
+	sort.SliceStable(quotes, func(i, j int) bool {
+		a, b := quotes[i], quotes[j]
+		if desc {
+			a, b = b, a
+		}
+
+		switch {
+		case byText && a.Text != b.Text:
+			return a.Text < b.Text
+		case !byText && !a.CreatedAt.Equal(b.CreatedAt):
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+
+	for i := range quotes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&quotes[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) SampleQuotes(ctx context.Context, filter database.SampleFilter) ([]database.Quote, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var candidates []database.Quote
+	for _, q := range s.quotes {
+		state, err := s.state(q.stateID)
+		if err != nil || !state.IsPublic || !q.live() || q.originalID != nil || slices.Contains(filter.Exclude, q.id) {
+			continue
+		}
+
+		quote := s.hydrate(q)
+
+		tagged := filter.Hashtag == ""
+		for _, hashtag := range quote.Hashtags {
+			tagged = tagged || hashtag.Value == filter.Hashtag
+		}
+
+		if tagged {
+			quote.Hashtags = nil
+			candidates = append(candidates, *quote)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	rng.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	if len(candidates) > filter.Size {
+		candidates = candidates[:filter.Size]
+	}
+
+	return candidates, nil
+}
+
+func (s *Store) SetQuoteState(ctx context.Context, id, stateID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.state(stateID); err != nil {
+		return err
+	}
+
+	i := s.quoteIndex(id)
+	if i < 0 {
+		return database.ErrRecordNotFound
+	}
+
+	s.quotes[i].stateID = stateID
+	s.quotes[i].updatedAt = time.Now()
+
+	return nil
+}
+
+func (s *Store) SetQuoteSource(ctx context.Context, id uuid.UUID, source *database.QuoteSource) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.quoteIndex(id)
+	if i < 0 {
+		return database.ErrRecordNotFound
+	}
+
+	s.quotes[i].source = database.QuoteSource{}
+	if source != nil {
+		s.quotes[i].source = *source
+	}
+	s.quotes[i].updatedAt = time.Now()
+
+	return nil
+}
+
+func (s *Store) SetQuoteVerification(ctx context.Context, id uuid.UUID, status string, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.quoteIndex(id)
+	if i < 0 {
+		return database.ErrRecordNotFound
+	}
+
+	now := time.Now()
+	s.quotes[i].verification = status
+	s.quotes[i].verifiedBy = &userID
+	s.quotes[i].verifiedAt = &now
+	s.quotes[i].updatedAt = now
+
+	return nil
+}
+
+func (s *Store) IsExistsWithThisState(ctx context.Context, quoteStateID uuid.UUID) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, q := range s.quotes {
+		if q.stateID == quoteStateID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) quoteIndex(id uuid.UUID) int {
+	for i := range s.quotes {
+		if s.quotes[i].id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Store) setQuoteHashtags(quoteID uuid.UUID, hashtagIDs []uuid.UUID) error {
+	ids := []uuid.UUID{}
+	for _, id := range hashtagIDs {
+		found := false
+		for _, h := range s.hashtags {
+			if h.ID == id {
+				found = true
+			}
+		}
+
+		if !found {
+			return database.ErrRecordNotFound
+		}
+
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	s.quoteHashtags[quoteID] = ids
+	return nil
+}
+
+func (s *Store) hydrate(q quote) *database.Quote {
+	quote := &database.Quote{
+		ID:        q.id,
+		CreatedAt: q.createdAt,
+		UpdatedAt: q.updatedAt,
+		CreatedBy: q.createdBy,
+		Author:    q.author,
+		Text:      q.text,
+		Hashtags:  s.quoteHashtagList(q.id),
+
+		LikeCount:     len(s.likes[q.id]),
+		FavoriteCount: len(s.favorites[q.id]),
+		CommentCount:  s.commentCount(q.id),
+
+		Source:       q.source,
+		Verification: q.verification,
+		VerifiedBy:   q.verifiedBy,
+		VerifiedAt:   q.verifiedAt,
+
+		Language:   q.language,
+		OriginalID: q.originalID,
+		PublishAt:  q.publishAt,
+	}
+
+	if quote.Verification == "" {
+		quote.Verification = database.VerificationUnverified
+	}
+
+	if state, err := s.state(q.stateID); err == nil {
+		quote.State = *state
+	}
+
+	if photo, err := s.photo(q.photoID); err == nil {
+		quote.Photo = photo
+	}
+
+	return quote
+}
+
+// attributed reports whether q passes the verification and source type
+// filter of a listing.
+func attributed(q quote, filter database.AttributionFilter) bool {
+	verification := q.verification
+	if verification == "" {
+		verification = database.VerificationUnverified
+	}
+
+	return (filter.Verification == "" || verification == filter.Verification) &&
+		(filter.SourceType == "" || q.source.Type == filter.SourceType)
+}
+
+// sortQuotes orders a listing by the sort parameter, falling back to
+// creation time.
+func sortQuotes(quotes []database.Quote, filters f.Filters) {
+	column, desc := filters.SortColumn(), filters.SortDirection() == "DESC"
+	sort.SliceStable(quotes, func(i, j int) bool {
+		a, b := quotes[i], quotes[j]
+		if desc {
+			a, b = b, a
+		}
+
+		switch column {
+		case "text":
+			if a.Text != b.Text {
+				return a.Text < b.Text
+			}
+		case "id":
+			if a.ID != b.ID {
+				return a.ID.String() < b.ID.String()
+			}
+		case "popularity":
+			if a.LikeCount != b.LikeCount {
+				return a.LikeCount < b.LikeCount
+			}
+		}
+		return quotes[i].CreatedAt.Before(quotes[j].CreatedAt)
+	})
+}
+
+// schedule
+
+func (s *Store) ScheduleQuote(ctx context.Context, id uuid.UUID, publishAt *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.quoteIndex(id)
+	if i < 0 {
+		return database.ErrRecordNotFound
+	}
+
+	s.quotes[i].publishAt = publishAt
+	s.quotes[i].updatedAt = time.Now()
+
+	return nil
+}
+
+func (s *Store) GetScheduledQuotes(ctx context.Context, filters f.Filters) ([]database.Quote, f.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	quotes := []database.Quote{}
+	for _, q := range s.quotes {
+		if q.publishAt != nil {
+			quotes = append(quotes, *s.hydrate(q))
+		}
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool {
+		return quotes[i].PublishAt.Before(*quotes[j].PublishAt)
+	})
+
+	metadata := f.CalculateMetadata(len(quotes), filters.Page, filters.PageSize)
+
+	return paginate(quotes, filters), metadata, nil
+}
+
+func (s *Store) PublishDueQuotes(ctx context.Context) ([]database.Quote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quotes := []database.Quote{}
+	for i, q := range s.quotes {
+		if q.publishAt == nil || !q.live() {
+			continue
+		}
+
+		state, err := s.state(q.stateID)
+		if err != nil || !state.IsPublic {
+			continue
+		}
+
+		s.quotes[i].publishAt = nil
+		s.quotes[i].updatedAt = time.Now()
+		quotes = append(quotes, *s.hydrate(s.quotes[i]))
+	}
+
+	return quotes, nil
+}
+
+// translations
+
+func (s *Store) InsertTranslation(ctx context.Context, originalID uuid.UUID, language, author, text string, userID uuid.UUID) (*database.Quote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.quoteIndex(originalID)
+	if i < 0 {
+		return nil, database.ErrRecordNotFound
+	}
+
+	if s.quotes[i].originalID != nil {
+		originalID = *s.quotes[i].originalID
+		if i = s.quoteIndex(originalID); i < 0 {
+			return nil, database.ErrRecordNotFound
+		}
+	}
+
+	original := s.quotes[i]
+	if original.language == language {
+		return nil, database.ErrDuplicateLanguage
+	}
+
+	for _, q := range s.quotes {
+		if q.originalID != nil && *q.originalID == originalID && q.language == language {
+			return nil, database.ErrDuplicateLanguage
+		}
+	}
+
+	var stateID *uuid.UUID
+	for i := range s.states {
+		if s.states[i].IsDefault {
+			stateID = &s.states[i].ID
+		}
+	}
+
+	if stateID == nil {
+		return nil, database.ErrDefaultState
+	}
+
+	q := quote{
+		id:         uuid.New(),
+		createdAt:  time.Now(),
+		updatedAt:  time.Now(),
+		createdBy:  userID,
+		author:     author,
+		text:       text,
+		stateID:    *stateID,
+		photoID:    original.photoID,
+		language:   language,
+		originalID: &originalID,
+	}
+
+	s.quoteHashtags[q.id] = append([]uuid.UUID(nil), s.quoteHashtags[originalID]...)
+	s.quotes = append(s.quotes, q)
+
+	return s.hydrate(q), nil
+}
+
+func (s *Store) SetQuoteLanguage(ctx context.Context, id uuid.UUID, language string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.quoteIndex(id)
+	if i < 0 {
+		return database.ErrRecordNotFound
+	}
+
+	rootID := id
+	if s.quotes[i].originalID != nil {
+		rootID = *s.quotes[i].originalID
+	}
+
+	for _, q := range s.quotes {
+		related := q.id == rootID || (q.originalID != nil && *q.originalID == rootID)
+		if related && q.id != id && q.language == language {
+			return database.ErrDuplicateLanguage
+		}
+	}
+
+	s.quotes[i].language = language
+	s.quotes[i].updatedAt = time.Now()
+
+	return nil
+}
+
+func (s *Store) GetQuoteTranslations(ctx context.Context, originalIDs []uuid.UUID) (map[uuid.UUID][]database.Quote, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	translations := make(map[uuid.UUID][]database.Quote)
+
+	for _, q := range s.quotes {
+		if q.originalID == nil || !slices.Contains(originalIDs, *q.originalID) {
+			continue
+		}
+
+		state, err := s.state(q.stateID)
+		if err != nil || !state.IsPublic || !q.live() {
+			continue
+		}
+
+		translations[*q.originalID] = append(translations[*q.originalID], *s.hydrate(q))
+	}
+
+	for id := range translations {
+		sort.Slice(translations[id], func(i, j int) bool {
+			return translations[id][i].Language < translations[id][j].Language
+		})
+	}
+
+	return translations, nil
+}
+
+// matchesTranslated reports whether the text of q or of one of its public
+// translations matches query.
+func (s *Store) matchesTranslated(q quote, query string) bool {
+	if matches(q.text, query) {
+		return true
+	}
+
+	for _, t := range s.quotes {
+		if t.originalID == nil || *t.originalID != q.id || !matches(t.text, query) {
+			continue
+		}
+
+		if state, err := s.state(t.stateID); err == nil && state.IsPublic && t.live() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reactions
+
+func (s *Store) SetQuoteLiked(ctx context.Context, userID, quoteID uuid.UUID, liked bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.setReaction(s.likes, userID, quoteID, liked)
+}
+
+func (s *Store) SetQuoteFavorited(ctx context.Context, userID, quoteID uuid.UUID, favorited bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.setReaction(s.favorites, userID, quoteID, favorited)
+}
+
+func (s *Store) setReaction(reactions map[uuid.UUID]map[uuid.UUID]time.Time, userID, quoteID uuid.UUID, on bool) (int, error) {
+	i := s.quoteIndex(quoteID)
+	if i < 0 {
+		return 0, database.ErrRecordNotFound
+	}
+
+	if !on {
+		delete(reactions[quoteID], userID)
+		return len(reactions[quoteID]), nil
+	}
+
+	state, err := s.state(s.quotes[i].stateID)
+	if err != nil || !state.IsPublic || !s.quotes[i].live() {
+		return 0, database.ErrRecordNotFound
+	}
+
+	if s.userIndex(userID) < 0 {
+		return 0, errForeignKey
+	}
+
+	if reactions[quoteID] == nil {
+		reactions[quoteID] = make(map[uuid.UUID]time.Time)
+	}
+	if _, ok := reactions[quoteID][userID]; !ok {
+		reactions[quoteID][userID] = time.Now()
+	}
+
+	return len(reactions[quoteID]), nil
+}
+
+func (s *Store) GetQuoteReactions(ctx context.Context, userID uuid.UUID, quoteIDs []uuid.UUID) (map[uuid.UUID]database.Reaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reactions := make(map[uuid.UUID]database.Reaction)
+	for _, id := range quoteIDs {
+		_, liked := s.likes[id][userID]
+		_, favorited := s.favorites[id][userID]
+
+		if liked || favorited {
+			reactions[id] = database.Reaction{Liked: liked, Favorited: favorited}
+		}
+	}
+
+	return reactions, nil
+}
+
+func (s *Store) GetFavoriteQuotes(ctx context.Context, userID uuid.UUID, filters f.Filters) ([]database.Quote, f.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	favoritedAt := make(map[uuid.UUID]time.Time)
+	quotes := []database.Quote{}
+	for _, q := range s.quotes {
+		at, ok := s.favorites[q.id][userID]
+		if !ok {
+			continue
+		}
+
+		state, err := s.state(q.stateID)
+		if err != nil || !state.IsPublic || !q.live() {
+			continue
+		}
+
+		favoritedAt[q.id] = at
+		quotes = append(quotes, *s.hydrate(q))
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool {
+		return favoritedAt[quotes[i].ID].After(favoritedAt[quotes[j].ID])
+	})
+
+	metadata := f.CalculateMetadata(len(quotes), filters.Page, filters.PageSize)
+
+	return paginate(quotes, filters), metadata, nil
+}
+
+// matches approximates to_tsvector('simple', value) @@ plainto_tsquery('simple', query):
+// every word of the query has to appear as a word of the value.
+func matches(value, query string) bool {
+	words := make(map[string]bool)
+	for _, w := range lexemes(value) {
+		words[w] = true
+	}
+
+	for _, w := range lexemes(query) {
+		if !words[w] {
+			return false
+		}
+	}
+	return true
+}
+
+func lexemes(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func paginate[T any](items []T, filters f.Filters) []T {
+	offset, limit := filters.Offset(), filters.Limit()
+	if offset < 0 || offset >= len(items) {
+		return []T{}
+	}
+
+	end := len(items)
+	if limit >= 0 && offset+limit < end {
+		end = offset + limit
+	}
+
+	return items[offset:end]
+}
+
+func remove(ids []uuid.UUID, id uuid.UUID) []uuid.UUID {
+	out := ids[:0]
+	for _, v := range ids {
+		if v != id {
+			out = append(out, v)
+		}
+	}
+	return out
+}
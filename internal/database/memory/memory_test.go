@@ -0,0 +1,15 @@
+package memory_test
+
+import (
+	"testing"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/database/memory"
+	"javlonrahimov/quotes-api/internal/database/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		return memory.New()
+	})
+}
@@ -0,0 +1,305 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+type stateChange struct {
+	id        uuid.UUID
+	quoteID   uuid.UUID
+	fromState uuid.UUID
+	toState   uuid.UUID
+	changedBy *uuid.UUID
+	reason    string
+	createdAt time.Time
+}
+
+var _ database.ReportStore = (*Store)(nil)
+
+// state history
+
+func (s *Store) ChangeQuoteState(ctx context.Context, quoteID, stateID uuid.UUID, changedBy *uuid.UUID, reason string) (*database.QuoteStateChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.changeQuoteState(quoteID, stateID, changedBy, reason)
+}
+
+// changeQuoteState moves a quote to stateID and records the change. It
+// returns a nil change when the quote already is in that state.
+func (s *Store) changeQuoteState(quoteID, stateID uuid.UUID, changedBy *uuid.UUID, reason string) (*database.QuoteStateChange, error) {
+	if _, err := s.state(stateID); err != nil {
+		return nil, err
+	}
+
+	i := s.quoteIndex(quoteID)
+	if i < 0 {
+		return nil, database.ErrRecordNotFound
+	}
+
+	from := s.quotes[i].stateID
+	if from == stateID {
+		return nil, nil
+	}
+
+	now := time.Now()
+	s.quotes[i].stateID = stateID
+	s.quotes[i].updatedAt = now
+
+	change := stateChange{
+		id:        uuid.New(),
+		quoteID:   quoteID,
+		fromState: from,
+		toState:   stateID,
+		changedBy: changedBy,
+		reason:    reason,
+		createdAt: now,
+	}
+	s.stateChanges = append(s.stateChanges, change)
+
+	return &database.QuoteStateChange{
+		ID:        change.id,
+		QuoteID:   quoteID,
+		FromState: &database.QuoteState{ID: from},
+		ToState:   &database.QuoteState{ID: stateID},
+		ChangedBy: changedBy,
+		Reason:    reason,
+		CreatedAt: now,
+	}, nil
+}
+
+func (s *Store) GetQuoteStateHistory(ctx context.Context, quoteID uuid.UUID, filters f.Filters) ([]database.QuoteStateChange, f.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	changes := []database.QuoteStateChange{}
+	for _, c := range s.stateChanges {
+		if c.quoteID != quoteID {
+			continue
+		}
+
+		change := database.QuoteStateChange{
+			ID:        c.id,
+			QuoteID:   c.quoteID,
+			ChangedBy: c.changedBy,
+			Reason:    c.reason,
+			CreatedAt: c.createdAt,
+		}
+		change.FromState, _ = s.state(c.fromState)
+		change.ToState, _ = s.state(c.toState)
+
+		changes = append(changes, change)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].CreatedAt.After(changes[j].CreatedAt)
+	})
+
+	metadata := f.CalculateMetadata(len(changes), filters.Page, filters.PageSize)
+
+	return paginate(changes, filters), metadata, nil
+}
+
+// reports
+
+func (s *Store) InsertQuoteReport(ctx context.Context, report *database.QuoteReport, threshold int) (*database.QuoteStateChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.quoteIndex(report.QuoteID)
+	if i < 0 {
+		return nil, database.ErrRecordNotFound
+	}
+
+	if state, err := s.state(s.quotes[i].stateID); err != nil || !state.IsPublic || !s.quotes[i].live() {
+		return nil, database.ErrRecordNotFound
+	}
+
+	open := 0
+	for _, r := range s.reports {
+		if r.QuoteID != report.QuoteID || r.Status != database.ReportOpen {
+			continue
+		}
+		if r.UserID == report.UserID {
+			return nil, database.ErrDuplicateReport
+		}
+		open++
+	}
+
+	report.ID = uuid.New()
+	report.Status = database.ReportOpen
+	report.CreatedAt = time.Now()
+
+	s.reports = append(s.reports, *report)
+	open++
+
+	if threshold <= 0 || open < threshold {
+		return nil, nil
+	}
+
+	var review uuid.UUID
+	for _, st := range s.states {
+		if st.IsDefault {
+			review = st.ID
+		}
+	}
+	if review == uuid.Nil {
+		return nil, database.ErrDefaultState
+	}
+
+	change, err := s.changeQuoteState(report.QuoteID, review, nil, fmt.Sprintf("reported by %d users", open))
+	if err != nil || change == nil {
+		return nil, err
+	}
+
+	for j := range s.reports {
+		if s.reports[j].QuoteID == report.QuoteID && s.reports[j].Status == database.ReportOpen {
+			s.reports[j].StateChangeID = &change.ID
+		}
+	}
+	report.StateChangeID = &change.ID
+
+	return change, nil
+}
+
+func (s *Store) GetQuoteReport(ctx context.Context, id uuid.UUID) (*database.QuoteReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, r := range s.reports {
+		if r.ID == id {
+			return &r, nil
+		}
+	}
+
+	return nil, database.ErrRecordNotFound
+}
+
+func (s *Store) GetQuoteReports(ctx context.Context, status string, filters f.Filters) ([]database.QuoteReport, f.Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reports := []database.QuoteReport{}
+	for _, r := range s.reports {
+		i := s.quoteIndex(r.QuoteID)
+		if r.Status != status || i < 0 {
+			continue
+		}
+
+		r.QuoteAuthor = s.quotes[i].author
+		r.QuoteText = s.quotes[i].text
+		if state, err := s.state(s.quotes[i].stateID); err == nil {
+			r.QuoteState = *state
+		}
+
+		for _, o := range s.reports {
+			if o.QuoteID == r.QuoteID && o.Status == database.ReportOpen {
+				r.OpenReportCount++
+			}
+		}
+
+		reports = append(reports, r)
+	}
+
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].CreatedAt.Before(reports[j].CreatedAt)
+	})
+
+	metadata := f.CalculateMetadata(len(reports), filters.Page, filters.PageSize)
+
+	return paginate(reports, filters), metadata, nil
+}
+
+func (s *Store) ResolveQuoteReports(ctx context.Context, quoteID, moderatorID uuid.UUID, stateID *uuid.UUID) (int, *database.QuoteStateChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var change *database.QuoteStateChange
+
+	if stateID != nil {
+		var err error
+		change, err = s.changeQuoteState(quoteID, *stateID, &moderatorID, "reports resolved")
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return s.closeQuoteReports(quoteID, moderatorID, database.ReportResolved, change), change, nil
+}
+
+func (s *Store) DismissQuoteReports(ctx context.Context, quoteID, moderatorID uuid.UUID) (int, *database.QuoteStateChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var change *database.QuoteStateChange
+
+	if restore, ok := s.reportedFromState(quoteID); ok {
+		var err error
+		change, err = s.changeQuoteState(quoteID, restore, &moderatorID, "reports dismissed")
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return s.closeQuoteReports(quoteID, moderatorID, database.ReportDismissed, change), change, nil
+}
+
+// reportedFromState returns the state a quote had before its open reports
+// moved it to review, provided that was the quote's last change.
+func (s *Store) reportedFromState(quoteID uuid.UUID) (uuid.UUID, bool) {
+	var changeID *uuid.UUID
+	for _, r := range s.reports {
+		if r.QuoteID == quoteID && r.Status == database.ReportOpen && r.StateChangeID != nil {
+			changeID = r.StateChangeID
+			break
+		}
+	}
+
+	i := s.quoteIndex(quoteID)
+	if changeID == nil || i < 0 {
+		return uuid.Nil, false
+	}
+
+	var last *stateChange
+	for j := range s.stateChanges {
+		if s.stateChanges[j].quoteID == quoteID {
+			last = &s.stateChanges[j]
+		}
+	}
+
+	if last == nil || last.id != *changeID || s.quotes[i].stateID != last.toState {
+		return uuid.Nil, false
+	}
+
+	return last.fromState, true
+}
+
+func (s *Store) closeQuoteReports(quoteID, moderatorID uuid.UUID, status string, change *database.QuoteStateChange) int {
+	now := time.Now()
+	closed := 0
+
+	for i := range s.reports {
+		r := &s.reports[i]
+		if r.QuoteID != quoteID || r.Status != database.ReportOpen {
+			continue
+		}
+
+		r.Status = status
+		r.ResolvedBy = &moderatorID
+		r.ResolvedAt = &now
+		if change != nil {
+			r.StateChangeID = &change.ID
+		}
+		closed++
+	}
+
+	return closed
+}
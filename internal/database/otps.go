@@ -19,9 +19,9 @@ type OTP struct {
 	Scope     string    `db:"scope"`
 }
 
-func (db *DB) InsertOTP(otp *OTP) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) InsertOTP(ctx context.Context, otp *OTP) error {
+	ctx, done := db.write(ctx, "InsertOTP")
+	defer done()
 
 	query := `
 			insert into otps (id, hash, user_id, expiry, created, "scope")
@@ -36,9 +36,9 @@ func (db *DB) InsertOTP(otp *OTP) error {
 	return nil
 }
 
-func (db *DB) GetOTPForEmail(email string, scope string) (*OTP, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) GetOTPForEmail(ctx context.Context, email string, scope string) (*OTP, error) {
+	ctx, done := db.read(ctx, "GetOTPForEmail")
+	defer done()
 
 	var otp OTP
 
@@ -64,9 +64,9 @@ func (db *DB) GetOTPForEmail(email string, scope string) (*OTP, error) {
 	return nil, ErrRecordNotFound
 }
 
-func (db *DB) DeleteAllOTPForUser(userID uuid.UUID, scope string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) DeleteAllOTPForUser(ctx context.Context, userID uuid.UUID, scope string) error {
+	ctx, done := db.write(ctx, "DeleteAllOTPForUser")
+	defer done()
 
 	query := `
 			delete from otps
@@ -80,17 +80,19 @@ func (db *DB) DeleteAllOTPForUser(userID uuid.UUID, scope string) error {
 	return nil
 }
 
-func (db *DB) NewOtp(userID uuid.UUID, ttl time.Duration, scope string) (*OTP, error) {
-	otp, err := generateOTP(userID, ttl, scope)
+func (db *DB) NewOtp(ctx context.Context, userID uuid.UUID, ttl time.Duration, scope string) (*OTP, error) {
+	otp, err := GenerateOTP(userID, ttl, scope)
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.InsertOTP(otp)
+	err = db.InsertOTP(ctx, otp)
 	return otp, err
 }
 
-func generateOTP(userID uuid.UUID, ttl time.Duration, scope string) (*OTP, error) {
+// GenerateOTP creates a fresh one-time password for the user. Plaintext is
+// only held in memory; Hash is what gets stored.
+func GenerateOTP(userID uuid.UUID, ttl time.Duration, scope string) (*OTP, error) {
 
 	otp := &OTP{
 		ID:      uuid.New(),
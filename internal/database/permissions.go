@@ -6,10 +6,10 @@ import (
 	"github.com/lib/pq"
 )
 
-func (db *DB) GetAllPermissionsForUser(userID uuid.UUID) ([]string, error) {
+func (db *DB) GetAllPermissionsForUser(ctx context.Context, userID uuid.UUID) ([]string, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+	ctx, done := db.read(ctx, "GetAllPermissionsForUser")
+	defer done()
 
 	query := `
 		SELECT permissions.code
@@ -44,13 +44,14 @@ func (db *DB) GetAllPermissionsForUser(userID uuid.UUID) ([]string, error) {
 	return permissions, nil
 }
 
-func (db *DB) AddPermissionForUser(userID uuid.UUID, codes ...string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) AddPermissionForUser(ctx context.Context, userID uuid.UUID, codes ...string) error {
+	ctx, done := db.write(ctx, "AddPermissionForUser")
+	defer done()
 
 	query := `
 	insert into users_permissions
-	select $1, permissions.id from permissions where permissions.code = any($2)`
+	select $1, permissions.id from permissions where permissions.code = any($2)
+	on conflict do nothing`
 
 	_, err := db.DB.ExecContext(ctx, query, userID, pq.Array(codes))
 
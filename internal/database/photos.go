@@ -16,9 +16,9 @@ type Photo struct {
 	Url      string    `db:"url"`
 }
 
-func (db *DB) InsertPhoto(color, blurHash, author, url string) (*Photo, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) InsertPhoto(ctx context.Context, color, blurHash, author, url string) (*Photo, error) {
+	ctx, done := db.write(ctx, "InsertPhoto")
+	defer done()
 
 	photo := Photo{
 		ID:       uuid.New(),
@@ -42,9 +42,9 @@ func (db *DB) InsertPhoto(color, blurHash, author, url string) (*Photo, error) {
 	return &photo, nil
 }
 
-func (db *DB) GetPhotoById(id uuid.UUID) (*Photo, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) GetPhotoById(ctx context.Context, id uuid.UUID) (*Photo, error) {
+	ctx, done := db.read(ctx, "GetPhotoById")
+	defer done()
 
 	query := `
 		select id, color, blur_hash, author, url
@@ -65,9 +65,33 @@ func (db *DB) GetPhotoById(id uuid.UUID) (*Photo, error) {
 	return &photo, nil
 }
 
-func (db *DB) GetPhotos() ([]*Photo, f.Metadata, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) GetPhotoByUrl(ctx context.Context, url string) (*Photo, error) {
+	ctx, done := db.read(ctx, "GetPhotoByUrl")
+	defer done()
+
+	query := `
+		select id, color, blur_hash, author, url
+		from photos where url = $1
+		limit 1`
+
+	var photo Photo
+
+	err := db.QueryRowContext(ctx, query, url).Scan(&photo.ID, &photo.Color, &photo.BlurHash, &photo.Author, &photo.Url)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &photo, nil
+}
+
+func (db *DB) GetPhotos(ctx context.Context) ([]*Photo, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetPhotos")
+	defer done()
 
 	query := `
 		select count(*) over(), id, color, blur_hash, author, url
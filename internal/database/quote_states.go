@@ -16,9 +16,9 @@ type QuoteState struct {
 	IsPublic  bool      `db:"is_public"`
 }
 
-func (db *DB) InsertQuoteState(state *QuoteState) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) InsertQuoteState(ctx context.Context, state *QuoteState) error {
+	ctx, done := db.write(ctx, "InsertQuoteState")
+	defer done()
 
 	query := `
 		insert into quote_states (id, value, is_default, color, is_public)
@@ -38,7 +38,7 @@ func (db *DB) InsertQuoteState(state *QuoteState) error {
 	}
 
 	if state.IsDefault {
-		err := db.SetDefaultQuoteState(state.ID)
+		err := db.SetDefaultQuoteState(ctx, state.ID)
 		if err != nil {
 			return err
 		}
@@ -47,9 +47,9 @@ func (db *DB) InsertQuoteState(state *QuoteState) error {
 	return nil
 }
 
-func (db *DB) getDefaultQuoteState() (*QuoteState, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) getDefaultQuoteState(ctx context.Context) (*QuoteState, error) {
+	ctx, done := db.read(ctx, "getDefaultQuoteState")
+	defer done()
 
 	var state QuoteState
 
@@ -66,11 +66,11 @@ func (db *DB) getDefaultQuoteState() (*QuoteState, error) {
 	return &state, nil
 }
 
-func (db *DB) DeleteQuoteStateById(id uuid.UUID) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) DeleteQuoteStateById(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "DeleteQuoteStateById")
+	defer done()
 
-	if state, _ := db.getDefaultQuoteState(); state != nil && state.ID == id {
+	if state, _ := db.getDefaultQuoteState(ctx); state != nil && state.ID == id {
 		return ErrDefaultState
 	}
 
@@ -83,9 +83,9 @@ func (db *DB) DeleteQuoteStateById(id uuid.UUID) error {
 	return nil
 }
 
-func (db *DB) ExistsQuoteStateById(id uuid.UUID) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) ExistsQuoteStateById(ctx context.Context, id uuid.UUID) bool {
+	ctx, done := db.read(ctx, "ExistsQuoteStateById")
+	defer done()
 
 	query :=
 		`select exists (select id from quote_states where id = $1)`
@@ -99,9 +99,9 @@ func (db *DB) ExistsQuoteStateById(id uuid.UUID) bool {
 	return exists
 }
 
-func (db *DB) SetDefaultQuoteState(id uuid.UUID) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) SetDefaultQuoteState(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "SetDefaultQuoteState")
+	defer done()
 
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
@@ -133,9 +133,9 @@ func (db *DB) SetDefaultQuoteState(id uuid.UUID) error {
 	return nil
 }
 
-func (db *DB) GetAllQuoteStates() ([]QuoteState, f.Metadata, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) GetAllQuoteStates(ctx context.Context) ([]QuoteState, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetAllQuoteStates")
+	defer done()
 
 	query := `
 		select count(*) over(), id, value, is_default, color, is_public
@@ -178,9 +178,9 @@ func (db *DB) GetAllQuoteStates() ([]QuoteState, f.Metadata, error) {
 	return quoteStates, metadata, nil
 }
 
-func (db *DB) getQuoteStateById(stateID uuid.UUID) (*QuoteState, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) getQuoteStateById(ctx context.Context, stateID uuid.UUID) (*QuoteState, error) {
+	ctx, done := db.read(ctx, "getQuoteStateById")
+	defer done()
 
 	query := `
 		select id, value, is_default, color, is_public
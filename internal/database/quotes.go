@@ -6,9 +6,13 @@ import (
 	"errors"
 	"fmt"
 	f "javlonrahimov/quotes-api/internal/filters"
+	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type Quote struct {
@@ -21,19 +25,119 @@ type Quote struct {
 	State     QuoteState `db:"-"`
 	Photo     *Photo     `db:"-"`
 	Hashtags  []Hashtag  `db:"-"`
+
+	LikeCount     int `db:"like_count"`
+	FavoriteCount int `db:"favorite_count"`
+	CommentCount  int `db:"comment_count"`
+
+	// AuthorID links the quote to an entry of the authors table; Author is
+	// the free text it was resolved from.
+	AuthorID *uuid.UUID `db:"author_id"`
+
+	Source       QuoteSource `db:"-"`
+	Verification string      `db:"verification"`
+	VerifiedBy   *uuid.UUID  `db:"verified_by"`
+	VerifiedAt   *time.Time  `db:"verified_at"`
+
+	// Language is the ISO 639 code of the quote's text, empty when unknown.
+	// OriginalID is set on translations and points at the quote translated.
+	Language   string     `db:"language"`
+	OriginalID *uuid.UUID `db:"original_id"`
+
+	// PublishAt holds a quote back from public reads until the given time,
+	// even when its state is public. It is cleared once the quote is live.
+	PublishAt *time.Time `db:"publish_at"`
 }
 
-func (db *DB) InsertQuote(author, text string, userID, photoID uuid.UUID, hashtagIDs []uuid.UUID, stateID *uuid.UUID) (*Quote, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+// quoteColumns is the select list scanQuote reads, for queries that join
+// quotes q with quote_states s and photos p.
+const quoteColumns = `
+	q.id, q.created_at, q.updated_at, q.created_by, q.author, q.text,
+	s.id, s.value, s.is_default, s.color, s.is_public,
+	p.id, p.url, p.color, p.blur_hash, p.author,
+	q.like_count, q.favorite_count, q.comment_count, q.author_id,
+	q.verification, q.verified_by, q.verified_at,
+	q.source_type, q.source_title, q.source_url, q.source_location, q.source_year, q.source_language,
+	q.language, q.original_id, q.publish_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanQuote scans a row of quoteColumns, preceded by the columns of extra.
+func scanQuote(row scanner, extra ...any) (Quote, error) {
+	quote := Quote{Photo: &Photo{}}
+
+	dest := append(extra,
+		&quote.ID, &quote.CreatedAt, &quote.UpdatedAt, &quote.CreatedBy, &quote.Author, &quote.Text,
+		&quote.State.ID, &quote.State.Value, &quote.State.IsDefault, &quote.State.Color, &quote.State.IsPublic,
+		&quote.Photo.ID, &quote.Photo.Url, &quote.Photo.Color, &quote.Photo.BlurHash, &quote.Photo.Author,
+		&quote.LikeCount, &quote.FavoriteCount, &quote.CommentCount, &quote.AuthorID,
+		&quote.Verification, &quote.VerifiedBy, &quote.VerifiedAt,
+		&quote.Source.Type, &quote.Source.Title, &quote.Source.URL, &quote.Source.Location, &quote.Source.Year, &quote.Source.Language,
+		&quote.Language, &quote.OriginalID, &quote.PublishAt,
+	)
 
-	state, err := db.getDefaultQuoteState()
+	err := row.Scan(dest...)
+	return quote, err
+}
+
+// IsScheduled reports whether the quote is waiting for its publish time.
+func (q *Quote) IsScheduled() bool {
+	return q.PublishAt != nil && q.PublishAt.After(time.Now())
+}
+
+// IsLive reports whether everyone can see the quote: its state is public and
+// it isn't waiting for its publish time.
+func (q *Quote) IsLive() bool {
+	return q.State.IsPublic && !q.IsScheduled()
+}
+
+// Verification statuses of a quote's attribution. Quotes start out
+// unverified; only holders of quotes:verify can change the status.
+const (
+	VerificationUnverified    = "unverified"
+	VerificationVerified      = "verified"
+	VerificationDisputed      = "disputed"
+	VerificationMisattributed = "misattributed"
+)
+
+var VerificationStatuses = []string{VerificationUnverified, VerificationVerified, VerificationDisputed, VerificationMisattributed}
+
+// SourceTypes are the kinds of work a quote can be cited from.
+var SourceTypes = []string{"book", "speech", "article", "url"}
+
+// QuoteSource is where a quote was taken from. Location is a page for
+// printed works and a timestamp for recordings. Language is the language
+// the quote was originally said or written in. A zero QuoteSource means
+// the quote has no source.
+type QuoteSource struct {
+	Type     string
+	Title    string
+	URL      string
+	Location string
+	Year     *int
+	Language string
+}
+
+// AttributionFilter narrows quote listings by verification status and
+// source type; empty fields match everything.
+type AttributionFilter struct {
+	Verification string
+	SourceType   string
+}
+
+func (db *DB) InsertQuote(ctx context.Context, author, text string, userID, photoID uuid.UUID, hashtagIDs []uuid.UUID, stateID *uuid.UUID) (*Quote, error) {
+	ctx, done := db.write(ctx, "InsertQuote")
+	defer done()
+
+	state, err := db.getDefaultQuoteState(ctx)
 	if err != nil && state != nil {
 		return nil, err
 	}
 
 	if stateID != nil {
-		state, err = db.getQuoteStateById(*stateID)
+		state, err = db.getQuoteStateById(ctx, *stateID)
 		if err != nil {
 			switch {
 			case errors.Is(err, sql.ErrNoRows):
@@ -44,7 +148,7 @@ func (db *DB) InsertQuote(author, text string, userID, photoID uuid.UUID, hashta
 		}
 	}
 
-	photo, err := db.GetPhotoById(photoID)
+	photo, err := db.GetPhotoById(ctx, photoID)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -63,6 +167,8 @@ func (db *DB) InsertQuote(author, text string, userID, photoID uuid.UUID, hashta
 		CreatedBy: userID,
 		State:     *state,
 		Photo:     photo,
+
+		Verification: VerificationUnverified,
 	}
 
 	// todo change state to state_id
@@ -77,12 +183,17 @@ func (db *DB) InsertQuote(author, text string, userID, photoID uuid.UUID, hashta
 		return nil, err
 	}
 
-	err = db.insertQuoteHashtags(hashtagIDs, quote.ID)
+	quote.AuthorID, err = linkQuoteAuthor(ctx, db, quote.ID, quote.Author)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.insertQuoteHashtags(ctx, hashtagIDs, quote.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	hashtags, _, err := db.GetQuoteHashtags(quote.ID)
+	hashtags, _, err := db.GetQuoteHashtags(ctx, quote.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -92,11 +203,11 @@ func (db *DB) InsertQuote(author, text string, userID, photoID uuid.UUID, hashta
 	return quote, nil
 }
 
-func (db *DB) UpdateQuote(quoteID, photoID uuid.UUID, author, text string, hashtagIDs []uuid.UUID) (*Quote, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) UpdateQuote(ctx context.Context, quoteID, photoID uuid.UUID, author, text string, hashtagIDs []uuid.UUID) (*Quote, error) {
+	ctx, done := db.write(ctx, "UpdateQuote")
+	defer done()
 
-	quote, err := db.GetQuoteById(quoteID)
+	quote, err := db.GetQuoteById(ctx, quoteID)
 	if err != nil {
 		return nil, err
 	}
@@ -104,19 +215,24 @@ func (db *DB) UpdateQuote(quoteID, photoID uuid.UUID, author, text string, hasht
 	quote.Author = author
 	quote.Text = text
 
-	err = db.insertQuoteHashtags(hashtagIDs, quote.ID)
+	err = db.deleteQuoteHashtags(ctx, quote.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.insertQuoteHashtags(ctx, hashtagIDs, quote.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	hashtags, _, err := db.GetQuoteHashtags(quote.ID)
+	hashtags, _, err := db.GetQuoteHashtags(ctx, quote.ID)
 	if err != nil {
 		return nil, err
 	}
 
 	quote.Hashtags = hashtags
 
-	photo, err := db.GetPhotoById(photoID)
+	photo, err := db.GetPhotoById(ctx, photoID)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -134,7 +250,7 @@ func (db *DB) UpdateQuote(quoteID, photoID uuid.UUID, author, text string, hasht
 		where id = $5
 		returning updated_at`
 
-	err = db.QueryRowContext(ctx, query, quote.Text, photo.ID, quote.Author, time.Now()).Scan(&quote.UpdatedAt)
+	err = db.QueryRowContext(ctx, query, quote.Text, quote.Author, photo.ID, time.Now(), quote.ID).Scan(&quote.UpdatedAt)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -144,49 +260,46 @@ func (db *DB) UpdateQuote(quoteID, photoID uuid.UUID, author, text string, hasht
 		}
 	}
 
+	quote.AuthorID, err = linkQuoteAuthor(ctx, db, quote.ID, quote.Author)
+	if err != nil {
+		return nil, err
+	}
+
 	return quote, nil
 }
 
-func (db *DB) DeleteQuoteById(id uuid.UUID) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) DeleteQuoteById(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "DeleteQuoteById")
+	defer done()
 
 	query := `delete from quotes where id = $1`
 
-	_, err := db.ExecContext(ctx, query, id)
+	result, err := db.ExecContext(ctx, query, id)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrRecordNotFound
-		default:
-			return err
-		}
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrRecordNotFound
 	}
 
 	return nil
 }
 
-func (db *DB) GetQuoteById(id uuid.UUID) (*Quote, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) GetQuoteById(ctx context.Context, id uuid.UUID) (*Quote, error) {
+	ctx, done := db.read(ctx, "GetQuoteById")
+	defer done()
 
 	query := `
-		select q.id, q.created_at, q.updated_at, q.created_by, q.author, q.text, s.value, p.id, p.url, p.color, p.blur_hash, p.author
+		select ` + quoteColumns + `
 		from quotes q
 		inner join quote_states s
 		on q.state = s.id
 		inner join photos p 
 		on q.photo_id = p.id
-		where id = $1`
-
-	var quote Quote
+		where q.id = $1`
 
-	err := db.QueryRowContext(ctx, query, id).Scan(
-		&quote.ID, &quote.CreatedAt, &quote.UpdatedAt,
-		&quote.CreatedBy, &quote.Author, &quote.Text,
-		&quote.State, &quote.Photo.ID, &quote.Photo.Url,
-		&quote.Photo.Color, &quote.Photo.BlurHash, &quote.Photo.Author,
-	)
+	quote, err := scanQuote(db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -196,7 +309,7 @@ func (db *DB) GetQuoteById(id uuid.UUID) (*Quote, error) {
 		}
 	}
 
-	hashtags, _, err := db.GetQuoteHashtags(id)
+	hashtags, _, err := db.GetQuoteHashtags(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -206,12 +319,34 @@ func (db *DB) GetQuoteById(id uuid.UUID) (*Quote, error) {
 	return &quote, nil
 }
 
-func (db *DB) GetUserQuotes(userID uuid.UUID, author string, text string, state uuid.UUID, filters f.Filters) ([]Quote, f.Metadata, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+// GetQuoteByText finds a quote by its exact author and text, in any state.
+func (db *DB) GetQuoteByText(ctx context.Context, author, text string) (*Quote, error) {
+	ctx, done := db.read(ctx, "GetQuoteByText")
+	defer done()
+
+	query := `select id from quotes where author = $1 and text = $2 limit 1`
+
+	var id uuid.UUID
+
+	err := db.QueryRowContext(ctx, query, author, text).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return db.GetQuoteById(ctx, id)
+}
+
+func (db *DB) GetUserQuotes(ctx context.Context, userID uuid.UUID, author string, text string, state uuid.UUID, attribution AttributionFilter, filters f.Filters) ([]Quote, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetUserQuotes")
+	defer done()
 
 	query := fmt.Sprintf(`
-		select count(*) over(), q.id, q.created_at, q.updated_at, q.created_by, q.author, text, s.id, s.value, s.is_default, s.color, s.is_public, p.id, p.url, p.color, p.blur_hash, p.author
+		select count(*) over(), `+quoteColumns+`
 		from quotes q
 		inner join quote_states s
 		on q.state = s.id
@@ -221,10 +356,12 @@ func (db *DB) GetUserQuotes(userID uuid.UUID, author string, text string, state
 		and (to_tsvector('simple', q.text) @@ plainto_tsquery('simple', $2) or $2 = '')
 		and q.created_by = $3
 		and s.id = $4
+		and (q.verification = $5 or $5 = '')
+		and (q.source_type = $6 or $6 = '')
 		order by q.%s %s, q.created_at asc
-		limit $5 offset $6`, filters.SortColumn(), filters.SortDirection())
+		limit $7 offset $8`, QuoteSortColumn(filters), filters.SortDirection())
 
-	args := []interface{}{author, text, userID, state, filters.Limit(), filters.Offset()}
+	args := []interface{}{author, text, userID, state, attribution.Verification, attribution.SourceType, filters.Limit(), filters.Offset()}
 
 	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -237,32 +374,12 @@ func (db *DB) GetUserQuotes(userID uuid.UUID, author string, text string, state
 	quotes := make([]Quote, 0)
 
 	for rows.Next() {
-		quote := Quote{Photo: &Photo{}}
-
-		err := rows.Scan(
-			&totalRecords,
-			&quote.ID,
-			&quote.CreatedAt,
-			&quote.UpdatedAt,
-			&quote.CreatedBy,
-			&quote.Author,
-			&quote.Text,
-			&quote.State.ID,
-			&quote.State.Value,
-			&quote.State.IsDefault,
-			&quote.State.Color,
-			&quote.State.IsPublic,
-			&quote.Photo.ID,
-			&quote.Photo.Url,
-			&quote.Photo.Color,
-			&quote.Photo.BlurHash,
-			&quote.Photo.Author,
-		)
+		quote, err := scanQuote(rows, &totalRecords)
 		if err != nil {
 			return nil, f.Metadata{}, err
 		}
 
-		hashtags, _, err := db.GetQuoteHashtags(quote.ID)
+		hashtags, _, err := db.GetQuoteHashtags(ctx, quote.ID)
 		if err != nil {
 			return nil, f.Metadata{}, err
 		}
@@ -281,26 +398,49 @@ func (db *DB) GetUserQuotes(userID uuid.UUID, author string, text string, state
 	return quotes, metadata, nil
 }
 
-func (db *DB) GetQuotes(author string, text string, filters f.Filters) ([]Quote, f.Metadata, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+// QuoteSortColumn maps the sort parameter of a quote listing to the column it
+// orders by; popularity is the like count.
+func QuoteSortColumn(filters f.Filters) string {
+	switch filters.SortColumn() {
+	case "popularity":
+		return "like_count"
+	case "date":
+		return "created_at"
+	default:
+		return filters.SortColumn()
+	}
+}
 
-	query := `
-		select count(*) over(), q.id, q.created_at, q.updated_at, q.created_by, q.author, text, s.id, s.value, s.is_default, s.color, s.is_public, p.id, p.url, p.color, p.blur_hash, p.author
+// GetQuotes lists public quotes, leaving out translations. The text search
+// uses the stemming of each quote's language and also finds an original
+// through its public translations.
+func (db *DB) GetQuotes(ctx context.Context, author string, text string, attribution AttributionFilter, filters f.Filters) ([]Quote, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetQuotes")
+	defer done()
+
+	query := fmt.Sprintf(`
+		select count(*) over(), `+quoteColumns+`
 		from quotes q
 		inner join quote_states s
 		on q.state = s.id and s.is_public=true
 		inner join photos p 
 		on q.photo_id = p.id
-		where (to_tsvector('simple', q.author) @@ plainto_tsquery('simple', $1) or $1 = '')
-		and (to_tsvector('simple', q.text) @@ plainto_tsquery('simple', $2) or $2 = '')
-		order by case when $3 = 'text' then q.text end
-		         , case when $3 = 'created_at' then q.created_at end
-		limit $4 offset $5`
-	// q.%s %s, q.created_at as
-	// filters.SortColumn(), filters.SortDirection())
-
-	args := []interface{}{author, text, filters.SortColumn(), filters.Limit(), filters.Offset()}
+		where q.original_id is null
+		and (q.publish_at is null or q.publish_at <= now())
+		and (to_tsvector('simple', q.author) @@ plainto_tsquery('simple', $1) or $1 = '')
+		and ($2 = '' or exists (
+			select 1
+			from quotes t
+			inner join quote_states ts
+			on t.state = ts.id
+			where (t.id = q.id or (t.original_id = q.id and ts.is_public and (t.publish_at is null or t.publish_at <= now())))
+			and to_tsvector(quote_ts_config(t.language), t.text) @@ plainto_tsquery(quote_ts_config(t.language), $2)))
+		and (q.verification = $3 or $3 = '')
+		and (q.source_type = $4 or $4 = '')
+		order by q.%s %s, q.created_at desc, q.id
+		limit $5 offset $6`, QuoteSortColumn(filters), filters.SortDirection())
+
+	args := []interface{}{author, text, attribution.Verification, attribution.SourceType, filters.Limit(), filters.Offset()}
 
 	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -313,32 +453,12 @@ func (db *DB) GetQuotes(author string, text string, filters f.Filters) ([]Quote,
 	quotes := make([]Quote, 0)
 
 	for rows.Next() {
-		quote := Quote{Photo: &Photo{}}
-
-		err := rows.Scan(
-			&totalRecords,
-			&quote.ID,
-			&quote.CreatedAt,
-			&quote.UpdatedAt,
-			&quote.CreatedBy,
-			&quote.Author,
-			&quote.Text,
-			&quote.State.ID,
-			&quote.State.Value,
-			&quote.State.IsDefault,
-			&quote.State.Color,
-			&quote.State.IsPublic,
-			&quote.Photo.ID,
-			&quote.Photo.Url,
-			&quote.Photo.Color,
-			&quote.Photo.BlurHash,
-			&quote.Photo.Author,
-		)
+		quote, err := scanQuote(rows, &totalRecords)
 		if err != nil {
 			return nil, f.Metadata{}, err
 		}
 
-		hashtags, _, err := db.GetQuoteHashtags(quote.ID)
+		hashtags, _, err := db.GetQuoteHashtags(ctx, quote.ID)
 		if err != nil {
 			return nil, f.Metadata{}, err
 		}
@@ -357,11 +477,11 @@ func (db *DB) GetQuotes(author string, text string, filters f.Filters) ([]Quote,
 	return quotes, metadata, nil
 }
 
-func (db *DB) SetQuoteState(id, stateID uuid.UUID) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) SetQuoteState(ctx context.Context, id, stateID uuid.UUID) error {
+	ctx, done := db.write(ctx, "SetQuoteState")
+	defer done()
 
-	if !db.ExistsQuoteStateById(stateID) {
+	if !db.ExistsQuoteStateById(ctx, stateID) {
 		return ErrRecordNotFound
 	}
 
@@ -382,12 +502,63 @@ func (db *DB) SetQuoteState(id, stateID uuid.UUID) error {
 	return nil
 }
 
-func (db *DB) IsExistsWithThisState(quoteStateID uuid.UUID) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+// SetQuoteSource replaces the source of a quote; a nil source removes it.
+func (db *DB) SetQuoteSource(ctx context.Context, id uuid.UUID, source *QuoteSource) error {
+	ctx, done := db.write(ctx, "SetQuoteSource")
+	defer done()
+
+	if source == nil {
+		source = &QuoteSource{}
+	}
+
+	query := `
+		update quotes
+		set source_type = $1, source_title = $2, source_url = $3, source_location = $4, source_year = $5, source_language = $6, updated_at = now()
+		where id = $7`
+
+	args := []interface{}{source.Type, source.Title, source.URL, source.Location, source.Year, source.Language, id}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// SetQuoteVerification records the verification status of a quote and who
+// set it.
+func (db *DB) SetQuoteVerification(ctx context.Context, id uuid.UUID, status string, userID uuid.UUID) error {
+	ctx, done := db.write(ctx, "SetQuoteVerification")
+	defer done()
+
+	query := `
+		update quotes
+		set verification = $1, verified_by = $2, verified_at = now(), updated_at = now()
+		where id = $3`
+
+	result, err := db.ExecContext(ctx, query, status, userID, id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) IsExistsWithThisState(ctx context.Context, quoteStateID uuid.UUID) bool {
+	ctx, done := db.read(ctx, "IsExistsWithThisState")
+	defer done()
 
 	query :=
-		`select exists (select id from quotes where state = $1)`
+		`select exists (select id from quotes where state = $1) or exists (select id from comments where state = $1)`
 
 	var exists bool
 
@@ -398,9 +569,9 @@ func (db *DB) IsExistsWithThisState(quoteStateID uuid.UUID) bool {
 	return exists
 }
 
-func (db *DB) insertQuoteHashtags(hashtagIDs []uuid.UUID, quoteID uuid.UUID) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) insertQuoteHashtags(ctx context.Context, hashtagIDs []uuid.UUID, quoteID uuid.UUID) error {
+	ctx, done := db.write(ctx, "insertQuoteHashtags")
+	defer done()
 
 	query := `insert into quote_hashtags (quote_id, hashtag_id)
 		values ($1, $2)`
@@ -408,9 +579,385 @@ func (db *DB) insertQuoteHashtags(hashtagIDs []uuid.UUID, quoteID uuid.UUID) err
 	for _, id := range hashtagIDs {
 		_, err := db.ExecContext(ctx, query, quoteID, id)
 		if err != nil {
-			return err
+			switch {
+			case isForeignKeyViolation(err):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
 		}
 	}
 
 	return nil
 }
+
+func (db *DB) deleteQuoteHashtags(ctx context.Context, quoteID uuid.UUID) error {
+	ctx, done := db.write(ctx, "deleteQuoteHashtags")
+	defer done()
+
+	query := `delete from quote_hashtags where quote_id = $1`
+
+	_, err := db.ExecContext(ctx, query, quoteID)
+	return err
+}
+
+// QuoteImport describes one quote for ImportQuotes. The photo is matched by
+// Url and the hashtags by value; both are created when missing.
+type QuoteImport struct {
+	Author   string
+	Text     string
+	Photo    Photo
+	Hashtags []string
+	StateID  *uuid.UUID
+}
+
+// ImportedQuote is the outcome of one QuoteImport. Duplicate is set when a
+// quote with the same author and text already existed, in which case QuoteID
+// refers to that quote and nothing was written.
+type ImportedQuote struct {
+	QuoteID   uuid.UUID
+	Duplicate bool
+}
+
+func (db *DB) ImportQuotes(ctx context.Context, userID uuid.UUID, quotes []QuoteImport) ([]ImportedQuote, error) {
+	ctx, done := db.write(ctx, "ImportQuotes")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var defaultStateID *uuid.UUID
+	err = tx.QueryRowContext(ctx, `select id from quote_states where is_default = true`).Scan(&defaultStateID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	results := make([]ImportedQuote, 0, len(quotes))
+
+	for _, q := range quotes {
+		var existingID uuid.UUID
+		err := tx.QueryRowContext(ctx, `select id from quotes where author = $1 and text = $2 limit 1`, q.Author, q.Text).Scan(&existingID)
+		switch {
+		case err == nil:
+			results = append(results, ImportedQuote{QuoteID: existingID, Duplicate: true})
+			continue
+		case !errors.Is(err, sql.ErrNoRows):
+			return nil, err
+		}
+
+		stateID := defaultStateID
+		if q.StateID != nil {
+			stateID = q.StateID
+		}
+		if stateID == nil {
+			return nil, ErrDefaultState
+		}
+
+		photoID, err := importPhoto(ctx, tx, q.Photo)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		quoteID := uuid.New()
+
+		query := `
+			insert into quotes (id, created_at, updated_at, author, text, created_by, state, photo_id)
+			values ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+		_, err = tx.ExecContext(ctx, query, quoteID, now, now, q.Author, q.Text, userID, *stateID, photoID)
+		if err != nil {
+			switch {
+			case isForeignKeyViolation(err):
+				return nil, ErrRecordNotFound
+			default:
+				return nil, err
+			}
+		}
+
+		_, err = linkQuoteAuthor(ctx, tx, quoteID, q.Author)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, value := range q.Hashtags {
+			_, err = tx.ExecContext(ctx, `insert into hashtags (id, value) values ($1, $2) on conflict (value) do nothing`, uuid.New(), value)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		query = `
+			insert into quote_hashtags (quote_id, hashtag_id)
+			select $1, id from hashtags where value = any($2)
+			on conflict do nothing`
+
+		_, err = tx.ExecContext(ctx, query, quoteID, pq.Array(q.Hashtags))
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, ImportedQuote{QuoteID: quoteID})
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func importPhoto(ctx context.Context, tx *sqlx.Tx, photo Photo) (uuid.UUID, error) {
+	var id uuid.UUID
+
+	err := tx.QueryRowContext(ctx, `select id from photos where url = $1 limit 1`, photo.Url).Scan(&id)
+	switch {
+	case err == nil:
+		return id, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return id, err
+	}
+
+	id = uuid.New()
+
+	query := `
+		insert into photos (id, color, blur_hash, author, url)
+		values ($1, $2, $3, $4, $5)`
+
+	_, err = tx.ExecContext(ctx, query, id, photo.Color, photo.BlurHash, photo.Author, photo.Url)
+	return id, err
+}
+
+// QuoteFilter selects the quotes streamed by ExportQuotes. Author and Text
+// match like GetQuotes. Only quotes in a public state are included unless
+// AnyState is set; StateID narrows the result to one state. Originals leaves
+// translations out. Sort is one of created_at, text, -created_at or -text and
+// defaults to created_at.
+type QuoteFilter struct {
+	Author    string
+	Text      string
+	StateID   *uuid.UUID
+	AnyState  bool
+	Originals bool
+	Sort      string
+}
+
+// SortClause returns the order by expression for the filter, with the quote
+// id as a tie-breaker so that the order is stable.
+func (filter QuoteFilter) SortClause() string {
+	direction := "asc"
+	column := strings.TrimPrefix(filter.Sort, "-")
+	if strings.HasPrefix(filter.Sort, "-") {
+		direction = "desc"
+	}
+
+	if column != "text" {
+		column = "created_at"
+	}
+
+	return fmt.Sprintf("q.%s %s, q.id %s", column, direction, direction)
+}
+
+// exportBatchSize is how many rows ExportQuotes fetches from its cursor at a
+// time; each fetch is bounded by the read timeout.
+const exportBatchSize = 500
+
+// ExportQuotes streams every quote matching filter to fn, hashtags included,
+// through a server-side cursor. It stops at the first error fn returns.
+func (db *DB) ExportQuotes(ctx context.Context, filter QuoteFilter, fn func(*Quote) error) error {
+	tx, err := db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		declare export_quotes no scroll cursor for
+		select array(select h.id::text from quote_hashtags qh inner join hashtags h on qh.hashtag_id = h.id where qh.quote_id = q.id order by h.value),
+		       array(select h.value from quote_hashtags qh inner join hashtags h on qh.hashtag_id = h.id where qh.quote_id = q.id order by h.value),
+		       ` + quoteColumns + `
+		from quotes q
+		inner join quote_states s
+		on q.state = s.id
+		inner join photos p
+		on q.photo_id = p.id
+		where (to_tsvector('simple', q.author) @@ plainto_tsquery('simple', $1) or $1 = '')
+		and (to_tsvector('simple', q.text) @@ plainto_tsquery('simple', $2) or $2 = '')
+		and ((s.is_public = true and (q.publish_at is null or q.publish_at <= now())) or $3)
+		and (s.id = $4 or $4 is null)
+		and (q.original_id is null or not $5)
+		order by ` + filter.SortClause()
+
+	_, err = tx.ExecContext(ctx, query, filter.Author, filter.Text, filter.AnyState, filter.StateID, filter.Originals)
+	if err != nil {
+		return err
+	}
+
+	for {
+		quotes, err := db.fetchExportBatch(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for i := range quotes {
+			if err := fn(&quotes[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(quotes) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+func (db *DB) fetchExportBatch(ctx context.Context, tx *sqlx.Tx) ([]Quote, error) {
+	ctx, done := db.read(ctx, "ExportQuotes")
+	defer done()
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`fetch forward %d from export_quotes`, exportBatchSize))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quotes := make([]Quote, 0, exportBatchSize)
+
+	for rows.Next() {
+		var hashtagIDs, hashtagValues pq.StringArray
+
+		quote, err := scanQuote(rows, &hashtagIDs, &hashtagValues)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range hashtagIDs {
+			id, err := uuid.Parse(hashtagIDs[i])
+			if err != nil {
+				return nil, err
+			}
+			quote.Hashtags = append(quote.Hashtags, Hashtag{ID: id, Value: hashtagValues[i]})
+		}
+
+		quotes = append(quotes, quote)
+	}
+
+	return quotes, rows.Err()
+}
+
+// SampleFilter describes a random draw of public quotes. Size is the number
+// of distinct quotes wanted; fewer are returned only when fewer match.
+type SampleFilter struct {
+	Size    int
+	Hashtag string
+	Exclude []uuid.UUID
+}
+
+// sampleCandidates is the condition a quote must meet to be drawn by
+// SampleQuotes: an original, public, live, not excluded and, when asked for,
+// tagged. Translations come with their original rather than on their own.
+const sampleCandidates = `
+	q.original_id is null
+	and (q.publish_at is null or q.publish_at <= now())
+	and not (q.id = any($2::uuid[]))
+	and ($3 = '' or exists (
+		select 1 from quote_hashtags qh inner join hashtags h on qh.hashtag_id = h.id
+		where qh.quote_id = q.id and h.value = $3))`
+
+// SampleQuotes returns a random selection of public quotes, without their
+// hashtags. Each probe picks the first matching quote at or after a random
+// sample_key, wrapping around to the lowest key when there is none, so a
+// draw costs a few index lookups rather than a sort of the whole table.
+// Probes that land on the same quote are made up for in further rounds,
+// which exclude the quotes drawn so far.
+func (db *DB) SampleQuotes(ctx context.Context, filter SampleFilter) ([]Quote, error) {
+	ctx, done := db.read(ctx, "SampleQuotes")
+	defer done()
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	exclude := make(pq.StringArray, len(filter.Exclude))
+	for i, id := range filter.Exclude {
+		exclude[i] = id.String()
+	}
+
+	query := `
+		select ` + quoteColumns + `
+		from unnest($1::float8[]) as r(key)
+		cross join lateral (
+			(select q.id from quotes q
+			inner join quote_states s on q.state = s.id and s.is_public = true
+			where q.sample_key >= r.key and ` + sampleCandidates + `
+			order by q.sample_key
+			limit 1)
+			union all
+			(select q.id from quotes q
+			inner join quote_states s on q.state = s.id and s.is_public = true
+			where ` + sampleCandidates + `
+			order by q.sample_key
+			limit 1)
+			limit 1
+		) x
+		inner join quotes q on q.id = x.id
+		inner join quote_states s on q.state = s.id
+		inner join photos p on q.photo_id = p.id`
+
+	quotes := make([]Quote, 0, filter.Size)
+
+	for len(quotes) < filter.Size {
+		keys := make(pq.Float64Array, filter.Size-len(quotes))
+		for i := range keys {
+			keys[i] = rng.Float64()
+		}
+
+		found, err := db.sampleRound(ctx, query, keys, exclude, filter.Hashtag)
+		if err != nil {
+			return nil, err
+		}
+
+		// Every probe finds a quote while any is left, so an empty round
+		// means the matching quotes are used up.
+		if len(found) == 0 {
+			break
+		}
+
+		for _, quote := range found {
+			if len(quotes) < filter.Size {
+				quotes = append(quotes, quote)
+				exclude = append(exclude, quote.ID.String())
+			}
+		}
+	}
+
+	return quotes, nil
+}
+
+// sampleRound runs one round of SampleQuotes probes and returns the distinct
+// quotes they landed on.
+func (db *DB) sampleRound(ctx context.Context, query string, keys pq.Float64Array, exclude pq.StringArray, hashtag string) ([]Quote, error) {
+	rows, err := db.QueryContext(ctx, query, keys, exclude, hashtag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[uuid.UUID]bool)
+	var quotes []Quote
+
+	for rows.Next() {
+		quote, err := scanQuote(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		if !seen[quote.ID] {
+			seen[quote.ID] = true
+			quotes = append(quotes, quote)
+		}
+	}
+
+	return quotes, rows.Err()
+}
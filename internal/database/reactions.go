@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Reaction is how one user has reacted to one quote.
+type Reaction struct {
+	Liked     bool
+	Favorited bool
+}
+
+// SetQuoteLiked adds or removes userID's like and returns the quote's new
+// like count. Only public quotes can be liked; removing works on any quote.
+func (db *DB) SetQuoteLiked(ctx context.Context, userID, quoteID uuid.UUID, liked bool) (int, error) {
+	ctx, done := db.write(ctx, "SetQuoteLiked")
+	defer done()
+
+	return db.setReaction(ctx, "quote_likes", "like_count", userID, quoteID, liked)
+}
+
+// SetQuoteFavorited adds or removes the quote from userID's favorites and
+// returns its new favorite count, on the same terms as SetQuoteLiked.
+func (db *DB) SetQuoteFavorited(ctx context.Context, userID, quoteID uuid.UUID, favorited bool) (int, error) {
+	ctx, done := db.write(ctx, "SetQuoteFavorited")
+	defer done()
+
+	return db.setReaction(ctx, "quote_favorites", "favorite_count", userID, quoteID, favorited)
+}
+
+// setReaction keeps the reaction rows in table and the denormalized counter
+// in column in step within one transaction.
+func (db *DB) setReaction(ctx context.Context, table, column string, userID, quoteID uuid.UUID, on bool) (int, error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		select s.is_public and (q.publish_at is null or q.publish_at <= now())
+		from quotes q
+		inner join quote_states s
+		on q.state = s.id
+		where q.id = $1
+		for update of q`
+
+	var isPublic bool
+	err = tx.QueryRowContext(ctx, query, quoteID).Scan(&isPublic)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	if on && !isPublic {
+		return 0, ErrRecordNotFound
+	}
+
+	var result sql.Result
+	if on {
+		result, err = tx.ExecContext(ctx, fmt.Sprintf(`insert into %s (user_id, quote_id) values ($1, $2) on conflict do nothing`, table), userID, quoteID)
+	} else {
+		result, err = tx.ExecContext(ctx, fmt.Sprintf(`delete from %s where user_id = $1 and quote_id = $2`, table), userID, quoteID)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	delta := -1
+	if on {
+		delta = 1
+	}
+
+	var count int
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		query = fmt.Sprintf(`update quotes set %[1]s = %[1]s + $1 where id = $2 returning %[1]s`, column)
+		err = tx.QueryRowContext(ctx, query, delta, quoteID).Scan(&count)
+	} else {
+		err = tx.QueryRowContext(ctx, fmt.Sprintf(`select %s from quotes where id = $1`, column), quoteID).Scan(&count)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetQuoteReactions reports userID's reactions to each of quoteIDs. Quotes
+// without any reaction are missing from the map.
+func (db *DB) GetQuoteReactions(ctx context.Context, userID uuid.UUID, quoteIDs []uuid.UUID) (map[uuid.UUID]Reaction, error) {
+	ctx, done := db.read(ctx, "GetQuoteReactions")
+	defer done()
+
+	ids := make(pq.StringArray, len(quoteIDs))
+	for i, id := range quoteIDs {
+		ids[i] = id.String()
+	}
+
+	query := `
+		select q.id,
+		       exists(select 1 from quote_likes l where l.user_id = $1 and l.quote_id = q.id),
+		       exists(select 1 from quote_favorites f where f.user_id = $1 and f.quote_id = q.id)
+		from unnest($2::uuid[]) as q(id)`
+
+	rows, err := db.QueryContext(ctx, query, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactions := make(map[uuid.UUID]Reaction)
+
+	for rows.Next() {
+		var id uuid.UUID
+		var reaction Reaction
+
+		err := rows.Scan(&id, &reaction.Liked, &reaction.Favorited)
+		if err != nil {
+			return nil, err
+		}
+
+		if reaction.Liked || reaction.Favorited {
+			reactions[id] = reaction
+		}
+	}
+
+	return reactions, rows.Err()
+}
+
+// GetFavoriteQuotes lists the public quotes userID has favorited, most
+// recently favorited first.
+func (db *DB) GetFavoriteQuotes(ctx context.Context, userID uuid.UUID, filters f.Filters) ([]Quote, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetFavoriteQuotes")
+	defer done()
+
+	query := `
+		select count(*) over(), ` + quoteColumns + `
+		from quote_favorites fav
+		inner join quotes q
+		on fav.quote_id = q.id
+		inner join quote_states s
+		on q.state = s.id and s.is_public = true
+		inner join photos p
+		on q.photo_id = p.id
+		where fav.user_id = $1
+		and (q.publish_at is null or q.publish_at <= now())
+		order by fav.created_at desc, q.id
+		limit $2 offset $3`
+
+	rows, err := db.QueryContext(ctx, query, userID, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	quotes := make([]Quote, 0)
+
+	for rows.Next() {
+		quote, err := scanQuote(rows, &totalRecords)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		quotes = append(quotes, quote)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+	rows.Close()
+
+	for i := range quotes {
+		hashtags, _, err := db.GetQuoteHashtags(ctx, quotes[i].ID)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+		quotes[i].Hashtags = hashtags
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return quotes, metadata, nil
+}
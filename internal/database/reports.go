@@ -0,0 +1,335 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+// Report statuses. A report stays open until a moderator resolves it (the
+// report was justified) or dismisses it.
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+// ReportReasons are the categories a quote can be reported for.
+var ReportReasons = []string{"misattribution", "offensive", "copyright", "spam", "other"}
+
+// QuoteReport is a user's complaint about a public quote. StateChangeID
+// links the report to the state change it caused or was settled with.
+type QuoteReport struct {
+	ID            uuid.UUID
+	QuoteID       uuid.UUID
+	UserID        uuid.UUID
+	Reason        string
+	Note          string
+	Status        string
+	ResolvedBy    *uuid.UUID
+	ResolvedAt    *time.Time
+	StateChangeID *uuid.UUID
+	CreatedAt     time.Time
+
+	// Filled in by GetQuoteReports for the moderators' inbox.
+	QuoteAuthor     string
+	QuoteText       string
+	QuoteState      QuoteState
+	OpenReportCount int
+}
+
+// ReportStore is implemented by stores that support reporting quotes.
+type ReportStore interface {
+	QuoteStateHistoryStore
+	InsertQuoteReport(ctx context.Context, report *QuoteReport, threshold int) (*QuoteStateChange, error)
+	GetQuoteReport(ctx context.Context, id uuid.UUID) (*QuoteReport, error)
+	GetQuoteReports(ctx context.Context, status string, filters f.Filters) ([]QuoteReport, f.Metadata, error)
+	ResolveQuoteReports(ctx context.Context, quoteID, moderatorID uuid.UUID, stateID *uuid.UUID) (int, *QuoteStateChange, error)
+	DismissQuoteReports(ctx context.Context, quoteID, moderatorID uuid.UUID) (int, *QuoteStateChange, error)
+}
+
+var _ ReportStore = (*DB)(nil)
+
+// InsertQuoteReport files a report against a public quote. A user can only
+// have one open report per quote. Once threshold open reports have piled up
+// (0 disables this) the quote is moved back to the default state for review
+// and the returned change is linked to all of them.
+func (db *DB) InsertQuoteReport(ctx context.Context, report *QuoteReport, threshold int) (*QuoteStateChange, error) {
+	ctx, done := db.write(ctx, "InsertQuoteReport")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var isPublic bool
+
+	query := `
+		select s.is_public and (q.publish_at is null or q.publish_at <= now())
+		from quotes q
+		inner join quote_states s
+		on q.state = s.id
+		where q.id = $1
+		for update of q`
+
+	err = tx.QueryRowContext(ctx, query, report.QuoteID).Scan(&isPublic)
+	switch {
+	case errors.Is(err, sql.ErrNoRows), err == nil && !isPublic:
+		return nil, ErrRecordNotFound
+	case err != nil:
+		return nil, err
+	}
+
+	report.ID = uuid.New()
+	report.Status = ReportOpen
+
+	query = `
+		insert into quote_reports (id, quote_id, user_id, reason, note)
+		values ($1, $2, $3, $4, $5)
+		returning created_at`
+
+	args := []interface{}{report.ID, report.QuoteID, report.UserID, report.Reason, report.Note}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&report.CreatedAt)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err):
+			return nil, ErrDuplicateReport
+		default:
+			return nil, err
+		}
+	}
+
+	var open int
+
+	err = tx.QueryRowContext(ctx, `select count(*) from quote_reports where quote_id = $1 and status = 'open'`, report.QuoteID).Scan(&open)
+	if err != nil {
+		return nil, err
+	}
+
+	var change *QuoteStateChange
+
+	if threshold > 0 && open >= threshold {
+		var review uuid.UUID
+
+		err = tx.QueryRowContext(ctx, `select id from quote_states where is_default = true`).Scan(&review)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return nil, ErrDefaultState
+			default:
+				return nil, err
+			}
+		}
+
+		change, err = changeQuoteState(ctx, tx, report.QuoteID, review, nil, fmt.Sprintf("reported by %d users", open))
+		if err != nil {
+			return nil, err
+		}
+
+		if change != nil {
+			_, err = tx.ExecContext(ctx, `update quote_reports set state_change_id = $1 where quote_id = $2 and status = 'open'`, change.ID, report.QuoteID)
+			if err != nil {
+				return nil, err
+			}
+			report.StateChangeID = &change.ID
+		}
+	}
+
+	return change, tx.Commit()
+}
+
+func (db *DB) GetQuoteReport(ctx context.Context, id uuid.UUID) (*QuoteReport, error) {
+	ctx, done := db.read(ctx, "GetQuoteReport")
+	defer done()
+
+	var report QuoteReport
+
+	query := `
+		select id, quote_id, user_id, reason, note, status, resolved_by, resolved_at, state_change_id, created_at
+		from quote_reports
+		where id = $1`
+
+	err := db.QueryRowContext(ctx, query, id).Scan(
+		&report.ID, &report.QuoteID, &report.UserID, &report.Reason, &report.Note, &report.Status,
+		&report.ResolvedBy, &report.ResolvedAt, &report.StateChangeID, &report.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &report, nil
+}
+
+// GetQuoteReports lists reports with the given status, oldest first, along
+// with the reported quote and how many open reports it has.
+func (db *DB) GetQuoteReports(ctx context.Context, status string, filters f.Filters) ([]QuoteReport, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetQuoteReports")
+	defer done()
+
+	query := `
+		select count(*) over(), r.id, r.quote_id, r.user_id, r.reason, r.note, r.status, r.resolved_by, r.resolved_at, r.state_change_id, r.created_at,
+		       q.author, q.text, s.id, s.value, s.is_default, s.color, s.is_public,
+		       (select count(*) from quote_reports o where o.quote_id = r.quote_id and o.status = 'open')
+		from quote_reports r
+		inner join quotes q
+		on r.quote_id = q.id
+		inner join quote_states s
+		on q.state = s.id
+		where r.status = $1
+		order by r.created_at, r.id
+		limit $2 offset $3`
+
+	rows, err := db.QueryContext(ctx, query, status, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	reports := make([]QuoteReport, 0)
+
+	for rows.Next() {
+		var report QuoteReport
+
+		err := rows.Scan(
+			&totalRecords,
+			&report.ID, &report.QuoteID, &report.UserID, &report.Reason, &report.Note, &report.Status,
+			&report.ResolvedBy, &report.ResolvedAt, &report.StateChangeID, &report.CreatedAt,
+			&report.QuoteAuthor, &report.QuoteText,
+			&report.QuoteState.ID, &report.QuoteState.Value, &report.QuoteState.IsDefault, &report.QuoteState.Color, &report.QuoteState.IsPublic,
+			&report.OpenReportCount,
+		)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		reports = append(reports, report)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return reports, metadata, nil
+}
+
+// ResolveQuoteReports closes every open report of a quote as justified. With
+// stateID set the quote is moved to that state too, and the change is linked
+// to the reports. It returns how many reports were closed.
+func (db *DB) ResolveQuoteReports(ctx context.Context, quoteID, moderatorID uuid.UUID, stateID *uuid.UUID) (int, *QuoteStateChange, error) {
+	ctx, done := db.write(ctx, "ResolveQuoteReports")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	var change *QuoteStateChange
+
+	if stateID != nil {
+		change, err = changeQuoteState(ctx, tx, quoteID, *stateID, &moderatorID, "reports resolved")
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	query := `
+		update quote_reports
+		set status = 'resolved', resolved_by = $1, resolved_at = now(), state_change_id = coalesce($2, state_change_id)
+		where quote_id = $3 and status = 'open'`
+
+	var changeID *uuid.UUID
+	if change != nil {
+		changeID = &change.ID
+	}
+
+	result, err := tx.ExecContext(ctx, query, moderatorID, changeID, quoteID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	closed, _ := result.RowsAffected()
+
+	return int(closed), change, tx.Commit()
+}
+
+// DismissQuoteReports closes every open report of a quote as unfounded. If
+// the reports had moved the quote to review and nobody has moved it since,
+// the quote goes back to the state it had before.
+func (db *DB) DismissQuoteReports(ctx context.Context, quoteID, moderatorID uuid.UUID) (int, *QuoteStateChange, error) {
+	ctx, done := db.write(ctx, "DismissQuoteReports")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	var change *QuoteStateChange
+	var restore *uuid.UUID
+
+	query := `
+		select c.from_state
+		from quote_state_changes c
+		inner join quotes q
+		on c.quote_id = q.id and q.state = c.to_state
+		where c.id = (
+			select state_change_id
+			from quote_reports
+			where quote_id = $1 and status = 'open' and state_change_id is not null
+			limit 1)
+		and not exists (
+			select 1
+			from quote_state_changes later
+			where later.quote_id = c.quote_id and later.created_at > c.created_at)`
+
+	err = tx.QueryRowContext(ctx, query, quoteID).Scan(&restore)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, nil, err
+	}
+
+	if restore != nil {
+		change, err = changeQuoteState(ctx, tx, quoteID, *restore, &moderatorID, "reports dismissed")
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	query = `
+		update quote_reports
+		set status = 'dismissed', resolved_by = $1, resolved_at = now(), state_change_id = coalesce($2, state_change_id)
+		where quote_id = $3 and status = 'open'`
+
+	var changeID *uuid.UUID
+	if change != nil {
+		changeID = &change.ID
+	}
+
+	result, err := tx.ExecContext(ctx, query, moderatorID, changeID, quoteID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	closed, _ := result.RowsAffected()
+
+	return int(closed), change, tx.Commit()
+}
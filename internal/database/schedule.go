@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+// ScheduleQuote sets the time a quote goes public. A nil publishAt clears the
+// schedule, making the quote public right away if its state is.
+func (db *DB) ScheduleQuote(ctx context.Context, id uuid.UUID, publishAt *time.Time) error {
+	ctx, done := db.write(ctx, "ScheduleQuote")
+	defer done()
+
+	result, err := db.ExecContext(ctx, `update quotes set publish_at = $1, updated_at = now() where id = $2`, publishAt, id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetScheduledQuotes lists the quotes with a publish time, whatever their
+// state, the next to go live first.
+func (db *DB) GetScheduledQuotes(ctx context.Context, filters f.Filters) ([]Quote, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetScheduledQuotes")
+	defer done()
+
+	query := `
+		select count(*) over(), ` + quoteColumns + `
+		from quotes q
+		inner join quote_states s
+		on q.state = s.id
+		inner join photos p
+		on q.photo_id = p.id
+		where q.publish_at is not null
+		order by q.publish_at, q.id
+		limit $1 offset $2`
+
+	rows, err := db.QueryContext(ctx, query, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	quotes := make([]Quote, 0)
+
+	for rows.Next() {
+		quote, err := scanQuote(rows, &totalRecords)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		quotes = append(quotes, quote)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+	rows.Close()
+
+	for i := range quotes {
+		quotes[i].Hashtags, _, err = db.GetQuoteHashtags(ctx, quotes[i].ID)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return quotes, metadata, nil
+}
+
+// PublishDueQuotes clears the publish time of the public quotes whose time
+// has passed and returns them. Quotes scheduled in a state that isn't public
+// keep their publish time until they are moved to a public one.
+func (db *DB) PublishDueQuotes(ctx context.Context) ([]Quote, error) {
+	ctx, done := db.write(ctx, "PublishDueQuotes")
+	defer done()
+
+	query := `
+		update quotes q
+		set publish_at = null, updated_at = now()
+		from quote_states s
+		where q.state = s.id and s.is_public
+		and q.publish_at <= now()
+		returning q.id`
+
+	var ids []uuid.UUID
+
+	err := db.SelectContext(ctx, &ids, query)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := make([]Quote, 0, len(ids))
+
+	for _, id := range ids {
+		quote, err := db.GetQuoteById(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		quotes = append(quotes, *quote)
+	}
+
+	return quotes, nil
+}
@@ -0,0 +1,125 @@
+// Package sqlite is a database.Store backed by SQLite, for single-node and
+// embedded deployments that don't want to run Postgres. It uses the pure Go
+// modernc.org/sqlite driver and its own migrations in assets/migrations_sqlite.
+package sqlite
+
+import (
+	"context"
+	"strings"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/leveledlog"
+	"javlonrahimov/quotes-api/internal/migrations"
+
+	"github.com/jmoiron/sqlx"
+
+	_ "modernc.org/sqlite"
+)
+
+var pragmas = []string{
+	"_pragma=foreign_keys(1)",
+	"_pragma=busy_timeout(5000)",
+	"_pragma=journal_mode(WAL)",
+}
+
+type DB struct {
+	*sqlx.DB
+	tracker *database.Tracker
+}
+
+var (
+	_ database.Store    = (*DB)(nil)
+	_ database.Resetter = (*DB)(nil)
+)
+
+// Open connects to the SQLite database at dsn (a file path or ":memory:")
+// without checking its schema.
+//
+// SQLite serialises writers anyway, so the pool is limited to a single
+// connection; that also keeps ":memory:" databases from being split across
+// connections. Methods therefore never hold a result set open while running
+// another query.
+func Open(dsn string) (*sqlx.DB, error) {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+
+	db, err := sqlx.Connect("sqlite", dsn+sep+strings.Join(pragmas, "&"))
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+func New(dsn string, automigrate bool, opts database.Options, logger *leveledlog.Logger) (*DB, error) {
+	db, err := Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := migrations.SQLite(db.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	if automigrate {
+		err = migrator.Up()
+	} else {
+		err = migrator.Check()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &DB{DB: db, tracker: database.NewTracker(opts, logger)}, nil
+}
+
+func (db *DB) Reset(ctx context.Context) error {
+	ctx, done := db.write(ctx, "Reset")
+	defer done()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"quote_likes", "quote_favorites", "quote_hashtags", "quotes", "hashtags", "photos", "otps", "users_permissions", "users"} {
+		_, err := tx.ExecContext(ctx, "delete from "+table)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (db *DB) read(ctx context.Context, name string) (context.Context, func()) {
+	return db.tracker.Read(ctx, name)
+}
+
+func (db *DB) write(ctx context.Context, name string) (context.Context, func()) {
+	return db.tracker.Write(ctx, name)
+}
+
+func isUniqueViolation(err error, constraint string) bool {
+	return strings.Contains(err.Error(), "UNIQUE constraint failed: "+constraint)
+}
+
+func isForeignKeyViolation(err error) bool {
+	return strings.Contains(err.Error(), "FOREIGN KEY constraint failed")
+}
+
+// matchQuery turns free text into an FTS5 query equivalent to
+// plainto_tsquery('simple', text) restricted to column: every word must match.
+func matchQuery(column, text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		terms = append(terms, column+` : "`+strings.ReplaceAll(word, `"`, `""`)+`"`)
+	}
+	return strings.Join(terms, " AND ")
+}
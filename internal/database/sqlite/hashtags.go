@@ -0,0 +1,150 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"javlonrahimov/quotes-api/internal/database"
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+func (db *DB) InsertHashtag(ctx context.Context, value string) (*database.Hashtag, error) {
+	ctx, done := db.write(ctx, "InsertHashtag")
+	defer done()
+
+	hashtag := database.Hashtag{
+		ID:    uuid.New(),
+		Value: value,
+	}
+
+	query := `insert into hashtags (id, value) values (?, ?)`
+
+	_, err := db.ExecContext(ctx, query, hashtag.ID, hashtag.Value)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "hashtags.value"):
+			return nil, database.ErrDuplicateHashtag
+		default:
+			return nil, err
+		}
+	}
+
+	return &hashtag, nil
+}
+
+func (db *DB) GetHashtagByValue(ctx context.Context, value string) (*database.Hashtag, error) {
+	ctx, done := db.read(ctx, "GetHashtagByValue")
+	defer done()
+
+	var hashtag database.Hashtag
+
+	err := db.GetContext(ctx, &hashtag, `select id, value from hashtags where value = ?`, value)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &hashtag, nil
+}
+
+func (db *DB) DeleteHashtagById(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "DeleteHashtagById")
+	defer done()
+
+	query := `delete from hashtags where id = ?`
+
+	result, err := db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) IsQuoteExistsWithThisHashtag(ctx context.Context, hashtagID uuid.UUID) bool {
+	ctx, done := db.read(ctx, "IsQuoteExistsWithThisHashtag")
+	defer done()
+
+	query := `select exists (select 1 from quote_hashtags where hashtag_id = ?)`
+
+	var exists bool
+
+	err := db.QueryRowContext(ctx, query, hashtagID).Scan(&exists)
+	if err != nil {
+		return false
+	}
+	return exists
+}
+
+func (db *DB) GetQuoteHashtags(ctx context.Context, quoteID uuid.UUID) ([]database.Hashtag, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetQuoteHashtags")
+	defer done()
+
+	query := `
+		select h.id, h.value
+		from hashtags h
+		inner join quote_hashtags q
+		on h.id = q.hashtag_id
+		where q.quote_id = ?`
+
+	hashtags := []database.Hashtag{}
+
+	err := db.SelectContext(ctx, &hashtags, query, quoteID)
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+
+	metadata := f.CalculateMetadata(len(hashtags), 1, len(hashtags))
+
+	return hashtags, metadata, nil
+}
+
+func (db *DB) GetHashtags(ctx context.Context, filters f.Filters) ([]database.Hashtag, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetHashtags")
+	defer done()
+
+	query := fmt.Sprintf(`
+		select count(*) over(), id, value
+		from hashtags
+		order by %s %s, value asc
+		limit ? offset ?`, filters.SortColumn(), filters.SortDirection())
+
+	rows, err := db.QueryContext(ctx, query, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	hashtags := []database.Hashtag{}
+
+	for rows.Next() {
+		var hashtag database.Hashtag
+
+		err := rows.Scan(&totalRecords, &hashtag.ID, &hashtag.Value)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		hashtags = append(hashtags, hashtag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return hashtags, metadata, nil
+}
@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+
+	"github.com/google/uuid"
+)
+
+func (db *DB) InsertOTP(ctx context.Context, otp *database.OTP) error {
+	ctx, done := db.write(ctx, "InsertOTP")
+	defer done()
+
+	query := `
+		insert into otps (id, hash, user_id, expiry, created, "scope")
+		values (?, ?, ?, ?, ?, ?)`
+
+	args := []interface{}{otp.ID, otp.Hash, otp.UserID, otp.Expiry.UTC(), otp.Created.UTC(), otp.Scope}
+
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (db *DB) GetOTPForEmail(ctx context.Context, email string, scope string) (*database.OTP, error) {
+	ctx, done := db.read(ctx, "GetOTPForEmail")
+	defer done()
+
+	var otp database.OTP
+
+	query := `
+		select otps.id, otps.hash, otps.user_id, otps.expiry, otps.created
+		from otps
+		inner join users on otps.user_id = users.id
+		where users.email = ? and otps."scope" = ?
+		order by otps.created desc
+		limit 1`
+
+	err := db.QueryRowContext(ctx, query, email, scope).Scan(&otp.ID, &otp.Hash, &otp.UserID, &otp.Expiry, &otp.Created)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &otp, nil
+}
+
+func (db *DB) DeleteAllOTPForUser(ctx context.Context, userID uuid.UUID, scope string) error {
+	ctx, done := db.write(ctx, "DeleteAllOTPForUser")
+	defer done()
+
+	query := `delete from otps where user_id = ? and "scope" = ?`
+
+	_, err := db.ExecContext(ctx, query, userID, scope)
+	return err
+}
+
+func (db *DB) NewOtp(ctx context.Context, userID uuid.UUID, ttl time.Duration, scope string) (*database.OTP, error) {
+	otp, err := database.GenerateOTP(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.InsertOTP(ctx, otp)
+	return otp, err
+}
@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+func (db *DB) GetAllPermissionsForUser(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	ctx, done := db.read(ctx, "GetAllPermissionsForUser")
+	defer done()
+
+	query := `
+		select permissions.code
+		from permissions
+		inner join users_permissions on users_permissions.permission_id = permissions.id
+		where users_permissions.user_id = ?`
+
+	var permissions []string
+
+	err := db.SelectContext(ctx, &permissions, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+func (db *DB) AddPermissionForUser(ctx context.Context, userID uuid.UUID, codes ...string) error {
+	ctx, done := db.write(ctx, "AddPermissionForUser")
+	defer done()
+
+	query := `
+		insert or ignore into users_permissions
+		select ?, permissions.id from permissions where permissions.code = ?`
+
+	for _, code := range codes {
+		_, err := db.ExecContext(ctx, query, userID, code)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"javlonrahimov/quotes-api/internal/database"
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+func (db *DB) InsertPhoto(ctx context.Context, color, blurHash, author, url string) (*database.Photo, error) {
+	ctx, done := db.write(ctx, "InsertPhoto")
+	defer done()
+
+	photo := database.Photo{
+		ID:       uuid.New(),
+		Color:    color,
+		BlurHash: blurHash,
+		Author:   author,
+		Url:      url,
+	}
+
+	query := `
+		insert into photos (id, color, blur_hash, author, url)
+		values (?, ?, ?, ?, ?)`
+
+	_, err := db.ExecContext(ctx, query, photo.ID, photo.Color, photo.BlurHash, photo.Author, photo.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &photo, nil
+}
+
+func (db *DB) GetPhotoById(ctx context.Context, id uuid.UUID) (*database.Photo, error) {
+	ctx, done := db.read(ctx, "GetPhotoById")
+	defer done()
+
+	query := `select id, color, blur_hash, author, url from photos where id = ?`
+
+	var photo database.Photo
+
+	err := db.GetContext(ctx, &photo, query, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &photo, nil
+}
+
+func (db *DB) GetPhotoByUrl(ctx context.Context, url string) (*database.Photo, error) {
+	ctx, done := db.read(ctx, "GetPhotoByUrl")
+	defer done()
+
+	query := `select id, color, blur_hash, author, url from photos where url = ? limit 1`
+
+	var photo database.Photo
+
+	err := db.GetContext(ctx, &photo, query, url)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &photo, nil
+}
+
+func (db *DB) GetPhotos(ctx context.Context) ([]*database.Photo, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetPhotos")
+	defer done()
+
+	query := `select id, color, blur_hash, author, url from photos order by rowid`
+
+	photos := []*database.Photo{}
+
+	err := db.SelectContext(ctx, &photos, query)
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+
+	metadata := f.CalculateMetadata(len(photos), 1, len(photos))
+
+	return photos, metadata, nil
+}
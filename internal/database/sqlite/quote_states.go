@@ -0,0 +1,165 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"javlonrahimov/quotes-api/internal/database"
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+func (db *DB) InsertQuoteState(ctx context.Context, state *database.QuoteState) error {
+	ctx, done := db.write(ctx, "InsertQuoteState")
+	defer done()
+
+	query := `
+		insert into quote_states (id, value, is_default, color, is_public)
+		values (?, ?, ?, ?, ?)
+		returning id`
+
+	args := []interface{}{uuid.New(), state.Value, false, state.Color, state.IsPublic}
+
+	err := db.QueryRowContext(ctx, query, args...).Scan(&state.ID)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "quote_states.value"):
+			return database.ErrDuplicateQuoteState
+		default:
+			return err
+		}
+	}
+
+	if state.IsDefault {
+		err := db.SetDefaultQuoteState(ctx, state.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) getDefaultQuoteState(ctx context.Context) (*database.QuoteState, error) {
+	ctx, done := db.read(ctx, "getDefaultQuoteState")
+	defer done()
+
+	var state database.QuoteState
+
+	query := `
+		select id, value, is_default, color, is_public
+		from quote_states
+		where is_default = true`
+
+	err := db.GetContext(ctx, &state, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func (db *DB) DeleteQuoteStateById(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "DeleteQuoteStateById")
+	defer done()
+
+	if state, _ := db.getDefaultQuoteState(ctx); state != nil && state.ID == id {
+		return database.ErrDefaultState
+	}
+
+	query := `delete from quote_states where id = ?`
+
+	_, err := db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (db *DB) ExistsQuoteStateById(ctx context.Context, id uuid.UUID) bool {
+	ctx, done := db.read(ctx, "ExistsQuoteStateById")
+	defer done()
+
+	query := `select exists (select 1 from quote_states where id = ?)`
+
+	var exists bool
+
+	err := db.QueryRowContext(ctx, query, id).Scan(&exists)
+	if err != nil {
+		return false
+	}
+	return exists
+}
+
+func (db *DB) SetDefaultQuoteState(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "SetDefaultQuoteState")
+	defer done()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+
+	err = tx.QueryRowContext(ctx, `select exists (select 1 from quote_states where id = ?)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return database.ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `update quote_states set is_default = (id = ?)`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *DB) GetAllQuoteStates(ctx context.Context) ([]database.QuoteState, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetAllQuoteStates")
+	defer done()
+
+	query := `
+		select id, value, is_default, color, is_public
+		from quote_states
+		order by rowid`
+
+	var quoteStates []database.QuoteState
+
+	err := db.SelectContext(ctx, &quoteStates, query)
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+
+	metadata := f.CalculateMetadata(len(quoteStates), 1, len(quoteStates))
+
+	return quoteStates, metadata, nil
+}
+
+func (db *DB) getQuoteStateById(ctx context.Context, stateID uuid.UUID) (*database.QuoteState, error) {
+	ctx, done := db.read(ctx, "getQuoteStateById")
+	defer done()
+
+	query := `
+		select id, value, is_default, color, is_public
+		from quote_states
+		where id = ?`
+
+	var quoteState database.QuoteState
+
+	err := db.GetContext(ctx, &quoteState, query, stateID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &quoteState, nil
+}
@@ -0,0 +1,731 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+const quoteColumns = `
+	q.id, q.created_at, q.updated_at, q.created_by, q.author, q.text,
+	s.id, s.value, s.is_default, s.color, s.is_public,
+	p.id, p.url, p.color, p.blur_hash, p.author,
+	q.like_count, q.favorite_count,
+	q.verification, q.verified_by, q.verified_at,
+	q.source_type, q.source_title, q.source_url, q.source_location, q.source_year, q.source_language,
+	q.language, q.original_id, q.publish_at`
+
+// quoteIsLive holds back quotes whose publish time hasn't come yet. Times are
+// stored as UTC text, which sorts chronologically.
+const quoteIsLive = `(q.publish_at is null or q.publish_at <= datetime('now'))`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanQuote(row scanner, extra ...any) (database.Quote, error) {
+	quote := database.Quote{Photo: &database.Photo{}}
+
+	dest := append(extra,
+		&quote.ID, &quote.CreatedAt, &quote.UpdatedAt, &quote.CreatedBy, &quote.Author, &quote.Text,
+		&quote.State.ID, &quote.State.Value, &quote.State.IsDefault, &quote.State.Color, &quote.State.IsPublic,
+		&quote.Photo.ID, &quote.Photo.Url, &quote.Photo.Color, &quote.Photo.BlurHash, &quote.Photo.Author,
+		&quote.LikeCount, &quote.FavoriteCount,
+		&quote.Verification, &quote.VerifiedBy, &quote.VerifiedAt,
+		&quote.Source.Type, &quote.Source.Title, &quote.Source.URL, &quote.Source.Location, &quote.Source.Year, &quote.Source.Language,
+		&quote.Language, &quote.OriginalID, &quote.PublishAt,
+	)
+
+	err := row.Scan(dest...)
+	return quote, err
+}
+
+func (db *DB) InsertQuote(ctx context.Context, author, text string, userID, photoID uuid.UUID, hashtagIDs []uuid.UUID, stateID *uuid.UUID) (*database.Quote, error) {
+	ctx, done := db.write(ctx, "InsertQuote")
+	defer done()
+
+	state, err := db.getDefaultQuoteState(ctx)
+	if err != nil && stateID == nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if stateID != nil {
+		state, err = db.getQuoteStateById(ctx, *stateID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	photo, err := db.GetPhotoById(ctx, photoID)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &database.Quote{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Author:    author,
+		Text:      text,
+		CreatedBy: userID,
+		State:     *state,
+		Photo:     photo,
+
+		Verification: database.VerificationUnverified,
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		insert into quotes (id, created_at, updated_at, author, text, created_by, state, photo_id)
+		values (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	args := []interface{}{quote.ID, quote.CreatedAt, quote.UpdatedAt, quote.Author, quote.Text, quote.CreatedBy, state.ID, photo.ID}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertQuoteHashtags(ctx, tx, hashtagIDs, quote.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	hashtags, _, err := db.GetQuoteHashtags(ctx, quote.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	quote.Hashtags = hashtags
+
+	return quote, nil
+}
+
+func (db *DB) UpdateQuote(ctx context.Context, quoteID, photoID uuid.UUID, author, text string, hashtagIDs []uuid.UUID) (*database.Quote, error) {
+	ctx, done := db.write(ctx, "UpdateQuote")
+	defer done()
+
+	quote, err := db.GetQuoteById(ctx, quoteID)
+	if err != nil {
+		return nil, err
+	}
+
+	photo, err := db.GetPhotoById(ctx, photoID)
+	if err != nil {
+		return nil, err
+	}
+
+	quote.Author = author
+	quote.Text = text
+	quote.Photo = photo
+	quote.UpdatedAt = time.Now().UTC()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		update quotes
+		set text = ?, author = ?, photo_id = ?, updated_at = ?, version = version + 1
+		where id = ?`
+
+	result, err := tx.ExecContext(ctx, query, quote.Text, quote.Author, photo.ID, quote.UpdatedAt, quote.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, database.ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from quote_hashtags where quote_id = ?`, quote.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertQuoteHashtags(ctx, tx, hashtagIDs, quote.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	hashtags, _, err := db.GetQuoteHashtags(ctx, quote.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	quote.Hashtags = hashtags
+
+	return quote, nil
+}
+
+func (db *DB) DeleteQuoteById(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "DeleteQuoteById")
+	defer done()
+
+	result, err := db.ExecContext(ctx, `delete from quotes where id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) GetQuoteById(ctx context.Context, id uuid.UUID) (*database.Quote, error) {
+	ctx, done := db.read(ctx, "GetQuoteById")
+	defer done()
+
+	query := `
+		select ` + quoteColumns + `
+		from quotes q
+		inner join quote_states s on q.state = s.id
+		inner join photos p on q.photo_id = p.id
+		where q.id = ?`
+
+	quote, err := scanQuote(db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	hashtags, _, err := db.GetQuoteHashtags(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	quote.Hashtags = hashtags
+
+	return &quote, nil
+}
+
+func (db *DB) GetQuoteByText(ctx context.Context, author, text string) (*database.Quote, error) {
+	ctx, done := db.read(ctx, "GetQuoteByText")
+	defer done()
+
+	var id uuid.UUID
+
+	err := db.QueryRowContext(ctx, `select id from quotes where author = ? and text = ? limit 1`, author, text).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return db.GetQuoteById(ctx, id)
+}
+
+func (db *DB) GetUserQuotes(ctx context.Context, userID uuid.UUID, author string, text string, state uuid.UUID, attribution database.AttributionFilter, filters f.Filters) ([]database.Quote, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetUserQuotes")
+	defer done()
+
+	query := fmt.Sprintf(`
+		select count(*) over(), `+quoteColumns+`
+		from quotes q
+		inner join quote_states s on q.state = s.id
+		inner join photos p on q.photo_id = p.id
+		where (? = '' or q.id in (select quote_id from quotes_fts where quotes_fts match ?))
+		and (? = '' or q.id in (select quote_id from quotes_fts where quotes_fts match ?))
+		and q.created_by = ?
+		and s.id = ?
+		and (? = '' or q.verification = ?)
+		and (? = '' or q.source_type = ?)
+		order by q.%s %s, q.created_at asc
+		limit ? offset ?`, database.QuoteSortColumn(filters), filters.SortDirection())
+
+	authorMatch, textMatch := matchQuery("author", author), matchQuery("text", text)
+	args := []interface{}{
+		authorMatch, authorMatch, textMatch, textMatch, userID, state,
+		attribution.Verification, attribution.Verification, attribution.SourceType, attribution.SourceType,
+		filters.Limit(), filters.Offset(),
+	}
+
+	return db.queryQuotes(ctx, query, args, filters)
+}
+
+// GetQuotes lists public quotes, leaving out translations; the text search
+// also finds an original through its public translations.
+func (db *DB) GetQuotes(ctx context.Context, author string, text string, attribution database.AttributionFilter, filters f.Filters) ([]database.Quote, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetQuotes")
+	defer done()
+
+	query := fmt.Sprintf(`
+		select count(*) over(), `+quoteColumns+`
+		from quotes q
+		inner join quote_states s on q.state = s.id and s.is_public = true
+		inner join photos p on q.photo_id = p.id
+		where q.original_id is null
+		and `+quoteIsLive+`
+		and (? = '' or q.id in (select quote_id from quotes_fts where quotes_fts match ?))
+		and (? = '' or q.id in (
+			select coalesce(t.original_id, t.id)
+			from quotes t
+			inner join quote_states ts on t.state = ts.id
+			where (t.original_id is null or (ts.is_public and (t.publish_at is null or t.publish_at <= datetime('now'))))
+			and t.id in (select quote_id from quotes_fts where quotes_fts match ?)))
+		and (? = '' or q.verification = ?)
+		and (? = '' or q.source_type = ?)
+		order by q.%s %s, q.created_at desc, q.id
+		limit ? offset ?`, database.QuoteSortColumn(filters), filters.SortDirection())
+
+	authorMatch, textMatch := matchQuery("author", author), matchQuery("text", text)
+	args := []interface{}{
+		authorMatch, authorMatch, textMatch, textMatch,
+		attribution.Verification, attribution.Verification, attribution.SourceType, attribution.SourceType,
+		filters.Limit(), filters.Offset(),
+	}
+
+	return db.queryQuotes(ctx, query, args, filters)
+}
+
+// queryQuotes runs a listing query whose first column is the total row count,
+// then loads each quote's hashtags once the result set has been released.
+func (db *DB) queryQuotes(ctx context.Context, query string, args []interface{}, filters f.Filters) ([]database.Quote, f.Metadata, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	quotes := make([]database.Quote, 0)
+
+	for rows.Next() {
+		quote, err := scanQuote(rows, &totalRecords)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		quotes = append(quotes, quote)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+	rows.Close()
+
+	for i := range quotes {
+		hashtags, _, err := db.GetQuoteHashtags(ctx, quotes[i].ID)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		quotes[i].Hashtags = hashtags
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return quotes, metadata, nil
+}
+
+func (db *DB) SetQuoteState(ctx context.Context, id, stateID uuid.UUID) error {
+	ctx, done := db.write(ctx, "SetQuoteState")
+	defer done()
+
+	if !db.ExistsQuoteStateById(ctx, stateID) {
+		return database.ErrRecordNotFound
+	}
+
+	query := `update quotes set state = ?, updated_at = ? where id = ?`
+
+	result, err := db.ExecContext(ctx, query, stateID, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected < 1 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// SetQuoteSource replaces the source of a quote; a nil source removes it.
+func (db *DB) SetQuoteSource(ctx context.Context, id uuid.UUID, source *database.QuoteSource) error {
+	ctx, done := db.write(ctx, "SetQuoteSource")
+	defer done()
+
+	if source == nil {
+		source = &database.QuoteSource{}
+	}
+
+	query := `
+		update quotes
+		set source_type = ?, source_title = ?, source_url = ?, source_location = ?, source_year = ?, source_language = ?, updated_at = ?
+		where id = ?`
+
+	args := []interface{}{source.Type, source.Title, source.URL, source.Location, source.Year, source.Language, time.Now().UTC(), id}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected < 1 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) SetQuoteVerification(ctx context.Context, id uuid.UUID, status string, userID uuid.UUID) error {
+	ctx, done := db.write(ctx, "SetQuoteVerification")
+	defer done()
+
+	now := time.Now().UTC()
+
+	query := `update quotes set verification = ?, verified_by = ?, verified_at = ?, updated_at = ? where id = ?`
+
+	result, err := db.ExecContext(ctx, query, status, userID, now, now, id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected < 1 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) IsExistsWithThisState(ctx context.Context, quoteStateID uuid.UUID) bool {
+	ctx, done := db.read(ctx, "IsExistsWithThisState")
+	defer done()
+
+	query := `select exists (select 1 from quotes where state = ?)`
+
+	var exists bool
+
+	err := db.QueryRowContext(ctx, query, quoteStateID).Scan(&exists)
+	if err != nil {
+		return false
+	}
+	return exists
+}
+
+func insertQuoteHashtags(ctx context.Context, tx *sql.Tx, hashtagIDs []uuid.UUID, quoteID uuid.UUID) error {
+	query := `insert into quote_hashtags (quote_id, hashtag_id) values (?, ?)`
+
+	for _, id := range hashtagIDs {
+		_, err := tx.ExecContext(ctx, query, quoteID, id)
+		if err != nil {
+			switch {
+			case isForeignKeyViolation(err):
+				return database.ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) ImportQuotes(ctx context.Context, userID uuid.UUID, quotes []database.QuoteImport) ([]database.ImportedQuote, error) {
+	ctx, done := db.write(ctx, "ImportQuotes")
+	defer done()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var defaultStateID *uuid.UUID
+	err = tx.QueryRowContext(ctx, `select id from quote_states where is_default = true`).Scan(&defaultStateID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	results := make([]database.ImportedQuote, 0, len(quotes))
+
+	for _, q := range quotes {
+		var existingID uuid.UUID
+		err := tx.QueryRowContext(ctx, `select id from quotes where author = ? and text = ? limit 1`, q.Author, q.Text).Scan(&existingID)
+		switch {
+		case err == nil:
+			results = append(results, database.ImportedQuote{QuoteID: existingID, Duplicate: true})
+			continue
+		case !errors.Is(err, sql.ErrNoRows):
+			return nil, err
+		}
+
+		stateID := defaultStateID
+		if q.StateID != nil {
+			stateID = q.StateID
+		}
+		if stateID == nil {
+			return nil, database.ErrDefaultState
+		}
+
+		photoID, err := importPhoto(ctx, tx, q.Photo)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now().UTC()
+		quoteID := uuid.New()
+
+		query := `
+			insert into quotes (id, created_at, updated_at, author, text, created_by, state, photo_id)
+			values (?, ?, ?, ?, ?, ?, ?, ?)`
+
+		_, err = tx.ExecContext(ctx, query, quoteID, now, now, q.Author, q.Text, userID, *stateID, photoID)
+		if err != nil {
+			switch {
+			case isForeignKeyViolation(err):
+				return nil, database.ErrRecordNotFound
+			default:
+				return nil, err
+			}
+		}
+
+		for _, value := range q.Hashtags {
+			_, err = tx.ExecContext(ctx, `insert into hashtags (id, value) values (?, ?) on conflict (value) do nothing`, uuid.New(), value)
+			if err != nil {
+				return nil, err
+			}
+
+			query := `
+				insert into quote_hashtags (quote_id, hashtag_id)
+				select ?, id from hashtags where value = ?
+				on conflict do nothing`
+
+			_, err = tx.ExecContext(ctx, query, quoteID, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		results = append(results, database.ImportedQuote{QuoteID: quoteID})
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func importPhoto(ctx context.Context, tx *sql.Tx, photo database.Photo) (uuid.UUID, error) {
+	var id uuid.UUID
+
+	err := tx.QueryRowContext(ctx, `select id from photos where url = ? limit 1`, photo.Url).Scan(&id)
+	switch {
+	case err == nil:
+		return id, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return id, err
+	}
+
+	id = uuid.New()
+
+	query := `
+		insert into photos (id, color, blur_hash, author, url)
+		values (?, ?, ?, ?, ?)`
+
+	_, err = tx.ExecContext(ctx, query, id, photo.Color, photo.BlurHash, photo.Author, photo.Url)
+	return id, err
+}
+
+// exportBatchSize is how many rows ExportQuotes reads per query.
+const exportBatchSize = 500
+
+// ExportQuotes streams every quote matching filter to fn. SQLite has a single
+// connection, so rather than holding one cursor open for the whole export it
+// reads keyset-paginated batches and releases the connection in between.
+func (db *DB) ExportQuotes(ctx context.Context, filter database.QuoteFilter, fn func(*database.Quote) error) error {
+	var after *database.Quote
+
+	for {
+		quotes, err := db.exportBatch(ctx, filter, after)
+		if err != nil {
+			return err
+		}
+
+		for i := range quotes {
+			if err := fn(&quotes[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(quotes) < exportBatchSize {
+			return nil
+		}
+		after = &quotes[len(quotes)-1]
+	}
+}
+
+func (db *DB) exportBatch(ctx context.Context, filter database.QuoteFilter, after *database.Quote) ([]database.Quote, error) {
+	ctx, done := db.read(ctx, "ExportQuotes")
+	defer done()
+
+	authorMatch, textMatch := matchQuery("author", filter.Author), matchQuery("text", filter.Text)
+	args := []interface{}{authorMatch, authorMatch, textMatch, textMatch, filter.AnyState, filter.StateID, filter.StateID, filter.Originals}
+
+	keyset := ""
+	if after != nil {
+		column, op := "created_at", ">"
+		if strings.TrimPrefix(filter.Sort, "-") == "text" {
+			column = "text"
+		}
+		if strings.HasPrefix(filter.Sort, "-") {
+			op = "<"
+		}
+
+		var value interface{} = after.CreatedAt
+		if column == "text" {
+			value = after.Text
+		}
+
+		keyset = fmt.Sprintf("and (q.%[1]s %[2]s ? or (q.%[1]s = ? and q.id %[2]s ?))", column, op)
+		args = append(args, value, value, after.ID)
+	}
+
+	query := `
+		select ` + quoteColumns + `
+		from quotes q
+		inner join quote_states s on q.state = s.id
+		inner join photos p on q.photo_id = p.id
+		where (? = '' or q.id in (select quote_id from quotes_fts where quotes_fts match ?))
+		and (? = '' or q.id in (select quote_id from quotes_fts where quotes_fts match ?))
+		and ((s.is_public = true and ` + quoteIsLive + `) or ?)
+		and (? is null or s.id = ?)
+		and (q.original_id is null or not ?)
+		` + keyset + `
+		order by ` + filter.SortClause() + `
+		limit ` + strconv.Itoa(exportBatchSize)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quotes := make([]database.Quote, 0, exportBatchSize)
+
+	for rows.Next() {
+		quote, err := scanQuote(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		quotes = append(quotes, quote)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range quotes {
+		hashtags, _, err := db.GetQuoteHashtags(ctx, quotes[i].ID)
+		if err != nil {
+			return nil, err
+		}
+
+		quotes[i].Hashtags = hashtags
+	}
+
+	return quotes, nil
+}
+
+// SampleQuotes returns a random selection of public quotes, without their
+// hashtags. SQLite has no lateral joins, so each random probe is its own
+// indexed lookup on sample_key. A probe that finds nothing at or after its
+// key is retried from the lowest key, and the quotes drawn so far are
+// excluded, so every probe adds a quote until the matching ones run out.
+func (db *DB) SampleQuotes(ctx context.Context, filter database.SampleFilter) ([]database.Quote, error) {
+	ctx, done := db.read(ctx, "SampleQuotes")
+	defer done()
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	exclude := append([]uuid.UUID(nil), filter.Exclude...)
+	quotes := make([]database.Quote, 0, filter.Size)
+
+	for len(quotes) < filter.Size {
+		quote, err := db.sampleQuote(ctx, int64(rng.Uint64()), filter.Hashtag, exclude)
+		if errors.Is(err, sql.ErrNoRows) {
+			quote, err = db.sampleQuote(ctx, math.MinInt64, filter.Hashtag, exclude)
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return quotes, nil
+		case err != nil:
+			return nil, err
+		}
+
+		quotes = append(quotes, quote)
+		exclude = append(exclude, quote.ID)
+	}
+
+	return quotes, nil
+}
+
+// sampleQuote returns the first matching public quote at or after key.
+func (db *DB) sampleQuote(ctx context.Context, key int64, hashtag string, exclude []uuid.UUID) (database.Quote, error) {
+	args := []interface{}{key, hashtag, hashtag}
+	placeholders := ""
+	for _, id := range exclude {
+		placeholders += ", ?"
+		args = append(args, id)
+	}
+
+	query := `
+		select ` + quoteColumns + `
+		from quotes q
+		inner join quote_states s on q.state = s.id and s.is_public = true
+		inner join photos p on q.photo_id = p.id
+		where q.sample_key >= ?
+		and q.original_id is null
+		and ` + quoteIsLive + `
+		and (? = '' or exists (
+			select 1 from quote_hashtags qh inner join hashtags h on qh.hashtag_id = h.id
+			where qh.quote_id = q.id and h.value = ?))
+		and q.id not in (''` + placeholders + `)
+		order by q.sample_key
+		limit 1`
+
+	return scanQuote(db.QueryRowContext(ctx, query, args...))
+}
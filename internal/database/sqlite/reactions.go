@@ -0,0 +1,135 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+func (db *DB) SetQuoteLiked(ctx context.Context, userID, quoteID uuid.UUID, liked bool) (int, error) {
+	ctx, done := db.write(ctx, "SetQuoteLiked")
+	defer done()
+
+	return db.setReaction(ctx, "quote_likes", "like_count", userID, quoteID, liked)
+}
+
+func (db *DB) SetQuoteFavorited(ctx context.Context, userID, quoteID uuid.UUID, favorited bool) (int, error) {
+	ctx, done := db.write(ctx, "SetQuoteFavorited")
+	defer done()
+
+	return db.setReaction(ctx, "quote_favorites", "favorite_count", userID, quoteID, favorited)
+}
+
+func (db *DB) setReaction(ctx context.Context, table, column string, userID, quoteID uuid.UUID, on bool) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		select s.is_public and ` + quoteIsLive + `
+		from quotes q
+		inner join quote_states s on q.state = s.id
+		where q.id = ?`
+
+	var isPublic bool
+	err = tx.QueryRowContext(ctx, query, quoteID).Scan(&isPublic)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, database.ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	if on && !isPublic {
+		return 0, database.ErrRecordNotFound
+	}
+
+	var result sql.Result
+	if on {
+		result, err = tx.ExecContext(ctx, fmt.Sprintf(`insert into %s (user_id, quote_id, created_at) values (?, ?, ?) on conflict do nothing`, table), userID, quoteID, time.Now().UTC())
+	} else {
+		result, err = tx.ExecContext(ctx, fmt.Sprintf(`delete from %s where user_id = ? and quote_id = ?`, table), userID, quoteID)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	delta := -1
+	if on {
+		delta = 1
+	}
+
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`update quotes set %[1]s = %[1]s + ? where id = ?`, column), delta, quoteID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx, fmt.Sprintf(`select %s from quotes where id = ?`, column), quoteID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (db *DB) GetQuoteReactions(ctx context.Context, userID uuid.UUID, quoteIDs []uuid.UUID) (map[uuid.UUID]database.Reaction, error) {
+	ctx, done := db.read(ctx, "GetQuoteReactions")
+	defer done()
+
+	reactions := make(map[uuid.UUID]database.Reaction)
+
+	query := `
+		select exists(select 1 from quote_likes where user_id = ? and quote_id = ?),
+		       exists(select 1 from quote_favorites where user_id = ? and quote_id = ?)`
+
+	for _, id := range quoteIDs {
+		var reaction database.Reaction
+
+		err := db.QueryRowContext(ctx, query, userID, id, userID, id).Scan(&reaction.Liked, &reaction.Favorited)
+		if err != nil {
+			return nil, err
+		}
+
+		if reaction.Liked || reaction.Favorited {
+			reactions[id] = reaction
+		}
+	}
+
+	return reactions, nil
+}
+
+func (db *DB) GetFavoriteQuotes(ctx context.Context, userID uuid.UUID, filters f.Filters) ([]database.Quote, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetFavoriteQuotes")
+	defer done()
+
+	query := `
+		select count(*) over(), ` + quoteColumns + `
+		from quote_favorites fav
+		inner join quotes q on fav.quote_id = q.id
+		inner join quote_states s on q.state = s.id and s.is_public = true
+		inner join photos p on q.photo_id = p.id
+		where fav.user_id = ?
+		and ` + quoteIsLive + `
+		order by fav.created_at desc, q.id
+		limit ? offset ?`
+
+	return db.queryQuotes(ctx, query, []interface{}{userID, filters.Limit(), filters.Offset()}, filters)
+}
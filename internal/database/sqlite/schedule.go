@@ -0,0 +1,94 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+func (db *DB) ScheduleQuote(ctx context.Context, id uuid.UUID, publishAt *time.Time) error {
+	ctx, done := db.write(ctx, "ScheduleQuote")
+	defer done()
+
+	var value interface{}
+	if publishAt != nil {
+		value = publishAt.UTC()
+	}
+
+	result, err := db.ExecContext(ctx, `update quotes set publish_at = ?, updated_at = ? where id = ?`, value, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected < 1 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) GetScheduledQuotes(ctx context.Context, filters f.Filters) ([]database.Quote, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetScheduledQuotes")
+	defer done()
+
+	query := `
+		select count(*) over(), ` + quoteColumns + `
+		from quotes q
+		inner join quote_states s on q.state = s.id
+		inner join photos p on q.photo_id = p.id
+		where q.publish_at is not null
+		order by q.publish_at, q.id
+		limit ? offset ?`
+
+	return db.queryQuotes(ctx, query, []interface{}{filters.Limit(), filters.Offset()}, filters)
+}
+
+func (db *DB) PublishDueQuotes(ctx context.Context) ([]database.Quote, error) {
+	ctx, done := db.write(ctx, "PublishDueQuotes")
+	defer done()
+
+	query := `
+		update quotes
+		set publish_at = null, updated_at = ?
+		where publish_at <= datetime('now')
+		and state in (select id from quote_states where is_public = true)
+		returning id`
+
+	rows, err := db.QueryContext(ctx, query, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	quotes := make([]database.Quote, 0, len(ids))
+
+	for _, id := range ids {
+		quote, err := db.GetQuoteById(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		quotes = append(quotes, *quote)
+	}
+
+	return quotes, nil
+}
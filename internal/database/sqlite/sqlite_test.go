@@ -0,0 +1,25 @@
+package sqlite_test
+
+import (
+	"io"
+	"testing"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/database/sqlite"
+	"javlonrahimov/quotes-api/internal/database/storetest"
+	"javlonrahimov/quotes-api/internal/leveledlog"
+)
+
+func TestStore(t *testing.T) {
+	logger := leveledlog.NewLogger(io.Discard, leveledlog.LevelAll, false)
+
+	storetest.Run(t, func(t *testing.T) database.Store {
+		db, err := sqlite.New(":memory:", true, database.Options{}, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		return db
+	})
+}
@@ -0,0 +1,189 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+
+	"github.com/google/uuid"
+)
+
+func (db *DB) InsertTranslation(ctx context.Context, originalID uuid.UUID, language, author, text string, userID uuid.UUID) (*database.Quote, error) {
+	ctx, done := db.write(ctx, "InsertTranslation")
+	defer done()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var originalLanguage string
+	var photoID uuid.UUID
+
+	query := `
+		select o.id, o.language, o.photo_id
+		from quotes q
+		inner join quotes o on o.id = coalesce(q.original_id, q.id)
+		where q.id = ?`
+
+	err = tx.QueryRowContext(ctx, query, originalID).Scan(&originalID, &originalLanguage, &photoID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if originalLanguage == language {
+		return nil, database.ErrDuplicateLanguage
+	}
+
+	var stateID uuid.UUID
+
+	err = tx.QueryRowContext(ctx, `select id from quote_states where is_default = true`).Scan(&stateID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrDefaultState
+		default:
+			return nil, err
+		}
+	}
+
+	id := uuid.New()
+	now := time.Now().UTC()
+
+	query = `
+		insert into quotes (id, created_at, updated_at, author, text, created_by, state, photo_id, language, original_id)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	args := []interface{}{id, now, now, author, text, userID, stateID, photoID, language, originalID}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "quotes.original_id"):
+			return nil, database.ErrDuplicateLanguage
+		default:
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `insert into quote_hashtags (quote_id, hashtag_id) select ?, hashtag_id from quote_hashtags where quote_id = ?`, id, originalID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return db.GetQuoteById(ctx, id)
+}
+
+func (db *DB) SetQuoteLanguage(ctx context.Context, id uuid.UUID, language string) error {
+	ctx, done := db.write(ctx, "SetQuoteLanguage")
+	defer done()
+
+	var taken bool
+
+	query := `
+		select exists (
+			select 1
+			from quotes q
+			inner join quotes o on o.id = q.original_id
+			where q.id = ? and o.language = ?)
+		or exists (
+			select 1
+			from quotes t
+			where t.original_id = ? and t.language = ?)`
+
+	err := db.QueryRowContext(ctx, query, id, language, id, language).Scan(&taken)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return database.ErrDuplicateLanguage
+	}
+
+	result, err := db.ExecContext(ctx, `update quotes set language = ?, updated_at = ? where id = ?`, language, time.Now().UTC(), id)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "quotes.original_id"):
+			return database.ErrDuplicateLanguage
+		default:
+			return err
+		}
+	}
+
+	if affected, _ := result.RowsAffected(); affected < 1 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) GetQuoteTranslations(ctx context.Context, originalIDs []uuid.UUID) (map[uuid.UUID][]database.Quote, error) {
+	ctx, done := db.read(ctx, "GetQuoteTranslations")
+	defer done()
+
+	translations := make(map[uuid.UUID][]database.Quote)
+	if len(originalIDs) == 0 {
+		return translations, nil
+	}
+
+	args := make([]interface{}, len(originalIDs))
+	for i := range originalIDs {
+		args[i] = originalIDs[i]
+	}
+
+	query := `
+		select ` + quoteColumns + `
+		from quotes q
+		inner join quote_states s on q.state = s.id and s.is_public = true
+		inner join photos p on q.photo_id = p.id
+		where q.original_id in (?` + strings.Repeat(", ?", len(originalIDs)-1) + `)
+		and ` + quoteIsLive + `
+		order by q.language`
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotes []database.Quote
+
+	for rows.Next() {
+		quote, err := scanQuote(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		quotes = append(quotes, quote)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, quote := range quotes {
+		quote.Hashtags, _, err = db.GetQuoteHashtags(ctx, quote.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		translations[*quote.OriginalID] = append(translations[*quote.OriginalID], quote)
+	}
+
+	return translations, nil
+}
@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+
+	"github.com/google/uuid"
+)
+
+func (db *DB) InsertUser(ctx context.Context, email, hashedPassword, name string) (*database.User, error) {
+	ctx, done := db.write(ctx, "InsertUser")
+	defer done()
+
+	user := &database.User{}
+
+	query := `
+		insert into users (id, created_at, email, hashed_password, name)
+		values (?, ?, ?, ?, ?)
+		returning id, created_at, email, name, is_activated`
+
+	err := db.QueryRowContext(ctx, query, uuid.New(), time.Now().UTC(), email, hashedPassword, name).Scan(
+		&user.ID, &user.CreatedAt, &user.Email, &user.Name, &user.IsActivated,
+	)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "users.email"):
+			return nil, database.ErrDuplicateEmail
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (db *DB) GetUser(ctx context.Context, id uuid.UUID) (*database.User, error) {
+	ctx, done := db.read(ctx, "GetUser")
+	defer done()
+
+	var user database.User
+
+	query := `select id, created_at, email, hashed_password, name, is_activated from users where id = ?`
+
+	err := db.GetContext(ctx, &user, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return &user, err
+}
+
+func (db *DB) GetUserByEmail(ctx context.Context, email string) (*database.User, error) {
+	ctx, done := db.read(ctx, "GetUserByEmail")
+	defer done()
+
+	var user database.User
+
+	query := `select id, created_at, email, hashed_password, name, is_activated from users where email = ?`
+
+	err := db.GetContext(ctx, &user, query, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, database.ErrRecordNotFound
+	}
+
+	return &user, err
+}
+
+func (db *DB) UpdateUserHashedPassword(ctx context.Context, id uuid.UUID, hashedPassword string) error {
+	ctx, done := db.write(ctx, "UpdateUserHashedPassword")
+	defer done()
+
+	query := `update users set hashed_password = ? where id = ?`
+
+	_, err := db.ExecContext(ctx, query, hashedPassword, id)
+	return err
+}
+
+func (db *DB) ActivateUser(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "ActivateUser")
+	defer done()
+
+	query := `update users set is_activated = true where id = ?`
+
+	_, err := db.ExecContext(ctx, query, id)
+	return err
+}
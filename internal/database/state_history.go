@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// QuoteStateChange records one move of a quote between states. ChangedBy is
+// nil for changes the system made on its own, such as hiding a quote that
+// was reported too often.
+type QuoteStateChange struct {
+	ID        uuid.UUID
+	QuoteID   uuid.UUID
+	FromState *QuoteState
+	ToState   *QuoteState
+	ChangedBy *uuid.UUID
+	Reason    string
+	CreatedAt time.Time
+}
+
+// QuoteStateHistoryStore is implemented by stores that keep a history of
+// quote state changes.
+type QuoteStateHistoryStore interface {
+	ChangeQuoteState(ctx context.Context, quoteID, stateID uuid.UUID, changedBy *uuid.UUID, reason string) (*QuoteStateChange, error)
+	GetQuoteStateHistory(ctx context.Context, quoteID uuid.UUID, filters f.Filters) ([]QuoteStateChange, f.Metadata, error)
+}
+
+var _ QuoteStateHistoryStore = (*DB)(nil)
+
+// ChangeQuoteState works like SetQuoteState but also records the change. It
+// returns a nil change when the quote already is in that state.
+func (db *DB) ChangeQuoteState(ctx context.Context, quoteID, stateID uuid.UUID, changedBy *uuid.UUID, reason string) (*QuoteStateChange, error) {
+	ctx, done := db.write(ctx, "ChangeQuoteState")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	change, err := changeQuoteState(ctx, tx, quoteID, stateID, changedBy, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	return change, tx.Commit()
+}
+
+func changeQuoteState(ctx context.Context, tx *sqlx.Tx, quoteID, stateID uuid.UUID, changedBy *uuid.UUID, reason string) (*QuoteStateChange, error) {
+	var exists bool
+
+	err := tx.QueryRowContext(ctx, `select exists (select id from quote_states where id = $1)`, stateID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrRecordNotFound
+	}
+
+	var fromState uuid.UUID
+
+	err = tx.QueryRowContext(ctx, `select state from quotes where id = $1 for update`, quoteID).Scan(&fromState)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if fromState == stateID {
+		return nil, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `update quotes set state = $1, updated_at = $2 where id = $3`, stateID, time.Now(), quoteID)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &QuoteStateChange{
+		ID:        uuid.New(),
+		QuoteID:   quoteID,
+		FromState: &QuoteState{ID: fromState},
+		ToState:   &QuoteState{ID: stateID},
+		ChangedBy: changedBy,
+		Reason:    reason,
+	}
+
+	query := `
+		insert into quote_state_changes (id, quote_id, from_state, to_state, changed_by, reason)
+		values ($1, $2, $3, $4, $5, $6)
+		returning created_at`
+
+	args := []interface{}{change.ID, quoteID, fromState, stateID, changedBy, reason}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&change.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// GetQuoteStateHistory lists the state changes of a quote, newest first.
+func (db *DB) GetQuoteStateHistory(ctx context.Context, quoteID uuid.UUID, filters f.Filters) ([]QuoteStateChange, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetQuoteStateHistory")
+	defer done()
+
+	query := `
+		select count(*) over(), c.id, c.quote_id, c.changed_by, c.reason, c.created_at,
+		       fs.id, fs.value, fs.is_default, fs.color, fs.is_public,
+		       ts.id, ts.value, ts.is_default, ts.color, ts.is_public
+		from quote_state_changes c
+		left join quote_states fs
+		on c.from_state = fs.id
+		left join quote_states ts
+		on c.to_state = ts.id
+		where c.quote_id = $1
+		order by c.created_at desc, c.id
+		limit $2 offset $3`
+
+	rows, err := db.QueryContext(ctx, query, quoteID, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	changes := make([]QuoteStateChange, 0)
+
+	for rows.Next() {
+		var change QuoteStateChange
+		var from, to nullQuoteState
+
+		err := rows.Scan(
+			&totalRecords, &change.ID, &change.QuoteID, &change.ChangedBy, &change.Reason, &change.CreatedAt,
+			&from.ID, &from.Value, &from.IsDefault, &from.Color, &from.IsPublic,
+			&to.ID, &to.Value, &to.IsDefault, &to.Color, &to.IsPublic,
+		)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		change.FromState, change.ToState = from.state(), to.state()
+		changes = append(changes, change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return changes, metadata, nil
+}
+
+// nullQuoteState scans a quote state from a left join, where a deleted state
+// comes back as nulls.
+type nullQuoteState struct {
+	ID        *uuid.UUID
+	Value     sql.NullString
+	IsDefault sql.NullBool
+	Color     sql.NullString
+	IsPublic  sql.NullBool
+}
+
+func (s nullQuoteState) state() *QuoteState {
+	if s.ID == nil {
+		return nil
+	}
+
+	return &QuoteState{
+		ID:        *s.ID,
+		Value:     s.Value.String,
+		IsDefault: s.IsDefault.Bool,
+		Color:     s.Color.String,
+		IsPublic:  s.IsPublic.Bool,
+	}
+}
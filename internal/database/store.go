@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+type QuoteStore interface {
+	InsertQuote(ctx context.Context, author, text string, userID, photoID uuid.UUID, hashtagIDs []uuid.UUID, stateID *uuid.UUID) (*Quote, error)
+	UpdateQuote(ctx context.Context, quoteID, photoID uuid.UUID, author, text string, hashtagIDs []uuid.UUID) (*Quote, error)
+	DeleteQuoteById(ctx context.Context, id uuid.UUID) error
+	GetQuoteById(ctx context.Context, id uuid.UUID) (*Quote, error)
+	GetQuoteByText(ctx context.Context, author, text string) (*Quote, error)
+	GetUserQuotes(ctx context.Context, userID uuid.UUID, author string, text string, state uuid.UUID, attribution AttributionFilter, filters f.Filters) ([]Quote, f.Metadata, error)
+	GetQuotes(ctx context.Context, author string, text string, attribution AttributionFilter, filters f.Filters) ([]Quote, f.Metadata, error)
+	SetQuoteState(ctx context.Context, id, stateID uuid.UUID) error
+	SetQuoteSource(ctx context.Context, id uuid.UUID, source *QuoteSource) error
+	SetQuoteVerification(ctx context.Context, id uuid.UUID, status string, userID uuid.UUID) error
+	IsExistsWithThisState(ctx context.Context, quoteStateID uuid.UUID) bool
+	ImportQuotes(ctx context.Context, userID uuid.UUID, quotes []QuoteImport) ([]ImportedQuote, error)
+	ExportQuotes(ctx context.Context, filter QuoteFilter, fn func(*Quote) error) error
+	SampleQuotes(ctx context.Context, filter SampleFilter) ([]Quote, error)
+}
+
+type ReactionStore interface {
+	SetQuoteLiked(ctx context.Context, userID, quoteID uuid.UUID, liked bool) (int, error)
+	SetQuoteFavorited(ctx context.Context, userID, quoteID uuid.UUID, favorited bool) (int, error)
+	GetQuoteReactions(ctx context.Context, userID uuid.UUID, quoteIDs []uuid.UUID) (map[uuid.UUID]Reaction, error)
+	GetFavoriteQuotes(ctx context.Context, userID uuid.UUID, filters f.Filters) ([]Quote, f.Metadata, error)
+}
+
+// TranslationStore links quotes to their translations. A translation is a
+// quote of its own, moderated like any other, whose OriginalID points at the
+// quote it translates; each original has at most one translation per
+// language.
+type TranslationStore interface {
+	InsertTranslation(ctx context.Context, originalID uuid.UUID, language, author, text string, userID uuid.UUID) (*Quote, error)
+	SetQuoteLanguage(ctx context.Context, id uuid.UUID, language string) error
+	GetQuoteTranslations(ctx context.Context, originalIDs []uuid.UUID) (map[uuid.UUID][]Quote, error)
+}
+
+// ScheduleStore holds quotes back from public reads until their publish
+// time. PublishDueQuotes clears the publish time of the public quotes whose
+// time has come and returns them, so that they can be announced once.
+type ScheduleStore interface {
+	ScheduleQuote(ctx context.Context, id uuid.UUID, publishAt *time.Time) error
+	GetScheduledQuotes(ctx context.Context, filters f.Filters) ([]Quote, f.Metadata, error)
+	PublishDueQuotes(ctx context.Context) ([]Quote, error)
+}
+
+type UserStore interface {
+	InsertUser(ctx context.Context, email, hashedPassword, name string) (*User, error)
+	GetUser(ctx context.Context, id uuid.UUID) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	UpdateUserHashedPassword(ctx context.Context, id uuid.UUID, hashedPassword string) error
+	ActivateUser(ctx context.Context, id uuid.UUID) error
+}
+
+type OTPStore interface {
+	InsertOTP(ctx context.Context, otp *OTP) error
+	GetOTPForEmail(ctx context.Context, email string, scope string) (*OTP, error)
+	DeleteAllOTPForUser(ctx context.Context, userID uuid.UUID, scope string) error
+	NewOtp(ctx context.Context, userID uuid.UUID, ttl time.Duration, scope string) (*OTP, error)
+}
+
+type HashtagStore interface {
+	InsertHashtag(ctx context.Context, value string) (*Hashtag, error)
+	GetHashtagByValue(ctx context.Context, value string) (*Hashtag, error)
+	DeleteHashtagById(ctx context.Context, id uuid.UUID) error
+	IsQuoteExistsWithThisHashtag(ctx context.Context, hashtagID uuid.UUID) bool
+	GetQuoteHashtags(ctx context.Context, quoteID uuid.UUID) ([]Hashtag, f.Metadata, error)
+	GetHashtags(ctx context.Context, filters f.Filters) ([]Hashtag, f.Metadata, error)
+}
+
+type PhotoStore interface {
+	InsertPhoto(ctx context.Context, color, blurHash, author, url string) (*Photo, error)
+	GetPhotoById(ctx context.Context, id uuid.UUID) (*Photo, error)
+	GetPhotoByUrl(ctx context.Context, url string) (*Photo, error)
+	GetPhotos(ctx context.Context) ([]*Photo, f.Metadata, error)
+}
+
+type QuoteStateStore interface {
+	InsertQuoteState(ctx context.Context, state *QuoteState) error
+	DeleteQuoteStateById(ctx context.Context, id uuid.UUID) error
+	ExistsQuoteStateById(ctx context.Context, id uuid.UUID) bool
+	SetDefaultQuoteState(ctx context.Context, id uuid.UUID) error
+	GetAllQuoteStates(ctx context.Context) ([]QuoteState, f.Metadata, error)
+}
+
+type PermissionStore interface {
+	GetAllPermissionsForUser(ctx context.Context, userID uuid.UUID) ([]string, error)
+	AddPermissionForUser(ctx context.Context, userID uuid.UUID, codes ...string) error
+}
+
+// Store is everything the API needs from the data layer. *DB is the Postgres
+// implementation; internal/database/memory provides one for tests.
+type Store interface {
+	QuoteStore
+	UserStore
+	OTPStore
+	HashtagStore
+	PhotoStore
+	QuoteStateStore
+	PermissionStore
+	ReactionStore
+	TranslationStore
+	ScheduleStore
+}
+
+// Resetter is implemented by stores that can wipe their data (users, quotes,
+// hashtags and photos) while keeping the schema and the quote states and
+// permissions that the migrations seed. It exists for development databases.
+type Resetter interface {
+	Reset(ctx context.Context) error
+}
+
+var (
+	_ Store    = (*DB)(nil)
+	_ Resetter = (*DB)(nil)
+)
@@ -0,0 +1,715 @@
+// Package storetest is a contract test suite for database.Store
+// implementations. Every backend runs the same tests, so that the API behaves
+// the same on Postgres, SQLite and in memory.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/database"
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+// Run runs the suite. open returns an empty store, holding only what the
+// migrations seed, and is called once per test.
+func Run(t *testing.T, open func(t *testing.T) database.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s database.Store)
+	}{
+		{"Users", testUsers},
+		{"OTPs", testOTPs},
+		{"Permissions", testPermissions},
+		{"Hashtags", testHashtags},
+		{"Photos", testPhotos},
+		{"QuoteStates", testQuoteStates},
+		{"Quotes", testQuotes},
+		{"QuoteVisibility", testQuoteVisibility},
+		{"Sample", testSample},
+		{"Reactions", testReactions},
+		{"Schedule", testSchedule},
+		{"Translations", testTranslations},
+		{"Import", testImport},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+var ctx = context.Background()
+
+func page(sort string) f.Filters {
+	return f.Filters{Page: 1, PageSize: 50, Sort: sort, SortSafeList: []string{sort}}
+}
+
+func ok(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantErr(t *testing.T, what string, err, want error) {
+	t.Helper()
+
+	if !errors.Is(err, want) {
+		t.Fatalf("%s: got error %v, want %v", what, err, want)
+	}
+}
+
+func ids(quotes []database.Quote) map[uuid.UUID]bool {
+	set := make(map[uuid.UUID]bool)
+	for _, q := range quotes {
+		set[q.ID] = true
+	}
+	return set
+}
+
+// fixture holds the records most quote tests need.
+type fixture struct {
+	user    *database.User
+	photo   *database.Photo
+	hashtag *database.Hashtag
+	public  database.QuoteState
+	hidden  database.QuoteState
+}
+
+func newFixture(t *testing.T, s database.Store) *fixture {
+	t.Helper()
+
+	var fx fixture
+	var err error
+
+	fx.user, err = s.InsertUser(ctx, "fixture@example.org", "hash", "Fixture")
+	ok(t, err)
+
+	fx.photo, err = s.InsertPhoto(ctx, "#FFFFFF", "blur", "Photographer", "https://example.org/fixture.jpg")
+	ok(t, err)
+
+	fx.hashtag, err = s.InsertHashtag(ctx, "fixture")
+	ok(t, err)
+
+	states, _, err := s.GetAllQuoteStates(ctx)
+	ok(t, err)
+
+	for _, state := range states {
+		switch {
+		case state.IsPublic && fx.public.ID == uuid.Nil:
+			fx.public = state
+		case !state.IsPublic && !state.IsDefault && fx.hidden.ID == uuid.Nil:
+			fx.hidden = state
+		}
+	}
+
+	if fx.public.ID == uuid.Nil || fx.hidden.ID == uuid.Nil {
+		t.Fatalf("the store seeds no public or no hidden non-default state: %+v", states)
+	}
+
+	return &fx
+}
+
+// quote inserts a quote in state, or in the default state when state is nil.
+func (fx *fixture) quote(t *testing.T, s database.Store, text string, state *database.QuoteState) *database.Quote {
+	t.Helper()
+
+	var stateID *uuid.UUID
+	if state != nil {
+		stateID = &state.ID
+	}
+
+	quote, err := s.InsertQuote(ctx, "Seneca", text, fx.user.ID, fx.photo.ID, []uuid.UUID{fx.hashtag.ID}, stateID)
+	ok(t, err)
+
+	return quote
+}
+
+func testUsers(t *testing.T, s database.Store) {
+	user, err := s.InsertUser(ctx, "user@example.org", "hash", "User")
+	ok(t, err)
+
+	if user.ID == uuid.Nil || user.IsActivated {
+		t.Fatalf("InsertUser = %+v, want an ID and not activated", user)
+	}
+
+	_, err = s.InsertUser(ctx, "user@example.org", "hash", "Other")
+	wantErr(t, "InsertUser with a used email", err, database.ErrDuplicateEmail)
+
+	ok(t, s.ActivateUser(ctx, user.ID))
+	ok(t, s.UpdateUserHashedPassword(ctx, user.ID, "new-hash"))
+
+	got, err := s.GetUserByEmail(ctx, "user@example.org")
+	ok(t, err)
+
+	if got.ID != user.ID || !got.IsActivated || got.HashedPassword != "new-hash" {
+		t.Fatalf("GetUserByEmail = %+v, want the activated user with the new hash", got)
+	}
+
+	got, err = s.GetUser(ctx, user.ID)
+	ok(t, err)
+
+	if got.Email != "user@example.org" || got.Name != "User" {
+		t.Fatalf("GetUser = %+v", got)
+	}
+
+	// GetUser reports a missing user with a nil user rather than an error.
+	got, err = s.GetUser(ctx, uuid.New())
+	if got != nil || err != nil {
+		t.Fatalf("GetUser of a missing user = %+v, %v, want nil, nil", got, err)
+	}
+
+	_, err = s.GetUserByEmail(ctx, "nobody@example.org")
+	wantErr(t, "GetUserByEmail of a missing user", err, database.ErrRecordNotFound)
+}
+
+func testOTPs(t *testing.T, s database.Store) {
+	user, err := s.InsertUser(ctx, "otp@example.org", "hash", "OTP")
+	ok(t, err)
+
+	otp, err := s.NewOtp(ctx, user.ID, time.Hour, "authentication")
+	ok(t, err)
+
+	if otp.Plaintext == "" {
+		t.Fatal("NewOtp returned no plaintext")
+	}
+
+	got, err := s.GetOTPForEmail(ctx, "otp@example.org", "authentication")
+	ok(t, err)
+
+	if got.Hash != otp.Hash || got.UserID != user.ID {
+		t.Fatalf("GetOTPForEmail = %+v, want %+v", got, otp)
+	}
+
+	_, err = s.GetOTPForEmail(ctx, "otp@example.org", "password-reset")
+	wantErr(t, "GetOTPForEmail in another scope", err, database.ErrRecordNotFound)
+
+	ok(t, s.DeleteAllOTPForUser(ctx, user.ID, "authentication"))
+
+	_, err = s.GetOTPForEmail(ctx, "otp@example.org", "authentication")
+	wantErr(t, "GetOTPForEmail after DeleteAllOTPForUser", err, database.ErrRecordNotFound)
+}
+
+func testPermissions(t *testing.T, s database.Store) {
+	user, err := s.InsertUser(ctx, "perm@example.org", "hash", "Perm")
+	ok(t, err)
+
+	ok(t, s.AddPermissionForUser(ctx, user.ID, "quotes:state"))
+	ok(t, s.AddPermissionForUser(ctx, user.ID, "quotes:state"))
+
+	got, err := s.GetAllPermissionsForUser(ctx, user.ID)
+	ok(t, err)
+
+	if len(got) != 1 || got[0] != "quotes:state" {
+		t.Fatalf("GetAllPermissionsForUser = %v, want [quotes:state]", got)
+	}
+}
+
+func testHashtags(t *testing.T, s database.Store) {
+	hashtag, err := s.InsertHashtag(ctx, "stoicism")
+	ok(t, err)
+
+	_, err = s.InsertHashtag(ctx, "stoicism")
+	wantErr(t, "InsertHashtag with a used value", err, database.ErrDuplicateHashtag)
+
+	got, err := s.GetHashtagByValue(ctx, "stoicism")
+	ok(t, err)
+
+	if got.ID != hashtag.ID {
+		t.Fatalf("GetHashtagByValue = %+v, want %+v", got, hashtag)
+	}
+
+	if s.IsQuoteExistsWithThisHashtag(ctx, hashtag.ID) {
+		t.Fatal("IsQuoteExistsWithThisHashtag = true for an unused hashtag")
+	}
+
+	ok(t, s.DeleteHashtagById(ctx, hashtag.ID))
+
+	_, err = s.GetHashtagByValue(ctx, "stoicism")
+	wantErr(t, "GetHashtagByValue after delete", err, database.ErrRecordNotFound)
+}
+
+func testPhotos(t *testing.T, s database.Store) {
+	photo, err := s.InsertPhoto(ctx, "#000000", "blur", "Photographer", "https://example.org/photo.jpg")
+	ok(t, err)
+
+	got, err := s.GetPhotoById(ctx, photo.ID)
+	ok(t, err)
+
+	if got.Url != photo.Url || got.Color != "#000000" {
+		t.Fatalf("GetPhotoById = %+v, want %+v", got, photo)
+	}
+
+	got, err = s.GetPhotoByUrl(ctx, "https://example.org/photo.jpg")
+	ok(t, err)
+
+	if got.ID != photo.ID {
+		t.Fatalf("GetPhotoByUrl = %+v, want %+v", got, photo)
+	}
+
+	_, err = s.GetPhotoById(ctx, uuid.New())
+	wantErr(t, "GetPhotoById of a missing photo", err, database.ErrRecordNotFound)
+}
+
+func testQuoteStates(t *testing.T, s database.Store) {
+	state := &database.QuoteState{Value: "archived", Color: "#123456"}
+	ok(t, s.InsertQuoteState(ctx, state))
+
+	if !s.ExistsQuoteStateById(ctx, state.ID) {
+		t.Fatal("ExistsQuoteStateById = false for a new state")
+	}
+
+	err := s.InsertQuoteState(ctx, &database.QuoteState{Value: "archived", Color: "#654321"})
+	wantErr(t, "InsertQuoteState with a used value", err, database.ErrDuplicateQuoteState)
+
+	states, _, err := s.GetAllQuoteStates(ctx)
+	ok(t, err)
+
+	var defaults int
+	var previous uuid.UUID
+	for _, st := range states {
+		if st.IsDefault {
+			defaults++
+			previous = st.ID
+		}
+	}
+	if defaults != 1 {
+		t.Fatalf("GetAllQuoteStates holds %d default states, want 1", defaults)
+	}
+
+	ok(t, s.SetDefaultQuoteState(ctx, state.ID))
+
+	err = s.DeleteQuoteStateById(ctx, state.ID)
+	wantErr(t, "DeleteQuoteStateById of the default state", err, database.ErrDefaultState)
+
+	ok(t, s.SetDefaultQuoteState(ctx, previous))
+	ok(t, s.DeleteQuoteStateById(ctx, state.ID))
+
+	if s.ExistsQuoteStateById(ctx, state.ID) {
+		t.Fatal("ExistsQuoteStateById = true after delete")
+	}
+}
+
+func testQuotes(t *testing.T, s database.Store) {
+	fx := newFixture(t, s)
+
+	quote := fx.quote(t, s, "Luck is what happens when preparation meets opportunity.", nil)
+
+	if !quote.State.IsDefault {
+		t.Fatalf("InsertQuote without a state put the quote in %q, want the default state", quote.State.Value)
+	}
+	if len(quote.Hashtags) != 1 || quote.Hashtags[0].ID != fx.hashtag.ID {
+		t.Fatalf("InsertQuote hashtags = %+v, want [%s]", quote.Hashtags, fx.hashtag.Value)
+	}
+	if quote.Verification != database.VerificationUnverified {
+		t.Fatalf("InsertQuote verification = %q, want %q", quote.Verification, database.VerificationUnverified)
+	}
+
+	got, err := s.GetQuoteById(ctx, quote.ID)
+	ok(t, err)
+
+	if got.Text != quote.Text || got.Author != "Seneca" || got.Photo == nil || got.Photo.ID != fx.photo.ID || got.CreatedBy != fx.user.ID {
+		t.Fatalf("GetQuoteById = %+v, want %+v", got, quote)
+	}
+
+	got, err = s.GetQuoteByText(ctx, "Seneca", quote.Text)
+	ok(t, err)
+
+	if got.ID != quote.ID {
+		t.Fatalf("GetQuoteByText = %s, want %s", got.ID, quote.ID)
+	}
+
+	other, err := s.InsertHashtag(ctx, "other")
+	ok(t, err)
+
+	updated, err := s.UpdateQuote(ctx, quote.ID, fx.photo.ID, "Lucius Seneca", "Updated text of the quote.", []uuid.UUID{other.ID})
+	ok(t, err)
+
+	if updated.Author != "Lucius Seneca" || updated.Text != "Updated text of the quote." || len(updated.Hashtags) != 1 || updated.Hashtags[0].ID != other.ID {
+		t.Fatalf("UpdateQuote = %+v", updated)
+	}
+
+	if !s.IsQuoteExistsWithThisHashtag(ctx, other.ID) || s.IsQuoteExistsWithThisHashtag(ctx, fx.hashtag.ID) {
+		t.Fatal("IsQuoteExistsWithThisHashtag does not follow UpdateQuote")
+	}
+
+	ok(t, s.SetQuoteState(ctx, quote.ID, fx.public.ID))
+
+	got, err = s.GetQuoteById(ctx, quote.ID)
+	ok(t, err)
+
+	if got.State.ID != fx.public.ID {
+		t.Fatalf("state after SetQuoteState = %q, want %q", got.State.Value, fx.public.Value)
+	}
+	if !s.IsExistsWithThisState(ctx, fx.public.ID) {
+		t.Fatal("IsExistsWithThisState = false for a used state")
+	}
+
+	year := 65
+	ok(t, s.SetQuoteSource(ctx, quote.ID, &database.QuoteSource{Type: "book", Title: "Letters", Year: &year}))
+	ok(t, s.SetQuoteVerification(ctx, quote.ID, database.VerificationVerified, fx.user.ID))
+
+	got, err = s.GetQuoteById(ctx, quote.ID)
+	ok(t, err)
+
+	if got.Source.Title != "Letters" || got.Source.Year == nil || *got.Source.Year != 65 {
+		t.Fatalf("source after SetQuoteSource = %+v", got.Source)
+	}
+	if got.Verification != database.VerificationVerified || got.VerifiedAt == nil || got.VerifiedBy == nil || *got.VerifiedBy != fx.user.ID {
+		t.Fatalf("verification after SetQuoteVerification = %q by %v at %v", got.Verification, got.VerifiedBy, got.VerifiedAt)
+	}
+
+	ok(t, s.DeleteQuoteById(ctx, quote.ID))
+
+	_, err = s.GetQuoteById(ctx, quote.ID)
+	wantErr(t, "GetQuoteById after delete", err, database.ErrRecordNotFound)
+
+	err = s.DeleteQuoteById(ctx, quote.ID)
+	wantErr(t, "DeleteQuoteById twice", err, database.ErrRecordNotFound)
+
+	err = s.SetQuoteState(ctx, uuid.New(), fx.public.ID)
+	wantErr(t, "SetQuoteState of a missing quote", err, database.ErrRecordNotFound)
+}
+
+func testQuoteVisibility(t *testing.T, s database.Store) {
+	fx := newFixture(t, s)
+
+	pending := fx.quote(t, s, "A pending quote waiting for moderation.", nil)
+	public := fx.quote(t, s, "A public quote everybody may read.", &fx.public)
+	hidden := fx.quote(t, s, "A hidden quote nobody should read.", &fx.hidden)
+
+	quotes, metadata, err := s.GetQuotes(ctx, "", "", database.AttributionFilter{}, page("id"))
+	ok(t, err)
+
+	if got := ids(quotes); len(got) != 1 || !got[public.ID] || metadata.TotalRecords != 1 {
+		t.Fatalf("GetQuotes = %d quotes (%d in total), want only the public one", len(quotes), metadata.TotalRecords)
+	}
+
+	quotes, _, err = s.GetQuotes(ctx, "", "nobody", database.AttributionFilter{}, page("id"))
+	ok(t, err)
+
+	if len(quotes) != 0 {
+		t.Fatalf("GetQuotes matching the hidden quote = %d quotes, want none", len(quotes))
+	}
+
+	quotes, _, err = s.GetUserQuotes(ctx, fx.user.ID, "", "", pending.State.ID, database.AttributionFilter{}, page("created_at"))
+	ok(t, err)
+
+	if got := ids(quotes); len(got) != 1 || !got[pending.ID] {
+		t.Fatalf("GetUserQuotes in the default state = %d quotes, want the pending one", len(quotes))
+	}
+
+	quotes, _, err = s.GetUserQuotes(ctx, fx.user.ID, "", "", fx.hidden.ID, database.AttributionFilter{}, page("created_at"))
+	ok(t, err)
+
+	if got := ids(quotes); len(got) != 1 || !got[hidden.ID] {
+		t.Fatalf("GetUserQuotes in the hidden state = %d quotes, want the hidden one", len(quotes))
+	}
+
+	var exported []uuid.UUID
+	err = s.ExportQuotes(ctx, database.QuoteFilter{}, func(q *database.Quote) error {
+		exported = append(exported, q.ID)
+		return nil
+	})
+	ok(t, err)
+
+	if len(exported) != 1 || exported[0] != public.ID {
+		t.Fatalf("ExportQuotes = %v, want only the public quote", exported)
+	}
+}
+
+func testSample(t *testing.T, s database.Store) {
+	fx := newFixture(t, s)
+
+	tagged, err := s.InsertHashtag(ctx, "tagged")
+	ok(t, err)
+
+	public := make(map[uuid.UUID]bool)
+	for _, text := range []string{"The first public quote.", "The second public quote.", "The third public quote."} {
+		public[fx.quote(t, s, text, &fx.public).ID] = true
+	}
+
+	quote, err := s.InsertQuote(ctx, "Seneca", "The tagged public quote.", fx.user.ID, fx.photo.ID, []uuid.UUID{tagged.ID}, &fx.public.ID)
+	ok(t, err)
+	public[quote.ID] = true
+
+	fx.quote(t, s, "A pending quote that is never drawn.", nil)
+
+	future := time.Now().Add(time.Hour)
+	scheduled := fx.quote(t, s, "A scheduled quote that is not drawn yet.", &fx.public)
+	ok(t, s.ScheduleQuote(ctx, scheduled.ID, &future))
+
+	// A public translation comes with its original, never on its own.
+	translation, err := s.InsertTranslation(ctx, quote.ID, "de", "Seneca", "Das markierte öffentliche Zitat.", fx.user.ID)
+	ok(t, err)
+	ok(t, s.SetQuoteState(ctx, translation.ID, fx.public.ID))
+
+	// The keys are random, so draw often enough for probes to land past the
+	// highest key and on the same quote.
+	for i := 0; i < 50; i++ {
+		sample, err := s.SampleQuotes(ctx, database.SampleFilter{Size: 3})
+		ok(t, err)
+
+		got := ids(sample)
+		if len(sample) != 3 || len(got) != 3 {
+			t.Fatalf("SampleQuotes of 3 out of 4 = %d quotes, %d distinct, want 3", len(sample), len(got))
+		}
+		for id := range got {
+			if !public[id] {
+				t.Fatalf("SampleQuotes drew %s, which is not public and live", id)
+			}
+		}
+
+		sample, err = s.SampleQuotes(ctx, database.SampleFilter{Size: 10})
+		ok(t, err)
+
+		if got := ids(sample); len(sample) != 4 || len(got) != 4 {
+			t.Fatalf("SampleQuotes of 10 out of 4 = %d quotes, %d distinct, want all 4", len(sample), len(got))
+		}
+
+		sample, err = s.SampleQuotes(ctx, database.SampleFilter{Size: 10, Exclude: []uuid.UUID{quote.ID}})
+		ok(t, err)
+
+		if got := ids(sample); len(sample) != 3 || got[quote.ID] {
+			t.Fatalf("SampleQuotes excluding one of 4 = %d quotes, want the other 3", len(sample))
+		}
+
+		sample, err = s.SampleQuotes(ctx, database.SampleFilter{Size: 3, Hashtag: "tagged"})
+		ok(t, err)
+
+		if len(sample) != 1 || sample[0].ID != quote.ID {
+			t.Fatalf("SampleQuotes by hashtag = %d quotes, want the tagged one", len(sample))
+		}
+	}
+}
+
+func testReactions(t *testing.T, s database.Store) {
+	fx := newFixture(t, s)
+
+	public := fx.quote(t, s, "A public quote to like and favorite.", &fx.public)
+	pending := fx.quote(t, s, "A pending quote nobody can like yet.", nil)
+
+	fan, err := s.InsertUser(ctx, "fan@example.org", "hash", "Fan")
+	ok(t, err)
+
+	_, err = s.SetQuoteLiked(ctx, fx.user.ID, public.ID, true)
+	ok(t, err)
+
+	count, err := s.SetQuoteLiked(ctx, fx.user.ID, public.ID, true)
+	ok(t, err)
+
+	if count != 1 {
+		t.Fatalf("liking twice gives a count of %d, want 1", count)
+	}
+
+	count, err = s.SetQuoteLiked(ctx, fan.ID, public.ID, true)
+	ok(t, err)
+
+	if count != 2 {
+		t.Fatalf("a second user's like gives a count of %d, want 2", count)
+	}
+
+	_, err = s.SetQuoteLiked(ctx, fx.user.ID, pending.ID, true)
+	wantErr(t, "liking a quote that isn't public", err, database.ErrRecordNotFound)
+
+	_, err = s.SetQuoteLiked(ctx, fx.user.ID, uuid.New(), true)
+	wantErr(t, "liking a missing quote", err, database.ErrRecordNotFound)
+
+	_, err = s.SetQuoteFavorited(ctx, fan.ID, public.ID, true)
+	ok(t, err)
+
+	got, err := s.GetQuoteById(ctx, public.ID)
+	ok(t, err)
+
+	if got.LikeCount != 2 || got.FavoriteCount != 1 {
+		t.Fatalf("counts = %d likes and %d favorites, want 2 and 1", got.LikeCount, got.FavoriteCount)
+	}
+
+	reactions, err := s.GetQuoteReactions(ctx, fan.ID, []uuid.UUID{public.ID, pending.ID})
+	ok(t, err)
+
+	if r := reactions[public.ID]; !r.Liked || !r.Favorited {
+		t.Fatalf("GetQuoteReactions = %+v, want liked and favorited", reactions)
+	}
+	if r := reactions[pending.ID]; r.Liked || r.Favorited {
+		t.Fatalf("GetQuoteReactions = %+v for a quote without reactions", r)
+	}
+
+	favorites, _, err := s.GetFavoriteQuotes(ctx, fan.ID, page("favorited_at"))
+	ok(t, err)
+
+	if len(favorites) != 1 || favorites[0].ID != public.ID {
+		t.Fatalf("GetFavoriteQuotes = %d quotes, want the favorited one", len(favorites))
+	}
+
+	_, err = s.SetQuoteLiked(ctx, fx.user.ID, public.ID, false)
+	ok(t, err)
+
+	count, err = s.SetQuoteLiked(ctx, fx.user.ID, public.ID, false)
+	ok(t, err)
+
+	if count != 1 {
+		t.Fatalf("unliking twice gives a count of %d, want 1", count)
+	}
+}
+
+func testSchedule(t *testing.T, s database.Store) {
+	fx := newFixture(t, s)
+
+	later := fx.quote(t, s, "A public quote held back until later.", &fx.public)
+	due := fx.quote(t, s, "A public quote whose time has come.", &fx.public)
+	pending := fx.quote(t, s, "A pending quote with a past publish time.", nil)
+
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+
+	ok(t, s.ScheduleQuote(ctx, later.ID, &future))
+	ok(t, s.ScheduleQuote(ctx, due.ID, &past))
+	ok(t, s.ScheduleQuote(ctx, pending.ID, &past))
+
+	err := s.ScheduleQuote(ctx, uuid.New(), &future)
+	wantErr(t, "ScheduleQuote of a missing quote", err, database.ErrRecordNotFound)
+
+	got, err := s.GetQuoteById(ctx, later.ID)
+	ok(t, err)
+
+	if !got.IsScheduled() {
+		t.Fatalf("scheduled quote has publish time %v, want one in the future", got.PublishAt)
+	}
+
+	quotes, _, err := s.GetQuotes(ctx, "", "", database.AttributionFilter{}, page("id"))
+	ok(t, err)
+
+	if got := ids(quotes); got[later.ID] {
+		t.Fatal("GetQuotes lists a quote scheduled for later")
+	}
+
+	scheduled, _, err := s.GetScheduledQuotes(ctx, page("publish_at"))
+	ok(t, err)
+
+	if len(scheduled) != 3 {
+		t.Fatalf("GetScheduledQuotes = %d quotes, want 3", len(scheduled))
+	}
+
+	published, err := s.PublishDueQuotes(ctx)
+	ok(t, err)
+
+	if got := ids(published); len(got) != 1 || !got[due.ID] {
+		t.Fatalf("PublishDueQuotes = %d quotes, want only the due public one", len(published))
+	}
+
+	got, err = s.GetQuoteById(ctx, due.ID)
+	ok(t, err)
+
+	if got.PublishAt != nil {
+		t.Fatalf("published quote keeps publish time %v", got.PublishAt)
+	}
+
+	published, err = s.PublishDueQuotes(ctx)
+	ok(t, err)
+
+	if len(published) != 0 {
+		t.Fatalf("PublishDueQuotes again = %d quotes, want none", len(published))
+	}
+
+	ok(t, s.ScheduleQuote(ctx, later.ID, nil))
+
+	got, err = s.GetQuoteById(ctx, later.ID)
+	ok(t, err)
+
+	if got.PublishAt != nil {
+		t.Fatalf("unscheduled quote keeps publish time %v", got.PublishAt)
+	}
+}
+
+func testTranslations(t *testing.T, s database.Store) {
+	fx := newFixture(t, s)
+
+	original := fx.quote(t, s, "The original text of a translated quote.", &fx.public)
+	ok(t, s.SetQuoteLanguage(ctx, original.ID, "en"))
+
+	translation, err := s.InsertTranslation(ctx, original.ID, "de", "Seneca", "Der übersetzte Text eines Zitats.", fx.user.ID)
+	ok(t, err)
+
+	if translation.OriginalID == nil || *translation.OriginalID != original.ID || translation.Language != "de" {
+		t.Fatalf("InsertTranslation = %+v", translation)
+	}
+
+	_, err = s.InsertTranslation(ctx, original.ID, "de", "Seneca", "Noch eine deutsche Übersetzung.", fx.user.ID)
+	wantErr(t, "InsertTranslation in a used language", err, database.ErrDuplicateLanguage)
+
+	_, err = s.InsertTranslation(ctx, original.ID, "en", "Seneca", "The original's own language.", fx.user.ID)
+	wantErr(t, "InsertTranslation in the original's language", err, database.ErrDuplicateLanguage)
+
+	_, err = s.InsertTranslation(ctx, uuid.New(), "fr", "Seneca", "Le texte d'une citation absente.", fx.user.ID)
+	wantErr(t, "InsertTranslation of a missing quote", err, database.ErrRecordNotFound)
+
+	translations, err := s.GetQuoteTranslations(ctx, []uuid.UUID{original.ID})
+	ok(t, err)
+
+	if len(translations[original.ID]) != 0 {
+		t.Fatal("GetQuoteTranslations lists a translation that isn't public")
+	}
+
+	ok(t, s.SetQuoteState(ctx, translation.ID, fx.public.ID))
+
+	translations, err = s.GetQuoteTranslations(ctx, []uuid.UUID{original.ID})
+	ok(t, err)
+
+	if got := translations[original.ID]; len(got) != 1 || got[0].ID != translation.ID {
+		t.Fatalf("GetQuoteTranslations = %+v, want the public translation", got)
+	}
+
+	for _, originals := range []bool{false, true} {
+		exported := make(map[uuid.UUID]bool)
+		err = s.ExportQuotes(ctx, database.QuoteFilter{Originals: originals}, func(q *database.Quote) error {
+			exported[q.ID] = true
+			return nil
+		})
+		ok(t, err)
+
+		if !exported[original.ID] || exported[translation.ID] == originals {
+			t.Fatalf("ExportQuotes with Originals %v = %v, want the original and the translation only without", originals, exported)
+		}
+	}
+}
+
+func testImport(t *testing.T, s database.Store) {
+	fx := newFixture(t, s)
+
+	existing := fx.quote(t, s, "A quote that is already in the store.", nil)
+
+	imported, err := s.ImportQuotes(ctx, fx.user.ID, []database.QuoteImport{
+		{
+			Author: existing.Author,
+			Text:   existing.Text,
+			Photo:  *fx.photo,
+		},
+		{
+			Author:   "Epictetus",
+			Text:     "A new quote brought in by the import.",
+			Photo:    database.Photo{Url: "https://example.org/imported.jpg", Color: "#FFFFFF", BlurHash: "blur", Author: "Photographer"},
+			Hashtags: []string{"imported"},
+		},
+	})
+	ok(t, err)
+
+	if len(imported) != 2 || !imported[0].Duplicate || imported[0].QuoteID != existing.ID || imported[1].Duplicate {
+		t.Fatalf("ImportQuotes = %+v, want the existing quote as a duplicate and a new one", imported)
+	}
+
+	quote, err := s.GetQuoteById(ctx, imported[1].QuoteID)
+	ok(t, err)
+
+	if !quote.State.IsDefault || len(quote.Hashtags) != 1 || quote.Hashtags[0].Value != "imported" || quote.Photo == nil || quote.Photo.Url != "https://example.org/imported.jpg" {
+		t.Fatalf("imported quote = %+v", quote)
+	}
+}
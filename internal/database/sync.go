@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Entities of a ChangeSet, as named in tombstones.
+const (
+	SyncQuote   = "quote"
+	SyncHashtag = "hashtag"
+	SyncPhoto   = "photo"
+	SyncState   = "state"
+)
+
+// Tombstone tells a client to drop an entity it may have cached: the row was
+// deleted or, for quotes, is no longer public.
+type Tombstone struct {
+	Entity string
+	ID     uuid.UUID
+}
+
+// SyncCursor is how far a client has synced. Changes are ordered by the ID of
+// the transaction that wrote them, then by sync sequence number.
+type SyncCursor struct {
+	XID int64
+	Seq int64
+}
+
+// ChangeSet holds what changed after a sync cursor. Next is the cursor to
+// resume from; HasMore is set when the set was cut at the limit and the
+// client should ask again right away.
+type ChangeSet struct {
+	Quotes     []Quote
+	Hashtags   []Hashtag
+	Photos     []Photo
+	States     []QuoteState
+	Tombstones []Tombstone
+	Next       SyncCursor
+	HasMore    bool
+}
+
+// SyncStore is implemented by stores that number their changes, so that
+// offline clients can fetch only what changed since their last sync.
+type SyncStore interface {
+	GetChanges(ctx context.Context, since SyncCursor, limit int) (*ChangeSet, error)
+}
+
+// DataVersioner is implemented by stores that can cheaply tell whether their
+// data changed: the version differs after any write that clients could see.
+type DataVersioner interface {
+	DataVersion(ctx context.Context) (string, error)
+}
+
+var (
+	_ SyncStore     = (*DB)(nil)
+	_ DataVersioner = (*DB)(nil)
+)
+
+// DataVersion combines the sync sequence with the authors table, which isn't
+// synced but changes what a bundle holds.
+func (db *DB) DataVersion(ctx context.Context) (string, error) {
+	ctx, done := db.read(ctx, "DataVersion")
+	defer done()
+
+	query := `
+		select format('%s.%s.%s',
+			(select last_value from sync_seq),
+			(select count(*) from authors),
+			(select coalesce(sum(version), 0) from authors))`
+
+	var version string
+
+	err := db.QueryRowContext(ctx, query).Scan(&version)
+	if err != nil {
+		return "", err
+	}
+
+	return version, nil
+}
+
+// GetChanges returns up to limit changes after since, in cursor order. Only
+// public quotes whose publish time has passed are returned; any other changed
+// quote is reported as a tombstone.
+//
+// Sequence numbers are taken when a row is written, not when its transaction
+// commits, so they alone can't tell whether an earlier change is still to
+// come. Only changes of transactions older than every one still running are
+// returned: a long write, such as an import, holds back the changes committed
+// after it started until it ends, instead of letting clients skip past it.
+func (db *DB) GetChanges(ctx context.Context, since SyncCursor, limit int) (*ChangeSet, error) {
+	ctx, done := db.read(ctx, "GetChanges")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The oldest transaction still running when the snapshot was taken; every
+	// one before it has ended, so nothing below it can show up later.
+	var xmin int64
+
+	err = tx.QueryRowContext(ctx, `select pg_snapshot_xmin(pg_current_snapshot())::text::bigint`).Scan(&xmin)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		select entity, id, xid::text::bigint, seq, deleted
+		from (
+			select 'quote' as entity, id, sync_xid as xid, sync_seq as seq, false as deleted from quotes
+			where (sync_xid, sync_seq) > ($1::text::xid8, $2) and sync_xid < $3::text::xid8
+			union all
+			select 'hashtag', id, sync_xid, sync_seq, false from hashtags
+			where (sync_xid, sync_seq) > ($1::text::xid8, $2) and sync_xid < $3::text::xid8
+			union all
+			select 'photo', id, sync_xid, sync_seq, false from photos
+			where (sync_xid, sync_seq) > ($1::text::xid8, $2) and sync_xid < $3::text::xid8
+			union all
+			select 'state', id, sync_xid, sync_seq, false from quote_states
+			where (sync_xid, sync_seq) > ($1::text::xid8, $2) and sync_xid < $3::text::xid8
+			union all
+			select entity, entity_id, xid, seq, true from sync_tombstones
+			where (xid, seq) > ($1::text::xid8, $2) and xid < $3::text::xid8
+		) c
+		order by xid, seq
+		limit $4`
+
+	rows, err := tx.QueryContext(ctx, query, since.XID, since.Seq, xmin, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := &ChangeSet{Next: since}
+	changed := make(map[string]pq.StringArray)
+
+	for n := 0; rows.Next(); n++ {
+		var entity string
+		var id uuid.UUID
+		var cursor SyncCursor
+		var deleted bool
+
+		err := rows.Scan(&entity, &id, &cursor.XID, &cursor.Seq, &deleted)
+		if err != nil {
+			return nil, err
+		}
+
+		if n == limit {
+			changes.HasMore = true
+			break
+		}
+
+		changes.Next = cursor
+
+		if deleted {
+			changes.Tombstones = append(changes.Tombstones, Tombstone{Entity: entity, ID: id})
+		} else {
+			changed[entity] = append(changed[entity], id.String())
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(changed[SyncQuote]) > 0 {
+		changes.Quotes, err = syncQuotes(ctx, tx, changed[SyncQuote])
+		if err != nil {
+			return nil, err
+		}
+
+		public := make(map[uuid.UUID]bool, len(changes.Quotes))
+		for _, quote := range changes.Quotes {
+			public[quote.ID] = true
+		}
+
+		for _, id := range changed[SyncQuote] {
+			if quoteID := uuid.MustParse(id); !public[quoteID] {
+				changes.Tombstones = append(changes.Tombstones, Tombstone{Entity: SyncQuote, ID: quoteID})
+			}
+		}
+	}
+
+	if len(changed[SyncHashtag]) > 0 {
+		err = tx.SelectContext(ctx, &changes.Hashtags, `select id, value from hashtags where id = any($1::uuid[])`, changed[SyncHashtag])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(changed[SyncPhoto]) > 0 {
+		err = tx.SelectContext(ctx, &changes.Photos, `select id, color, blur_hash, author, url from photos where id = any($1::uuid[])`, changed[SyncPhoto])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(changed[SyncState]) > 0 {
+		err = tx.SelectContext(ctx, &changes.States, `select id, value, is_default, color, is_public from quote_states where id = any($1::uuid[])`, changed[SyncState])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+// syncQuotes loads the quotes of ids that are public and live, hashtags
+// included.
+func syncQuotes(ctx context.Context, tx *sqlx.Tx, ids pq.StringArray) ([]Quote, error) {
+	query := `
+		select array(select h.id::text from quote_hashtags qh inner join hashtags h on qh.hashtag_id = h.id where qh.quote_id = q.id order by h.value),
+		       array(select h.value from quote_hashtags qh inner join hashtags h on qh.hashtag_id = h.id where qh.quote_id = q.id order by h.value),
+		       ` + quoteColumns + `
+		from quotes q
+		inner join quote_states s
+		on q.state = s.id and s.is_public = true
+		inner join photos p
+		on q.photo_id = p.id
+		where q.id = any($1::uuid[])
+		and (q.publish_at is null or q.publish_at <= now())
+		order by q.sync_xid, q.sync_seq`
+
+	rows, err := tx.QueryContext(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotes []Quote
+
+	for rows.Next() {
+		var hashtagIDs, hashtagValues pq.StringArray
+
+		quote, err := scanQuote(rows, &hashtagIDs, &hashtagValues)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range hashtagIDs {
+			id, err := uuid.Parse(hashtagIDs[i])
+			if err != nil {
+				return nil, err
+			}
+			quote.Hashtags = append(quote.Hashtags, Hashtag{ID: id, Value: hashtagValues[i]})
+		}
+
+		quotes = append(quotes, quote)
+	}
+
+	return quotes, rows.Err()
+}
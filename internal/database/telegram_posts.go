@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+// Telegram post statuses. A post stays pending until Telegram has answered;
+// one left pending by a crash is not retried, a repost sends it again.
+const (
+	TelegramPostPending = "pending"
+	TelegramPostPosted  = "posted"
+	TelegramPostFailed  = "failed"
+)
+
+// TelegramPost logs one message about a quote sent to a Telegram chat.
+// CreatedBy is set on reposts only.
+type TelegramPost struct {
+	ID        uuid.UUID
+	QuoteID   uuid.UUID
+	ChatID    string
+	IsRepost  bool
+	Status    string
+	MessageID *int64
+	Error     string
+	CreatedBy *uuid.UUID
+	CreatedAt time.Time
+	PostedAt  *time.Time
+}
+
+// TelegramPostStore is implemented by stores that keep a log of quotes posted
+// to Telegram.
+type TelegramPostStore interface {
+	InsertTelegramPost(ctx context.Context, post *TelegramPost) error
+	UpdateTelegramPost(ctx context.Context, post *TelegramPost) error
+	GetTelegramPosts(ctx context.Context, quoteID *uuid.UUID, status string, filters f.Filters) ([]TelegramPost, f.Metadata, error)
+}
+
+var _ TelegramPostStore = (*DB)(nil)
+
+// InsertTelegramPost adds a pending post. A quote is posted to a chat
+// automatically only once: another automatic post of it is rejected with
+// ErrDuplicateTelegramPost, while reposts always go in.
+func (db *DB) InsertTelegramPost(ctx context.Context, post *TelegramPost) error {
+	ctx, done := db.write(ctx, "InsertTelegramPost")
+	defer done()
+
+	post.ID = uuid.New()
+	post.Status = TelegramPostPending
+
+	query := `
+		insert into telegram_posts (id, quote_id, chat_id, is_repost, created_by)
+		values ($1, $2, $3, $4, $5)
+		on conflict (quote_id, chat_id) where not is_repost do nothing
+		returning created_at`
+
+	err := db.QueryRowContext(ctx, query, post.ID, post.QuoteID, post.ChatID, post.IsRepost, post.CreatedBy).Scan(&post.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrDuplicateTelegramPost
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateTelegramPost records the outcome of sending a post.
+func (db *DB) UpdateTelegramPost(ctx context.Context, post *TelegramPost) error {
+	ctx, done := db.write(ctx, "UpdateTelegramPost")
+	defer done()
+
+	query := `
+		update telegram_posts
+		set status = $2, message_id = $3, error = $4, posted_at = $5
+		where id = $1`
+
+	result, err := db.ExecContext(ctx, query, post.ID, post.Status, post.MessageID, post.Error, post.PostedAt)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetTelegramPosts lists posts, newest first, optionally only those of
+// quoteID or with status.
+func (db *DB) GetTelegramPosts(ctx context.Context, quoteID *uuid.UUID, status string, filters f.Filters) ([]TelegramPost, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetTelegramPosts")
+	defer done()
+
+	query := `
+		select count(*) over(), id, quote_id, chat_id, is_repost, status, message_id, error, created_by, created_at, posted_at
+		from telegram_posts
+		where ($1::uuid is null or quote_id = $1)
+		and (status = $2 or $2 = '')
+		order by created_at desc, id
+		limit $3 offset $4`
+
+	rows, err := db.QueryContext(ctx, query, quoteID, status, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	posts := make([]TelegramPost, 0)
+
+	for rows.Next() {
+		var p TelegramPost
+
+		err := rows.Scan(
+			&totalRecords,
+			&p.ID, &p.QuoteID, &p.ChatID, &p.IsRepost, &p.Status, &p.MessageID, &p.Error, &p.CreatedBy, &p.CreatedAt, &p.PostedAt,
+		)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		posts = append(posts, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return posts, metadata, nil
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// InsertTranslation adds a translation of originalID in the default state.
+// Translating a translation translates its original instead. The photo and
+// hashtags are copied from the original. ErrDuplicateLanguage is returned
+// when the original already is in, or has a translation in, language.
+func (db *DB) InsertTranslation(ctx context.Context, originalID uuid.UUID, language, author, text string, userID uuid.UUID) (*Quote, error) {
+	ctx, done := db.write(ctx, "InsertTranslation")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var originalLanguage string
+	var photoID uuid.UUID
+
+	query := `
+		select o.id, o.language, o.photo_id
+		from quotes q
+		inner join quotes o
+		on o.id = coalesce(q.original_id, q.id)
+		where q.id = $1
+		for update of o`
+
+	err = tx.QueryRowContext(ctx, query, originalID).Scan(&originalID, &originalLanguage, &photoID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if originalLanguage == language {
+		return nil, ErrDuplicateLanguage
+	}
+
+	var stateID uuid.UUID
+
+	err = tx.QueryRowContext(ctx, `select id from quote_states where is_default = true`).Scan(&stateID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrDefaultState
+		default:
+			return nil, err
+		}
+	}
+
+	id := uuid.New()
+	now := time.Now()
+
+	query = `
+		insert into quotes (id, created_at, updated_at, author, text, created_by, state, photo_id, language, original_id)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	args := []interface{}{id, now, now, author, text, userID, stateID, photoID, language, originalID}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err):
+			return nil, ErrDuplicateLanguage
+		default:
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `insert into quote_hashtags (quote_id, hashtag_id) select $1, hashtag_id from quote_hashtags where quote_id = $2`, id, originalID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = linkQuoteAuthor(ctx, tx, id, author)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return db.GetQuoteById(ctx, id)
+}
+
+// SetQuoteLanguage sets the language of a quote. The language has to differ
+// from those of the quote's original and translations, otherwise
+// ErrDuplicateLanguage is returned.
+func (db *DB) SetQuoteLanguage(ctx context.Context, id uuid.UUID, language string) error {
+	ctx, done := db.write(ctx, "SetQuoteLanguage")
+	defer done()
+
+	var taken bool
+
+	query := `
+		select exists (
+			select 1
+			from quotes q
+			inner join quotes o
+			on o.id = q.original_id
+			where q.id = $1 and o.language = $2)
+		or exists (
+			select 1
+			from quotes t
+			where t.original_id = $1 and t.language = $2)`
+
+	err := db.QueryRowContext(ctx, query, id, language).Scan(&taken)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return ErrDuplicateLanguage
+	}
+
+	result, err := db.ExecContext(ctx, `update quotes set language = $1, updated_at = now() where id = $2`, language, id)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err):
+			return ErrDuplicateLanguage
+		default:
+			return err
+		}
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetQuoteTranslations returns the public translations of each of
+// originalIDs, ordered by language.
+func (db *DB) GetQuoteTranslations(ctx context.Context, originalIDs []uuid.UUID) (map[uuid.UUID][]Quote, error) {
+	ctx, done := db.read(ctx, "GetQuoteTranslations")
+	defer done()
+
+	translations := make(map[uuid.UUID][]Quote)
+	if len(originalIDs) == 0 {
+		return translations, nil
+	}
+
+	ids := make(pq.StringArray, len(originalIDs))
+	for i := range originalIDs {
+		ids[i] = originalIDs[i].String()
+	}
+
+	query := `
+		select ` + quoteColumns + `
+		from quotes q
+		inner join quote_states s
+		on q.state = s.id and s.is_public = true
+		inner join photos p
+		on q.photo_id = p.id
+		where q.original_id = any($1::uuid[])
+		and (q.publish_at is null or q.publish_at <= now())
+		order by q.language`
+
+	rows, err := db.QueryContext(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotes []Quote
+
+	for rows.Next() {
+		quote, err := scanQuote(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		quotes = append(quotes, quote)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, quote := range quotes {
+		quote.Hashtags, _, err = db.GetQuoteHashtags(ctx, quote.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		translations[*quote.OriginalID] = append(translations[*quote.OriginalID], quote)
+	}
+
+	return translations, nil
+}
@@ -17,9 +17,9 @@ type User struct {
 	IsActivated    bool      `db:"is_activated"`
 }
 
-func (db *DB) InsertUser(email, hashedPassword, name string) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) InsertUser(ctx context.Context, email, hashedPassword, name string) (*User, error) {
+	ctx, done := db.write(ctx, "InsertUser")
+	defer done()
 
 	user := &User{}
 
@@ -43,9 +43,9 @@ func (db *DB) InsertUser(email, hashedPassword, name string) (*User, error) {
 	return user, err
 }
 
-func (db *DB) GetUser(id uuid.UUID) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) GetUser(ctx context.Context, id uuid.UUID) (*User, error) {
+	ctx, done := db.read(ctx, "GetUser")
+	defer done()
 
 	var user User
 
@@ -59,9 +59,9 @@ func (db *DB) GetUser(id uuid.UUID) (*User, error) {
 	return &user, err
 }
 
-func (db *DB) GetUserByEmail(email string) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, done := db.read(ctx, "GetUserByEmail")
+	defer done()
 
 	var user User
 
@@ -75,9 +75,9 @@ func (db *DB) GetUserByEmail(email string) (*User, error) {
 	return &user, err
 }
 
-func (db *DB) UpdateUserHashedPassword(id uuid.UUID, hashedPassword string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) UpdateUserHashedPassword(ctx context.Context, id uuid.UUID, hashedPassword string) error {
+	ctx, done := db.write(ctx, "UpdateUserHashedPassword")
+	defer done()
 
 	query := `UPDATE users SET hashed_password = $1 WHERE id = $2`
 
@@ -85,9 +85,9 @@ func (db *DB) UpdateUserHashedPassword(id uuid.UUID, hashedPassword string) erro
 	return err
 }
 
-func (db *DB) ActivateUser(id uuid.UUID) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
+func (db *DB) ActivateUser(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "ActivateUser")
+	defer done()
 
 	query := `UPDATE users SET is_activated = true WHERE id = $1`
 
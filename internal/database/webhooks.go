@@ -0,0 +1,487 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Webhook delivery statuses. A delivery stays pending while attempts are
+// left; it fails once they run out or its webhook is disabled.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a partner endpoint subscribed to quote events. An empty
+// EventTypes subscribes to all of them. FailureCount counts failed attempts
+// since the last success; the webhook is disabled once it gets too high.
+type Webhook struct {
+	ID           uuid.UUID
+	URL          string
+	Secret       string
+	EventTypes   []string
+	IsActive     bool
+	FailureCount int
+	DisabledAt   *time.Time
+	CreatedBy    uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// WebhookDelivery is one event to be sent to one webhook. Payload is kept as
+// sent, so that redeliveries carry the same body.
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	WebhookID      uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        string
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode *int
+	LastError      string
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time
+
+	// Filled in by ClaimDueWebhookDeliveries for the sender.
+	URL    string
+	Secret string
+}
+
+// WebhookAttempt is the outcome of one try at a delivery. StatusCode is nil
+// when no response came back.
+type WebhookAttempt struct {
+	StatusCode  *int
+	Error       string
+	Duration    time.Duration
+	AttemptedAt time.Time
+}
+
+// WebhookStore is implemented by stores that support outgoing webhooks.
+type WebhookStore interface {
+	InsertWebhook(ctx context.Context, webhook *Webhook) error
+	GetWebhook(ctx context.Context, id uuid.UUID) (*Webhook, error)
+	GetWebhooks(ctx context.Context, filters f.Filters) ([]Webhook, f.Metadata, error)
+	UpdateWebhook(ctx context.Context, webhook *Webhook) error
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	EnqueueWebhookDeliveries(ctx context.Context, eventID uuid.UUID, eventType string, payload []byte) (int, error)
+	ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, delivery *WebhookDelivery, attempt WebhookAttempt, next *time.Time, maxFailures int) error
+	GetWebhookDelivery(ctx context.Context, id uuid.UUID) (*WebhookDelivery, []WebhookAttempt, error)
+	GetWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, status string, filters f.Filters) ([]WebhookDelivery, f.Metadata, error)
+	RedeliverWebhookDelivery(ctx context.Context, id uuid.UUID) error
+}
+
+var _ WebhookStore = (*DB)(nil)
+
+func (db *DB) InsertWebhook(ctx context.Context, webhook *Webhook) error {
+	ctx, done := db.write(ctx, "InsertWebhook")
+	defer done()
+
+	webhook.ID = uuid.New()
+	webhook.IsActive = true
+
+	query := `
+		insert into webhooks (id, url, secret, event_types, created_by)
+		values ($1, $2, $3, $4, $5)
+		returning created_at, updated_at`
+
+	return db.QueryRowContext(ctx, query, webhook.ID, webhook.URL, webhook.Secret, pq.Array(webhook.EventTypes), webhook.CreatedBy).
+		Scan(&webhook.CreatedAt, &webhook.UpdatedAt)
+}
+
+func (db *DB) GetWebhook(ctx context.Context, id uuid.UUID) (*Webhook, error) {
+	ctx, done := db.read(ctx, "GetWebhook")
+	defer done()
+
+	query := `
+		select id, url, secret, event_types, is_active, failure_count, disabled_at, created_by, created_at, updated_at
+		from webhooks
+		where id = $1`
+
+	var webhook Webhook
+
+	err := db.QueryRowContext(ctx, query, id).Scan(
+		&webhook.ID, &webhook.URL, &webhook.Secret, pq.Array(&webhook.EventTypes), &webhook.IsActive,
+		&webhook.FailureCount, &webhook.DisabledAt, &webhook.CreatedBy, &webhook.CreatedAt, &webhook.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &webhook, nil
+}
+
+func (db *DB) GetWebhooks(ctx context.Context, filters f.Filters) ([]Webhook, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetWebhooks")
+	defer done()
+
+	query := `
+		select count(*) over(), id, url, secret, event_types, is_active, failure_count, disabled_at, created_by, created_at, updated_at
+		from webhooks
+		order by created_at, id
+		limit $1 offset $2`
+
+	rows, err := db.QueryContext(ctx, query, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	webhooks := make([]Webhook, 0)
+
+	for rows.Next() {
+		var webhook Webhook
+
+		err := rows.Scan(
+			&totalRecords,
+			&webhook.ID, &webhook.URL, &webhook.Secret, pq.Array(&webhook.EventTypes), &webhook.IsActive,
+			&webhook.FailureCount, &webhook.DisabledAt, &webhook.CreatedBy, &webhook.CreatedAt, &webhook.UpdatedAt,
+		)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return webhooks, metadata, nil
+}
+
+// UpdateWebhook saves the URL, secret, event types and active flag of
+// webhook. Turning a webhook back on clears its failure count.
+func (db *DB) UpdateWebhook(ctx context.Context, webhook *Webhook) error {
+	ctx, done := db.write(ctx, "UpdateWebhook")
+	defer done()
+
+	query := `
+		update webhooks
+		set url = $2, secret = $3, event_types = $4, is_active = $5,
+		    failure_count = case when $5 and not is_active then 0 else failure_count end,
+		    disabled_at = case when $5 then null else coalesce(disabled_at, now()) end,
+		    updated_at = now()
+		where id = $1
+		returning failure_count, disabled_at, updated_at`
+
+	err := db.QueryRowContext(ctx, query, webhook.ID, webhook.URL, webhook.Secret, pq.Array(webhook.EventTypes), webhook.IsActive).
+		Scan(&webhook.FailureCount, &webhook.DisabledAt, &webhook.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "DeleteWebhook")
+	defer done()
+
+	result, err := db.ExecContext(ctx, `delete from webhooks where id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// EnqueueWebhookDeliveries adds a pending delivery of the event for every
+// active webhook subscribed to its type, and returns how many were added.
+func (db *DB) EnqueueWebhookDeliveries(ctx context.Context, eventID uuid.UUID, eventType string, payload []byte) (int, error) {
+	ctx, done := db.write(ctx, "EnqueueWebhookDeliveries")
+	defer done()
+
+	query := `
+		insert into webhook_deliveries (id, webhook_id, event_id, event_type, payload)
+		select gen_random_uuid(), id, $1, $2, $3
+		from webhooks
+		where is_active = true
+		and (cardinality(event_types) = 0 or $2 = any(event_types))`
+
+	result, err := db.ExecContext(ctx, query, eventID, eventType, string(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// ClaimDueWebhookDeliveries returns up to limit pending deliveries of active
+// webhooks that are due, and pushes their next attempt back by lease so that
+// other instances leave them alone while they are being sent.
+func (db *DB) ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	ctx, done := db.write(ctx, "ClaimDueWebhookDeliveries")
+	defer done()
+
+	query := `
+		with due as (
+			select d.id
+			from webhook_deliveries d
+			inner join webhooks w
+			on d.webhook_id = w.id and w.is_active = true
+			where d.status = 'pending' and d.next_attempt_at <= now()
+			order by d.next_attempt_at
+			limit $1
+			for update of d skip locked
+		)
+		update webhook_deliveries d
+		set next_attempt_at = now() + $2 * interval '1 millisecond'
+		from due, webhooks w
+		where d.id = due.id and w.id = d.webhook_id
+		returning d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+		          d.last_status_code, d.last_error, d.delivered_at, d.created_at, w.url, w.secret`
+
+	rows, err := db.QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+
+	for rows.Next() {
+		var d WebhookDelivery
+
+		err := rows.Scan(
+			&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastStatusCode, &d.LastError, &d.DeliveredAt, &d.CreatedAt, &d.URL, &d.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RecordWebhookAttempt logs an attempt at delivery and settles it: a 2xx
+// status succeeds, otherwise the delivery is retried at next or, with next
+// nil, fails. Failed attempts count against the webhook, which is disabled
+// once maxFailures of them follow each other (0 never disables it).
+func (db *DB) RecordWebhookAttempt(ctx context.Context, delivery *WebhookDelivery, attempt WebhookAttempt, next *time.Time, maxFailures int) error {
+	ctx, done := db.write(ctx, "RecordWebhookAttempt")
+	defer done()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		insert into webhook_attempts (delivery_id, status_code, error, duration_ms, attempted_at)
+		values ($1, $2, $3, $4, $5)`,
+		delivery.ID, attempt.StatusCode, attempt.Error, attempt.Duration.Milliseconds(), attempt.AttemptedAt)
+	if err != nil {
+		return err
+	}
+
+	succeeded := attempt.StatusCode != nil && *attempt.StatusCode >= 200 && *attempt.StatusCode < 300
+
+	status := DeliveryPending
+	switch {
+	case succeeded:
+		status = DeliverySucceeded
+	case next == nil:
+		status = DeliveryFailed
+	}
+
+	nextAttemptAt := attempt.AttemptedAt
+	if next != nil {
+		nextAttemptAt = *next
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		update webhook_deliveries
+		set status = $2, attempts = attempts + 1, next_attempt_at = $3, last_status_code = $4, last_error = $5,
+		    delivered_at = case when $6 then $7 else delivered_at end
+		where id = $1`,
+		delivery.ID, status, nextAttemptAt, attempt.StatusCode, attempt.Error, succeeded, attempt.AttemptedAt)
+	if err != nil {
+		return err
+	}
+
+	if succeeded {
+		_, err = tx.ExecContext(ctx, `update webhooks set failure_count = 0 where id = $1`, delivery.WebhookID)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			update webhooks
+			set failure_count = failure_count + 1,
+			    is_active = is_active and ($2 = 0 or failure_count + 1 < $2),
+			    disabled_at = case when is_active and $2 > 0 and failure_count + 1 >= $2 then now() else disabled_at end
+			where id = $1`,
+			delivery.WebhookID, maxFailures)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetWebhookDelivery returns a delivery with its attempts, oldest first.
+func (db *DB) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (*WebhookDelivery, []WebhookAttempt, error) {
+	ctx, done := db.read(ctx, "GetWebhookDelivery")
+	defer done()
+
+	query := `
+		select id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at
+		from webhook_deliveries
+		where id = $1`
+
+	var d WebhookDelivery
+
+	err := db.QueryRowContext(ctx, query, id).Scan(
+		&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.LastStatusCode, &d.LastError, &d.DeliveredAt, &d.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		select status_code, error, duration_ms, attempted_at
+		from webhook_attempts
+		where delivery_id = $1
+		order by attempted_at, id`, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	attempts := make([]WebhookAttempt, 0)
+
+	for rows.Next() {
+		var attempt WebhookAttempt
+		var durationMs int64
+
+		err := rows.Scan(&attempt.StatusCode, &attempt.Error, &durationMs, &attempt.AttemptedAt)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		attempt.Duration = time.Duration(durationMs) * time.Millisecond
+		attempts = append(attempts, attempt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return &d, attempts, nil
+}
+
+// GetWebhookDeliveries lists the deliveries of a webhook, newest first,
+// optionally only those with status.
+func (db *DB) GetWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, status string, filters f.Filters) ([]WebhookDelivery, f.Metadata, error) {
+	ctx, done := db.read(ctx, "GetWebhookDeliveries")
+	defer done()
+
+	query := `
+		select count(*) over(), id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at
+		from webhook_deliveries
+		where webhook_id = $1
+		and (status = $2 or $2 = '')
+		order by created_at desc, id
+		limit $3 offset $4`
+
+	rows, err := db.QueryContext(ctx, query, webhookID, status, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, f.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	deliveries := make([]WebhookDelivery, 0)
+
+	for rows.Next() {
+		var d WebhookDelivery
+
+		err := rows.Scan(
+			&totalRecords,
+			&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastStatusCode, &d.LastError, &d.DeliveredAt, &d.CreatedAt,
+		)
+		if err != nil {
+			return nil, f.Metadata{}, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, f.Metadata{}, err
+	}
+
+	metadata := f.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return deliveries, metadata, nil
+}
+
+// RedeliverWebhookDelivery puts a delivery back in the queue to be sent right
+// away, with a fresh set of attempts. The attempts already made stay logged.
+func (db *DB) RedeliverWebhookDelivery(ctx context.Context, id uuid.UUID) error {
+	ctx, done := db.write(ctx, "RedeliverWebhookDelivery")
+	defer done()
+
+	result, err := db.ExecContext(ctx, `
+		update webhook_deliveries
+		set status = 'pending', attempts = 0, next_attempt_at = now()
+		where id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,237 @@
+// Package events is a small bus that API instances use to tell each other, and
+// their own live feeds, about writes. On Postgres events travel through NOTIFY
+// and reach every instance listening on the channel, the publisher included;
+// on other databases they stay within the process.
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"javlonrahimov/quotes-api/internal/leveledlog"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres notification channel events are sent on.
+const Channel = "quotes_api_events"
+
+// Event types. ID names the quote, hashtag, photo or user the event is about.
+// It is left nil by StatesChanged, which covers every quote state, and by bulk
+// writes such as imports.
+const (
+	QuoteCreated       = "quote.created"
+	QuoteUpdated       = "quote.updated"
+	QuoteDeleted       = "quote.deleted"
+	QuoteStateChanged  = "quote.state_changed"
+	QuotePublished     = "quote.published"
+	HashtagCreated     = "hashtag.created"
+	HashtagDeleted     = "hashtag.deleted"
+	PhotoCreated       = "photo.created"
+	StatesChanged      = "states.changed"
+	PermissionsChanged = "permissions.changed"
+	UserChanged        = "user.changed"
+
+	// Resync is delivered locally after the listener reconnected, since
+	// notifications sent while it was down are lost. Subscribers should drop
+	// whatever they derived from earlier events.
+	Resync = "resync"
+)
+
+// Event is kept small: Postgres limits a notification to 8000 bytes, so
+// subscribers load whatever else they need by ID.
+type Event struct {
+	Type   string    `json:"type"`
+	ID     uuid.UUID `json:"id,omitempty"`
+	Origin string    `json:"origin"`
+	At     time.Time `json:"at"`
+}
+
+// Bus delivers published events to its subscribers. Subscribers are called
+// one event at a time from a single goroutine and must not block; anything
+// slow belongs on a goroutine or a buffered channel of their own.
+type Bus struct {
+	origin string
+	logger *leveledlog.Logger
+
+	mu     sync.RWMutex
+	nextID int
+	subs   map[int]func(Event)
+
+	db       *sql.DB
+	listener *pq.Listener
+	queue    chan Event
+	done     chan struct{}
+}
+
+// NewLocal returns a bus that delivers events within this process only.
+func NewLocal(logger *leveledlog.Logger) *Bus {
+	b := newBus(logger)
+
+	go b.run()
+
+	return b
+}
+
+// NewPostgres returns a bus that publishes through db and listens for events
+// from every instance on a dedicated connection to dsn. The connection is
+// re-established after failures; a Resync event follows each reconnect.
+func NewPostgres(db *sql.DB, dsn string, logger *leveledlog.Logger) (*Bus, error) {
+	b := newBus(logger)
+	b.db = db
+
+	b.listener = pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			logger.Warning("events: listener disconnected: %v", err)
+		case pq.ListenerEventReconnected:
+			logger.Info("events: listener reconnected")
+		case pq.ListenerEventConnectionAttemptFailed:
+			logger.Warning("events: listener reconnect failed: %v", err)
+		}
+	})
+
+	err := b.listener.Listen(Channel)
+	if err != nil {
+		b.listener.Close()
+		return nil, err
+	}
+
+	go b.listen()
+	go b.run()
+
+	return b, nil
+}
+
+func newBus(logger *leveledlog.Logger) *Bus {
+	return &Bus{
+		origin: uuid.NewString(),
+		logger: logger,
+		subs:   make(map[int]func(Event)),
+		queue:  make(chan Event, 256),
+		done:   make(chan struct{}),
+	}
+}
+
+// Origin identifies this instance in the events it publishes.
+func (b *Bus) Origin() string {
+	return b.origin
+}
+
+// Publish sends e to every instance. Type is required; Origin and At are
+// filled in.
+func (b *Bus) Publish(ctx context.Context, e Event) error {
+	e.Origin = b.origin
+	e.At = time.Now().UTC()
+
+	if b.db == nil {
+		b.enqueue(e)
+		return nil
+	}
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.db.ExecContext(ctx, `select pg_notify($1, $2)`, Channel, string(payload))
+	return err
+}
+
+// Subscribe registers fn for every event from now on and returns a function
+// that removes it again.
+func (b *Bus) Subscribe(fn func(Event)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = fn
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subs, id)
+	}
+}
+
+// Close stops listening and delivering events.
+func (b *Bus) Close() error {
+	select {
+	case <-b.done:
+		return nil
+	default:
+	}
+
+	close(b.done)
+
+	if b.listener != nil {
+		return b.listener.Close()
+	}
+	return nil
+}
+
+func (b *Bus) enqueue(e Event) {
+	select {
+	case b.queue <- e:
+	case <-b.done:
+	}
+}
+
+func (b *Bus) run() {
+	for {
+		select {
+		case e := <-b.queue:
+			b.mu.RLock()
+			subs := make([]func(Event), 0, len(b.subs))
+			for _, fn := range b.subs {
+				subs = append(subs, fn)
+			}
+			b.mu.RUnlock()
+
+			for _, fn := range subs {
+				fn(e)
+			}
+		case <-b.done:
+			return
+		}
+	}
+}
+
+func (b *Bus) listen() {
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case n, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+
+			// A nil notification means the connection was re-established.
+			if n == nil {
+				b.enqueue(Event{Type: Resync, Origin: b.origin, At: time.Now().UTC()})
+				continue
+			}
+
+			var e Event
+			err := json.Unmarshal([]byte(n.Extra), &e)
+			if err != nil {
+				b.logger.Warning("events: invalid payload %q: %v", n.Extra, err)
+				continue
+			}
+
+			b.enqueue(e)
+		case <-ping.C:
+			go b.listener.Ping()
+		case <-b.done:
+			return
+		}
+	}
+}
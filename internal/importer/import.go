@@ -0,0 +1,181 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/validator"
+
+	"github.com/google/uuid"
+)
+
+const (
+	StatusCreated   = "created"
+	StatusDuplicate = "duplicate"
+	StatusRejected  = "rejected"
+	StatusSkipped   = "skipped"
+)
+
+type Options struct {
+	// SkipInvalid imports the valid rows even when others are rejected.
+	// Otherwise a single rejected row aborts the whole import.
+	SkipInvalid bool
+	// AllowState honours the state column; when false every quote gets the
+	// default state, as with POST /v1/quote for non-sudoers.
+	AllowState bool
+}
+
+type Result struct {
+	Row     int                  `json:"row"`
+	Status  string               `json:"status"`
+	QuoteID *uuid.UUID           `json:"quoteID,omitempty"`
+	Errors  *validator.Validator `json:"errors,omitempty"`
+}
+
+type Report struct {
+	Committed bool     `json:"committed"`
+	Created   int      `json:"created"`
+	Duplicate int      `json:"duplicate"`
+	Rejected  int      `json:"rejected"`
+	Rows      []Result `json:"rows"`
+}
+
+// Import validates rows with the same rules as POST /v1/quote and writes the
+// valid ones for userID in a single ImportQuotes call.
+func Import(ctx context.Context, store database.Store, userID uuid.UUID, rows []Row, opts Options) (*Report, error) {
+	states, _, err := store.GetAllQuoteStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	photos := make(map[string]bool)
+
+	report := &Report{Rows: make([]Result, len(rows))}
+
+	var quotes []database.QuoteImport
+	var pending []int
+
+	for i, row := range rows {
+		report.Rows[i].Row = row.Number
+
+		quote, v, err := validate(ctx, store, row, states, photos, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		if v.HasErrors() {
+			report.Rows[i].Status = StatusRejected
+			report.Rows[i].Errors = &v
+			report.Rejected++
+			continue
+		}
+
+		report.Rows[i].Status = StatusSkipped
+		quotes = append(quotes, quote)
+		pending = append(pending, i)
+	}
+
+	if len(quotes) == 0 || (report.Rejected > 0 && !opts.SkipInvalid) {
+		return report, nil
+	}
+
+	imported, err := store.ImportQuotes(ctx, userID, quotes)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Committed = true
+
+	for j, result := range imported {
+		row := &report.Rows[pending[j]]
+		id := result.QuoteID
+		row.QuoteID = &id
+
+		if result.Duplicate {
+			row.Status = StatusDuplicate
+			report.Duplicate++
+		} else {
+			row.Status = StatusCreated
+			report.Created++
+		}
+	}
+
+	return report, nil
+}
+
+func validate(ctx context.Context, store database.Store, row Row, states []database.QuoteState, photos map[string]bool, opts Options) (database.QuoteImport, validator.Validator, error) {
+	var v validator.Validator
+
+	if row.Err != nil {
+		v.AddError(row.Err.Error())
+		return database.QuoteImport{}, v, nil
+	}
+
+	rec := row.Record
+
+	quote := database.QuoteImport{
+		Author: strings.TrimSpace(rec.Author),
+		Text:   strings.TrimSpace(rec.Text),
+		Photo: database.Photo{
+			Url:      strings.TrimSpace(rec.PhotoUrl),
+			Color:    rec.PhotoColor,
+			BlurHash: rec.PhotoBlurHash,
+			Author:   rec.PhotoAuthor,
+		},
+	}
+
+	for _, value := range rec.Hashtags {
+		value = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(value), "#"))
+		if value == "" {
+			continue
+		}
+		v.CheckField(len(value) > 2, "hashtags", "min length must be at least 3 characters long")
+		if !validator.In(value, quote.Hashtags...) {
+			quote.Hashtags = append(quote.Hashtags, value)
+		}
+	}
+
+	v.CheckField(len(quote.Author) > 2, "author", "invalid author name")
+	v.CheckField(len(quote.Text) > 10, "text", "min length must be at least 10 characters long")
+	v.CheckField(len(quote.Hashtags) != 0, "hashtags", "add at least one hashtag")
+	v.CheckField(quote.Photo.Url != "", "photoUrl", "required field")
+
+	if quote.Photo.Url != "" {
+		exists, ok := photos[quote.Photo.Url]
+		if !ok {
+			_, err := store.GetPhotoByUrl(ctx, quote.Photo.Url)
+			switch {
+			case err == nil:
+				exists = true
+			case !errors.Is(err, database.ErrRecordNotFound):
+				return quote, v, err
+			}
+		}
+
+		if !exists {
+			v.CheckField(validator.IsValidHexColor(quote.Photo.Color), "photoColor", "invalid color, required to create a new photo")
+			v.CheckField(quote.Photo.BlurHash != "", "photoBlurHash", "required to create a new photo")
+			v.CheckField(len(quote.Photo.Author) > 2, "photoAuthor", "must be longer than 2, required to create a new photo")
+		}
+
+		// Only rows that are going to be written make the photo known to
+		// later rows, so a rejected row can't vouch for one that follows.
+		photos[quote.Photo.Url] = exists || !v.HasErrors()
+	}
+
+	if opts.AllowState && rec.State != "" {
+		found := false
+		for _, state := range states {
+			if state.Value == rec.State {
+				id := state.ID
+				quote.StateID = &id
+				found = true
+			}
+		}
+		v.CheckField(found, "state", "unknown state")
+	}
+
+	return quote, v, nil
+}
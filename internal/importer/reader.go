@@ -0,0 +1,236 @@
+// Package importer parses quote imports in CSV, JSON or NDJSON form and
+// loads them through database.Store with a per-row report.
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// Record is one quote as it appears in an import file. CSV files carry the
+// same names as header columns, with hashtags separated by commas,
+// semicolons or spaces.
+type Record struct {
+	Author        string   `json:"author"`
+	Text          string   `json:"text"`
+	PhotoUrl      string   `json:"photoUrl"`
+	PhotoColor    string   `json:"photoColor"`
+	PhotoBlurHash string   `json:"photoBlurHash"`
+	PhotoAuthor   string   `json:"photoAuthor"`
+	Hashtags      []string `json:"hashtags"`
+	State         string   `json:"state"`
+}
+
+// Row is a numbered record. Err is set when the row itself could not be
+// decoded; such rows are reported as rejected.
+type Row struct {
+	Number int
+	Record Record
+	Err    error
+}
+
+// FormatFromContentType maps a request Content-Type to an import format.
+func FormatFromContentType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case "text/csv", "application/csv":
+		return FormatCSV
+	case "application/json":
+		return FormatJSON
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return FormatNDJSON
+	}
+	return ""
+}
+
+// FormatFromName maps a file extension to an import format.
+func FormatFromName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV
+	case ".json":
+		return FormatJSON
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	}
+	return ""
+}
+
+// ReadAll decodes every row from r. It fails when the input as a whole is
+// malformed or holds more than maxRows rows.
+func ReadAll(r io.Reader, format string, maxRows int) ([]Row, error) {
+	var rows []Row
+
+	add := func(row Row) error {
+		if len(rows) >= maxRows {
+			return fmt.Errorf("import must not contain more than %d rows", maxRows)
+		}
+		row.Number = len(rows) + 1
+		rows = append(rows, row)
+		return nil
+	}
+
+	var err error
+	switch format {
+	case FormatCSV:
+		err = readCSV(r, add)
+	case FormatJSON:
+		err = readJSON(r, add)
+	case FormatNDJSON:
+		err = readNDJSON(r, add)
+	default:
+		err = fmt.Errorf("unsupported import format %q, use csv, json or ndjson", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("import must contain at least one row")
+	}
+
+	return rows, nil
+}
+
+func readCSV(r io.Reader, add func(Row) error) error {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("csv must start with a header row")
+		}
+		return err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		switch name {
+		case "author", "text", "photoUrl", "photoColor", "photoBlurHash", "photoAuthor", "hashtags", "state":
+			columns[name] = i
+		default:
+			return fmt.Errorf("csv header contains unknown column %q", name)
+		}
+	}
+
+	for _, name := range []string{"author", "text"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("csv header must contain a %q column", name)
+		}
+	}
+
+	for {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var row Row
+		switch {
+		case errors.Is(err, csv.ErrFieldCount):
+			row.Err = err
+		case err != nil:
+			return err
+		default:
+			get := func(name string) string {
+				if i, ok := columns[name]; ok {
+					return strings.TrimSpace(fields[i])
+				}
+				return ""
+			}
+
+			row.Record = Record{
+				Author:        get("author"),
+				Text:          get("text"),
+				PhotoUrl:      get("photoUrl"),
+				PhotoColor:    get("photoColor"),
+				PhotoBlurHash: get("photoBlurHash"),
+				PhotoAuthor:   get("photoAuthor"),
+				Hashtags: strings.FieldsFunc(get("hashtags"), func(r rune) bool {
+					return r == ',' || r == ';' || r == ' '
+				}),
+				State: get("state"),
+			}
+		}
+
+		if err := add(row); err != nil {
+			return err
+		}
+	}
+}
+
+func readJSON(r io.Reader, add func(Row) error) error {
+	dec := json.NewDecoder(r)
+
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("body contains badly-formed JSON: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return errors.New("json import must be an array of quotes")
+	}
+
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return fmt.Errorf("body contains badly-formed JSON: %w", err)
+		}
+		if err := add(decodeRecord(raw)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("body contains badly-formed JSON: %w", err)
+	}
+
+	return nil
+}
+
+func readNDJSON(r io.Reader, add func(Row) error) error {
+	dec := json.NewDecoder(r)
+
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("body contains badly-formed JSON: %w", err)
+		}
+		if err := add(decodeRecord(raw)); err != nil {
+			return err
+		}
+	}
+}
+
+func decodeRecord(raw json.RawMessage) Row {
+	var row Row
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(&row.Record)
+	if err != nil {
+		row.Err = err
+	}
+
+	return row
+}
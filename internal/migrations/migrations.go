@@ -0,0 +1,273 @@
+// Package migrations runs the schema migrations embedded in assets against
+// Postgres or SQLite, and reports on what has and hasn't been applied.
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"javlonrahimov/quotes-api/assets"
+
+	"github.com/golang-migrate/migrate/v4"
+	msqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+)
+
+var (
+	ErrSchemaAhead = errors.New("database schema is newer than this binary")
+	ErrDirty       = errors.New("database schema is dirty")
+)
+
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%06d %s", m.Version, m.Name)
+}
+
+type Status struct {
+	// Version is the applied schema version, 0 if nothing has been applied.
+	Version uint
+	Dirty   bool
+	// Latest is the newest migration embedded in this binary.
+	Latest  uint
+	Pending []Migration
+}
+
+type Migrator struct {
+	m          *migrate.Migrate
+	migrations []Migration
+	shared     bool
+}
+
+// Postgres returns a migrator for the database at dsn. It uses its own
+// connection, which Close releases.
+func Postgres(dsn string) (*Migrator, error) {
+	src, migrations, err := load("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", src, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{m: m, migrations: migrations}, nil
+}
+
+// SQLite returns a migrator that runs on an already open SQLite handle, so
+// that ":memory:" databases can be migrated. Close leaves db open.
+func SQLite(db *sql.DB) (*Migrator, error) {
+	src, migrations, err := load("migrations_sqlite")
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := msqlite.WithInstance(db, &msqlite.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "sqlite", driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{m: m, migrations: migrations, shared: true}, nil
+}
+
+func load(dir string) (source.Driver, []Migration, error) {
+	src, err := iofs.New(assets.EmbeddedFiles, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var migrations []Migration
+
+	version, err := src.First()
+	for err == nil {
+		var name string
+		name, err = upName(src, version)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: name})
+		version, err = src.Next(version)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	return src, migrations, nil
+}
+
+func upName(src source.Driver, version uint) (string, error) {
+	r, name, err := src.ReadUp(version)
+	if err != nil {
+		return "", err
+	}
+	r.Close()
+
+	return strings.ReplaceAll(name, "_", " "), nil
+}
+
+func (m *Migrator) Close() error {
+	if m.shared {
+		return nil
+	}
+
+	srcErr, dbErr := m.m.Close()
+	if srcErr != nil {
+		return srcErr
+	}
+	return dbErr
+}
+
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+func (m *Migrator) Status() (Status, error) {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return Status{}, err
+	}
+
+	status := Status{Version: version, Dirty: dirty, Latest: m.Latest()}
+
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			status.Pending = append(status.Pending, migration)
+		}
+	}
+
+	return status, nil
+}
+
+// Check refuses to go on if the schema was migrated by a newer binary or was
+// left dirty by a failed migration.
+func (m *Migrator) Check() error {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	if version > m.Latest() {
+		return fmt.Errorf("%w: schema is at version %d but this binary only knows up to %d; upgrade the binary or run 'api migrate goto %d' with the newer one", ErrSchemaAhead, version, m.Latest(), m.Latest())
+	}
+
+	if dirty {
+		return fmt.Errorf("%w at version %d; repair it by hand and run 'api migrate force <version>'", ErrDirty, version)
+	}
+
+	return nil
+}
+
+// Up applies every pending migration. Having nothing to do is not an error.
+func (m *Migrator) Up() error {
+	if err := m.Check(); err != nil {
+		return err
+	}
+
+	return ignoreNoChange(m.m.Up())
+}
+
+// Down rolls back the last n applied migrations.
+func (m *Migrator) Down(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("number of migrations to roll back must be positive, got %d", n)
+	}
+
+	return ignoreNoChange(m.m.Steps(-n))
+}
+
+// Goto migrates up or down to exactly version.
+func (m *Migrator) Goto(version uint) error {
+	return ignoreNoChange(m.m.Migrate(version))
+}
+
+// Force records version as the current, clean schema version without running
+// anything. -1 means no version.
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+// PlanUp lists the migrations Up would apply.
+func (m *Migrator) PlanUp() ([]Migration, error) {
+	return m.PlanGoto(m.Latest())
+}
+
+// PlanDown lists, in order, the migrations Down(n) would roll back.
+func (m *Migrator) PlanDown(n int) ([]Migration, error) {
+	version, _, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(plan) < n; i-- {
+		if m.migrations[i].Version <= version {
+			plan = append(plan, m.migrations[i])
+		}
+	}
+
+	return plan, nil
+}
+
+// PlanGoto lists, in order, the migrations Goto(target) would apply or roll
+// back.
+func (m *Migrator) PlanGoto(target uint) ([]Migration, error) {
+	version, _, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []Migration
+
+	if target >= version {
+		for _, migration := range m.migrations {
+			if migration.Version > version && migration.Version <= target {
+				plan = append(plan, migration)
+			}
+		}
+		return plan, nil
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		if m.migrations[i].Version <= version && m.migrations[i].Version > target {
+			plan = append(plan, m.migrations[i])
+		}
+	}
+
+	return plan, nil
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return err
+}
@@ -8,9 +8,12 @@ import (
 	"javlonrahimov/quotes-api/internal/validator"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/exp/slices"
 )
 
 func DecodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
@@ -107,3 +110,66 @@ func ReadTime(qs url.Values, key string, layout string, defaultValue time.Time,
 
 	return t
 }
+
+// AcceptLanguages returns the base language codes of the Accept-Language
+// header, most preferred first. "*" and languages with q=0 are left out.
+func AcceptLanguages(r *http.Request) []string {
+	type language struct {
+		code    string
+		quality float64
+	}
+
+	var languages []language
+
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		code, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if code == "" || code == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			f, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil {
+				continue
+			}
+			quality = f
+		}
+
+		if quality > 0 {
+			languages = append(languages, language{code, quality})
+		}
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+
+	var codes []string
+	for _, l := range languages {
+		if !slices.Contains(codes, l.code) {
+			codes = append(codes, l.code)
+		}
+	}
+
+	return codes
+}
+
+// IfMatch reports whether a write may go ahead: the request has no If-Match
+// header, or it lists the current entity tag or "*". Weak tags never match.
+func IfMatch(r *http.Request, current string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,83 @@
+package response
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Validators control the ETag header of JSONCached. The ETag is derived from
+// the response body; listings mark it as weak since their bodies are only
+// meant to be equivalent, not byte-identical, across deployments.
+type Validators struct {
+	Weak bool
+}
+
+// ETag returns the strong entity tag of the body JSON would write for data.
+func ETag(data any) (string, error) {
+	js, err := marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	return etag(js), nil
+}
+
+// JSONCached writes data like JSON, with an ETag, unless the request's
+// If-None-Match shows that the client already has it, in which case it
+// answers 304 Not Modified without a body.
+func JSONCached(w http.ResponseWriter, r *http.Request, status int, data any, v Validators) error {
+	js, err := marshal(data)
+	if err != nil {
+		return err
+	}
+
+	tag := etag(js)
+	if v.Weak {
+		tag = "W/" + tag
+	}
+
+	w.Header().Set("ETag", tag)
+
+	if notModified(r, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+func notModified(r *http.Request, tag string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	for _, t := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || (t != "" && strings.TrimPrefix(t, "W/") == strings.TrimPrefix(tag, "W/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func marshal(data any) ([]byte, error) {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(js, '\n'), nil
+}
+
+func etag(js []byte) string {
+	sum := sha256.Sum256(js)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
@@ -0,0 +1,303 @@
+// Package seed loads fixture data into a database.Store and fabricates
+// realistic quotes for development and load testing.
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"javlonrahimov/quotes-api/internal/database"
+	"javlonrahimov/quotes-api/internal/password"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+type Fixtures struct {
+	States   []State  `json:"states" yaml:"states"`
+	Users    []User   `json:"users" yaml:"users"`
+	Photos   []Photo  `json:"photos" yaml:"photos"`
+	Hashtags []string `json:"hashtags" yaml:"hashtags"`
+	Quotes   []Quote  `json:"quotes" yaml:"quotes"`
+}
+
+type State struct {
+	Value     string `json:"value" yaml:"value"`
+	Color     string `json:"color" yaml:"color"`
+	IsDefault bool   `json:"isDefault" yaml:"isDefault"`
+	IsPublic  bool   `json:"isPublic" yaml:"isPublic"`
+}
+
+type User struct {
+	Email       string   `json:"email" yaml:"email"`
+	Name        string   `json:"name" yaml:"name"`
+	Password    string   `json:"password" yaml:"password"`
+	Activated   bool     `json:"activated" yaml:"activated"`
+	Permissions []string `json:"permissions" yaml:"permissions"`
+}
+
+type Photo struct {
+	Url      string `json:"url" yaml:"url"`
+	Color    string `json:"color" yaml:"color"`
+	BlurHash string `json:"blurHash" yaml:"blurHash"`
+	Author   string `json:"author" yaml:"author"`
+}
+
+// Quote refers to its state, photo, hashtags and creator by natural key:
+// state value, photo URL, hashtag value and user email.
+type Quote struct {
+	Author    string   `json:"author" yaml:"author"`
+	Text      string   `json:"text" yaml:"text"`
+	State     string   `json:"state" yaml:"state"`
+	Photo     string   `json:"photo" yaml:"photo"`
+	Hashtags  []string `json:"hashtags" yaml:"hashtags"`
+	CreatedBy string   `json:"createdBy" yaml:"createdBy"`
+}
+
+// Report counts what Apply created and what was already there.
+type Report struct {
+	Created  map[string]int
+	Existing map[string]int
+}
+
+func (r Report) String() string {
+	var parts []string
+	for _, kind := range []string{"states", "users", "photos", "hashtags", "quotes"} {
+		parts = append(parts, fmt.Sprintf("%s: %d created, %d existing", kind, r.Created[kind], r.Existing[kind]))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Decode reads fixtures in the format implied by name's extension (.json,
+// .yaml or .yml).
+func Decode(r io.Reader, name string) (*Fixtures, error) {
+	var fixtures Fixtures
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&fixtures); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(r)
+		dec.KnownFields(true)
+		if err := dec.Decode(&fixtures); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported fixture format, use .json, .yaml or .yml", name)
+	}
+
+	return &fixtures, nil
+}
+
+// Apply loads fixtures into store. Records that already exist (matched by
+// state value, user email, photo URL, hashtag value, or quote author and
+// text) are left untouched, so applying the same fixtures twice is a no-op.
+func Apply(ctx context.Context, store database.Store, fixtures *Fixtures) (Report, error) {
+	report := Report{Created: make(map[string]int), Existing: make(map[string]int)}
+
+	count := func(kind string, created bool) {
+		if created {
+			report.Created[kind]++
+		} else {
+			report.Existing[kind]++
+		}
+	}
+
+	states, err := applyStates(ctx, store, fixtures.States, count)
+	if err != nil {
+		return report, err
+	}
+
+	users := make(map[string]uuid.UUID)
+	for _, u := range fixtures.Users {
+		user, created, err := ensureUser(ctx, store, u)
+		if err != nil {
+			return report, fmt.Errorf("user %s: %w", u.Email, err)
+		}
+		users[u.Email] = user.ID
+		count("users", created)
+	}
+
+	photos := make(map[string]uuid.UUID)
+	for _, p := range fixtures.Photos {
+		photo, created, err := ensurePhoto(ctx, store, p)
+		if err != nil {
+			return report, fmt.Errorf("photo %s: %w", p.Url, err)
+		}
+		photos[p.Url] = photo.ID
+		count("photos", created)
+	}
+
+	hashtags := make(map[string]uuid.UUID)
+	for _, value := range fixtures.Hashtags {
+		hashtag, created, err := ensureHashtag(ctx, store, value)
+		if err != nil {
+			return report, fmt.Errorf("hashtag %s: %w", value, err)
+		}
+		hashtags[hashtag.Value] = hashtag.ID
+		count("hashtags", created)
+	}
+
+	for i, q := range fixtures.Quotes {
+		created, err := ensureQuote(ctx, store, q, states, users, photos, hashtags)
+		if err != nil {
+			return report, fmt.Errorf("quote %d (%s): %w", i+1, q.Author, err)
+		}
+		count("quotes", created)
+	}
+
+	return report, nil
+}
+
+func applyStates(ctx context.Context, store database.Store, fixtures []State, count func(string, bool)) (map[string]uuid.UUID, error) {
+	existing, _, err := store.GetAllQuoteStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]uuid.UUID)
+	for _, state := range existing {
+		states[state.Value] = state.ID
+	}
+
+	for _, s := range fixtures {
+		if _, ok := states[s.Value]; ok {
+			count("states", false)
+			continue
+		}
+
+		state := &database.QuoteState{Value: s.Value, Color: s.Color, IsDefault: s.IsDefault, IsPublic: s.IsPublic}
+
+		err := store.InsertQuoteState(ctx, state)
+		if err != nil {
+			return nil, fmt.Errorf("state %s: %w", s.Value, err)
+		}
+
+		states[s.Value] = state.ID
+		count("states", true)
+	}
+
+	return states, nil
+}
+
+func ensureUser(ctx context.Context, store database.Store, u User) (*database.User, bool, error) {
+	user, err := store.GetUserByEmail(ctx, u.Email)
+	created := false
+
+	switch {
+	case errors.Is(err, database.ErrRecordNotFound):
+		hashedPassword, err := password.Hash(u.Password)
+		if err != nil {
+			return nil, false, err
+		}
+
+		user, err = store.InsertUser(ctx, u.Email, hashedPassword, u.Name)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if u.Activated {
+			if err := store.ActivateUser(ctx, user.ID); err != nil {
+				return nil, false, err
+			}
+		}
+		created = true
+	case err != nil:
+		return nil, false, err
+	}
+
+	if len(u.Permissions) > 0 {
+		if err := store.AddPermissionForUser(ctx, user.ID, u.Permissions...); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return user, created, nil
+}
+
+func ensurePhoto(ctx context.Context, store database.Store, p Photo) (*database.Photo, bool, error) {
+	photo, err := store.GetPhotoByUrl(ctx, p.Url)
+	switch {
+	case errors.Is(err, database.ErrRecordNotFound):
+		photo, err = store.InsertPhoto(ctx, p.Color, p.BlurHash, p.Author, p.Url)
+		return photo, err == nil, err
+	case err != nil:
+		return nil, false, err
+	}
+
+	return photo, false, nil
+}
+
+func ensureHashtag(ctx context.Context, store database.Store, value string) (*database.Hashtag, bool, error) {
+	value = strings.ToLower(value)
+
+	hashtag, err := store.GetHashtagByValue(ctx, value)
+	switch {
+	case errors.Is(err, database.ErrRecordNotFound):
+		hashtag, err = store.InsertHashtag(ctx, value)
+		return hashtag, err == nil, err
+	case err != nil:
+		return nil, false, err
+	}
+
+	return hashtag, false, nil
+}
+
+func ensureQuote(ctx context.Context, store database.Store, q Quote, states, users, photos, hashtags map[string]uuid.UUID) (bool, error) {
+	_, err := store.GetQuoteByText(ctx, q.Author, q.Text)
+	switch {
+	case err == nil:
+		return false, nil
+	case !errors.Is(err, database.ErrRecordNotFound):
+		return false, err
+	}
+
+	userID, ok := users[q.CreatedBy]
+	if !ok {
+		user, err := store.GetUserByEmail(ctx, q.CreatedBy)
+		if err != nil {
+			return false, fmt.Errorf("createdBy %q: %w", q.CreatedBy, err)
+		}
+		userID = user.ID
+	}
+
+	photoID, ok := photos[q.Photo]
+	if !ok {
+		photo, err := store.GetPhotoByUrl(ctx, q.Photo)
+		if err != nil {
+			return false, fmt.Errorf("photo %q: %w", q.Photo, err)
+		}
+		photoID = photo.ID
+	}
+
+	var stateID *uuid.UUID
+	if q.State != "" {
+		id, ok := states[q.State]
+		if !ok {
+			return false, fmt.Errorf("unknown state %q", q.State)
+		}
+		stateID = &id
+	}
+
+	var hashtagIDs []uuid.UUID
+	for _, value := range q.Hashtags {
+		hashtag, _, err := ensureHashtag(ctx, store, value)
+		if err != nil {
+			return false, err
+		}
+		hashtags[hashtag.Value] = hashtag.ID
+		hashtagIDs = append(hashtagIDs, hashtag.ID)
+	}
+
+	_, err = store.InsertQuote(ctx, q.Author, q.Text, userID, photoID, hashtagIDs, stateID)
+	return err == nil, err
+}
@@ -0,0 +1,161 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"javlonrahimov/quotes-api/internal/database"
+	f "javlonrahimov/quotes-api/internal/filters"
+
+	"github.com/google/uuid"
+)
+
+// GeneratorEmail is the account that owns generated quotes.
+const GeneratorEmail = "generator@example.org"
+
+var authors = []string{
+	"Alisher Navoi", "Abdulla Qodiriy", "Cholpon", "Erkin Vohidov", "Abdulla Oripov",
+	"Rumi", "Omar Khayyam", "Hafez", "Saadi", "Ibn Sina",
+	"Seneca", "Marcus Aurelius", "Epictetus", "Confucius", "Lao Tzu",
+	"Leo Tolstoy", "Fyodor Dostoevsky", "Anton Chekhov", "Alexander Pushkin",
+	"Maya Angelou", "Mark Twain", "Oscar Wilde", "Albert Einstein", "Marie Curie",
+	"Mahatma Gandhi", "Nelson Mandela", "Victor Hugo", "Khalil Gibran",
+}
+
+var (
+	subjects = []string{
+		"Patience", "Knowledge", "Kindness", "Silence", "Courage", "A true friend",
+		"Every journey", "The wise heart", "Hard work", "Hope", "Honesty", "Time",
+		"A book", "The mind", "Love", "Gratitude", "Freedom", "Every failure",
+	}
+	verbs = []string{
+		"is the key to", "opens the door to", "is the beginning of", "grows into",
+		"is the shortest road to", "is the mother of", "teaches us", "is the price of",
+		"always leads to", "is worth more than", "protects", "reveals",
+	}
+	objects = []string{
+		"wisdom", "joy", "a peaceful life", "every victory", "true wealth", "understanding",
+		"the truth", "a brighter tomorrow", "inner strength", "lasting happiness",
+		"a thousand words", "all good things", "the soul", "greatness",
+	}
+	endings = []string{
+		".", ", but only for those who wait.", ", if you let it.", " and nothing less.",
+		", even in the darkest hour.", ", as the old ones said.", ".", ".",
+	}
+)
+
+// Generate fabricates n quotes spread across the existing states, photos and
+// hashtags. Most quotes land in a public state, some in the default state and
+// the rest in any other state. Unlike Apply, every call inserts n new quotes.
+func Generate(ctx context.Context, store database.Store, n int, rng *rand.Rand) error {
+	states, _, err := store.GetAllQuoteStates(ctx)
+	if err != nil {
+		return err
+	}
+	if len(states) == 0 {
+		return errors.New("no quote states found, run migrations first")
+	}
+
+	photos, _, err := store.GetPhotos(ctx)
+	if err != nil {
+		return err
+	}
+	if len(photos) == 0 {
+		return errors.New("no photos found, load fixtures first")
+	}
+
+	hashtags, err := allHashtags(ctx, store)
+	if err != nil {
+		return err
+	}
+	if len(hashtags) == 0 {
+		return errors.New("no hashtags found, load fixtures first")
+	}
+
+	user, _, err := ensureUser(ctx, store, User{
+		Email:     GeneratorEmail,
+		Name:      "Quote Generator",
+		Password:  uuid.NewString(),
+		Activated: true,
+	})
+	if err != nil {
+		return fmt.Errorf("user %s: %w", GeneratorEmail, err)
+	}
+
+	var weighted []uuid.UUID
+	for _, state := range states {
+		weight := 1
+		switch {
+		case state.IsPublic:
+			weight = 7
+		case state.IsDefault:
+			weight = 2
+		}
+		for i := 0; i < weight; i++ {
+			weighted = append(weighted, state.ID)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		stateID := weighted[rng.Intn(len(weighted))]
+		photoID := photos[rng.Intn(len(photos))].ID
+
+		var hashtagIDs []uuid.UUID
+		for _, j := range rng.Perm(len(hashtags))[:1+rng.Intn(maxHashtags(len(hashtags)))] {
+			hashtagIDs = append(hashtagIDs, hashtags[j].ID)
+		}
+
+		_, err := store.InsertQuote(ctx, pick(rng, authors), sentence(rng), user.ID, photoID, hashtagIDs, &stateID)
+		if err != nil {
+			return fmt.Errorf("quote %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func allHashtags(ctx context.Context, store database.Store) ([]database.Hashtag, error) {
+	var hashtags []database.Hashtag
+
+	filters := f.Filters{Page: 1, PageSize: 100, Sort: "value", SortSafeList: []string{"value"}}
+	for {
+		page, metadata, err := store.GetHashtags(ctx, filters)
+		if err != nil {
+			return nil, err
+		}
+
+		hashtags = append(hashtags, page...)
+
+		if filters.Page >= metadata.LastPage {
+			return hashtags, nil
+		}
+		filters.Page++
+	}
+}
+
+func sentence(rng *rand.Rand) string {
+	var b strings.Builder
+
+	b.WriteString(pick(rng, subjects))
+	b.WriteString(" ")
+	b.WriteString(pick(rng, verbs))
+	b.WriteString(" ")
+	b.WriteString(pick(rng, objects))
+	b.WriteString(pick(rng, endings))
+
+	return b.String()
+}
+
+func pick(rng *rand.Rand, values []string) string {
+	return values[rng.Intn(len(values))]
+}
+
+func maxHashtags(available int) int {
+	if available < 3 {
+		return available
+	}
+	return 3
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,11 +12,19 @@ import (
 	"time"
 )
 
-func Run(addr string, h http.Handler, certFile, keyFile string) error {
+// Run serves h until SIGINT or SIGTERM, then shuts down gracefully. The
+// onShutdown functions are called as shutdown starts, for handlers that would
+// otherwise keep their connections open, such as event streams.
+func Run(addr string, h http.Handler, certFile, keyFile string, onShutdown ...func()) error {
 	tlsConfig := &tls.Config{
 		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
 	}
 
+	// Requests derive their context from baseCtx, so anything still running
+	// once graceful shutdown gives up (including database queries) is cancelled.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
 	srv := &http.Server{
 		Addr:         addr,
 		Handler:      h,
@@ -23,6 +32,13 @@ func Run(addr string, h http.Handler, certFile, keyFile string) error {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		TLSConfig:    tlsConfig,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
+	}
+
+	for _, fn := range onShutdown {
+		srv.RegisterOnShutdown(fn)
 	}
 
 	shutdownError := make(chan error)
@@ -35,7 +51,10 @@ func Run(addr string, h http.Handler, certFile, keyFile string) error {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		shutdownError <- srv.Shutdown(ctx)
+		err := srv.Shutdown(ctx)
+		cancelRequests()
+
+		shutdownError <- err
 	}()
 
 	err := srv.ListenAndServe()
@@ -0,0 +1,190 @@
+// Package stream fans messages out to Server-Sent Events clients. A Hub keeps
+// the most recent messages so that clients reconnecting with Last-Event-ID
+// get what they missed.
+package stream
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a single event. ID is assigned by the hub; Tags are not sent and
+// only serve filtering.
+type Message struct {
+	ID    string
+	Event string
+	Data  []byte
+	Tags  []string
+}
+
+// Subscriber receives the messages its filter accepts on C, which is closed
+// when the subscriber falls too far behind or the hub is closed.
+type Subscriber struct {
+	C <-chan Message
+
+	c      chan Message
+	filter func(Message) bool
+}
+
+// Hub numbers messages and delivers them to subscribers. IDs carry the hub's
+// start time, so IDs from an earlier process or another instance are told
+// apart and not resumed from.
+type Hub struct {
+	mu      sync.Mutex
+	epoch   string
+	seq     uint64
+	history []Message
+	size    int
+	subs    map[*Subscriber]struct{}
+	closed  bool
+}
+
+func NewHub(size int) *Hub {
+	return &Hub{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:  size,
+		subs:  make(map[*Subscriber]struct{}),
+	}
+}
+
+// Publish assigns the next ID to m and delivers it. Subscribers whose buffer
+// is full are dropped rather than waited for; their clients reconnect and
+// resume from the last message they got.
+func (h *Hub) Publish(m Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	h.seq++
+	m.ID = h.epoch + "-" + strconv.FormatUint(h.seq, 10)
+
+	h.history = append(h.history, m)
+	if len(h.history) > h.size {
+		h.history = h.history[len(h.history)-h.size:]
+	}
+
+	for s := range h.subs {
+		if !s.filter(m) {
+			continue
+		}
+
+		select {
+		case s.c <- m:
+		default:
+			delete(h.subs, s)
+			close(s.c)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for the messages filter accepts. With a
+// lastEventID, the accepted messages published after it are returned for
+// replay; resumed is false when the ID is unknown or too old to resume from,
+// and the client should then reload what it shows.
+func (h *Hub) Subscribe(lastEventID string, filter func(Message) bool) (s *Subscriber, replay []Message, resumed bool) {
+	c := make(chan Message, 64)
+	s = &Subscriber{C: c, c: c, filter: filter}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(c)
+		return s, nil, true
+	}
+
+	h.subs[s] = struct{}{}
+
+	if lastEventID == "" {
+		return s, nil, true
+	}
+
+	seq, ok := h.parseID(lastEventID)
+	if !ok || seq > h.seq {
+		return s, nil, false
+	}
+
+	// The message right after lastEventID must still be in the history,
+	// otherwise some were lost.
+	if seq < h.seq && (len(h.history) == 0 || h.seq-uint64(len(h.history)) > seq) {
+		return s, nil, false
+	}
+
+	for _, m := range h.history {
+		if mseq, _ := h.parseID(m.ID); mseq > seq && filter(m) {
+			replay = append(replay, m)
+		}
+	}
+
+	return s, replay, true
+}
+
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.c)
+	}
+}
+
+// Close ends every subscription, so that streams return and the server can
+// shut down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for s := range h.subs {
+		delete(h.subs, s)
+		close(s.c)
+	}
+}
+
+func (h *Hub) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != h.epoch {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return n, true
+}
+
+// Write writes m in the event stream format.
+func Write(w io.Writer, m Message) error {
+	var b strings.Builder
+
+	if m.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", m.ID)
+	}
+	if m.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", m.Event)
+	}
+	for _, line := range strings.Split(string(m.Data), "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Comment writes a comment line, which clients ignore; it keeps idle
+// connections from being closed by proxies.
+func Comment(w io.Writer, text string) error {
+	_, err := fmt.Fprintf(w, ": %s\n\n", text)
+	return err
+}
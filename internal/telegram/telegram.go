@@ -0,0 +1,108 @@
+// Package telegram sends messages to chats through the Telegram Bot API.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CaptionLimit is the most characters a photo caption may have.
+const CaptionLimit = 1024
+
+// RetryAfterError is returned when Telegram rate limits the bot; the request
+// may be sent again once After has passed.
+type RetryAfterError struct {
+	After time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("telegram: too many requests, retry after %s", e.After)
+}
+
+type Client struct {
+	botToken string
+	client   *http.Client
+}
+
+func NewClient(botToken string) *Client {
+	return &Client{
+		botToken: botToken,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// SendMessage posts an HTML formatted text to chatID and returns the ID of
+// the message.
+func (c *Client) SendMessage(ctx context.Context, chatID, text string) (int64, error) {
+	return c.call(ctx, "sendMessage", map[string]any{
+		"chat_id":    chatID,
+		"text":       text,
+		"parse_mode": "HTML",
+	})
+}
+
+// SendPhoto posts the photo at photoURL with an HTML formatted caption to
+// chatID and returns the ID of the message.
+func (c *Client) SendPhoto(ctx context.Context, chatID, photoURL, caption string) (int64, error) {
+	return c.call(ctx, "sendPhoto", map[string]any{
+		"chat_id":    chatID,
+		"photo":      photoURL,
+		"caption":    caption,
+		"parse_mode": "HTML",
+	})
+}
+
+func (c *Client) call(ctx context.Context, method string, params map[string]any) (int64, error) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("https://api.telegram.org/bot%s/%s", c.botToken, method), bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		// The URL holds the bot token; keep it out of the error.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	defer res.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+		Result      struct {
+			MessageID int64 `json:"message_id"`
+		} `json:"result"`
+		Parameters struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&result)
+	if err != nil {
+		return 0, fmt.Errorf("telegram: %s: status %d: %w", method, res.StatusCode, err)
+	}
+
+	switch {
+	case result.OK:
+		return result.Result.MessageID, nil
+	case res.StatusCode == http.StatusTooManyRequests:
+		return 0, &RetryAfterError{After: time.Duration(result.Parameters.RetryAfter) * time.Second}
+	default:
+		return 0, fmt.Errorf("telegram: %s: %s", method, result.Description)
+	}
+}
@@ -12,6 +12,7 @@ import (
 var (
 	RgxEmail    = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 	RgxHexColor = regexp.MustCompile("^#(?:[0-9a-fA-F]{3}){1,2}$")
+	RgxLanguage = regexp.MustCompile("^[a-z]{2,3}$")
 )
 
 func NotBlank(value string) bool {
@@ -0,0 +1,95 @@
+// Package webhook signs and sends webhook payloads.
+//
+// Every request carries the event type, the delivery ID (stable across
+// retries, for receivers to drop duplicates), a Unix timestamp and a
+// signature: the hex HMAC-SHA256, keyed with the webhook's secret, of the
+// timestamp, a dot and the raw body. Receivers should recompute it and
+// reject timestamps too far from their own clock.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Request headers.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign returns the signature header value of body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a random signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Backoff returns how long to wait before retrying after the given number of
+// failed attempts: 30s doubling each time, capped at six hours.
+func Backoff(attempts int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempts && d < 6*time.Hour; i++ {
+		d *= 2
+	}
+
+	if d > 6*time.Hour {
+		d = 6 * time.Hour
+	}
+
+	return d
+}
+
+// Send posts body to url, signed with secret. It returns the response status
+// code, or an error when no response came back.
+func Send(ctx context.Context, client *http.Client, url, secret, event, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "quotes-api-webhooks")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little of the body so that the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	return resp.StatusCode, nil
+}
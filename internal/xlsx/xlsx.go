@@ -0,0 +1,130 @@
+// Package xlsx writes single-sheet Excel workbooks row by row, so large
+// sheets can be streamed without holding them in memory. Every cell is
+// written as an inline string.
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	contentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+
+	rootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+	workbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+
+	workbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+	sheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+	sheetEnd = `</sheetData></worksheet>`
+
+	// maxCellRunes is Excel's limit on the length of a cell's text.
+	maxCellRunes = 32767
+)
+
+type Writer struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	rows  int
+}
+
+// NewWriter starts a workbook with one sheet called name on w. Rows are
+// added with WriteRow and the workbook is finished by Close.
+func NewWriter(w io.Writer, name string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+
+	var escaped strings.Builder
+	if err := xml.EscapeText(&escaped, []byte(name)); err != nil {
+		return nil, err
+	}
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", contentTypes},
+		{"_rels/.rels", rootRels},
+		{"xl/_rels/workbook.xml.rels", workbookRels},
+		{"xl/workbook.xml", strings.Replace(workbook, "%s", escaped.String(), 1)},
+	}
+
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := bufio.NewWriter(f)
+	if _, err := sheet.WriteString(sheetStart); err != nil {
+		return nil, err
+	}
+
+	return &Writer{zw: zw, sheet: sheet}, nil
+}
+
+func (w *Writer) WriteRow(cells ...string) error {
+	w.rows++
+	row := strconv.Itoa(w.rows)
+
+	w.sheet.WriteString(`<row r="` + row + `">`)
+	for i, cell := range cells {
+		if utf8.RuneCountInString(cell) > maxCellRunes {
+			cell = string([]rune(cell)[:maxCellRunes])
+		}
+
+		w.sheet.WriteString(`<c r="` + column(i) + row + `" t="inlineStr"><is><t xml:space="preserve">`)
+		if err := xml.EscapeText(w.sheet, []byte(cell)); err != nil {
+			return err
+		}
+		w.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := w.sheet.WriteString(`</row>`)
+	return err
+}
+
+// Flush pushes buffered rows through to the underlying writer.
+func (w *Writer) Flush() error {
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zw.Flush()
+}
+
+func (w *Writer) Close() error {
+	if _, err := w.sheet.WriteString(sheetEnd); err != nil {
+		return err
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// column converts a zero-based index to a column name: 0 is A, 26 is AA.
+func column(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}